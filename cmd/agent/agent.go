@@ -16,7 +16,7 @@ func Run(configPath string) error {
 		return fmt.Errorf("loading config: %w", err)
 	}
 
-	log := logger.Init(cfg.Node.LogLevel)
+	log := logger.Init(cfg.Node.LogLevel, cfg.Node.LogFormat, cfg.Node.LogOutput)
 
 	interval, err := cfg.Node.ParseInterval()
 	if err != nil {
@@ -38,6 +38,7 @@ func Run(configPath string) error {
 		"",
 		cfg.Node.Port,
 		interval,
+		cfg.Node.MulticastTTL,
 		cfg.Node.SharedSecret,
 		log,
 	)
@@ -0,0 +1,132 @@
+package connect
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/render"
+	"lanmon/internal/rpc"
+	"lanmon/internal/sshpush"
+	"lanmon/internal/store"
+	"lanmon/pkg/config"
+)
+
+// pushResult is the outcome of pushing the key to one host.
+type pushResult struct {
+	host store.HostRecord
+	err  error
+}
+
+// isBatchSelection reports whether the host prompt input selects a list or
+// range of hosts ("1,3,5-8") rather than a single one.
+func isBatchSelection(input string) bool {
+	return strings.ContainsAny(input, ",-")
+}
+
+// parseIndexList parses a comma-separated list of 1-based host indexes and
+// inclusive ranges, e.g. "1,3,5-8". The result is sorted and deduplicated.
+func parseIndexList(input string, n int) ([]int, error) {
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(strings.TrimSpace(input), ",") {
+		part = strings.TrimSpace(part)
+		lo, hi, isRange := strings.Cut(part, "-")
+
+		start, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return nil, fmt.Errorf("invalid host index: %s", part)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil || end < start {
+				return nil, fmt.Errorf("invalid host range: %s", part)
+			}
+		}
+		if start < 1 || end > n {
+			return nil, fmt.Errorf("host index out of range 1-%d: %s", n, part)
+		}
+
+		for i := start; i <= end; i++ {
+			seen[i] = true
+		}
+	}
+
+	indexes := make([]int, 0, len(seen))
+	for i := range seen {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes, nil
+}
+
+// pushBatch pushes the key to every selected host with one set of
+// credentials, then prints a per-host summary. A failing host doesn't stop
+// the others; the returned error only reports how many failed.
+func pushBatch(cfg *config.Config, client *rpc.Client, hosts []store.HostRecord, indexes []int, reader *bufio.Reader, flags connectFlags, log zerolog.Logger) error {
+	fmt.Printf("\nSelected %d hosts for key push.\n", len(indexes))
+
+	selected := make([]store.HostRecord, len(indexes))
+	for i, idx := range indexes {
+		selected[i] = hosts[idx-1]
+	}
+
+	username, port, err := promptLogin(reader, defaultUser(selected...), cfg.Connect.SSHPort)
+	if err != nil {
+		return err
+	}
+	pubKeyPath, err := ensureKey(cfg, reader)
+	if err != nil {
+		return err
+	}
+	fingerprint, _ := sshpush.Fingerprint(pubKeyPath)
+
+	passwordBytes, err := readPassword()
+	if err != nil {
+		return err
+	}
+	password := string(passwordBytes)
+
+	fmt.Printf("\nPushing SSH key to %d hosts as %s ...\n", len(indexes), username)
+
+	results := runPool(len(indexes), cfg.Connect.MaxConcurrency, func(i int) pushResult {
+		host := selected[i]
+		err := sshpush.PushKey(host.Beacon.IPAddress, port, username, password, pubKeyPath, pushOptions(cfg, host, flags, log))
+		if err == nil {
+			if markErr := client.MarkKeyPushed(host.Beacon.MACAddress, username, fingerprint); markErr != nil {
+				fmt.Fprintf(os.Stderr, "⚠  %s: key pushed but not recorded: %v\n", host.Beacon.Hostname, markErr)
+			}
+		}
+		return pushResult{host: host, err: err}
+	})
+
+	// Zero password from memory
+	for i := range passwordBytes {
+		passwordBytes[i] = 0
+	}
+
+	return printPushSummary(results)
+}
+
+func printPushSummary(results []pushResult) error {
+	fmt.Println("\n  Push summary")
+	failed := 0
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+			fmt.Printf("  ✗ %-20s %-15s %v\n", render.Truncate(r.host.Beacon.Hostname, 20), r.host.Beacon.IPAddress, r.err)
+			continue
+		}
+		fmt.Printf("  ✓ %-20s %-15s key pushed\n", render.Truncate(r.host.Beacon.Hostname, 20), r.host.Beacon.IPAddress)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("key push failed for %d of %d hosts", failed, len(results))
+	}
+	fmt.Printf("\n✓ SSH key pushed to all %d hosts.\n", len(results))
+	return nil
+}
@@ -0,0 +1,42 @@
+package connect
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseIndexList(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []int
+		wantErr bool
+	}{
+		{"1,3,5-8\n", []int{1, 3, 5, 6, 7, 8}, false},
+		{" 2 - 3 , 1 ", []int{1, 2, 3}, false},
+		{"4,4,3-4", []int{3, 4}, false},
+		{"0,1", nil, true},
+		{"1,11", nil, true},
+		{"5-3", nil, true},
+		{"1,,2", nil, true},
+		{"1-", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseIndexList(tt.input, 10)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseIndexList(%q) error: got %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseIndexList(%q): got %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestIsBatchSelection(t *testing.T) {
+	for input, want := range map[string]bool{"3": false, "d3": false, "1,2": true, "2-4": true} {
+		if got := isBatchSelection(input); got != want {
+			t.Errorf("isBatchSelection(%q): got %v, want %v", input, got, want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package connect
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,9 +11,12 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/rs/zerolog"
 	"golang.org/x/term"
 
+	"lanmon/internal/render"
 	"lanmon/internal/rpc"
 	"lanmon/internal/sshpush"
 	"lanmon/internal/store"
@@ -20,17 +24,84 @@ import (
 	"lanmon/pkg/logger"
 )
 
-// Run starts the interactive SSH key distribution and connection CLI.
-func Run(configPath string) error {
+// Run starts the SSH key distribution and connection CLI. It is
+// interactive unless --host is given (see parseFlags).
+func Run(configPath string, args []string) error {
+	err := run(configPath, args)
+	var changed *sshpush.HostKeyChangedError
+	if errors.As(err, &changed) {
+		printKeyChanged(os.Stderr, changed)
+	}
+	var mismatch *sshpush.IdentityMismatchError
+	if errors.As(err, &mismatch) {
+		printIdentityMismatch(os.Stderr, mismatch)
+	}
+	return err
+}
+
+// printKeyChanged prints a host key change warning, in red on a terminal,
+// so it doesn't pass for an ordinary connection failure.
+func printKeyChanged(f *os.File, e *sshpush.HostKeyChangedError) {
+	red, reset := "\033[1;31m", "\033[0m"
+	if !term.IsTerminal(int(f.Fd())) {
+		red, reset = "", ""
+	}
+	fmt.Fprintf(f, "%s@@@ WARNING: HOST KEY FOR %s HAS CHANGED @@@%s\n", red, e.Host, reset)
+	fmt.Fprintln(f, "Someone could be intercepting the connection (man-in-the-middle),")
+	fmt.Fprintln(f, "or the host was reinstalled. Nothing was sent to it.")
+	for _, k := range e.Known {
+		fmt.Fprintf(f, "  Known key:   %s\n", k)
+	}
+	fmt.Fprintf(f, "  Offered key: %s\n", e.Offered)
+	fmt.Fprintln(f, "If the change is expected, remove the old line from known_hosts and retry.")
+}
+
+// printIdentityMismatch prints a warning, in red on a terminal, that the
+// host at an address is not the discovered one.
+func printIdentityMismatch(f *os.File, e *sshpush.IdentityMismatchError) {
+	red, reset := "\033[1;31m", "\033[0m"
+	if !term.IsTerminal(int(f.Fd())) {
+		red, reset = "", ""
+	}
+	fmt.Fprintf(f, "%s@@@ WARNING: %s IS NOT THE DISCOVERED HOST @@@%s\n", red, e.Host, reset)
+	fmt.Fprintf(f, "  Discovered: %s (%s)\n", e.Want.Hostname, e.Want.MAC)
+	if len(e.MACs) > 0 {
+		fmt.Fprintf(f, "  Found:      %s (%s)\n", e.Hostname, strings.Join(e.MACs, ", "))
+	} else {
+		fmt.Fprintf(f, "  Found:      %s\n", e.Hostname)
+	}
+	fmt.Fprintln(f, "Another machine may have taken over the address. The key was not pushed.")
+	fmt.Fprintln(f, "Pass --no-verify-identity if the host is known to report a different identity.")
+}
+
+func run(configPath string, args []string) error {
+	flags, err := parseFlags(args)
+	if err != nil {
+		return err
+	}
+
 	cfg, err := config.Load(configPath)
 	if err != nil {
 		return fmt.Errorf("loading config: %w", err)
 	}
 
-	log := logger.Init(cfg.Node.LogLevel)
+	log := logger.Init(cfg.Node.LogLevel, cfg.Node.LogFormat, cfg.Node.LogOutput)
+
+	if err := sshpush.ValidateHostKeyPolicy(cfg.Connect.HostKeyPolicy); err != nil {
+		return fmt.Errorf("connect.host_key_policy: %w", err)
+	}
+	if timeout, err := cfg.Connect.ParseSSHTimeout(); err != nil || timeout < 0 {
+		return fmt.Errorf("connect.ssh_timeout: invalid duration %q", cfg.Connect.SSHTimeout)
+	}
+	if err := sshpush.ValidateAuthorizedKeysFile(cfg.Connect.AuthorizedKeysFile); err != nil {
+		return fmt.Errorf("connect.authorized_keys_file: %w", err)
+	}
+	if flags.sudo {
+		cfg.Connect.UseSudo = true
+	}
 
 	// Connect to RPC server
-	client, err := rpc.NewClient(cfg.Connect.RPCSocket)
+	client, err := rpc.NewClient(cfg.Connect.RPCAddress(), cfg.Connect.RPCToken)
 	if err != nil {
 		return fmt.Errorf("connecting to server: %w\nIs 'lanmon node' running?", err)
 	}
@@ -41,41 +112,93 @@ func Run(configPath string) error {
 	if err != nil {
 		return fmt.Errorf("fetching active hosts: %w", err)
 	}
+	filtered := flags.filterOS != "" || flags.filterHost != "" || flags.filterTag != ""
+	if hosts, err = filterHosts(hosts, flags.filterOS, flags.filterHost); err != nil {
+		return err
+	}
+	hosts = filterLabel(hosts, flags.filterTag)
+	render.SortHosts(hosts, flags.sort)
+	var groups []render.Group
+	if flags.groupBy == "os" {
+		groups = render.GroupByOS(hosts)
+		hosts = render.Flatten(groups)
+	}
+
+	if flags.noteSet {
+		return runNote(client, hosts, flags)
+	}
+	if flags.tag != "" || flags.untag != "" {
+		return runLabel(client, hosts, flags)
+	}
+	if flags.exec != "" {
+		return runExec(cfg, hosts, flags, log)
+	}
+	if flags.put != "" {
+		return runPut(cfg, hosts, flags, log)
+	}
+	if flags.revoke {
+		return runRevoke(cfg, client, hosts, flags, log)
+	}
+	if flags.host != "" {
+		return runNonInteractive(cfg, client, hosts, flags, log)
+	}
 
 	if len(hosts) == 0 {
-		fmt.Println("No active hosts discovered. Make sure agents are running.")
+		if filtered {
+			fmt.Println("No active hosts match the filters.")
+		} else {
+			fmt.Println("No active hosts discovered. Make sure agents are running.")
+		}
 		return nil
 	}
 
-	// Display host table
-	fmt.Printf("\n  Active Hosts (%d found)\n\n", len(hosts))
-	displayHostTable(hosts)
-
 	reader := bufio.NewReader(os.Stdin)
 
-	// Prompt for host selection
-	fmt.Print("\nEnter host index: ")
-	indexStr, _ := reader.ReadString('\n')
-	indexStr = strings.TrimSpace(indexStr)
-	index, err := strconv.Atoi(indexStr)
-	if err != nil || index < 1 || index > len(hosts) {
-		return fmt.Errorf("invalid host index: %s", indexStr)
-	}
+	var selectedHost store.HostRecord
+	if flags.connect && len(hosts) == 1 {
+		selectedHost = hosts[0]
+	} else {
+		var sel selection
+		if useSelector(flags) {
+			picked, err := runSelector(reader, hosts)
+			if err != nil {
+				return err
+			}
+			if picked == nil {
+				fmt.Println("Aborted.")
+				return nil
+			}
+			sel = *picked
+		} else if sel, err = promptSelection(reader, hosts, groups, flags.wide); err != nil {
+			return err
+		}
 
-	selectedHost := hosts[index-1]
+		if sel.batch {
+			return pushBatch(cfg, client, hosts, sel.indexes, reader, flags, log)
+		}
+		selectedHost = hosts[sel.indexes[0]-1]
+		if sel.del {
+			return removeHost(client, selectedHost, reader)
+		}
+	}
 	fmt.Printf("\nSelected: %s (%s)\n", selectedHost.Beacon.Hostname, selectedHost.Beacon.IPAddress)
-
-	// --- Determine the username to use ---
-	// If key was already pushed, we know which user we pushed to previously.
-	// Still ask, but default to "root"
-	fmt.Print("Username [root]: ")
-	username, _ := reader.ReadString('\n')
-	username = strings.TrimSpace(username)
-	if username == "" {
-		username = "root"
+	if selectedHost.Notes != "" {
+		fmt.Println("Notes:")
+		for _, line := range strings.Split(selectedHost.Notes, "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+	if selectedHost.PushedKeyFingerprint != "" {
+		fmt.Printf("Pushed key: %s\n", selectedHost.PushedKeyFingerprint)
+	}
+	if warning := pushedKeyWarning(selectedHost, cfg.Connect.ServerPubKey); warning != "" {
+		fmt.Println(warning)
 	}
 
-	pubKeyPath := cfg.Connect.ServerPubKey
+	username, port, err := promptLogin(reader, defaultUser(selectedHost), cfg.Connect.SSHPort)
+	if err != nil {
+		return err
+	}
 
 	// --- Smart connect logic ---
 	//
@@ -87,23 +210,24 @@ func Run(configPath string) error {
 	//     → Auto-generate, then run key-push flow.
 	//
 
-	if _, err := os.Stat(pubKeyPath); os.IsNotExist(err) {
-		if err := generateSSHKey(pubKeyPath, reader); err != nil {
-			return err
-		}
+	pubKeyPath, err := ensureKey(cfg, reader)
+	if err != nil {
+		return err
 	}
+	// Push failures report an unreadable key; an empty fingerprint is fine
+	fingerprint, _ := sshpush.Fingerprint(pubKeyPath)
 
 	// Try a quick passwordless probe — if it works, just connect
-	if canSSHWithoutPassword(username, selectedHost.Beacon.IPAddress) {
+	if canSSHWithoutPassword(username, selectedHost.Beacon.IPAddress, port, sshTimeout(cfg, defaultProbeTimeout)) {
 		fmt.Printf("\n✓ Passwordless SSH already configured — connecting to %s@%s ...\n\n",
 			username, selectedHost.Beacon.IPAddress)
 		// Mark in DB in case it wasn't marked yet
-		if !selectedHost.SSHKeyPushed {
-			if err := client.MarkKeyPushed(selectedHost.Beacon.MACAddress); err != nil {
+		if !selectedHost.SSHKeyPushed || selectedHost.SSHKeyUser != username || selectedHost.PushedKeyFingerprint != fingerprint {
+			if err := client.MarkKeyPushed(selectedHost.Beacon.MACAddress, username, fingerprint); err != nil {
 				log.Warn().Err(err).Msg("Failed to update key push status in database")
 			}
 		}
-		return execSSH(username, selectedHost.Beacon.IPAddress)
+		return execSSH(username, selectedHost.Beacon.IPAddress, port)
 	}
 
 	// Passwordless didn't work — we need to push the key first
@@ -113,23 +237,21 @@ func Run(configPath string) error {
 	}
 
 	fmt.Printf("\nTo set up passwordless SSH to %s, enter the SSH password:\n", selectedHost.Beacon.Hostname)
-	fmt.Print("SSH password: ")
-	passwordBytes, err := term.ReadPassword(int(os.Stdin.Fd()))
+	passwordBytes, err := readPassword()
 	if err != nil {
-		return fmt.Errorf("reading password: %w", err)
+		return err
 	}
-	fmt.Println()
 	password := string(passwordBytes)
 
 	fmt.Printf("\nPushing SSH key to %s@%s...\n", username, selectedHost.Beacon.IPAddress)
 
 	err = sshpush.PushKey(
 		selectedHost.Beacon.IPAddress,
-		22,
+		port,
 		username,
 		password,
 		pubKeyPath,
-		cfg.Connect.KnownHosts,
+		pushOptions(cfg, selectedHost, flags, log),
 	)
 
 	// Zero password from memory
@@ -142,18 +264,180 @@ func Run(configPath string) error {
 	}
 
 	// Mark key as pushed in DB
-	if err := client.MarkKeyPushed(selectedHost.Beacon.MACAddress); err != nil {
+	if err := client.MarkKeyPushed(selectedHost.Beacon.MACAddress, username, fingerprint); err != nil {
 		log.Warn().Err(err).Msg("Failed to update key push status in database")
 	}
 
 	fmt.Printf("\n✓ SSH key pushed to %s@%s — connecting now ...\n\n",
 		username, selectedHost.Beacon.IPAddress)
 
-	return execSSH(username, selectedHost.Beacon.IPAddress)
+	return execSSH(username, selectedHost.Beacon.IPAddress, port)
+}
+
+// defaultUser is the username offered at the prompt: the user the key was
+// last pushed for, if all hosts agree on one, and "root" otherwise.
+func defaultUser(hosts ...store.HostRecord) string {
+	user := ""
+	for _, h := range hosts {
+		if h.SSHKeyUser == "" || (user != "" && h.SSHKeyUser != user) {
+			return "root"
+		}
+		user = h.SSHKeyUser
+	}
+	if user == "" {
+		return "root"
+	}
+	return user
+}
+
+// pushedKeyWarning returns a warning if host was last pushed a different
+// key than the one at pubKeyPath, as after a key rotation, or "" if the
+// keys match or either fingerprint is unknown.
+func pushedKeyWarning(host store.HostRecord, pubKeyPath string) string {
+	if host.PushedKeyFingerprint == "" {
+		return ""
+	}
+	current, err := sshpush.Fingerprint(pubKeyPath)
+	if err != nil || current == host.PushedKeyFingerprint {
+		return ""
+	}
+	return fmt.Sprintf("⚠  %s was given key %s, but %s is %s; push again to replace it.",
+		host.Beacon.Hostname, host.PushedKeyFingerprint, pubKeyPath, current)
+}
+
+// promptLogin asks for the SSH username and port.
+func promptLogin(reader *bufio.Reader, defaultUsername string, defaultPort int) (string, int, error) {
+	fmt.Printf("Username [%s]: ", defaultUsername)
+	username, _ := reader.ReadString('\n')
+	username = strings.TrimSpace(username)
+	if username == "" {
+		username = defaultUsername
+	}
+
+	fmt.Printf("SSH port [%d]: ", defaultPort)
+	portStr, _ := reader.ReadString('\n')
+	port, err := parsePort(portStr, defaultPort)
+	if err != nil {
+		return "", 0, err
+	}
+	return username, port, nil
+}
+
+// sshOptions returns the sshpush settings from the connect config.
+func sshOptions(cfg *config.Config, log zerolog.Logger) sshpush.Options {
+	return sshpush.Options{
+		KnownHostsPath: cfg.Connect.KnownHosts,
+		HostKeyPolicy:  sshpush.HostKeyPolicy(cfg.Connect.HostKeyPolicy),
+		DialRetries:    cfg.Connect.Retries(),
+		Log:            log,
+		Timeout:        sshTimeout(cfg, sshpush.DefaultTimeout),
+		Sudo:           cfg.Connect.UseSudo,
+
+		AuthorizedKeysFile: cfg.Connect.AuthorizedKeysFile,
+	}
+}
+
+// pushOptions is sshOptions for pushing the key to host, which is first
+// checked to be the discovered host unless --no-verify-identity is given.
+func pushOptions(cfg *config.Config, host store.HostRecord, flags connectFlags, log zerolog.Logger) sshpush.Options {
+	opts := sshOptions(cfg, log)
+	if !flags.noVerifyIdentity {
+		opts.Identity = &sshpush.Identity{Hostname: host.Beacon.Hostname, MAC: host.Beacon.MACAddress}
+	}
+	return opts
+}
+
+// defaultProbeTimeout bounds the passwordless SSH probe when
+// connect.ssh_timeout is not set. It is shorter than sshpush's default
+// since a failed probe just means falling back to a key push.
+const defaultProbeTimeout = 5 * time.Second
+
+// sshTimeout returns connect.ssh_timeout, or def if it is not set. run
+// has already rejected invalid values.
+func sshTimeout(cfg *config.Config, def time.Duration) time.Duration {
+	if timeout, err := cfg.Connect.ParseSSHTimeout(); err == nil && timeout > 0 {
+		return timeout
+	}
+	return def
+}
+
+// ensureKey returns the public key to distribute, offering to generate the
+// key pair if it doesn't exist yet.
+func ensureKey(cfg *config.Config, reader *bufio.Reader) (string, error) {
+	pubKeyPath := cfg.Connect.ServerPubKey
+	if err := sshpush.ValidateKeyType(cfg.Connect.KeyType); err != nil {
+		return "", fmt.Errorf("connect.key_type: %w", err)
+	}
+
+	if _, err := os.Stat(pubKeyPath); os.IsNotExist(err) {
+		keyType := sshpush.ResolveKeyType(pubKeyPath, cfg.Connect.KeyType)
+		if err := generateSSHKey(pubKeyPath, keyType, reader); err != nil {
+			return "", err
+		}
+	}
+	return pubKeyPath, nil
+}
+
+// readPassword prompts for the SSH password without echoing it. Callers
+// zero the returned bytes once done.
+func readPassword() ([]byte, error) {
+	fmt.Print("SSH password: ")
+	passwordBytes, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return nil, fmt.Errorf("reading password: %w", err)
+	}
+	return passwordBytes, nil
+}
+
+// parseSelection parses the host prompt: a 1-based index, optionally
+// prefixed with "d" to delete that host instead of connecting to it.
+func parseSelection(input string, n int) (index int, del bool, err error) {
+	input = strings.TrimSpace(input)
+	indexStr := input
+	if rest, ok := strings.CutPrefix(strings.ToLower(input), "d"); ok {
+		indexStr, del = strings.TrimSpace(rest), true
+	}
+
+	index, err = strconv.Atoi(indexStr)
+	if err != nil || index < 1 || index > n {
+		return 0, false, fmt.Errorf("invalid host index: %s", input)
+	}
+	return index, del, nil
+}
+
+// parsePort parses the SSH port prompt, returning def for empty input.
+func parsePort(input string, def int) (int, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return def, nil
+	}
+	port, err := strconv.Atoi(input)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid SSH port: %s", input)
+	}
+	return port, nil
+}
+
+// removeHost confirms and deletes a host record on the node.
+func removeHost(client *rpc.Client, host store.HostRecord, reader *bufio.Reader) error {
+	fmt.Printf("Remove %s (%s, %s) from the host list? [y/N]: ",
+		host.Beacon.Hostname, host.Beacon.IPAddress, host.Beacon.MACAddress)
+	ans, _ := reader.ReadString('\n')
+	if strings.TrimSpace(strings.ToLower(ans)) != "y" {
+		fmt.Println("Aborted.")
+		return nil
+	}
+
+	if err := client.RemoveHost(host.Beacon.MACAddress); err != nil {
+		return fmt.Errorf("removing host: %w", err)
+	}
+	fmt.Printf("✓ Removed %s. It will reappear if it beacons again.\n", host.Beacon.Hostname)
+	return nil
 }
 
 // generateSSHKey checks if a key exists and, if not, generates one.
-func generateSSHKey(pubKeyPath string, reader *bufio.Reader) error {
+func generateSSHKey(pubKeyPath, keyType string, reader *bufio.Reader) error {
 	fmt.Printf("⚠  SSH public key not found at %s\n", pubKeyPath)
 	fmt.Print("Would you like to generate a new SSH key pair? [Y/n]: ")
 	ans, _ := reader.ReadString('\n')
@@ -162,15 +446,19 @@ func generateSSHKey(pubKeyPath string, reader *bufio.Reader) error {
 		return fmt.Errorf("SSH key required to proceed")
 	}
 
-	privKeyPath := strings.TrimSuffix(pubKeyPath, ".pub")
-	fmt.Printf("Generating key pair: %s ...\n", privKeyPath)
+	privKeyPath := sshpush.PrivateKeyPath(pubKeyPath)
+	args, err := sshpush.KeygenArgs(keyType, privKeyPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Generating %s key pair: %s ...\n", keyType, privKeyPath)
 
 	sshDir := filepath.Dir(pubKeyPath)
 	if err := os.MkdirAll(sshDir, 0700); err != nil {
 		return fmt.Errorf("creating SSH directory %s: %w", sshDir, err)
 	}
 
-	cmd := exec.Command("ssh-keygen", "-t", "rsa", "-b", "4096", "-f", privKeyPath, "-N", "")
+	cmd := exec.Command("ssh-keygen", args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
@@ -181,11 +469,12 @@ func generateSSHKey(pubKeyPath string, reader *bufio.Reader) error {
 }
 
 // canSSHWithoutPassword tests if passwordless SSH works by attempting a quick connection.
-func canSSHWithoutPassword(user, host string) bool {
+func canSSHWithoutPassword(user, host string, port int, timeout time.Duration) bool {
 	cmd := exec.Command("ssh",
+		"-p", strconv.Itoa(port),
 		"-o", "BatchMode=yes",
 		"-o", "StrictHostKeyChecking=no",
-		"-o", "ConnectTimeout=5",
+		"-o", "ConnectTimeout="+connectTimeoutArg(timeout),
 		"-o", "LogLevel=ERROR",
 		fmt.Sprintf("%s@%s", user, host),
 		"exit",
@@ -193,58 +482,28 @@ func canSSHWithoutPassword(user, host string) bool {
 	return cmd.Run() == nil
 }
 
+// connectTimeoutArg renders timeout for ssh's ConnectTimeout option, which
+// takes whole seconds: rounded up, and at least 1.
+func connectTimeoutArg(timeout time.Duration) string {
+	secs := int((timeout + time.Second - 1) / time.Second)
+	return strconv.Itoa(max(secs, 1))
+}
+
 // execSSH replaces the current process with an interactive SSH session.
-func execSSH(user, host string) error {
+func execSSH(user, host string, port int) error {
+	target := fmt.Sprintf("%s@%s", user, host)
+	portStr := strconv.Itoa(port)
+
 	sshBin, err := exec.LookPath("ssh")
 	if err != nil {
 		// Fall back to non-exec mode
-		cmd := exec.Command("ssh", fmt.Sprintf("%s@%s", user, host))
+		cmd := exec.Command("ssh", "-p", portStr, target)
 		cmd.Stdin = os.Stdin
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 		return cmd.Run()
 	}
 	// Use syscall.Exec to replace the process so the terminal feels native
-	args := []string{"ssh", fmt.Sprintf("%s@%s", user, host)}
+	args := []string{"ssh", "-p", portStr, target}
 	return syscall.Exec(sshBin, args, os.Environ())
 }
-
-func displayHostTable(hosts []store.HostRecord) {
-	fmt.Printf("  %-4s %-20s %-16s %-18s %-25s %-10s %-5s\n",
-		"#", "Hostname", "IP Address", "MAC Address", "OS", "Last Seen", "Key")
-	fmt.Printf("  %s %s %s %s %s %s %s\n",
-		strings.Repeat("─", 4),
-		strings.Repeat("─", 20),
-		strings.Repeat("─", 16),
-		strings.Repeat("─", 18),
-		strings.Repeat("─", 25),
-		strings.Repeat("─", 10),
-		strings.Repeat("─", 5))
-
-	for i, host := range hosts {
-		keyStatus := "✗"
-		if host.SSHKeyPushed {
-			keyStatus = "✓"
-		}
-
-		hostname := truncate(host.Beacon.Hostname, 20)
-		osName := truncate(host.Beacon.OS.Name, 25)
-
-		fmt.Printf("  %-4d %-20s %-16s %-18s %-25s %-10s %-5s\n",
-			i+1,
-			hostname,
-			host.Beacon.IPAddress,
-			host.Beacon.MACAddress,
-			osName,
-			host.LastSeen.Format("15:04:05"),
-			keyStatus,
-		)
-	}
-}
-
-func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen-1] + "…"
-}
@@ -0,0 +1,447 @@
+package connect
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/ssh"
+
+	"lanmon/internal/beacon"
+	"lanmon/internal/render"
+	"lanmon/internal/sshpush"
+	"lanmon/internal/store"
+	"lanmon/pkg/config"
+)
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		input   string
+		index   int
+		del     bool
+		wantErr bool
+	}{
+		{"3\n", 3, false, false},
+		{"d3\n", 3, true, false},
+		{" D 2 ", 2, true, false},
+		{"0", 0, false, true},
+		{"6", 0, false, true},
+		{"d", 0, false, true},
+		{"x1", 0, false, true},
+	}
+
+	for _, tt := range tests {
+		index, del, err := parseSelection(tt.input, 5)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSelection(%q) error: got %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if index != tt.index || del != tt.del {
+			t.Errorf("parseSelection(%q): got (%d, %v), want (%d, %v)", tt.input, index, del, tt.index, tt.del)
+		}
+	}
+}
+
+func TestParsePort(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{"\n", 2222, false},
+		{"22\n", 22, false},
+		{"0", 0, true},
+		{"70000", 0, true},
+		{"ssh", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parsePort(tt.input, 2222)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parsePort(%q) error: got %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parsePort(%q): got %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	f, err := parseFlags([]string{"--host", "aa:bb:cc:dd:ee:01", "--push", "--password-env", "PASS", "--no-connect"})
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if f.host != "aa:bb:cc:dd:ee:01" || !f.push || f.passwordEnv != "PASS" || !f.noConnect || f.user != "root" {
+		t.Errorf("unexpected flags: %+v", f)
+	}
+
+	if f, err := parseFlags(nil); err != nil || f.host != "" {
+		t.Errorf("expected interactive mode without flags, got %+v, %v", f, err)
+	}
+	if _, err := parseFlags([]string{"--push", "--password-env", "PASS"}); err == nil {
+		t.Error("expected error for flags without --host")
+	}
+	if _, err := parseFlags([]string{"--host", "10.0.0.5", "--push"}); err == nil {
+		t.Error("expected error for --push without --password-env")
+	}
+
+	f, err = parseFlags([]string{"--filter-os", "ubuntu", "--filter-host", "web-*", "--connect"})
+	if err != nil {
+		t.Fatalf("expected filters to work interactively: %v", err)
+	}
+	if f.filterOS != "ubuntu" || f.filterHost != "web-*" || !f.connect {
+		t.Errorf("unexpected flags: %+v", f)
+	}
+}
+
+func TestParseFlags_Exec(t *testing.T) {
+	f, err := parseFlags([]string{"--exec", "uptime", "--all"})
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if f.exec != "uptime" || !f.all || f.userSet {
+		t.Errorf("unexpected flags: %+v", f)
+	}
+
+	if f, err := parseFlags([]string{"--exec", "uptime", "--host", "10.0.0.5", "--user", "alice"}); err != nil || !f.userSet {
+		t.Errorf("expected --exec with --host to parse, got %+v, %v", f, err)
+	}
+
+	for _, args := range [][]string{
+		{"--exec", "uptime"},
+		{"--all"},
+		{"--exec", "uptime", "--all", "--host", "10.0.0.5"},
+	} {
+		if _, err := parseFlags(args); err == nil {
+			t.Errorf("parseFlags(%q): expected error", args)
+		}
+	}
+}
+
+func TestParseFlags_Revoke(t *testing.T) {
+	f, err := parseFlags([]string{"--revoke", "--host", "10.0.0.5", "--password-env", "PASS"})
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if !f.revoke || f.host != "10.0.0.5" || f.passwordEnv != "PASS" {
+		t.Errorf("unexpected flags: %+v", f)
+	}
+
+	for _, args := range [][]string{
+		{"--revoke"},
+		{"--revoke", "--host", "10.0.0.5", "--exec", "uptime"},
+		{"--revoke", "--host", "10.0.0.5", "--push", "--password-env", "PASS"},
+	} {
+		if _, err := parseFlags(args); err == nil {
+			t.Errorf("parseFlags(%q): expected error", args)
+		}
+	}
+}
+
+func TestParseFlags_SortAndGroup(t *testing.T) {
+	f, err := parseFlags(nil)
+	if err != nil || f.sort != render.SortLastSeen {
+		t.Errorf("default sort: got %q, %v; want last-seen", f.sort, err)
+	}
+
+	f, err = parseFlags([]string{"--sort", "ip", "--group-by", "os"})
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if f.sort != render.SortIP || f.groupBy != "os" {
+		t.Errorf("unexpected flags: %+v", f)
+	}
+
+	for _, args := range [][]string{{"--sort", "mac"}, {"--group-by", "subnet"}} {
+		if _, err := parseFlags(args); err == nil {
+			t.Errorf("parseFlags(%q): expected error", args)
+		}
+	}
+}
+
+func TestParsePutSpec(t *testing.T) {
+	tests := []struct {
+		spec, local, remote string
+		wantErr             bool
+	}{
+		{"./file.conf:/etc/app/file.conf", "./file.conf", "/etc/app/file.conf", false},
+		{"conf/app.conf:/etc/app/", "conf/app.conf", "/etc/app/app.conf", false},
+		{"file.conf", "", "", true},
+		{":/etc/app/file.conf", "", "", true},
+		{"file.conf:", "", "", true},
+	}
+	for _, tt := range tests {
+		local, remote, err := parsePutSpec(tt.spec)
+		if (err != nil) != tt.wantErr || local != tt.local || remote != tt.remote {
+			t.Errorf("parsePutSpec(%q): got %q, %q, %v", tt.spec, local, remote, err)
+		}
+	}
+
+	if _, err := parseFlags([]string{"--put", "a:/tmp/a"}); err == nil {
+		t.Error("expected --put without --host to fail")
+	}
+}
+
+func TestExecTargetsAndUser(t *testing.T) {
+	hosts := []store.HostRecord{
+		{Beacon: beacon.BeaconPayload{Hostname: "keyed"}, SSHKeyPushed: true, SSHKeyUser: "alice"},
+		{Beacon: beacon.BeaconPayload{Hostname: "bare"}},
+	}
+
+	targets, skipped := execTargets(hosts)
+	if len(targets) != 1 || targets[0].Beacon.Hostname != "keyed" {
+		t.Errorf("targets: got %+v", targets)
+	}
+	if len(skipped) != 1 || skipped[0].Beacon.Hostname != "bare" {
+		t.Errorf("skipped: got %+v", skipped)
+	}
+
+	if got := execUser(hosts[0], connectFlags{user: "root"}); got != "alice" {
+		t.Errorf("recorded user: got %q, want alice", got)
+	}
+	if got := execUser(hosts[0], connectFlags{user: "bob", userSet: true}); got != "bob" {
+		t.Errorf("explicit --user: got %q, want bob", got)
+	}
+	if got := execUser(hosts[1], connectFlags{user: "root"}); got != "root" {
+		t.Errorf("no recorded user: got %q, want root", got)
+	}
+}
+
+func TestFilterHosts(t *testing.T) {
+	host := func(name, osName string) store.HostRecord {
+		return store.HostRecord{Beacon: beacon.BeaconPayload{Hostname: name, OS: beacon.OSInfo{Name: osName}}}
+	}
+	hosts := []store.HostRecord{
+		host("web-1", "Ubuntu 22.04"),
+		host("web-2", "Debian 12"),
+		host("db-1", "Ubuntu 24.04"),
+	}
+
+	tests := []struct {
+		osPattern, hostPattern string
+		want                   []string
+	}{
+		{"", "", []string{"web-1", "web-2", "db-1"}},
+		{"ubuntu", "", []string{"web-1", "db-1"}},
+		{"", "web-*", []string{"web-1", "web-2"}},
+		{"ubuntu", "web-*", []string{"web-1"}},
+		{"*24.04", "", []string{"db-1"}},
+		{"", "WEB", []string{"web-1", "web-2"}},
+		{"", "mail", nil},
+	}
+	for _, tt := range tests {
+		got, err := filterHosts(hosts, tt.osPattern, tt.hostPattern)
+		if err != nil {
+			t.Fatalf("filterHosts(%q, %q): %v", tt.osPattern, tt.hostPattern, err)
+		}
+		var names []string
+		for _, h := range got {
+			names = append(names, h.Beacon.Hostname)
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("filterHosts(%q, %q): got %v, want %v", tt.osPattern, tt.hostPattern, names, tt.want)
+		}
+	}
+
+	if _, err := filterHosts(hosts, "", "web-["); err == nil {
+		t.Error("expected error for malformed glob")
+	}
+}
+
+func TestFindHost(t *testing.T) {
+	hosts := []store.HostRecord{
+		{Beacon: beacon.BeaconPayload{MACAddress: "aa:bb:cc:dd:ee:01", IPAddress: "10.0.0.5", Hostname: "web1"}},
+		{Beacon: beacon.BeaconPayload{MACAddress: "aa:bb:cc:dd:ee:02", IPAddress: "10.0.0.6", Hostname: "web2"}},
+	}
+
+	for query, want := range map[string]string{"10.0.0.6": "web2", "AA:BB:CC:DD:EE:01": "web1"} {
+		h, ok := findHost(hosts, query)
+		if !ok || h.Beacon.Hostname != want {
+			t.Errorf("findHost(%q): got %q, %v, want %q", query, h.Beacon.Hostname, ok, want)
+		}
+	}
+	if _, ok := findHost(hosts, "10.0.0.7"); ok {
+		t.Error("expected no match for unknown IP")
+	}
+}
+
+func TestDefaultUser(t *testing.T) {
+	alice := store.HostRecord{SSHKeyUser: "alice"}
+	bob := store.HostRecord{SSHKeyUser: "bob"}
+	unknown := store.HostRecord{}
+
+	tests := []struct {
+		name  string
+		hosts []store.HostRecord
+		want  string
+	}{
+		{"recorded user", []store.HostRecord{alice}, "alice"},
+		{"no recorded user", []store.HostRecord{unknown}, "root"},
+		{"all agree", []store.HostRecord{alice, alice}, "alice"},
+		{"users differ", []store.HostRecord{alice, bob}, "root"},
+		{"some unknown", []store.HostRecord{alice, unknown}, "root"},
+	}
+	for _, tt := range tests {
+		if got := defaultUser(tt.hosts...); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseFlags_Label(t *testing.T) {
+	f, err := parseFlags([]string{"--tag", "role=web", "3"})
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if f.tag != "role=web" || f.target != "3" {
+		t.Errorf("unexpected flags: %+v", f)
+	}
+	if f, err := parseFlags([]string{"--filter-tag", "env=staging", "--untag", "role", "10.0.0.5"}); err != nil || f.untag != "role" {
+		t.Errorf("untag: got %+v, %v", f, err)
+	}
+	if f, err := parseFlags([]string{"--wide", "--note", "", "aa:bb:cc:dd:ee:ff"}); err != nil || !f.noteSet || f.note != "" || !f.wide {
+		t.Errorf("clearing note: got %+v, %v", f, err)
+	}
+
+	for _, args := range [][]string{
+		{"--tag", "role=web"},
+		{"--tag", "role", "1"},
+		{"--tag", "role=web", "--untag", "env", "1"},
+		{"--tag", "role=web", "--note", "x", "1"},
+		{"--note", "x"},
+		{"--tag", "role=web", "--host", "10.0.0.5", "1"},
+		{"--filter-tag", "role=web", "1"},
+	} {
+		if _, err := parseFlags(args); err == nil {
+			t.Errorf("parseFlags(%q): expected error", args)
+		}
+	}
+}
+
+func TestFilterLabel(t *testing.T) {
+	host := func(name string, labels map[string]string) store.HostRecord {
+		return store.HostRecord{Beacon: beacon.BeaconPayload{Hostname: name}, Labels: labels}
+	}
+	hosts := []store.HostRecord{
+		host("web-1", map[string]string{"role": "web", "env": "prod"}),
+		host("web-2", map[string]string{"role": "web", "env": "staging"}),
+		host("db-1", map[string]string{"role": "db"}),
+		host("mail", nil),
+	}
+
+	for spec, want := range map[string][]string{
+		"":            {"web-1", "web-2", "db-1", "mail"},
+		"role=web":    {"web-1", "web-2"},
+		"env":         {"web-1", "web-2"},
+		"env=staging": {"web-2"},
+		"role=":       nil,
+	} {
+		var names []string
+		for _, h := range filterLabel(hosts, spec) {
+			names = append(names, h.Beacon.Hostname)
+		}
+		if !reflect.DeepEqual(names, want) {
+			t.Errorf("filterLabel(%q): got %v, want %v", spec, names, want)
+		}
+	}
+}
+
+func TestLabelTarget(t *testing.T) {
+	hosts := []store.HostRecord{
+		{Beacon: beacon.BeaconPayload{MACAddress: "aa:bb:cc:dd:ee:01", IPAddress: "10.0.0.5", Hostname: "web1"}},
+		{Beacon: beacon.BeaconPayload{MACAddress: "aa:bb:cc:dd:ee:02", IPAddress: "10.0.0.6", Hostname: "web2"}},
+	}
+
+	for target, want := range map[string]string{"2": "web2", "10.0.0.5": "web1"} {
+		h, err := labelTarget(hosts, target)
+		if err != nil || h.Beacon.Hostname != want {
+			t.Errorf("labelTarget(%q): got %q, %v, want %q", target, h.Beacon.Hostname, err, want)
+		}
+	}
+	for _, target := range []string{"0", "3", "10.0.0.7"} {
+		if _, err := labelTarget(hosts, target); err == nil {
+			t.Errorf("labelTarget(%q): expected error", target)
+		}
+	}
+}
+
+func TestPushedKeyWarning(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("converting public key: %v", err)
+	}
+	pubKeyPath := filepath.Join(t.TempDir(), "id_ed25519.pub")
+	if err := os.WriteFile(pubKeyPath, ssh.MarshalAuthorizedKey(sshPub), 0644); err != nil {
+		t.Fatalf("writing public key: %v", err)
+	}
+	current := ssh.FingerprintSHA256(sshPub)
+
+	host := store.HostRecord{Beacon: beacon.BeaconPayload{Hostname: "web"}}
+	for _, fingerprint := range []string{"", current} {
+		host.PushedKeyFingerprint = fingerprint
+		if w := pushedKeyWarning(host, pubKeyPath); w != "" {
+			t.Errorf("fingerprint %q: unexpected warning %q", fingerprint, w)
+		}
+	}
+
+	host.PushedKeyFingerprint = "SHA256:old"
+	if w := pushedKeyWarning(host, pubKeyPath); !strings.Contains(w, "SHA256:old") || !strings.Contains(w, current) {
+		t.Errorf("expected a warning naming both keys, got %q", w)
+	}
+	if w := pushedKeyWarning(host, filepath.Join(t.TempDir(), "missing.pub")); w != "" {
+		t.Errorf("unexpected warning without a local key: %q", w)
+	}
+}
+
+func TestSSHTimeout(t *testing.T) {
+	cfg := &config.Config{}
+	if got := sshTimeout(cfg, defaultProbeTimeout); got != 5*time.Second {
+		t.Errorf("unset: got %v, want 5s", got)
+	}
+	cfg.Connect.SSHTimeout = "30s"
+	if got := sshTimeout(cfg, defaultProbeTimeout); got != 30*time.Second {
+		t.Errorf("set: got %v, want 30s", got)
+	}
+	if got := sshOptions(cfg, zerolog.Nop()).Timeout; got != 30*time.Second {
+		t.Errorf("sshOptions: got %v, want 30s", got)
+	}
+
+	for timeout, want := range map[time.Duration]string{
+		5 * time.Second:         "5",
+		1500 * time.Millisecond: "2",
+		100 * time.Millisecond:  "1",
+	} {
+		if got := connectTimeoutArg(timeout); got != want {
+			t.Errorf("connectTimeoutArg(%v): got %s, want %s", timeout, got, want)
+		}
+	}
+}
+
+func TestPushOptions_Identity(t *testing.T) {
+	cfg := &config.Config{}
+	host := store.HostRecord{Beacon: beacon.BeaconPayload{Hostname: "web-1", MACAddress: "aa:bb:cc:dd:ee:01"}}
+
+	opts := pushOptions(cfg, host, connectFlags{}, zerolog.Nop())
+	if opts.Identity == nil || *opts.Identity != (sshpush.Identity{Hostname: "web-1", MAC: "aa:bb:cc:dd:ee:01"}) {
+		t.Errorf("expected the beacon's identity to be checked, got %+v", opts.Identity)
+	}
+
+	f, err := parseFlags([]string{"--no-verify-identity"})
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if opts := pushOptions(cfg, host, f, zerolog.Nop()); opts.Identity != nil {
+		t.Errorf("expected no identity check with --no-verify-identity, got %+v", opts.Identity)
+	}
+}
@@ -0,0 +1,106 @@
+package connect
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/render"
+	"lanmon/internal/sshpush"
+	"lanmon/internal/store"
+	"lanmon/pkg/config"
+)
+
+// execResult is the outcome of running the --exec command on one host.
+type execResult struct {
+	host   store.HostRecord
+	user   string
+	output string
+	err    error
+}
+
+// runExec runs flags.exec on the --host host, or with --all on every host
+// the key has been pushed to, and prints each host's output under a header.
+// Hosts without the key are skipped and listed at the end.
+func runExec(cfg *config.Config, hosts []store.HostRecord, flags connectFlags, log zerolog.Logger) error {
+	if flags.host != "" {
+		host, ok := findHost(hosts, flags.host)
+		if !ok {
+			return fmt.Errorf("no active host with MAC or IP %s", flags.host)
+		}
+		hosts = []store.HostRecord{host}
+	}
+
+	pubKeyPath := cfg.Connect.ServerPubKey
+	if _, err := os.Stat(pubKeyPath); err != nil {
+		return fmt.Errorf("SSH public key not found at %s (run 'lanmon connect' interactively to generate one)", pubKeyPath)
+	}
+
+	port := flags.port
+	if port == 0 {
+		port = cfg.Connect.SSHPort
+	}
+
+	targets, skipped := execTargets(hosts)
+	if len(targets) == 0 {
+		return fmt.Errorf("none of the %d hosts has the SSH key pushed", len(hosts))
+	}
+
+	results := runPool(len(targets), cfg.Connect.MaxConcurrency, func(i int) execResult {
+		host := targets[i]
+		user := execUser(host, flags)
+		output, err := sshpush.RunCommand(host.Beacon.IPAddress, port, user, pubKeyPath, flags.exec, sshOptions(cfg, log))
+		return execResult{host: host, user: user, output: output, err: err}
+	})
+
+	failed := 0
+	for _, r := range results {
+		fmt.Printf("\n=== %s (%s@%s) ===\n", r.host.Beacon.Hostname, r.user, r.host.Beacon.IPAddress)
+		if r.output != "" {
+			fmt.Print(r.output)
+			if !strings.HasSuffix(r.output, "\n") {
+				fmt.Println()
+			}
+		}
+		if r.err != nil {
+			failed++
+			fmt.Printf("✗ %v\n", r.err)
+		}
+	}
+
+	if len(skipped) > 0 {
+		fmt.Printf("\nSkipped %d hosts without the SSH key:\n", len(skipped))
+		for _, h := range skipped {
+			fmt.Printf("  - %-20s %s\n", render.Truncate(h.Beacon.Hostname, 20), h.Beacon.IPAddress)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("command failed on %d of %d hosts", failed, len(results))
+	}
+	return nil
+}
+
+// execTargets splits hosts into those the key has been pushed to and the
+// rest.
+func execTargets(hosts []store.HostRecord) (targets, skipped []store.HostRecord) {
+	for _, h := range hosts {
+		if h.SSHKeyPushed {
+			targets = append(targets, h)
+		} else {
+			skipped = append(skipped, h)
+		}
+	}
+	return targets, skipped
+}
+
+// execUser is the user to run the command as on host: --user if given,
+// else whoever the key was pushed for.
+func execUser(host store.HostRecord, flags connectFlags) string {
+	if !flags.userSet && host.SSHKeyUser != "" {
+		return host.SSHKeyUser
+	}
+	return flags.user
+}
@@ -0,0 +1,50 @@
+package connect
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"lanmon/internal/store"
+)
+
+// filterHosts keeps the hosts whose OS name and hostname match osPattern
+// and hostPattern; an empty pattern matches everything.
+func filterHosts(hosts []store.HostRecord, osPattern, hostPattern string) ([]store.HostRecord, error) {
+	if osPattern == "" && hostPattern == "" {
+		return hosts, nil
+	}
+
+	var kept []store.HostRecord
+	for _, h := range hosts {
+		okOS, err := matchPattern(osPattern, h.Beacon.OS.Name)
+		if err != nil {
+			return nil, fmt.Errorf("--filter-os: %w", err)
+		}
+		okHost, err := matchPattern(hostPattern, h.Beacon.Hostname)
+		if err != nil {
+			return nil, fmt.Errorf("--filter-host: %w", err)
+		}
+		if okOS && okHost {
+			kept = append(kept, h)
+		}
+	}
+	return kept, nil
+}
+
+// matchPattern matches s against a glob if pattern has glob characters,
+// otherwise checks for a substring. Both are case-insensitive.
+func matchPattern(pattern, s string) (bool, error) {
+	if pattern == "" {
+		return true, nil
+	}
+	pattern, s = strings.ToLower(pattern), strings.ToLower(s)
+	if strings.ContainsAny(pattern, "*?[") {
+		ok, err := path.Match(pattern, s)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		return ok, nil
+	}
+	return strings.Contains(s, pattern), nil
+}
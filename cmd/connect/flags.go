@@ -0,0 +1,241 @@
+package connect
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/render"
+	"lanmon/internal/rpc"
+	"lanmon/internal/sshpush"
+	"lanmon/internal/store"
+	"lanmon/pkg/config"
+)
+
+// connectFlags are the command-line options for scripted use. Passing
+// --host selects non-interactive mode: nothing is read from stdin. The
+// filter flags and --connect also work interactively.
+type connectFlags struct {
+	host        string
+	user        string
+	port        int
+	push        bool
+	passwordEnv string
+	noConnect   bool
+	sudo        bool
+
+	noVerifyIdentity bool
+
+	filterOS   string
+	filterHost string
+	filterTag  string
+	connect    bool
+
+	exec    string
+	all     bool
+	userSet bool
+
+	put       string
+	putLocal  string
+	putRemote string
+
+	revoke bool
+	noTUI  bool
+
+	sort    render.SortKey
+	groupBy string
+	wide    bool
+
+	// tag (key=value) or untag (key) labels the host named by target, an
+	// index into the listed hosts or a MAC or IP address. note replaces
+	// its notes instead; noteSet tells --note "" (clear) from no --note.
+	tag     string
+	untag   string
+	note    string
+	noteSet bool
+	target  string
+}
+
+// hostlessFlags may be used without --host.
+var hostlessFlags = map[string]bool{
+	"filter-os": true, "filter-host": true, "filter-tag": true, "connect": true, "exec": true, "all": true,
+	"no-tui": true, "sudo": true, "no-verify-identity": true, "sort": true, "group-by": true, "wide": true, "tag": true, "untag": true, "note": true,
+}
+
+func parseFlags(args []string) (connectFlags, error) {
+	var f connectFlags
+	fs := flag.NewFlagSet("connect", flag.ContinueOnError)
+	fs.StringVar(&f.host, "host", "", "select the host with this MAC or IP address (non-interactive)")
+	fs.StringVar(&f.user, "user", "root", "SSH username")
+	fs.IntVar(&f.port, "port", 0, "SSH port (default connect.ssh_port)")
+	fs.BoolVar(&f.push, "push", false, "push the key if passwordless SSH doesn't work yet")
+	fs.StringVar(&f.passwordEnv, "password-env", "", "environment variable holding the SSH password for --push or --revoke")
+	fs.BoolVar(&f.noConnect, "no-connect", false, "exit after the key is in place instead of starting ssh")
+	fs.BoolVar(&f.noVerifyIdentity, "no-verify-identity", false, "push even if the host's hostname and MACs don't match what discovery saw")
+	fs.BoolVar(&f.sudo, "sudo", false, "edit authorized_keys through sudo -n when pushing (default connect.use_sudo)")
+	fs.StringVar(&f.filterOS, "filter-os", "", "only list hosts whose OS matches (glob or substring)")
+	fs.StringVar(&f.filterHost, "filter-host", "", "only list hosts whose hostname matches (glob or substring)")
+	fs.StringVar(&f.filterTag, "filter-tag", "", "only list hosts with this label (key=value, or key for any value)")
+	fs.StringVar(&f.tag, "tag", "", "set the label key=value on the host given as argument (list index, MAC or IP)")
+	fs.StringVar(&f.untag, "untag", "", "remove the label key from the host given as argument")
+	fs.StringVar(&f.note, "note", "", "replace the notes on the host given as argument (\"\" clears them)")
+	fs.BoolVar(&f.connect, "connect", false, "skip the prompt when the filters leave exactly one host")
+	fs.StringVar(&f.exec, "exec", "", "run this command on the selected hosts (--host or --all) and print the output")
+	fs.BoolVar(&f.all, "all", false, "with --exec, run on every active host that has the key")
+	fs.StringVar(&f.put, "put", "", "copy a file to the --host host, as local:remote")
+	sortKey := fs.String("sort", "last-seen", "order hosts by: hostname, ip, last-seen (newest first) or os")
+	fs.StringVar(&f.groupBy, "group-by", "", "group the host table by: os")
+	fs.BoolVar(&f.wide, "wide", false, "add a column with the start of each host's notes to the host table")
+	fs.BoolVar(&f.noTUI, "no-tui", false, "pick the host from a numbered list instead of the interactive selector")
+	fs.BoolVar(&f.revoke, "revoke", false, "remove the key from the --host host (--password-env if the key no longer works)")
+	if err := fs.Parse(args); err != nil {
+		return f, err
+	}
+
+	fs.Visit(func(fl *flag.Flag) { f.noteSet = f.noteSet || fl.Name == "note" })
+
+	labeling := f.tag != "" || f.untag != "" || f.noteSet
+	switch {
+	case labeling && fs.NArg() != 1:
+		return f, fmt.Errorf("--tag, --untag and --note take one host (list index, MAC or IP) as argument")
+	case !labeling && fs.NArg() > 0:
+		return f, fmt.Errorf("unexpected argument: %s", fs.Arg(0))
+	}
+	if labeling {
+		f.target = fs.Arg(0)
+		if f.tag != "" && f.untag != "" || f.noteSet && (f.tag != "" || f.untag != "") {
+			return f, fmt.Errorf("--tag, --untag and --note cannot be combined")
+		}
+		if f.host != "" || f.exec != "" || f.put != "" || f.revoke || f.push {
+			return f, fmt.Errorf("--tag, --untag and --note cannot be combined with --host, --exec, --put, --revoke or --push")
+		}
+		if f.tag != "" {
+			if _, _, err := parseLabel(f.tag); err != nil {
+				return f, fmt.Errorf("--tag: %w", err)
+			}
+		}
+	}
+	fs.Visit(func(fl *flag.Flag) { f.userSet = f.userSet || fl.Name == "user" })
+
+	var err error
+	if f.sort, err = render.ParseSortKey(*sortKey); err != nil {
+		return f, err
+	}
+	if f.groupBy != "" && f.groupBy != "os" {
+		return f, fmt.Errorf("unknown --group-by value %q (want os)", f.groupBy)
+	}
+
+	if f.put != "" {
+		var err error
+		if f.putLocal, f.putRemote, err = parsePutSpec(f.put); err != nil {
+			return f, err
+		}
+		if f.exec != "" {
+			return f, fmt.Errorf("--put and --exec cannot be combined")
+		}
+	}
+	if f.revoke && (f.push || f.exec != "" || f.put != "") {
+		return f, fmt.Errorf("--revoke cannot be combined with --push, --exec or --put")
+	}
+	if f.all && f.exec == "" {
+		return f, fmt.Errorf("--all requires --exec")
+	}
+	if f.exec != "" && (f.host == "") == !f.all {
+		return f, fmt.Errorf("--exec requires either --host or --all")
+	}
+	if f.host == "" && !f.all {
+		var err error
+		fs.Visit(func(fl *flag.Flag) {
+			if !hostlessFlags[fl.Name] && err == nil {
+				err = fmt.Errorf("--host is required for non-interactive use (--%s)", fl.Name)
+			}
+		})
+		if err != nil {
+			return f, err
+		}
+	}
+	if f.push && f.passwordEnv == "" {
+		return f, fmt.Errorf("--push requires --password-env")
+	}
+	return f, nil
+}
+
+// parsePutSpec splits a --put argument into local and remote paths. A
+// remote path ending in "/" names a directory to copy into.
+func parsePutSpec(spec string) (local, remote string, err error) {
+	local, remote, ok := strings.Cut(spec, ":")
+	if !ok || local == "" || remote == "" {
+		return "", "", fmt.Errorf("--put wants local:remote, got %q", spec)
+	}
+	if strings.HasSuffix(remote, "/") {
+		remote += filepath.Base(local)
+	}
+	return local, remote, nil
+}
+
+// findHost returns the host whose MAC (case-insensitively) or IP is query.
+func findHost(hosts []store.HostRecord, query string) (store.HostRecord, bool) {
+	for _, h := range hosts {
+		if strings.EqualFold(h.Beacon.MACAddress, query) || h.Beacon.IPAddress == query {
+			return h, true
+		}
+	}
+	return store.HostRecord{}, false
+}
+
+// runNonInteractive is the scripted counterpart of the interactive flow:
+// connect if passwordless SSH already works, otherwise push the key when
+// --push allows it.
+func runNonInteractive(cfg *config.Config, client *rpc.Client, hosts []store.HostRecord, flags connectFlags, log zerolog.Logger) error {
+	host, ok := findHost(hosts, flags.host)
+	if !ok {
+		return fmt.Errorf("no active host with MAC or IP %s", flags.host)
+	}
+	ip := host.Beacon.IPAddress
+
+	port := flags.port
+	if port == 0 {
+		port = cfg.Connect.SSHPort
+	}
+
+	pubKeyPath := cfg.Connect.ServerPubKey
+	if _, err := os.Stat(pubKeyPath); err != nil {
+		return fmt.Errorf("SSH public key not found at %s (run 'lanmon connect' interactively to generate one)", pubKeyPath)
+	}
+	if warning := pushedKeyWarning(host, pubKeyPath); warning != "" {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+	fingerprint, _ := sshpush.Fingerprint(pubKeyPath)
+
+	if !canSSHWithoutPassword(flags.user, ip, port, sshTimeout(cfg, defaultProbeTimeout)) {
+		if !flags.push {
+			return fmt.Errorf("passwordless SSH to %s@%s is not set up; pass --push to push the key", flags.user, ip)
+		}
+
+		password := os.Getenv(flags.passwordEnv)
+		if password == "" {
+			return fmt.Errorf("environment variable %s is empty", flags.passwordEnv)
+		}
+
+		fmt.Printf("Pushing SSH key to %s@%s...\n", flags.user, ip)
+		if err := sshpush.PushKey(ip, port, flags.user, password, pubKeyPath, pushOptions(cfg, host, flags, log)); err != nil {
+			return fmt.Errorf("SSH key push failed: %w", err)
+		}
+		fmt.Printf("✓ SSH key pushed to %s@%s\n", flags.user, ip)
+	}
+
+	if !host.SSHKeyPushed || host.SSHKeyUser != flags.user || host.PushedKeyFingerprint != fingerprint || flags.push {
+		if err := client.MarkKeyPushed(host.Beacon.MACAddress, flags.user, fingerprint); err != nil {
+			log.Warn().Err(err).Msg("Failed to update key push status in database")
+		}
+	}
+
+	if flags.noConnect {
+		return nil
+	}
+	return execSSH(flags.user, ip, port)
+}
@@ -0,0 +1,107 @@
+package connect
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"lanmon/internal/render"
+	"lanmon/internal/rpc"
+	"lanmon/internal/store"
+)
+
+// parseLabel splits a key=value label spec.
+func parseLabel(spec string) (key, value string, err error) {
+	key, value, ok := strings.Cut(spec, "=")
+	if !ok {
+		return "", "", fmt.Errorf("want key=value, got %q", spec)
+	}
+	if err := store.ValidateLabelKey(key); err != nil {
+		return "", "", err
+	}
+	return key, value, nil
+}
+
+// filterLabel keeps the hosts carrying the label in spec: "key=value" for
+// that exact value, or a bare "key" for any value. An empty spec keeps all.
+func filterLabel(hosts []store.HostRecord, spec string) []store.HostRecord {
+	if spec == "" {
+		return hosts
+	}
+	key, value, exact := strings.Cut(spec, "=")
+
+	var kept []store.HostRecord
+	for _, h := range hosts {
+		v, ok := h.Labels[key]
+		if ok && (!exact || v == value) {
+			kept = append(kept, h)
+		}
+	}
+	return kept
+}
+
+// labelTarget resolves a --tag/--untag/--note argument: a 1-based index into
+// hosts, as numbered in the host table, or a MAC or IP address.
+func labelTarget(hosts []store.HostRecord, target string) (store.HostRecord, error) {
+	if n, err := strconv.Atoi(target); err == nil {
+		if n < 1 || n > len(hosts) {
+			return store.HostRecord{}, fmt.Errorf("no host #%d (%d listed)", n, len(hosts))
+		}
+		return hosts[n-1], nil
+	}
+	if h, ok := findHost(hosts, target); ok {
+		return h, nil
+	}
+	return store.HostRecord{}, fmt.Errorf("no active host with MAC or IP %s", target)
+}
+
+// runLabel sets or removes a label on one host. Indexes count the hosts as
+// listed with the same filter, --sort and --group-by flags.
+func runLabel(client *rpc.Client, hosts []store.HostRecord, flags connectFlags) error {
+	host, err := labelTarget(hosts, flags.target)
+	if err != nil {
+		return err
+	}
+	mac := host.Beacon.MACAddress
+
+	if flags.untag != "" {
+		if err := client.RemoveLabel(mac, flags.untag); err != nil {
+			return fmt.Errorf("removing label: %w", err)
+		}
+		delete(host.Labels, flags.untag)
+	} else {
+		key, value, err := parseLabel(flags.tag)
+		if err != nil {
+			return err
+		}
+		if err := client.SetLabel(mac, key, value); err != nil {
+			return fmt.Errorf("setting label: %w", err)
+		}
+		if host.Labels == nil {
+			host.Labels = make(map[string]string)
+		}
+		host.Labels[key] = value
+	}
+
+	fmt.Printf("✓ %s (%s): %s\n", host.Beacon.Hostname, mac, render.FormatLabels(host.Labels))
+	return nil
+}
+
+// runNote replaces the notes on one host, resolved like runLabel's.
+func runNote(client *rpc.Client, hosts []store.HostRecord, flags connectFlags) error {
+	host, err := labelTarget(hosts, flags.target)
+	if err != nil {
+		return err
+	}
+	mac := host.Beacon.MACAddress
+
+	if err := client.SetNotes(mac, flags.note); err != nil {
+		return fmt.Errorf("setting notes: %w", err)
+	}
+	if strings.TrimSpace(flags.note) == "" {
+		fmt.Printf("✓ %s (%s): notes cleared\n", host.Beacon.Hostname, mac)
+	} else {
+		fmt.Printf("✓ %s (%s): notes saved\n", host.Beacon.Hostname, mac)
+	}
+	return nil
+}
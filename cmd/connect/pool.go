@@ -0,0 +1,30 @@
+package connect
+
+import "sync"
+
+// runPool calls fn for each index in [0, n) with at most limit calls in
+// flight, and returns the results ordered by index regardless of the order
+// in which they complete. It is shared by every connect operation that
+// touches multiple hosts so they all honor connect.max_concurrency.
+func runPool[T any](n, limit int, fn func(i int) T) []T {
+	if limit < 1 {
+		limit = 1
+	}
+
+	results := make([]T, n)
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+	return results
+}
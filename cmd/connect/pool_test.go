@@ -0,0 +1,58 @@
+package connect
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunPool_RespectsLimit(t *testing.T) {
+	const limit = 3
+	var inFlight, peak int32
+
+	results := runPool(20, limit, func(i int) int {
+		cur := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if cur <= p || atomic.CompareAndSwapInt32(&peak, p, cur) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return i * 2
+	})
+
+	if peak > limit {
+		t.Errorf("peak concurrency: got %d, want <= %d", peak, limit)
+	}
+	if len(results) != 20 {
+		t.Fatalf("expected 20 results, got %d", len(results))
+	}
+	for i, r := range results {
+		if r != i*2 {
+			t.Errorf("result %d: got %d, want %d", i, r, i*2)
+		}
+	}
+}
+
+func TestRunPool_OrderedDespiteCompletionOrder(t *testing.T) {
+	// Earlier indexes sleep longer so they finish last.
+	results := runPool(5, 5, func(i int) int {
+		time.Sleep(time.Duration(5-i) * 2 * time.Millisecond)
+		return i
+	})
+
+	for i, r := range results {
+		if r != i {
+			t.Errorf("result %d: got %d, want %d", i, r, i)
+		}
+	}
+}
+
+func TestRunPool_ZeroLimit(t *testing.T) {
+	results := runPool(3, 0, func(i int) int { return i + 1 })
+	if len(results) != 3 || results[2] != 3 {
+		t.Errorf("unexpected results: %v", results)
+	}
+}
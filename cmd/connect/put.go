@@ -0,0 +1,38 @@
+package connect
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/sshpush"
+	"lanmon/internal/store"
+	"lanmon/pkg/config"
+)
+
+// runPut copies the --put file to the --host host.
+func runPut(cfg *config.Config, hosts []store.HostRecord, flags connectFlags, log zerolog.Logger) error {
+	host, ok := findHost(hosts, flags.host)
+	if !ok {
+		return fmt.Errorf("no active host with MAC or IP %s", flags.host)
+	}
+
+	pubKeyPath := cfg.Connect.ServerPubKey
+	if _, err := os.Stat(pubKeyPath); err != nil {
+		return fmt.Errorf("SSH public key not found at %s (run 'lanmon connect' interactively to generate one)", pubKeyPath)
+	}
+
+	port := flags.port
+	if port == 0 {
+		port = cfg.Connect.SSHPort
+	}
+
+	user := execUser(host, flags)
+	ip := host.Beacon.IPAddress
+	if err := sshpush.PutFile(ip, port, user, pubKeyPath, flags.putLocal, flags.putRemote, sshOptions(cfg, log)); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Copied %s to %s@%s:%s\n", flags.putLocal, user, ip, flags.putRemote)
+	return nil
+}
@@ -0,0 +1,60 @@
+package connect
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/rpc"
+	"lanmon/internal/sshpush"
+	"lanmon/internal/store"
+	"lanmon/pkg/config"
+)
+
+// runRevoke removes the server's key from the --host host and clears its
+// key status. Key auth is tried first; --password-env is the fallback for
+// hosts where the key no longer works.
+func runRevoke(cfg *config.Config, client *rpc.Client, hosts []store.HostRecord, flags connectFlags, log zerolog.Logger) error {
+	host, ok := findHost(hosts, flags.host)
+	if !ok {
+		return fmt.Errorf("no active host with MAC or IP %s", flags.host)
+	}
+
+	pubKeyPath := cfg.Connect.ServerPubKey
+	if _, err := os.Stat(pubKeyPath); err != nil {
+		return fmt.Errorf("SSH public key not found at %s", pubKeyPath)
+	}
+
+	port := flags.port
+	if port == 0 {
+		port = cfg.Connect.SSHPort
+	}
+
+	user := execUser(host, flags)
+	ip := host.Beacon.IPAddress
+	removed, err := sshpush.RevokeKey(ip, port, user, pubKeyPath, sshOptions(cfg, log))
+	if err != nil {
+		if flags.passwordEnv == "" {
+			return fmt.Errorf("%w (set --password-env if the key no longer works)", err)
+		}
+		password := os.Getenv(flags.passwordEnv)
+		if password == "" {
+			return fmt.Errorf("environment variable %s is empty", flags.passwordEnv)
+		}
+		if removed, err = sshpush.RevokeKeyPassword(ip, port, user, password, pubKeyPath, sshOptions(cfg, log)); err != nil {
+			return err
+		}
+	}
+
+	if removed {
+		fmt.Printf("✓ Key removed from %s@%s\n", user, ip)
+	} else {
+		fmt.Printf("Key was not in %s@%s's authorized_keys; nothing to remove\n", user, ip)
+	}
+
+	if err := client.ClearKeyPushed(host.Beacon.MACAddress); err != nil {
+		return fmt.Errorf("clearing key status: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package connect
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"lanmon/internal/render"
+	"lanmon/internal/store"
+)
+
+// selection is the user's choice of hosts, as 1-based indexes into the
+// host list.
+type selection struct {
+	indexes []int
+	batch   bool // push to all of indexes
+	del     bool // delete indexes[0] instead of connecting
+}
+
+// useSelector reports whether the interactive selector can be used: both
+// ends must be a terminal and --no-tui not given.
+func useSelector(flags connectFlags) bool {
+	return !flags.noTUI && term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// promptSelection shows the host table, with a section per group if
+// groups is set and a Notes column if wide, and reads an index from the
+// numeric prompt. With groups, hosts must be render.Flatten(groups).
+func promptSelection(reader *bufio.Reader, hosts []store.HostRecord, groups []render.Group, wide bool) (selection, error) {
+	fmt.Printf("\n  Active Hosts (%d found)\n\n", len(hosts))
+	switch {
+	case groups != nil:
+		render.NumberedGroupedTables(os.Stdout, groups, wide)
+	case wide:
+		render.WideHostTable(os.Stdout, hosts)
+	default:
+		render.HostTable(os.Stdout, hosts)
+	}
+
+	fmt.Print("\nEnter host index (1,3,5-8 to push to several, d<index> to delete): ")
+	input, _ := reader.ReadString('\n')
+	if isBatchSelection(input) {
+		indexes, err := parseIndexList(input, len(hosts))
+		return selection{indexes: indexes, batch: true}, err
+	}
+
+	index, del, err := parseSelection(input, len(hosts))
+	return selection{indexes: []int{index}, del: del}, err
+}
+
+// fuzzyMatch reports whether the characters of query appear in s in
+// order, ignoring case: "wb1" matches "web-01".
+func fuzzyMatch(query, s string) bool {
+	s = strings.ToLower(s)
+	for _, r := range strings.ToLower(query) {
+		i := strings.IndexRune(s, r)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(string(r)):]
+	}
+	return true
+}
+
+// Keys the selector understands besides printable characters.
+const (
+	keyNone = iota
+	keyUp
+	keyDown
+	keyEnter
+	keyBackspace
+	keyTab
+	keyDelete
+	keyCancel
+)
+
+// selector is the state of the interactive host picker: a query that
+// fuzzy-filters hosts by hostname and IP, a cursor into the matches, and
+// hosts marked for a batch push.
+type selector struct {
+	hosts   []store.HostRecord
+	query   string
+	matches []int // indexes into hosts
+	cursor  int
+	marked  map[int]bool
+}
+
+func newSelector(hosts []store.HostRecord) *selector {
+	s := &selector{hosts: hosts, marked: make(map[int]bool)}
+	s.filter()
+	return s
+}
+
+func (s *selector) filter() {
+	s.matches = s.matches[:0]
+	for i, h := range s.hosts {
+		if fuzzyMatch(s.query, h.Beacon.Hostname) || fuzzyMatch(s.query, h.Beacon.IPAddress) {
+			s.matches = append(s.matches, i)
+		}
+	}
+	s.cursor = min(s.cursor, max(len(s.matches)-1, 0))
+}
+
+// handle applies one key press. It returns the selection once the user
+// has made one, and done without a selection if they cancelled.
+func (s *selector) handle(key int, r rune) (sel *selection, done bool) {
+	switch key {
+	case keyUp:
+		s.cursor = max(s.cursor-1, 0)
+	case keyDown:
+		s.cursor = min(s.cursor+1, max(len(s.matches)-1, 0))
+	case keyBackspace:
+		if s.query != "" {
+			_, size := utf8.DecodeLastRuneInString(s.query)
+			s.query = s.query[:len(s.query)-size]
+			s.filter()
+		}
+	case keyTab:
+		if len(s.matches) > 0 {
+			i := s.matches[s.cursor]
+			s.marked[i] = !s.marked[i]
+		}
+	case keyDelete:
+		if len(s.matches) > 0 {
+			return &selection{indexes: []int{s.matches[s.cursor] + 1}, del: true}, true
+		}
+	case keyEnter:
+		var indexes []int
+		for i := range s.hosts {
+			if s.marked[i] {
+				indexes = append(indexes, i+1)
+			}
+		}
+		if len(indexes) > 0 {
+			return &selection{indexes: indexes, batch: true}, true
+		}
+		if len(s.matches) > 0 {
+			return &selection{indexes: []int{s.matches[s.cursor] + 1}}, true
+		}
+	case keyCancel:
+		return nil, true
+	case keyNone:
+		s.query += string(r)
+		s.filter()
+	}
+	return nil, false
+}
+
+// render draws the selector. The terminal is in raw mode, so lines end in
+// "\r\n".
+func (s *selector) render(w io.Writer, height int) {
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+	fmt.Fprintf(&b, "  Active Hosts (%d of %d)   ↑/↓ move · Tab mark · Enter select · Ctrl-X delete · Esc quit\r\n\r\n", len(s.matches), len(s.hosts))
+	fmt.Fprintf(&b, "  > %s\r\n\r\n", s.query)
+
+	// Keep the cursor on screen when there are more matches than rows
+	rows := max(height-5, 1)
+	first := max(s.cursor-rows+1, 0)
+	for n, i := range s.matches[first:min(first+rows, len(s.matches))] {
+		h := s.hosts[i]
+		pointer, mark := "  ", " "
+		if first+n == s.cursor {
+			pointer = "▸ "
+		}
+		if s.marked[i] {
+			mark = "*"
+		}
+		line := fmt.Sprintf("%s%s %-24s %-16s %s", pointer, mark, h.Beacon.Hostname, h.Beacon.IPAddress, h.Beacon.OS.Name)
+		if first+n == s.cursor {
+			line = "\033[7m" + line + "\033[0m"
+		}
+		b.WriteString(line + "\r\n")
+	}
+	io.WriteString(w, b.String())
+}
+
+// readKey reads one key press from a terminal in raw mode.
+func readKey(reader *bufio.Reader) (int, rune, error) {
+	r, _, err := reader.ReadRune()
+	if err != nil {
+		return keyNone, 0, err
+	}
+	switch r {
+	case '\r', '\n':
+		return keyEnter, 0, nil
+	case '\t':
+		return keyTab, 0, nil
+	case 0x7f, 0x08:
+		return keyBackspace, 0, nil
+	case 0x18: // Ctrl-X
+		return keyDelete, 0, nil
+	case 0x03, 0x04: // Ctrl-C, Ctrl-D
+		return keyCancel, 0, nil
+	case 0x10: // Ctrl-P
+		return keyUp, 0, nil
+	case 0x0e: // Ctrl-N
+		return keyDown, 0, nil
+	case 0x1b:
+		// A lone Esc cancels; arrow keys arrive as Esc [ A / Esc [ B
+		if reader.Buffered() == 0 {
+			return keyCancel, 0, nil
+		}
+		if next, _ := reader.ReadByte(); next != '[' && next != 'O' {
+			return keyCancel, 0, nil
+		}
+		switch code, _ := reader.ReadByte(); code {
+		case 'A':
+			return keyUp, 0, nil
+		case 'B':
+			return keyDown, 0, nil
+		}
+		return keyNone, 0, nil
+	}
+	if r < ' ' {
+		return keyNone, 0, nil
+	}
+	return keyNone, r, nil
+}
+
+// runSelector lets the user pick hosts interactively. It returns nil if
+// they cancelled.
+func runSelector(reader *bufio.Reader, hosts []store.HostRecord) (*selection, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, fmt.Errorf("setting terminal to raw mode: %w", err)
+	}
+	defer func() {
+		term.Restore(fd, state)
+		// Clear the selector off the screen
+		fmt.Print("\033[H\033[2J")
+	}()
+
+	s := newSelector(hosts)
+	for {
+		_, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			height = 24
+		}
+		s.render(os.Stdout, height)
+
+		key, r, err := readKey(reader)
+		if err != nil {
+			return nil, fmt.Errorf("reading key: %w", err)
+		}
+		if key == keyNone && r == 0 {
+			continue
+		}
+		if sel, done := s.handle(key, r); done {
+			return sel, nil
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package connect
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+
+	"lanmon/internal/beacon"
+	"lanmon/internal/store"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		query, s string
+		want     bool
+	}{
+		{"", "web-01", true},
+		{"wb1", "web-01", true},
+		{"WEB", "web-01", true},
+		{"10.5", "192.168.10.5", true},
+		{"bw", "web-01", false},
+		{"web-011", "web-01", false},
+	}
+	for _, tt := range tests {
+		if got := fuzzyMatch(tt.query, tt.s); got != tt.want {
+			t.Errorf("fuzzyMatch(%q, %q) = %v, want %v", tt.query, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestSelector(t *testing.T) {
+	host := func(name, ip string) store.HostRecord {
+		return store.HostRecord{Beacon: beacon.BeaconPayload{Hostname: name, IPAddress: ip}}
+	}
+	hosts := []store.HostRecord{
+		host("web-01", "10.0.0.1"),
+		host("db-01", "10.0.0.2"),
+		host("web-02", "10.0.0.3"),
+	}
+
+	type press struct {
+		key int
+		r   rune
+	}
+	typed := func(s string) []press {
+		var keys []press
+		for _, r := range s {
+			keys = append(keys, press{keyNone, r})
+		}
+		return keys
+	}
+
+	tests := []struct {
+		name string
+		keys []press
+		want *selection
+	}{
+		{"enter picks the first host", []press{{keyEnter, 0}}, &selection{indexes: []int{1}}},
+		{"arrows move", []press{{keyDown, 0}, {keyDown, 0}, {keyDown, 0}, {keyUp, 0}, {keyEnter, 0}}, &selection{indexes: []int{2}}},
+		{"query filters", append(typed("web2"), press{keyEnter, 0}), &selection{indexes: []int{3}}},
+		{"query by IP", append(typed(".0.2"), press{keyEnter, 0}), &selection{indexes: []int{2}}},
+		{"backspace widens", append(typed("dbx"), press{keyBackspace, 0}, press{keyEnter, 0}), &selection{indexes: []int{2}}},
+		{"tab marks a batch", []press{{keyTab, 0}, {keyDown, 0}, {keyDown, 0}, {keyTab, 0}, {keyEnter, 0}}, &selection{indexes: []int{1, 3}, batch: true}},
+		{"ctrl-x deletes", []press{{keyDown, 0}, {keyDelete, 0}}, &selection{indexes: []int{2}, del: true}},
+		{"no match can't be picked", append(typed("zzz"), press{keyEnter, 0}, press{keyCancel, 0}), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSelector(hosts)
+			var got *selection
+			for _, p := range tt.keys {
+				sel, done := s.handle(p.key, p.r)
+				if done {
+					got = sel
+					break
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("a\x1b[A\x1b[B\r\x7f\t\x18é\x03"))
+	want := []struct {
+		key int
+		r   rune
+	}{
+		{keyNone, 'a'}, {keyUp, 0}, {keyDown, 0}, {keyEnter, 0}, {keyBackspace, 0},
+		{keyTab, 0}, {keyDelete, 0}, {keyNone, 'é'}, {keyCancel, 0},
+	}
+	for i, w := range want {
+		key, r, err := readKey(reader)
+		if err != nil {
+			t.Fatalf("key %d: %v", i, err)
+		}
+		if key != w.key || r != w.r {
+			t.Errorf("key %d: got (%d, %q), want (%d, %q)", i, key, r, w.key, w.r)
+		}
+	}
+}
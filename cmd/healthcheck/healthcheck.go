@@ -0,0 +1,52 @@
+// Package healthcheck implements the lanmon healthcheck CLI, a liveness
+// probe for a running node.
+package healthcheck
+
+import (
+	"fmt"
+	"time"
+
+	"lanmon/internal/rpc"
+	"lanmon/pkg/config"
+)
+
+// timeout bounds the whole check, so a wedged node fails the probe instead
+// of hanging it.
+const timeout = 5 * time.Second
+
+// Run pings the local node over RPC and prints its status. It returns an
+// error if the node can't be reached or doesn't answer in time.
+func Run(configPath string) error {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	client, err := rpc.NewClient(cfg.Connect.RPCAddress(), cfg.Connect.RPCToken)
+	if err != nil {
+		return fmt.Errorf("connecting to server: %w\nIs 'lanmon node' running?", err)
+	}
+	defer client.Close()
+
+	type result struct {
+		reply *rpc.PingReply
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		reply, err := client.Ping()
+		done <- result{reply, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return fmt.Errorf("pinging server: %w", r.err)
+		}
+		fmt.Printf("ok: lanmon v%s (commit %s), up %s, %d hosts\n",
+			r.reply.Version, r.reply.Commit, r.reply.Uptime.Round(time.Second), r.reply.HostCount)
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("pinging server: no reply within %s", timeout)
+	}
+}
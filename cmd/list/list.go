@@ -0,0 +1,104 @@
+// Package list implements the lanmon list CLI (non-interactive host overview).
+package list
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"lanmon/internal/render"
+	"lanmon/internal/rpc"
+	"lanmon/internal/store"
+	"lanmon/pkg/config"
+)
+
+// Run prints the active hosts known to the local node.
+func Run(configPath string, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	groupBy := fs.String("group-by", "", "group hosts by: subnet, os, tag")
+	prefixLen := fs.Int("prefix-len", 24, "subnet prefix length used with --group-by subnet")
+	prefixLen6 := fs.Int("prefix-len6", 64, "IPv6 subnet prefix length used with --group-by subnet")
+	asJSON := fs.Bool("json", false, "print the hosts as a JSON array of host records")
+	match := fs.String("match", "", "only hosts whose hostname or IP contains this text")
+	offset := fs.Int("offset", 0, "skip this many hosts (in MAC address order)")
+	limit := fs.Int("limit", 0, "show at most this many hosts (0 = all)")
+	wide := fs.Bool("wide", false, "add a column with the start of each host's notes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *asJSON && *groupBy != "" {
+		return fmt.Errorf("--json and --group-by cannot be combined")
+	}
+	if *offset < 0 || *limit < 0 {
+		return fmt.Errorf("--offset and --limit must not be negative")
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	client, err := rpc.NewClient(cfg.Connect.RPCAddress(), cfg.Connect.RPCToken)
+	if err != nil {
+		return fmt.Errorf("connecting to server: %w\nIs 'lanmon node' running?", err)
+	}
+	defer client.Close()
+
+	// Only page on the server when asked, so plain listings keep working
+	// against nodes without QueryHosts
+	var hosts []store.HostRecord
+	paged := *match != "" || *offset > 0 || *limit > 0
+	if paged {
+		hosts, err = client.QueryHosts(store.QueryOptions{ActiveOnly: true, Match: *match, Offset: *offset, Limit: *limit})
+	} else {
+		hosts, err = client.ListActiveHosts()
+	}
+	if err != nil {
+		return fmt.Errorf("fetching active hosts: %w", err)
+	}
+
+	if *asJSON {
+		return render.HostsJSON(os.Stdout, hosts)
+	}
+
+	var groups []render.Group
+	switch *groupBy {
+	case "":
+	case "subnet":
+		groups, err = render.GroupBySubnet(hosts, *prefixLen, *prefixLen6)
+		if err != nil {
+			return err
+		}
+	case "os":
+		groups = render.GroupByOS(hosts)
+	case "tag":
+		groups = render.GroupByTag(hosts)
+	default:
+		return fmt.Errorf("unknown --group-by value %q (want subnet, os or tag)", *groupBy)
+	}
+
+	if len(hosts) == 0 {
+		if paged {
+			fmt.Println("No active hosts on this page.")
+		} else {
+			fmt.Println("No active hosts discovered.")
+		}
+		return nil
+	}
+
+	if paged {
+		fmt.Printf("\n  Active Hosts (%d-%d)\n\n", *offset+1, *offset+len(hosts))
+	} else {
+		fmt.Printf("\n  Active Hosts (%d found)\n\n", len(hosts))
+	}
+	if groups == nil {
+		if *wide {
+			render.WideHostTable(os.Stdout, hosts)
+		} else {
+			render.HostTable(os.Stdout, hosts)
+		}
+	} else {
+		render.GroupedTables(os.Stdout, groups, *wide)
+	}
+	return nil
+}
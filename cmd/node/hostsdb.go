@@ -0,0 +1,82 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"lanmon/internal/store"
+	"lanmon/pkg/config"
+	"lanmon/pkg/logger"
+)
+
+// ExportHosts writes the node's host database as JSON to the file named
+// in args, or to stdout if none (or "-") is given. It opens the database
+// read-only, so it works while the node runs.
+func ExportHosts(configPath string, args []string) error {
+	db, err := openStore(configPath, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if len(args) == 0 || args[0] == "-" {
+		return db.Export(os.Stdout)
+	}
+
+	f, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", args[0], err)
+	}
+	if err := db.Export(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ImportHosts merges host records from the JSON file named in args, or
+// from stdin if none (or "-") is given, into the node's host database.
+func ImportHosts(configPath string, args []string) error {
+	db, err := openStore(configPath, false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var r io.Reader = os.Stdin
+	if len(args) > 0 && args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("opening %s: %w", args[0], err)
+		}
+		defer f.Close()
+		r = f
+	}
+	return db.Import(r)
+}
+
+// openStore opens the database named in the config. The node holds the
+// database open while it runs, so either kind of open fails until it is
+// stopped.
+func openStore(configPath string, readOnly bool) (*store.Store, error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+
+	log := logger.Init(cfg.Node.LogLevel, cfg.Node.LogFormat, cfg.Node.LogOutput)
+	if readOnly {
+		db, err := store.NewReadOnly(cfg.Node.DBPath, log)
+		if errors.Is(err, store.ErrLocked) {
+			return nil, fmt.Errorf("%w\nStop the node to export hosts; while it runs, 'lanmon list --json' prints the active ones", err)
+		}
+		return db, err
+	}
+	db, err := store.New(cfg.Node.DBPath, log)
+	if errors.Is(err, store.ErrLocked) {
+		return nil, fmt.Errorf("%w\nStop the node to import hosts", err)
+	}
+	return db, err
+}
@@ -1,37 +1,53 @@
 package node
 
 import (
+	"context"
+	"crypto/tls"
+	"flag"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"syscall"
 	"time"
 
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/auth"
 	"lanmon/internal/discovery"
 	"lanmon/internal/hosts"
+	"lanmon/internal/httpapi"
+	"lanmon/internal/metrics"
 	"lanmon/internal/rpc"
-
 	"lanmon/internal/store"
 	"lanmon/pkg/config"
 	"lanmon/pkg/logger"
 )
 
 // Run starts the P2P discovery node.
-func Run(configPath string) error {
+func Run(configPath string, args []string) error {
+	fs := flag.NewFlagSet("node", flag.ContinueOnError)
+	debugPackets := fs.Bool("debug-packets", false, "log every dropped packet and why (overrides debug_packets)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
 	cfg, err := config.Load(configPath)
 	if err != nil {
 		return fmt.Errorf("loading config: %w", err)
 	}
-
-	log := logger.Init(cfg.Node.LogLevel)
-
-	if cfg.Node.SharedSecret == "" || cfg.Node.SharedSecret == "CHANGE_ME" {
-		return fmt.Errorf("shared_secret must be set in config (not 'CHANGE_ME')")
+	if *debugPackets {
+		cfg.Node.DebugPackets = true
 	}
 
-	if cfg.Node.NetworkRange == "" {
-		return fmt.Errorf("network_range must be set in config (e.g. '10.51.240.0/23')")
+	log := logger.Init(cfg.Node.LogLevel, cfg.Node.LogFormat, cfg.Node.LogOutput)
+	log.Debug().Str("path", configPath).Object("config", cfg.Node).Msg("Config loaded")
+
+	// The same checks as 'lanmon validate', so that no setting out of range,
+	// such as jitter as long as the interval, reaches the node
+	if err := config.Validate(cfg); err != nil {
+		return fmt.Errorf("invalid config (see 'lanmon config validate'):\n%w", err)
 	}
 
 	// Ensure database directory exists
@@ -53,9 +69,24 @@ func Run(configPath string) error {
 	}
 	defer db.Close()
 
-	// Initial sync of /etc/hosts from database
-	if err := hosts.Sync(db); err != nil {
-		log.Warn().Err(err).Msg("Failed to perform initial /etc/hosts sync")
+	var hostsOpts *hosts.Options
+	if cfg.Node.HostsManaged() {
+		hostsOpts = &hosts.Options{
+			Collision: cfg.Node.HostsCollision,
+			Path:      cfg.Node.HostsFile,
+			Domain:    cfg.Node.HostsDomain,
+			DryRun:    cfg.Node.HostsDryRun,
+		}
+		if err := hosts.ValidateCollision(hostsOpts.Collision); err != nil {
+			return err
+		}
+
+		// Initial sync of /etc/hosts from database
+		if err := hosts.Sync(db, *hostsOpts, log); err != nil {
+			log.Warn().Err(err).Msg("Failed to perform initial /etc/hosts sync")
+		}
+	} else {
+		log.Info().Msg("manage_hosts is off, leaving /etc/hosts alone")
 	}
 
 	// Start stale host expiry
@@ -63,46 +94,210 @@ func Run(configPath string) error {
 	if err != nil {
 		return fmt.Errorf("parsing stale threshold: %w", err)
 	}
-	db.RunExpiry(5*time.Second, staleThreshold)
+	purgeThreshold, err := cfg.Node.ParsePurgeThreshold()
+	if err != nil {
+		return fmt.Errorf("parsing purge threshold: %w", err)
+	}
+	db.RunExpiry(5*time.Second, staleThreshold, purgeThreshold)
+
+	flushInterval, err := cfg.Node.ParseDBFlushInterval()
+	if err != nil {
+		return fmt.Errorf("parsing db flush interval: %w", err)
+	}
+	db.SetFlushInterval(flushInterval)
 
 	// Start RPC server (for 'lanmon connect' to query this node)
-	if err := rpc.StartServer(cfg.Node.RPCSocket, db, log); err != nil {
+	// Closed on the way out, however Run returns, so the socket is removed
+	// and connected clients see the node go away.
+	rpcSrv, err := rpc.StartServer(cfg.Node.RPCSocket, nil, db, log)
+	if err != nil {
 		return fmt.Errorf("starting RPC server: %w", err)
 	}
+	defer rpcSrv.Close()
+	if cfg.Node.RPCAddr != "" {
+		a, err := rpcAuth(&cfg.Node)
+		if err != nil {
+			return fmt.Errorf("setting up TCP RPC authentication: %w", err)
+		}
+		tcpSrv, err := rpc.StartServer(cfg.Node.RPCAddr, a, db, log)
+		if err != nil {
+			return fmt.Errorf("starting TCP RPC server: %w", err)
+		}
+		defer tcpSrv.Close()
+	}
+
+	// Start the HTTP API, if configured
+	var api *httpapi.Server
+	if cfg.Node.HTTPAddr != "" {
+		if api, err = startHTTP(&cfg.Node, db, log); err != nil {
+			return fmt.Errorf("starting HTTP API: %w", err)
+		}
+	}
+
+	var metricsSrv *metrics.Server
+	if cfg.Node.MetricsAddr != "" {
+		if metricsSrv, err = metrics.Start(cfg.Node.MetricsAddr, db, log); err != nil {
+			return fmt.Errorf("starting metrics endpoint: %w", err)
+		}
+	}
+
+	maxAge, err := cfg.Node.ParseTimestampMaxAge()
+	if err != nil {
+		return fmt.Errorf("parsing timestamp max age: %w", err)
+	}
 
 	interval, err := cfg.Node.ParseInterval()
 	if err != nil {
 		return fmt.Errorf("parsing interval: %w", err)
 	}
+	jitter, err := cfg.Node.ParseJitter()
+	if err != nil {
+		return fmt.Errorf("parsing jitter: %w", err)
+	}
 
 	log.Info().
 		Str("db_path", cfg.Node.DBPath).
-		Str("network_range", cfg.Node.NetworkRange).
+		Strs("network_ranges", cfg.Node.Ranges()).
 		Msg("Starting LANNode P2P Discovery")
 
-	// Start discovery in a goroutine
+	// Shared with the broadcast loops so a reload can change it
+	sched := discovery.NewSchedule(interval, jitter)
+
+	opts := discovery.Options{
+		NetworkRanges: cfg.Node.Ranges(),
+		Interface:     cfg.Node.Interface,
+		Interfaces:    cfg.Node.Interfaces,
+		Port:          cfg.Node.Port,
+		Schedule:      sched,
+		Secret:        cfg.Node.SharedSecret,
+
+		PreviousSecret:   cfg.Node.SharedSecretPrevious,
+		TimestampMaxAge:  maxAge,
+		IgnoreInterfaces: cfg.Node.IgnoreInterfaces,
+		VerifySourcePort: cfg.Node.VerifySourcePort,
+		MaxPacketsPerMin: cfg.Node.MaxPacketsPerMin,
+		Workers:          cfg.Node.PacketWorkers,
+		EncryptPayload:   cfg.Node.EncryptPayload,
+		CompressPayload:  cfg.Node.CompressPayload,
+		LegacyKey:        cfg.Node.LegacySecretKey,
+		DebugPackets:     cfg.Node.DebugPackets,
+		Tags:             cfg.Node.Tags,
+		Hosts:            hostsOpts,
+
+		ListenAllInterfaces: cfg.Node.ListenAllInterfaces,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Start discovery in a goroutine, restarted if it stops on its own
 	errCh := make(chan error, 1)
 	go func() {
-		errCh <- discovery.StartNode(
-			cfg.Node.NetworkRange,
-			cfg.Node.Port,
-			interval,
-			cfg.Node.SharedSecret,
-			db,
-			log,
-		)
+		errCh <- supervise(ctx, func(ctx context.Context) error {
+			return discovery.StartNode(ctx, opts, db, log)
+		}, db, log)
 	}()
 
-	// Wait for shutdown signal or discovery error
+	// Wait for shutdown signal or discovery error; SIGHUP reloads the config
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	applied := cfg.Node
+
+	for {
+		var sig os.Signal
+		select {
+		case err := <-errCh:
+			return fmt.Errorf("discovery error: %w", err)
+		case sig = <-sigCh:
+		}
+		if sig == syscall.SIGHUP {
+			log.Info().Str("config", configPath).Msg("Reloading config")
+			reload(configPath, &applied, sched, db, log)
+			continue
+		}
 
-	select {
-	case err := <-errCh:
-		return fmt.Errorf("discovery error: %w", err)
-	case sig := <-sigCh:
 		log.Info().Str("signal", sig.String()).Msg("Shutting down")
-		os.Remove(cfg.Node.RPCSocket)
+		// Let discovery send its departure beacon and close the socket
+		cancel()
+		if err := <-errCh; err != nil {
+			log.Warn().Err(err).Msg("Discovery stopped with error")
+		}
+		shutdownCtx, done := context.WithTimeout(context.Background(), 5*time.Second)
+		defer done()
+		if api != nil {
+			if err := api.Shutdown(shutdownCtx); err != nil {
+				log.Warn().Err(err).Msg("HTTP API shutdown incomplete")
+			}
+		}
+		if metricsSrv != nil {
+			metricsSrv.Shutdown(shutdownCtx)
+		}
 		return nil
 	}
 }
+
+// rpcAuth builds the authenticator for TCP RPC: rpc_token grants
+// read-write access, and the tokens of api_auth, if it uses tokens, their
+// roles. It fails if neither is configured, as no client could connect.
+func rpcAuth(n *config.NodeConfig) (auth.Authenticator, error) {
+	var a auth.Any
+	if n.RPCToken != "" {
+		a = append(a, auth.NewStaticToken(n.RPCToken))
+	}
+	if n.APIAuth.UsesTokens() {
+		tokens, err := auth.New(n.APIAuth)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, tokens)
+	}
+	if len(a) == 0 {
+		return nil, fmt.Errorf("node.rpc_addr is set but neither rpc_token nor an api_auth token backend is configured")
+	}
+	return a, nil
+}
+
+// startHTTP starts the HTTP API with the authentication and TLS settings
+// from the node config.
+func startHTTP(n *config.NodeConfig, db *store.Store, log zerolog.Logger) (*httpapi.Server, error) {
+	opts := httpapi.Options{Addr: n.HTTPAddr}
+
+	if n.APIAuth.Enabled() {
+		a, err := auth.New(n.APIAuth)
+		if err != nil {
+			return nil, err
+		}
+		opts.Auth = a
+	} else if !isLoopback(n.HTTPAddr) {
+		log.Warn().Str("addr", n.HTTPAddr).Msg("HTTP API is reachable from the network without api_auth")
+	}
+
+	if n.HTTPTLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(n.HTTPTLSCert, n.HTTPTLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading TLS certificate: %w", err)
+		}
+		opts.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+		if cc, ok := opts.Auth.(*auth.ClientCert); ok {
+			opts.TLS.ClientCAs = cc.ClientCAs()
+			opts.TLS.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	} else if _, ok := opts.Auth.(*auth.ClientCert); ok {
+		return nil, fmt.Errorf("the mtls api_auth backend requires http_tls_cert and http_tls_key")
+	}
+
+	return httpapi.Start(opts, db, log)
+}
+
+// isLoopback reports whether addr binds only to a loopback address.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,25 @@
+package node
+
+import (
+	"testing"
+
+	"lanmon/pkg/config"
+)
+
+func TestRPCAuth(t *testing.T) {
+	n := &config.NodeConfig{RPCAddr: "tcp://127.0.0.1:5679"}
+	if a, err := rpcAuth(n); err == nil {
+		t.Errorf("expected an error without any token source, got %v", a)
+	}
+
+	// Client certificates can't authenticate TCP RPC
+	n.APIAuth = config.APIAuthConfig{Backend: "mtls", ClientCA: "/etc/lanmon/ca.pem"}
+	if a, err := rpcAuth(n); err == nil {
+		t.Errorf("expected an error with only mtls api_auth, got %v", a)
+	}
+
+	n.RPCToken = "s3cret"
+	if _, err := rpcAuth(n); err != nil {
+		t.Errorf("rpc_token: unexpected error %v", err)
+	}
+}
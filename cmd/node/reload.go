@@ -0,0 +1,105 @@
+package node
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/discovery"
+	"lanmon/internal/store"
+	"lanmon/pkg/config"
+	"lanmon/pkg/logger"
+)
+
+// liveSettings are the node settings a reload applies without a restart.
+var liveSettings = map[string]bool{
+	"log_level":       true,
+	"interval":        true,
+	"jitter":          true,
+	"stale_threshold": true,
+	"purge_threshold": true,
+
+	"db_flush_interval": true,
+}
+
+// reload re-reads the config file and applies the live settings. A config
+// that fails to load, parse or validate is rejected as a whole and the
+// current settings are kept. applied is the config last applied, at
+// startup or by an earlier reload; settings other than the live ones that
+// changed since then are logged as needing a restart, so each change is
+// reported once. On success applied becomes the reloaded config.
+func reload(configPath string, applied *config.NodeConfig, sched *discovery.Schedule, db *store.Store, log zerolog.Logger) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Error().Err(err).Msg("Config reload failed, keeping current settings")
+		return
+	}
+	if err := config.Validate(cfg); err != nil {
+		log.Error().Err(err).Msg("Reloaded config is invalid, keeping current settings")
+		return
+	}
+	n := &cfg.Node
+
+	interval, jitter, staleThreshold, purgeThreshold, err := liveDurations(n)
+	if err != nil {
+		log.Error().Err(err).Msg("Config reload failed, keeping current settings")
+		return
+	}
+	flushInterval, err := n.ParseDBFlushInterval()
+	if err != nil {
+		log.Error().Err(err).Msg("Config reload failed, keeping current settings")
+		return
+	}
+
+	logger.SetLevel(n.LogLevel)
+	sched.Set(interval, jitter)
+	db.SetExpiry(staleThreshold, purgeThreshold)
+	db.SetFlushInterval(flushInterval)
+
+	for _, name := range restartRequired(applied, n) {
+		log.Warn().Str("setting", name).Msg("Changed setting requires restart")
+	}
+	*applied = *n
+	log.Info().
+		Str("log_level", n.LogLevel).
+		Dur("interval", interval).
+		Dur("jitter", jitter).
+		Dur("stale_threshold", staleThreshold).
+		Dur("purge_threshold", purgeThreshold).
+		Dur("db_flush_interval", flushInterval).
+		Msg("Config reloaded")
+}
+
+// liveDurations parses the live duration settings.
+func liveDurations(n *config.NodeConfig) (interval, jitter, staleThreshold, purgeThreshold time.Duration, err error) {
+	if interval, err = n.ParseInterval(); err != nil {
+		return
+	}
+	if jitter, err = n.ParseJitter(); err != nil {
+		return
+	}
+	if staleThreshold, err = n.ParseStaleThreshold(); err != nil {
+		return
+	}
+	purgeThreshold, err = n.ParsePurgeThreshold()
+	return
+}
+
+// restartRequired returns the names of the settings, other than the live
+// ones, that differ between the applied and the reloaded config.
+func restartRequired(applied, reloaded *config.NodeConfig) []string {
+	var changed []string
+	a, b := reflect.ValueOf(*applied), reflect.ValueOf(*reloaded)
+	for i := 0; i < a.NumField(); i++ {
+		name, _, _ := strings.Cut(a.Type().Field(i).Tag.Get("toml"), ",")
+		if liveSettings[name] {
+			continue
+		}
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,100 @@
+package node
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/discovery"
+	"lanmon/pkg/config"
+)
+
+func TestRestartRequired(t *testing.T) {
+	running := config.NodeConfig{
+		NetworkRange: "10.0.0.0/24",
+		Port:         5678,
+		Interval:     "30s",
+		LogLevel:     "info",
+		Tags:         []string{"rack1"},
+	}
+
+	reloaded := running
+	reloaded.Interval = "10s"
+	reloaded.LogLevel = "debug"
+	reloaded.StaleThreshold = "2m"
+	if got := restartRequired(&running, &reloaded); len(got) != 0 {
+		t.Errorf("live settings only: got %v, want none", got)
+	}
+
+	reloaded.Port = 5679
+	reloaded.NetworkRange = "10.0.1.0/24"
+	reloaded.Tags = []string{"rack2"}
+	want := []string{"network_range", "port", "tags"}
+	if got := restartRequired(&running, &reloaded); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lanmon.toml")
+	write := func(port int, jitter string) {
+		t.Helper()
+		content := fmt.Sprintf(`[node]
+network_range = "10.0.0.0/24"
+shared_secret = "s3cret-value"
+db_path = %q
+rpc_socket = %q
+port = %d
+interval = "30s"
+jitter = %q
+`, filepath.Join(dir, "hosts.db"), filepath.Join(dir, "rpc.sock"), port, jitter)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(5678, "5s")
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	applied := cfg.Node
+	sched := discovery.NewSchedule(30*time.Second, 5*time.Second)
+	db := testStore(t)
+
+	var logs bytes.Buffer
+	log := zerolog.New(&logs)
+	reloadLogs := func() string {
+		logs.Reset()
+		reload(path, &applied, sched, db, log)
+		return logs.String()
+	}
+
+	// Jitter as long as the interval is rejected as a whole
+	write(5679, "30s")
+	if out := reloadLogs(); !strings.Contains(out, "invalid") || strings.Contains(out, "Config reloaded") {
+		t.Errorf("expected the invalid config rejected, got %s", out)
+	}
+	if applied.Port != 5678 || applied.Jitter != "5s" {
+		t.Errorf("rejected config was applied: %+v", applied)
+	}
+
+	// A restart-only change is reported once, not on every reload
+	write(5679, "10s")
+	if out := reloadLogs(); strings.Count(out, "requires restart") != 1 || !strings.Contains(out, `"setting":"port"`) {
+		t.Errorf("first reload: expected one port warning, got %s", out)
+	}
+	if out := reloadLogs(); strings.Contains(out, "requires restart") {
+		t.Errorf("unchanged reload repeated the warning: %s", out)
+	}
+	if applied.Jitter != "10s" {
+		t.Errorf("applied jitter: got %s, want 10s", applied.Jitter)
+	}
+}
@@ -0,0 +1,39 @@
+package node
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	toml "github.com/pelletier/go-toml/v2"
+
+	"lanmon/pkg/config"
+)
+
+// ShowConfig loads the configuration file and prints it as TOML as it will
+// be used: with defaults applied, paths expanded, and secrets redacted.
+func ShowConfig(path string) error {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	fmt.Printf("# Effective configuration from %s\n\n", path)
+	return showConfig(os.Stdout, cfg)
+}
+
+// showConfig writes cfg, redacted, to w as TOML. Settings read through an
+// accessor are filled in with the value it returns, so their defaults show.
+func showConfig(w io.Writer, cfg *config.Config) error {
+	shown := cfg.Redacted()
+	manageHosts := shown.Node.HostsManaged()
+	shown.Node.ManageHosts = &manageHosts
+	retries := shown.Connect.Retries()
+	shown.Connect.PushRetries = &retries
+
+	data, err := toml.Marshal(shown)
+	if err != nil {
+		return fmt.Errorf("encoding config: %w", err)
+	}
+	_, err = w.Write(data)
+	return err
+}
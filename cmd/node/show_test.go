@@ -0,0 +1,34 @@
+package node
+
+import (
+	"strings"
+	"testing"
+
+	"lanmon/pkg/config"
+)
+
+func TestShowConfig(t *testing.T) {
+	cfg := &config.Config{Node: config.NodeConfig{
+		Port:         5678,
+		SharedSecret: "super-secret-value",
+		DBPath:       "/var/lib/lanmon/hosts.db",
+	}}
+
+	var out strings.Builder
+	if err := showConfig(&out, cfg); err != nil {
+		t.Fatalf("showConfig: %v", err)
+	}
+	got := out.String()
+
+	if strings.Contains(got, "super-secret-value") {
+		t.Errorf("expected the secret to be redacted:\n%s", got)
+	}
+	for _, want := range []string{
+		"shared_secret = '***'", "port = 5678", "db_path = '/var/lib/lanmon/hosts.db'",
+		"manage_hosts = true", "push_retries = 3",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in:\n%s", want, got)
+		}
+	}
+}
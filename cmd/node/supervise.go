@@ -0,0 +1,66 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/discovery"
+	"lanmon/internal/metrics"
+	"lanmon/internal/store"
+)
+
+// Restart backoff for discovery: the delay doubles from minRestartDelay up
+// to maxRestartDelay, and starts over once a run has lasted healthyRun.
+// Variables so tests can shorten them.
+var (
+	minRestartDelay = time.Second
+	maxRestartDelay = time.Minute
+	healthyRun      = 5 * time.Minute
+)
+
+// supervise runs discovery with start until ctx is cancelled, restarting
+// it with backoff whenever it stops on its own. Each restart is logged and
+// counted in the store's stats. A first run that fails to start at all is
+// a setup problem, such as no interface in the network range, and its
+// error is returned rather than retried.
+func supervise(ctx context.Context, start func(context.Context) error, db *store.Store, log zerolog.Logger) error {
+	delay := minRestartDelay
+	for first := true; ; first = false {
+		began := time.Now()
+		err := runDiscovery(ctx, start)
+		if ctx.Err() != nil {
+			return err
+		}
+		if first && !errors.Is(err, discovery.ErrStopped) {
+			return err
+		}
+
+		if time.Since(began) >= healthyRun {
+			delay = minRestartDelay
+		}
+		log.Error().Err(err).Dur("retry_in", delay).Msg("Discovery stopped, restarting")
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxRestartDelay)
+
+		db.NoteDiscoveryRestart()
+		metrics.DiscoveryRestarts.Inc()
+	}
+}
+
+// runDiscovery calls start, turning a panic into an error.
+func runDiscovery(ctx context.Context, start func(context.Context) error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("%w: panic: %v", discovery.ErrStopped, v)
+		}
+	}()
+	return start(ctx)
+}
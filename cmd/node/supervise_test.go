@@ -0,0 +1,84 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/discovery"
+	"lanmon/internal/store"
+)
+
+func testStore(t *testing.T) *store.Store {
+	t.Helper()
+	db, err := store.New(filepath.Join(t.TempDir(), "test.db"), zerolog.Nop())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func shortBackoff(t *testing.T) {
+	oldMin, oldMax := minRestartDelay, maxRestartDelay
+	minRestartDelay, maxRestartDelay = time.Millisecond, 4*time.Millisecond
+	t.Cleanup(func() { minRestartDelay, maxRestartDelay = oldMin, oldMax })
+}
+
+func TestSupervise_Restarts(t *testing.T) {
+	shortBackoff(t)
+	db := testStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Stops twice, panics once, then runs until cancelled
+	runs := 0
+	start := func(ctx context.Context) error {
+		runs++
+		switch runs {
+		case 1, 2:
+			return discovery.ErrStopped
+		case 3:
+			panic("bad packet")
+		}
+		cancel()
+		<-ctx.Done()
+		return nil
+	}
+
+	if err := supervise(ctx, start, db, zerolog.Nop()); err != nil {
+		t.Fatalf("supervise: %v", err)
+	}
+	if runs != 4 {
+		t.Errorf("runs: got %d, want 4", runs)
+	}
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if stats.DiscoveryRestarts != 3 {
+		t.Errorf("restarts: got %d, want 3", stats.DiscoveryRestarts)
+	}
+}
+
+func TestSupervise_SetupErrorIsFatal(t *testing.T) {
+	shortBackoff(t)
+	setup := errors.New("no interface in range")
+
+	runs := 0
+	start := func(ctx context.Context) error {
+		runs++
+		return setup
+	}
+
+	if err := supervise(context.Background(), start, testStore(t), zerolog.Nop()); !errors.Is(err, setup) {
+		t.Errorf("expected the setup error, got %v", err)
+	}
+	if runs != 1 {
+		t.Errorf("runs: got %d, want 1", runs)
+	}
+}
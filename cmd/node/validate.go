@@ -0,0 +1,34 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"lanmon/pkg/config"
+)
+
+// ValidateConfig loads the configuration file and reports every problem
+// found, one per line. It returns an error if the file can't be loaded or
+// any check fails.
+func ValidateConfig(path string) error {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	err = config.Validate(cfg)
+	if err == nil {
+		fmt.Println("config OK")
+		return nil
+	}
+
+	problems := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		problems = joined.Unwrap()
+	}
+	for _, p := range problems {
+		fmt.Fprintln(os.Stderr, p)
+	}
+	return errors.New("config is invalid")
+}
@@ -0,0 +1,96 @@
+// Package ping implements the lanmon ping CLI, which checks that beacons get
+// between this host and a node in both directions.
+package ping
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"lanmon/internal/beacon"
+	"lanmon/internal/discovery"
+	"lanmon/pkg/config"
+)
+
+// defaultTimeout is how long to wait for an answer when --timeout isn't
+// given.
+const defaultTimeout = 2 * time.Second
+
+// Run sends a probe beacon to the node at the host named in args and
+// reports whether its answer passes the HMAC and timestamp checks. It
+// returns an error if no answer arrives or the answer fails a check.
+func Run(configPath string, args []string) error {
+	fs := flag.NewFlagSet("ping", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", defaultTimeout, "how long to wait for an answer")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: lanmon ping [--timeout D] <ip|hostname>")
+	}
+	host := fs.Arg(0)
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	maxAge, err := cfg.Node.ParseTimestampMaxAge()
+	if err != nil {
+		return fmt.Errorf("parsing timestamp max age: %w", err)
+	}
+
+	opts := discovery.Options{
+		NetworkRanges:    cfg.Node.Ranges(),
+		Interface:        cfg.Node.Interface,
+		Interfaces:       cfg.Node.Interfaces,
+		IgnoreInterfaces: cfg.Node.IgnoreInterfaces,
+		Port:             cfg.Node.Port,
+		Secret:           cfg.Node.SharedSecret,
+		PreviousSecret:   cfg.Node.SharedSecretPrevious,
+		TimestampMaxAge:  maxAge,
+		Tags:             cfg.Node.Tags,
+		EncryptPayload:   cfg.Node.EncryptPayload,
+		CompressPayload:  cfg.Node.CompressPayload,
+		LegacyKey:        cfg.Node.LegacySecretKey,
+	}
+
+	fmt.Printf("Probing %s on UDP port %d...\n", host, opts.Port)
+	res, err := discovery.Ping(host, opts, *timeout)
+	if errors.Is(err, discovery.ErrNoAnswer) {
+		return fmt.Errorf("%w\nIs 'lanmon node' running there? It doesn't answer probes that fail its\n"+
+			"HMAC or timestamp checks (shared_secret, legacy_secret_key, clock skew) or,\n"+
+			"with verify_source_port on, any probe at all; its log says which", err)
+	}
+	if err != nil {
+		return err
+	}
+	return report(os.Stdout, res)
+}
+
+// report prints res and returns an error if the answer failed a check.
+func report(w io.Writer, res *discovery.PingResult) error {
+	fmt.Fprintf(w, "Answer from %s in %s\n", res.From, res.RTT.Round(time.Microsecond))
+	fmt.Fprintln(w, "  probe:     accepted by the node (HMAC and timestamp ok there)")
+
+	switch {
+	case errors.Is(res.Err, beacon.ErrHMAC):
+		fmt.Fprintln(w, "  HMAC:      FAILED, the node's key doesn't match ours")
+		return fmt.Errorf("answer from %s: %w", res.From, res.Err)
+	case res.Payload == nil:
+		fmt.Fprintf(w, "  decode:    FAILED, %v\n", res.Err)
+		return fmt.Errorf("answer from %s: %w", res.From, res.Err)
+	}
+
+	p := res.Payload
+	fmt.Fprintf(w, "  node:      %s (%s, %s), payload v%d\n", p.Hostname, p.IPAddress, p.MACAddress, p.Version)
+	fmt.Fprintln(w, "  HMAC:      ok")
+	if res.Err != nil {
+		fmt.Fprintf(w, "  timestamp: FAILED, node clock %s ahead of ours\n", res.Skew)
+		return fmt.Errorf("answer from %s: %w", res.From, res.Err)
+	}
+	fmt.Fprintf(w, "  timestamp: ok, node clock %s ahead of ours\n", res.Skew)
+	return nil
+}
@@ -9,7 +9,9 @@ import (
 	"syscall"
 	"time"
 
+	"lanmon/internal/beacon"
 	"lanmon/internal/listener"
+	"lanmon/internal/metrics"
 	"lanmon/internal/rpc"
 	"lanmon/internal/store"
 	"lanmon/pkg/config"
@@ -23,7 +25,7 @@ func Run(configPath string) error {
 		return fmt.Errorf("loading config: %w", err)
 	}
 
-	log := logger.Init(cfg.Node.LogLevel)
+	log := logger.Init(cfg.Node.LogLevel, cfg.Node.LogFormat, cfg.Node.LogOutput)
 
 	if cfg.Node.SharedSecret == "" || cfg.Node.SharedSecret == "CHANGE_ME" {
 		return fmt.Errorf("shared_secret must be set in config (not 'CHANGE_ME')")
@@ -53,12 +55,34 @@ func Run(configPath string) error {
 	if err != nil {
 		return fmt.Errorf("parsing stale threshold: %w", err)
 	}
-	db.RunExpiry(5*time.Minute, staleThreshold)
+	purgeThreshold, err := cfg.Node.ParsePurgeThreshold()
+	if err != nil {
+		return fmt.Errorf("parsing purge threshold: %w", err)
+	}
+	maxAge, err := cfg.Node.ParseTimestampMaxAge()
+	if err != nil {
+		return fmt.Errorf("parsing timestamp max age: %w", err)
+	}
+	db.RunExpiry(5*time.Minute, staleThreshold, purgeThreshold)
+
+	flushInterval, err := cfg.Node.ParseDBFlushInterval()
+	if err != nil {
+		return fmt.Errorf("parsing db flush interval: %w", err)
+	}
+	db.SetFlushInterval(flushInterval)
 
 	// Start RPC server
-	if err := rpc.StartServer(cfg.Node.RPCSocket, db, log); err != nil {
+	rpcSrv, err := rpc.StartServer(cfg.Node.RPCSocket, nil, db, log)
+	if err != nil {
 		return fmt.Errorf("starting RPC server: %w", err)
 	}
+	defer rpcSrv.Close()
+
+	if cfg.Node.MetricsAddr != "" {
+		if _, err := metrics.Start(cfg.Node.MetricsAddr, db, log); err != nil {
+			return fmt.Errorf("starting metrics endpoint: %w", err)
+		}
+	}
 
 	log.Info().
 		Str("db_path", cfg.Node.DBPath).
@@ -66,20 +90,32 @@ func Run(configPath string) error {
 		Dur("stale_threshold", staleThreshold).
 		Msg("Starting legacy LANListener server (deprecated)")
 
+	if cfg.Node.VerifySourcePort {
+		log.Warn().Msg("verify_source_port is ignored by the legacy server: agents send from ephemeral ports")
+	}
+	if cfg.Node.DebugPackets {
+		log.Warn().Msg("debug_packets is ignored by the legacy server")
+	}
+
 	// Start listener in a goroutine so we can handle signals
 	errCh := make(chan error, 1)
 	go func() {
 		errCh <- listener.StartListener(
-			"",
+			cfg.Node.Interface,
 			"239.255.0.1",
 			cfg.Node.Port,
 			cfg.Node.SharedSecret,
+			&beacon.PacketOptions{
+				PreviousSecret: cfg.Node.SharedSecretPrevious,
+				LegacyKey:      cfg.Node.LegacySecretKey,
+			},
+			maxAge,
+			cfg.Node.PacketWorkers,
 			db,
 			log,
 		)
 	}()
 
-
 	// Wait for shutdown signal or listener error
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
@@ -89,8 +125,6 @@ func Run(configPath string) error {
 		return fmt.Errorf("listener error: %w", err)
 	case sig := <-sigCh:
 		log.Info().Str("signal", sig.String()).Msg("Shutting down")
-		// Cleanup: remove RPC socket
-		os.Remove(cfg.Node.RPCSocket)
 		return nil
 	}
 }
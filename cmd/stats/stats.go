@@ -0,0 +1,65 @@
+// Package stats implements the lanmon stats CLI (host and beacon totals).
+package stats
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/render"
+	"lanmon/internal/rpc"
+	"lanmon/internal/store"
+	"lanmon/pkg/config"
+)
+
+// Run prints aggregate statistics from the local node. Beacon counts are
+// kept in memory by the node and start again from zero when it restarts.
+// If the node can't be reached, the host counts are read from its
+// database instead.
+func Run(configPath string) error {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	client, err := rpc.NewClient(cfg.Connect.RPCAddress(), cfg.Connect.RPCToken)
+	if err != nil {
+		if dbErr := fromDatabase(cfg.Node.DBPath); dbErr != nil {
+			return fmt.Errorf("connecting to server: %w\nIs 'lanmon node' running?", err)
+		}
+		return nil
+	}
+	defer client.Close()
+
+	reply, err := client.Stats()
+	if err != nil {
+		return fmt.Errorf("fetching stats: %w", err)
+	}
+
+	fmt.Printf("\n  Node Statistics (lanmon v%s, commit %s, built %s)\n\n",
+		reply.Version, reply.Commit, reply.BuildDate)
+	render.StatsTable(os.Stdout, reply.Stats)
+	fmt.Println()
+	return nil
+}
+
+// fromDatabase prints the host counts stored in the database at path,
+// opened read-only. Beacon counts only exist in a running node.
+func fromDatabase(path string) error {
+	db, err := store.NewReadOnly(path, zerolog.Nop())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	stats, err := db.Stats()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n  Host Statistics (node not reachable, read from %s)\n\n", path)
+	render.StatsTable(os.Stdout, stats)
+	fmt.Println()
+	return nil
+}
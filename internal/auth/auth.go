@@ -0,0 +1,262 @@
+// Package auth authenticates callers of lanmon's remote APIs and maps them
+// to read-only or read-write access.
+package auth
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"lanmon/pkg/config"
+)
+
+var (
+	// ErrUnauthenticated is returned when credentials are missing or unknown.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrForbidden is returned when a caller's role doesn't allow an operation.
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Role is the access level granted to an authenticated caller.
+type Role int
+
+const (
+	// RoleReadOnly may call methods that only read host data.
+	RoleReadOnly Role = iota + 1
+	// RoleReadWrite may additionally call methods that modify host data.
+	RoleReadWrite
+)
+
+// ParseRole parses a role name: "read"/"ro" or "write"/"rw".
+func ParseRole(s string) (Role, error) {
+	switch strings.ToLower(s) {
+	case "read", "ro", "read-only":
+		return RoleReadOnly, nil
+	case "write", "rw", "read-write":
+		return RoleReadWrite, nil
+	}
+	return 0, fmt.Errorf("unknown role %q (want read or write)", s)
+}
+
+// Access is what an API method requires of its caller.
+type Access int
+
+const (
+	// Read is required by methods that only return host data.
+	Read Access = iota
+	// Write is required by methods that modify host data.
+	Write
+)
+
+// Allows reports whether the role grants the given access.
+func (r Role) Allows(a Access) bool {
+	switch a {
+	case Read:
+		return r == RoleReadOnly || r == RoleReadWrite
+	case Write:
+		return r == RoleReadWrite
+	}
+	return false
+}
+
+// Credentials are what a transport extracted from a request.
+type Credentials struct {
+	// Token is a bearer token, if one was presented.
+	Token string
+	// PeerCertificates is the client's TLS certificate chain, leaf first.
+	PeerCertificates []*x509.Certificate
+}
+
+// Authenticator resolves the role of a caller from its credentials.
+type Authenticator interface {
+	Authenticate(c Credentials) (Role, error)
+}
+
+// Authorize authenticates the caller and checks it may perform an
+// operation requiring the given access.
+func Authorize(a Authenticator, c Credentials, need Access) error {
+	role, err := a.Authenticate(c)
+	if err != nil {
+		return err
+	}
+	if !role.Allows(need) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Any accepts a caller if any of its authenticators does, with the role
+// granted by the first that does.
+type Any []Authenticator
+
+// Authenticate implements Authenticator.
+func (a Any) Authenticate(c Credentials) (Role, error) {
+	for _, x := range a {
+		if role, err := x.Authenticate(c); err == nil {
+			return role, nil
+		}
+	}
+	return 0, ErrUnauthenticated
+}
+
+// New builds the authenticator selected by the api_auth config.
+func New(cfg config.APIAuthConfig) (Authenticator, error) {
+	switch cfg.Backend {
+	case "", "token":
+		if cfg.Token == "" {
+			return nil, fmt.Errorf("api_auth: token must be set for the token backend")
+		}
+		return NewStaticToken(cfg.Token), nil
+	case "tokens_file":
+		return LoadTokensFile(cfg.TokensFile)
+	case "mtls":
+		return NewClientCert(cfg.ClientCA, cfg.ClientRoles)
+	}
+	return nil, fmt.Errorf("api_auth: unknown backend %q (want token, tokens_file or mtls)", cfg.Backend)
+}
+
+// StaticToken grants read-write access to holders of a single shared token.
+type StaticToken struct {
+	token []byte
+}
+
+// NewStaticToken returns an authenticator for a single shared token.
+func NewStaticToken(token string) *StaticToken {
+	return &StaticToken{token: []byte(token)}
+}
+
+// Authenticate implements Authenticator.
+func (s *StaticToken) Authenticate(c Credentials) (Role, error) {
+	if c.Token == "" || subtle.ConstantTimeCompare([]byte(c.Token), s.token) != 1 {
+		return 0, ErrUnauthenticated
+	}
+	return RoleReadWrite, nil
+}
+
+// TokensFile maps per-user tokens to roles.
+type TokensFile struct {
+	tokens map[string]Role
+}
+
+// LoadTokensFile reads a tokens file with one "<token> <role>" pair per
+// line. Blank lines and lines starting with # are ignored.
+func LoadTokensFile(path string) (*TokensFile, error) {
+	if path == "" {
+		return nil, fmt.Errorf("api_auth: tokens_file must be set for the tokens_file backend")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening tokens file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	tokens := make(map[string]Role)
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want \"<token> <role>\"", path, lineNo)
+		}
+		role, err := ParseRole(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		tokens[fields[0]] = role
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading tokens file %s: %w", path, err)
+	}
+	return &TokensFile{tokens: tokens}, nil
+}
+
+// Authenticate implements Authenticator.
+func (t *TokensFile) Authenticate(c Credentials) (Role, error) {
+	if c.Token == "" {
+		return 0, ErrUnauthenticated
+	}
+	// Compare against every entry so timing doesn't reveal which matched
+	var role Role
+	for token, r := range t.tokens {
+		if subtle.ConstantTimeCompare([]byte(c.Token), []byte(token)) == 1 {
+			role = r
+		}
+	}
+	if role == 0 {
+		return 0, ErrUnauthenticated
+	}
+	return role, nil
+}
+
+// ClientCert authenticates TLS clients by certificate. The chain must
+// verify against the configured CA, and the leaf's common name selects
+// the role.
+type ClientCert struct {
+	roots *x509.CertPool
+	roles map[string]Role
+}
+
+// NewClientCert loads the client CA bundle and the common name → role map.
+func NewClientCert(caPath string, roles map[string]string) (*ClientCert, error) {
+	if caPath == "" {
+		return nil, fmt.Errorf("api_auth: client_ca must be set for the mtls backend")
+	}
+	pem, err := os.ReadFile(caPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CA %s: %w", caPath, err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in client CA %s", caPath)
+	}
+
+	parsed := make(map[string]Role, len(roles))
+	for cn, name := range roles {
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, fmt.Errorf("api_auth: client_roles[%q]: %w", cn, err)
+		}
+		parsed[cn] = role
+	}
+	return &ClientCert{roots: roots, roles: parsed}, nil
+}
+
+// ClientCAs returns the pool a TLS server should verify clients against.
+func (c *ClientCert) ClientCAs() *x509.CertPool {
+	return c.roots
+}
+
+// Authenticate implements Authenticator.
+func (c *ClientCert) Authenticate(cred Credentials) (Role, error) {
+	if len(cred.PeerCertificates) == 0 {
+		return 0, ErrUnauthenticated
+	}
+
+	leaf := cred.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range cred.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         c.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+
+	role, ok := c.roles[leaf.Subject.CommonName]
+	if !ok {
+		return 0, fmt.Errorf("%w: no role for client %q", ErrUnauthenticated, leaf.Subject.CommonName)
+	}
+	return role, nil
+}
@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"lanmon/pkg/config"
+)
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role Role
+		need Access
+		want bool
+	}{
+		{RoleReadOnly, Read, true},
+		{RoleReadOnly, Write, false},
+		{RoleReadWrite, Read, true},
+		{RoleReadWrite, Write, true},
+		{0, Read, false},
+	}
+	for _, tt := range tests {
+		if got := tt.role.Allows(tt.need); got != tt.want {
+			t.Errorf("Role(%d).Allows(%d): got %v, want %v", tt.role, tt.need, got, tt.want)
+		}
+	}
+}
+
+func TestStaticToken(t *testing.T) {
+	a := NewStaticToken("s3cret")
+
+	if role, err := a.Authenticate(Credentials{Token: "s3cret"}); err != nil || role != RoleReadWrite {
+		t.Errorf("valid token: got role %d, err %v", role, err)
+	}
+	if _, err := a.Authenticate(Credentials{Token: "wrong"}); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("wrong token: expected ErrUnauthenticated, got %v", err)
+	}
+	if _, err := a.Authenticate(Credentials{}); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("missing token: expected ErrUnauthenticated, got %v", err)
+	}
+}
+
+func TestAny(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(path, []byte("bob-token read\n"), 0600); err != nil {
+		t.Fatalf("write tokens file: %v", err)
+	}
+	tokens, err := LoadTokensFile(path)
+	if err != nil {
+		t.Fatalf("load tokens file: %v", err)
+	}
+	a := Any{NewStaticToken("s3cret"), tokens}
+
+	if role, err := a.Authenticate(Credentials{Token: "s3cret"}); err != nil || role != RoleReadWrite {
+		t.Errorf("static token: got role %d, err %v", role, err)
+	}
+	if role, err := a.Authenticate(Credentials{Token: "bob-token"}); err != nil || role != RoleReadOnly {
+		t.Errorf("bob: got role %d, err %v", role, err)
+	}
+	if _, err := a.Authenticate(Credentials{Token: "mallory"}); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("unknown token: expected ErrUnauthenticated, got %v", err)
+	}
+	if _, err := (Any{}).Authenticate(Credentials{Token: "s3cret"}); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("no authenticators: expected ErrUnauthenticated, got %v", err)
+	}
+}
+
+func TestTokensFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	content := "# team tokens\nalice-token write\n\nbob-token read\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("write tokens file: %v", err)
+	}
+
+	a, err := LoadTokensFile(path)
+	if err != nil {
+		t.Fatalf("load tokens file: %v", err)
+	}
+
+	if role, err := a.Authenticate(Credentials{Token: "alice-token"}); err != nil || role != RoleReadWrite {
+		t.Errorf("alice: got role %d, err %v", role, err)
+	}
+	if role, err := a.Authenticate(Credentials{Token: "bob-token"}); err != nil || role != RoleReadOnly {
+		t.Errorf("bob: got role %d, err %v", role, err)
+	}
+	if _, err := a.Authenticate(Credentials{Token: "mallory"}); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("unknown token: expected ErrUnauthenticated, got %v", err)
+	}
+}
+
+func TestTokensFile_Invalid(t *testing.T) {
+	dir := t.TempDir()
+
+	badRole := filepath.Join(dir, "bad-role")
+	os.WriteFile(badRole, []byte("token admin\n"), 0600)
+	if _, err := LoadTokensFile(badRole); err == nil {
+		t.Error("expected error for unknown role")
+	}
+
+	badLine := filepath.Join(dir, "bad-line")
+	os.WriteFile(badLine, []byte("just-a-token\n"), 0600)
+	if _, err := LoadTokensFile(badLine); err == nil {
+		t.Error("expected error for malformed line")
+	}
+
+	if _, err := LoadTokensFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+// testCA creates a CA and returns it with its key and a PEM file path.
+func testCA(t *testing.T, dir string) (*x509.Certificate, *ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate CA key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "lanmon test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	path := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("write CA: %v", err)
+	}
+	return cert, key, path
+}
+
+func testClientCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, cn string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate client key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create client cert: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert
+}
+
+func TestClientCert(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, caPath := testCA(t, dir)
+
+	a, err := NewClientCert(caPath, map[string]string{"alice": "write", "bob": "read"})
+	if err != nil {
+		t.Fatalf("new client cert auth: %v", err)
+	}
+
+	alice := testClientCert(t, ca, caKey, "alice")
+	if role, err := a.Authenticate(Credentials{PeerCertificates: []*x509.Certificate{alice}}); err != nil || role != RoleReadWrite {
+		t.Errorf("alice: got role %d, err %v", role, err)
+	}
+
+	bob := testClientCert(t, ca, caKey, "bob")
+	if role, err := a.Authenticate(Credentials{PeerCertificates: []*x509.Certificate{bob}}); err != nil || role != RoleReadOnly {
+		t.Errorf("bob: got role %d, err %v", role, err)
+	}
+
+	// Valid chain but no role mapping
+	carol := testClientCert(t, ca, caKey, "carol")
+	if _, err := a.Authenticate(Credentials{PeerCertificates: []*x509.Certificate{carol}}); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("carol: expected ErrUnauthenticated, got %v", err)
+	}
+
+	// Certificate from a different CA
+	otherCA, otherKey, _ := testCA(t, t.TempDir())
+	forged := testClientCert(t, otherCA, otherKey, "alice")
+	if _, err := a.Authenticate(Credentials{PeerCertificates: []*x509.Certificate{forged}}); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("foreign CA: expected ErrUnauthenticated, got %v", err)
+	}
+
+	if _, err := a.Authenticate(Credentials{}); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("no certificate: expected ErrUnauthenticated, got %v", err)
+	}
+}
+
+func TestAuthorize_RoleEnforcement(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	os.WriteFile(path, []byte("rw-token write\nro-token read\n"), 0600)
+	a, err := LoadTokensFile(path)
+	if err != nil {
+		t.Fatalf("load tokens file: %v", err)
+	}
+
+	if err := Authorize(a, Credentials{Token: "ro-token"}, Read); err != nil {
+		t.Errorf("read-only reading: unexpected error %v", err)
+	}
+	if err := Authorize(a, Credentials{Token: "ro-token"}, Write); !errors.Is(err, ErrForbidden) {
+		t.Errorf("read-only writing: expected ErrForbidden, got %v", err)
+	}
+	if err := Authorize(a, Credentials{Token: "rw-token"}, Write); err != nil {
+		t.Errorf("read-write writing: unexpected error %v", err)
+	}
+	if err := Authorize(a, Credentials{Token: "nope"}, Read); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("unknown token: expected ErrUnauthenticated, got %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(config.APIAuthConfig{Token: "abc"}); err != nil {
+		t.Errorf("default token backend: %v", err)
+	}
+	if _, err := New(config.APIAuthConfig{Backend: "token"}); err == nil {
+		t.Error("expected error for token backend without a token")
+	}
+	if _, err := New(config.APIAuthConfig{Backend: "ldap"}); err == nil {
+		t.Error("expected error for unknown backend")
+	}
+}
@@ -6,14 +6,15 @@ import (
 	"time"
 
 	"github.com/rs/zerolog"
-	"github.com/vmihailenco/msgpack/v5"
 	"golang.org/x/net/ipv4"
 
 	"lanmon/internal/sysinfo"
 )
 
-// StartBeacon begins the periodic beacon broadcast loop.
-func StartBeacon(ifaceName, multicastGroup string, serverAddress string, port int, interval time.Duration, sharedSecret string, log zerolog.Logger) error {
+// StartBeacon begins the periodic beacon broadcast loop. ttl is the
+// multicast TTL: 1 keeps beacons on the local subnet, larger values let
+// them cross multicast routers.
+func StartBeacon(ifaceName, multicastGroup string, serverAddress string, port int, interval time.Duration, ttl int, sharedSecret string, log zerolog.Logger) error {
 	var addrs []*net.UDPAddr
 
 	// Resolve multicast address
@@ -41,19 +42,19 @@ func StartBeacon(ifaceName, multicastGroup string, serverAddress string, port in
 	}
 	defer conn.Close()
 
+	// ipv4.PacketConn is used for multicast control
+	pc := ipv4.NewPacketConn(conn)
 	if ifaceName != "" {
 		iface, err := net.InterfaceByName(ifaceName)
 		if err != nil {
 			return fmt.Errorf("finding interface %s: %w", ifaceName, err)
 		}
-		// ipv4.PacketConn is used for multicast control
-		pc := ipv4.NewPacketConn(conn)
 		if err := pc.SetMulticastInterface(iface); err != nil {
 			log.Warn().Err(err).Msg("Failed to set multicast interface")
 		}
-		if err := pc.SetMulticastTTL(1); err != nil {
-			log.Warn().Err(err).Msg("Failed to set multicast TTL")
-		}
+	}
+	if err := pc.SetMulticastTTL(ttl); err != nil {
+		log.Warn().Err(err).Msg("Failed to set multicast TTL")
 	}
 
 	if err := conn.SetWriteBuffer(4096); err != nil {
@@ -64,6 +65,7 @@ func StartBeacon(ifaceName, multicastGroup string, serverAddress string, port in
 		Str("interface", ifaceName).
 		Str("multicast_group", multicastGroup).
 		Int("port", port).
+		Int("multicast_ttl", ttl).
 		Dur("interval", interval).
 		Msg("Beacon started")
 
@@ -71,9 +73,10 @@ func StartBeacon(ifaceName, multicastGroup string, serverAddress string, port in
 	defer ticker.Stop()
 
 	// Helper to send to all targets
+	collector := sysinfo.NewCachedCollector(10 * time.Minute)
 	broadcast := func() {
 		for _, a := range addrs {
-			if err := sendBeacon(conn, a, sharedSecret, log); err != nil {
+			if err := sendBeacon(conn, a, collector, sharedSecret, log); err != nil {
 				log.Error().Err(err).Str("target", a.String()).Msg("Failed to send beacon")
 			}
 		}
@@ -87,8 +90,8 @@ func StartBeacon(ifaceName, multicastGroup string, serverAddress string, port in
 	return nil
 }
 
-func sendBeacon(conn *net.UDPConn, addr *net.UDPAddr, secret string, log zerolog.Logger) error {
-	info, err := sysinfo.Collect("")
+func sendBeacon(conn *net.UDPConn, addr *net.UDPAddr, collector *sysinfo.CachedCollector, secret string, log zerolog.Logger) error {
+	info, err := collector.Collect("", nil)
 	if err != nil {
 		return fmt.Errorf("collecting system info: %w", err)
 	}
@@ -110,15 +113,14 @@ func sendBeacon(conn *net.UDPConn, addr *net.UDPAddr, secret string, log zerolog
 			MemoryGB:  info.MemoryGB,
 			DiskCount: info.DiskCount,
 		},
+		Nonce: NewNonce(),
 	}
 
-	data, err := msgpack.Marshal(payload)
+	packet, dropped, err := EncodeFitting(payload, secret, nil)
 	if err != nil {
-		return fmt.Errorf("marshaling payload: %w", err)
+		return err
 	}
-
-	hmacSig := ComputeHMAC(data, secret)
-	packet := append(hmacSig, data...)
+	WarnPacketSize(log, packet, dropped)
 
 	_, err = conn.WriteToUDP(packet, addr)
 	if err != nil {
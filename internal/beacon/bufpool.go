@@ -0,0 +1,40 @@
+package beacon
+
+import "sync"
+
+// BufferPool recycles fixed-size packet buffers so receive loops don't
+// allocate a fresh copy for every datagram.
+//
+// Ownership: a buffer obtained from Get belongs to the caller until it is
+// passed to Put. A read loop hands the buffer to exactly one handler
+// goroutine, which must Put it back when done and must not keep any slice
+// of it afterwards (DecodePacket copies everything it returns).
+type BufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+// NewBufferPool returns a pool of buffers of the given size.
+func NewBufferPool(size int) *BufferPool {
+	p := &BufferPool{size: size}
+	p.pool.New = func() any {
+		buf := make([]byte, size)
+		return &buf
+	}
+	return p
+}
+
+// Get returns a buffer of the pool's full size.
+func (p *BufferPool) Get() *[]byte {
+	buf := p.pool.Get().(*[]byte)
+	*buf = (*buf)[:p.size]
+	return buf
+}
+
+// Put returns a buffer to the pool. Buffers of the wrong size are dropped.
+func (p *BufferPool) Put(buf *[]byte) {
+	if cap(*buf) != p.size {
+		return
+	}
+	p.pool.Put(buf)
+}
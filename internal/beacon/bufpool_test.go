@@ -0,0 +1,65 @@
+package beacon
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestBufferPool_GetPut(t *testing.T) {
+	p := NewBufferPool(4096)
+
+	buf := p.Get()
+	if len(*buf) != 4096 {
+		t.Fatalf("buffer length: got %d, want 4096", len(*buf))
+	}
+
+	// A shortened buffer comes back at full size
+	*buf = (*buf)[:10]
+	p.Put(buf)
+	if got := p.Get(); len(*got) != 4096 {
+		t.Errorf("recycled buffer length: got %d, want 4096", len(*got))
+	}
+
+	// Foreign buffers are not accepted into the pool
+	foreign := make([]byte, 16)
+	p.Put(&foreign)
+}
+
+// The benchmarks mimic a read loop handing each packet to a goroutine,
+// comparing a per-packet copy with a pooled buffer hand-off.
+
+var benchPacket = make([]byte, 512)
+
+func BenchmarkReceive_CopyPerPacket(b *testing.B) {
+	readBuf := make([]byte, 4096)
+	var wg sync.WaitGroup
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		n := copy(readBuf, benchPacket)
+		packet := make([]byte, n)
+		copy(packet, readBuf[:n])
+		wg.Add(1)
+		go func(p []byte) {
+			defer wg.Done()
+			_ = p[0]
+		}(packet)
+	}
+	wg.Wait()
+}
+
+func BenchmarkReceive_Pooled(b *testing.B) {
+	pool := NewBufferPool(4096)
+	var wg sync.WaitGroup
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf := pool.Get()
+		n := copy(*buf, benchPacket)
+		wg.Add(1)
+		go func(buf *[]byte, n int) {
+			defer wg.Done()
+			defer pool.Put(buf)
+			_ = (*buf)[:n][0]
+		}(buf, n)
+	}
+	wg.Wait()
+}
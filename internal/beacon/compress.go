@@ -0,0 +1,51 @@
+package beacon
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+)
+
+// frameCompressed marks a gzip-compressed body. Like frameEncrypted it
+// can't start a msgpack-encoded payload. In an encrypted packet it marks
+// the decrypted body instead, since payloads are compressed before they
+// are encrypted.
+const frameCompressed = 0xe2
+
+// maxInflatedSize bounds what a compressed body may inflate to, so a small
+// packet can't make a receiver allocate without limit. Real payloads are
+// far smaller.
+const maxInflatedSize = 64 << 10
+
+// ErrInflate is returned when an authenticated packet's compressed body
+// cannot be decompressed.
+var ErrInflate = errors.New("inflating payload failed")
+
+// compress returns data gzipped as frameCompressed || gzip stream, or data
+// unchanged if compressing wouldn't make it smaller.
+func compress(data []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(frameCompressed)
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	zw.Write(data)
+	zw.Close()
+
+	if buf.Len() >= len(data) {
+		return data
+	}
+	return buf.Bytes()
+}
+
+// inflate decompresses a body produced by compress.
+func inflate(body []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(body[1:]))
+	if err != nil {
+		return nil, ErrInflate
+	}
+	data, err := io.ReadAll(io.LimitReader(zr, maxInflatedSize+1))
+	if err != nil || len(data) > maxInflatedSize {
+		return nil, ErrInflate
+	}
+	return data, nil
+}
@@ -0,0 +1,92 @@
+package beacon
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// bulkyPayload returns a payload with enough repetitive detail to compress.
+func bulkyPayload() *BeaconPayload {
+	p := testPayload()
+	for i := 0; i < 8; i++ {
+		p.Hardware.GPUs = append(p.Hardware.GPUs, "NVIDIA GeForce RTX 4090 24GB")
+		p.Tags = append(p.Tags, fmt.Sprintf("rack=row-a-position-%d", i))
+	}
+	return p
+}
+
+func TestEncodeDecodePacket_Compressed(t *testing.T) {
+	secret := "test-shared-secret"
+
+	for name, opts := range map[string]*PacketOptions{
+		"compressed":           {Compress: true},
+		"compressed encrypted": {Compress: true, Encrypt: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			original := bulkyPayload()
+			plain, err := EncodePacket(original, secret, nil)
+			if err != nil {
+				t.Fatalf("encode failed: %v", err)
+			}
+			packet, err := EncodePacket(original, secret, opts)
+			if err != nil {
+				t.Fatalf("encode failed: %v", err)
+			}
+			if !opts.Encrypt {
+				if packet[HMACSize] != frameCompressed {
+					t.Errorf("expected a compressed frame, got first byte %#x", packet[HMACSize])
+				}
+				if len(packet) >= len(plain) {
+					t.Errorf("expected compression to shrink the packet: %d >= %d bytes", len(packet), len(plain))
+				}
+			}
+
+			decoded, err := DecodePacket(packet, secret, nil)
+			if err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			if decoded.Hostname != original.Hostname || len(decoded.Hardware.GPUs) != 8 || len(decoded.Tags) != 8 {
+				t.Errorf("round trip lost data: %+v", decoded)
+			}
+		})
+	}
+}
+
+func TestCompress_OnlyWhenSmaller(t *testing.T) {
+	random := make([]byte, 256)
+	rand.Read(random)
+	if got := compress(random); !bytes.Equal(got, random) {
+		t.Error("expected data that doesn't shrink to be left uncompressed")
+	}
+
+	repetitive := bytes.Repeat([]byte("lanmon "), 64)
+	got := compress(repetitive)
+	if got[0] != frameCompressed || len(got) >= len(repetitive) {
+		t.Fatalf("expected data that shrinks to be compressed, got %d bytes", len(got))
+	}
+	if back, err := inflate(got); err != nil || !bytes.Equal(back, repetitive) {
+		t.Errorf("inflate: got %q, %v", back, err)
+	}
+}
+
+func TestDecodePacket_BadCompressedBody(t *testing.T) {
+	var bomb bytes.Buffer
+	bomb.WriteByte(frameCompressed)
+	zw := gzip.NewWriter(&bomb)
+	zw.Write(make([]byte, maxInflatedSize+1))
+	zw.Close()
+
+	for name, body := range map[string][]byte{
+		"corrupt":   {frameCompressed, 0x1f, 0x8b, 0x00},
+		"oversized": bomb.Bytes(),
+	} {
+		packet := append(ComputeHMAC(body, "secret"), body...)
+		if _, err := DecodePacket(packet, "secret", nil); !errors.Is(err, ErrInflate) {
+			t.Errorf("%s: expected ErrInflate, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package beacon
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// frameEncrypted marks an AES-GCM encrypted body. It can't be confused with
+// a plaintext body: a msgpack-encoded payload always starts with a map
+// header (0x80-0x8f, 0xde or 0xdf).
+const frameEncrypted = 0xe1
+
+// encryptionKeyLabel domain-separates the encryption key from the HMAC key,
+// although both are derived from the same shared secret.
+const encryptionKeyLabel = "lanmon beacon encryption v1"
+
+// ErrDecrypt is returned when an authenticated packet's encrypted body
+// cannot be decrypted.
+var ErrDecrypt = errors.New("decrypting payload failed")
+
+// newAEAD returns the AES-256-GCM cipher keyed from the shared secret's
+// HMAC key.
+func newAEAD(hmacKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(computeHMAC([]byte(encryptionKeyLabel), hmacKey))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypt seals data as frameEncrypted || nonce || ciphertext.
+func encrypt(data, hmacKey []byte) ([]byte, error) {
+	aead, err := newAEAD(hmacKey)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	out := make([]byte, 1+aead.NonceSize(), 1+aead.NonceSize()+len(data)+aead.Overhead())
+	out[0] = frameEncrypted
+	nonce := out[1:]
+	rand.Read(nonce)
+	return aead.Seal(out, nonce, data, nil), nil
+}
+
+// decrypt opens a body produced by encrypt.
+func decrypt(body, hmacKey []byte) ([]byte, error) {
+	aead, err := newAEAD(hmacKey)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	body = body[1:]
+	if len(body) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+	nonce, ciphertext := body[:aead.NonceSize()], body[aead.NonceSize():]
+	data, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return data, nil
+}
@@ -9,20 +9,42 @@ import (
 // HMACSize is the length of the HMAC-SHA256 signature in bytes.
 const HMACSize = 32
 
-// ComputeHMAC returns the HMAC-SHA256 signature for the given data using the shared secret.
-func ComputeHMAC(data []byte, secret string) []byte {
+// DeriveKey returns the 32-byte key for a shared secret: the SHA-256 of its
+// UTF-8 bytes. Every secret maps to a key the same way, whether or not it
+// happens to look like hex.
+func DeriveKey(secret string) []byte {
+	key := sha256.Sum256([]byte(secret))
+	return key[:]
+}
+
+// LegacyKey returns the key releases before DeriveKey used: the secret
+// hex-decoded if it is valid hex, and its raw bytes otherwise. It exists
+// only so networks of older nodes can be upgraded (see
+// PacketOptions.LegacyKey).
+func LegacyKey(secret string) []byte {
 	key, _ := hex.DecodeString(secret)
-	// If hex decode fails, fallback to raw bytes or handle error
 	if len(key) == 0 {
 		key = []byte(secret)
 	}
+	return key
+}
+
+// ComputeHMAC returns the HMAC-SHA256 signature for the given data using the shared secret.
+func ComputeHMAC(data []byte, secret string) []byte {
+	return computeHMAC(data, DeriveKey(secret))
+}
+
+// VerifyHMAC performs a constant-time comparison of the expected HMAC against the provided signature.
+func VerifyHMAC(sig, data []byte, secret string) bool {
+	return verifyHMAC(sig, data, DeriveKey(secret))
+}
+
+func computeHMAC(data, key []byte) []byte {
 	mac := hmac.New(sha256.New, key)
 	mac.Write(data)
 	return mac.Sum(nil)
 }
 
-// VerifyHMAC performs a constant-time comparison of the expected HMAC against the provided signature.
-func VerifyHMAC(sig, data []byte, secret string) bool {
-	expected := ComputeHMAC(data, secret)
-	return hmac.Equal(sig, expected)
+func verifyHMAC(sig, data, key []byte) bool {
+	return hmac.Equal(sig, computeHMAC(data, key))
 }
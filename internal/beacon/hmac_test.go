@@ -1,6 +1,7 @@
 package beacon
 
 import (
+	"bytes"
 	"testing"
 )
 
@@ -64,3 +65,24 @@ func TestVerifyHMAC_TruncatedSignature(t *testing.T) {
 		t.Fatal("expected HMAC verification to fail with truncated signature")
 	}
 }
+
+func TestDeriveKey_HexLookingSecret(t *testing.T) {
+	// "abcd" is valid hex; the legacy derivation decoded it to two bytes
+	// while "hello" stayed raw, so the secret's spelling changed the scheme
+	for _, secret := range []string{"abcd", "hello", ""} {
+		key := DeriveKey(secret)
+		if len(key) != 32 {
+			t.Errorf("DeriveKey(%q): got %d bytes, want 32", secret, len(key))
+		}
+		if bytes.Equal(key, LegacyKey(secret)) {
+			t.Errorf("DeriveKey(%q) matches the legacy key", secret)
+		}
+	}
+
+	if got := LegacyKey("abcd"); !bytes.Equal(got, []byte{0xab, 0xcd}) {
+		t.Errorf("LegacyKey(abcd): got %x, want abcd", got)
+	}
+	if got := LegacyKey("hello"); string(got) != "hello" {
+		t.Errorf("LegacyKey(hello): got %q, want raw bytes", got)
+	}
+}
@@ -0,0 +1,75 @@
+package beacon
+
+import (
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+// NonceSize is the length of the random nonce senders put in each beacon.
+const NonceSize = 16
+
+// NewNonce returns a fresh random beacon nonce.
+func NewNonce() []byte {
+	nonce := make([]byte, NonceSize)
+	rand.Read(nonce)
+	return nonce
+}
+
+// NonceCache remembers recently seen beacon nonces so a receiver can reject
+// replays of a captured packet while its timestamp is still acceptable.
+// Entries older than the TTL are evicted, so memory is bounded by the
+// number of beacons received per TTL.
+type NonceCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewNonceCache returns a cache that remembers nonces for ttl.
+func NewNonceCache(ttl time.Duration) *NonceCache {
+	return &NonceCache{
+		ttl:       ttl,
+		now:       time.Now,
+		seen:      make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// Seen records nonce and reports whether it was already recorded within
+// the TTL, i.e. whether the beacon carrying it is a replay.
+func (c *NonceCache) Seen(nonce []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if now.Sub(c.lastSweep) >= c.ttl {
+		c.sweep(now)
+	}
+
+	key := string(nonce)
+	if at, ok := c.seen[key]; ok && now.Sub(at) < c.ttl {
+		return true
+	}
+	c.seen[key] = now
+	return false
+}
+
+// Len returns the number of nonces currently remembered.
+func (c *NonceCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.seen)
+}
+
+func (c *NonceCache) sweep(now time.Time) {
+	for key, at := range c.seen {
+		if now.Sub(at) >= c.ttl {
+			delete(c.seen, key)
+		}
+	}
+	c.lastSweep = now
+}
@@ -0,0 +1,53 @@
+package beacon
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestNewNonce(t *testing.T) {
+	a, b := NewNonce(), NewNonce()
+	if len(a) != NonceSize {
+		t.Errorf("nonce length: got %d, want %d", len(a), NonceSize)
+	}
+	if bytes.Equal(a, b) {
+		t.Error("expected distinct nonces")
+	}
+}
+
+func TestNonceCache_RejectsReplay(t *testing.T) {
+	c := NewNonceCache(time.Minute)
+	nonce := NewNonce()
+
+	if c.Seen(nonce) {
+		t.Fatal("first sighting reported as replay")
+	}
+	if !c.Seen(nonce) {
+		t.Error("second sighting not reported as replay")
+	}
+	if c.Seen(NewNonce()) {
+		t.Error("different nonce reported as replay")
+	}
+}
+
+func TestNonceCache_Evicts(t *testing.T) {
+	now := time.Now()
+	c := NewNonceCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	old := NewNonce()
+	c.Seen(old)
+	for i := 0; i < 10; i++ {
+		c.Seen(NewNonce())
+	}
+
+	// Past the TTL, the next call sweeps everything recorded before
+	now = now.Add(2 * time.Minute)
+	if c.Seen(old) {
+		t.Error("expired nonce reported as replay")
+	}
+	if got := c.Len(); got != 1 {
+		t.Errorf("entries after sweep: got %d, want 1", got)
+	}
+}
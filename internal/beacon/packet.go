@@ -0,0 +1,191 @@
+package beacon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var (
+	// ErrTooSmall is returned when a packet is too short to hold a signature and payload.
+	ErrTooSmall = errors.New("packet too small")
+	// ErrHMAC is returned when a packet's signature does not match its payload.
+	ErrHMAC = errors.New("HMAC validation failed")
+	// ErrUnmarshal is returned when a correctly signed packet's payload
+	// isn't a valid beacon.
+	ErrUnmarshal = errors.New("unmarshaling payload")
+	// ErrOversized is returned by ReadPacket when a datagram did not fit
+	// the read buffer and was truncated by the kernel.
+	ErrOversized = errors.New("oversized packet, truncated")
+)
+
+// PacketOptions controls how a beacon packet is framed on the wire.
+// A nil *PacketOptions selects the defaults, which produce the original
+// format: a 32-byte HMAC-SHA256 signature followed by the msgpack payload.
+//
+// Receivers detect the framing of each packet, so they read packets built
+// with any options; apart from LegacyKey and PreviousSecret, the options
+// only decide what EncodePacket sends.
+type PacketOptions struct {
+	// Encrypt seals the payload with AES-256-GCM under a key derived from
+	// the shared secret, so hostnames, addresses and hardware details aren't
+	// readable on the wire. The packet becomes
+	// HMAC || 0xe1 || 12-byte nonce || ciphertext, and the HMAC still
+	// covers everything after it.
+	Encrypt bool
+
+	// Compress gzips the payload when that makes it smaller, leaving room
+	// for more fields under MaxPacketSize. The body becomes
+	// 0xe2 || gzip stream, and is compressed before it is encrypted.
+	// Uncompressed bodies carry no marker, so existing packets read as
+	// before; nodes older than this option can't read compressed ones.
+	Compress bool
+
+	// LegacyKey signs (and encrypts) with LegacyKey instead of DeriveKey,
+	// for networks that still have nodes from before DeriveKey. Packets
+	// under either key are accepted while it is set, so a network can turn
+	// it on everywhere, upgrade, and then turn it off node by node.
+	LegacyKey bool
+
+	// PreviousSecret, if set, is a shared secret being rotated out.
+	// Packets signed with it are accepted as well as those signed with the
+	// current secret, so the secret can be changed one node at a time.
+	// Outgoing packets are always signed with the current secret.
+	PreviousSecret string
+}
+
+// signingKey returns the key outgoing packets are signed with.
+func (o *PacketOptions) signingKey(secret string) []byte {
+	if o != nil && o.LegacyKey {
+		return LegacyKey(secret)
+	}
+	return DeriveKey(secret)
+}
+
+// verifyKeys returns the keys incoming packets may be signed with, those
+// for the current secret first.
+func (o *PacketOptions) verifyKeys(secret string) [][]byte {
+	secrets := []string{secret}
+	if o != nil && o.PreviousSecret != "" {
+		secrets = append(secrets, o.PreviousSecret)
+	}
+
+	var keys [][]byte
+	for _, s := range secrets {
+		if o != nil && o.LegacyKey {
+			keys = append(keys, LegacyKey(s))
+		}
+		keys = append(keys, DeriveKey(s))
+	}
+	return keys
+}
+
+// EncodePacket serializes and signs a payload into a wire-format packet.
+func EncodePacket(payload *BeaconPayload, secret string, opts *PacketOptions) ([]byte, error) {
+	data, err := msgpack.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling payload: %w", err)
+	}
+
+	if opts != nil && opts.Compress {
+		data = compress(data)
+	}
+
+	key := opts.signingKey(secret)
+	if opts != nil && opts.Encrypt {
+		if data, err = encrypt(data, key); err != nil {
+			return nil, err
+		}
+	}
+
+	packet := make([]byte, 0, HMACSize+len(data))
+	packet = append(packet, computeHMAC(data, key)...)
+	packet = append(packet, data...)
+	return packet, nil
+}
+
+// DecodePacket verifies a packet's signature and deserializes its payload,
+// decrypting and inflating it first as needed. It returns ErrTooSmall or
+// ErrHMAC for packets that fail framing or authentication, and ErrDecrypt,
+// ErrInflate or an error wrapping ErrUnmarshal if the signed payload was
+// malformed.
+func DecodePacket(packet []byte, secret string, opts *PacketOptions) (*BeaconPayload, error) {
+	if len(packet) <= HMACSize {
+		return nil, ErrTooSmall
+	}
+
+	sig := packet[:HMACSize]
+	data := packet[HMACSize:]
+
+	var key []byte
+	for _, k := range opts.verifyKeys(secret) {
+		if verifyHMAC(sig, data, k) {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		return nil, ErrHMAC
+	}
+
+	if data[0] == frameEncrypted {
+		var err error
+		if data, err = decrypt(data, key); err != nil {
+			return nil, err
+		}
+	}
+	if len(data) > 0 && data[0] == frameCompressed {
+		var err error
+		if data, err = inflate(data); err != nil {
+			return nil, err
+		}
+	}
+
+	var payload BeaconPayload
+	if err := msgpack.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnmarshal, err)
+	}
+	return &payload, nil
+}
+
+// DecodeVerified decodes a packet as DecodePacket does, then makes the
+// checks every receiver makes before using a beacon: that its version is
+// supported and that its timestamp is within maxAge of the local clock
+// (zero meaning DefaultTimestampMaxAge). Errors wrap ErrTooSmall, ErrHMAC,
+// ErrDecrypt, ErrInflate, ErrUnmarshal, ErrUnsupportedVersion or
+// ErrStaleTimestamp.
+// A stale beacon's payload is returned along with the error, so callers
+// can report who sent it and how far off its clock is. Replays are left
+// to the caller's NonceCache.
+func DecodeVerified(packet []byte, secret string, maxAge time.Duration, opts *PacketOptions) (*BeaconPayload, error) {
+	payload, err := DecodePacket(packet, secret, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckVersion(payload.Version); err != nil {
+		return nil, err
+	}
+	if err := CheckTimestamp(payload.Timestamp, time.Now(), maxAge); err != nil {
+		return payload, err
+	}
+	return payload, nil
+}
+
+// ReadPacket reads one datagram into buf. UDP reads silently truncate
+// datagrams larger than the buffer, so callers size buf one byte beyond the
+// largest packet they accept: a read that fills buf completely must have
+// been cut short, and ReadPacket reports it as ErrOversized (with n and src
+// still set) rather than letting it fail HMAC verification later.
+func ReadPacket(conn *net.UDPConn, buf []byte) (int, *net.UDPAddr, error) {
+	n, src, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		return n, src, err
+	}
+	if n == len(buf) {
+		return n, src, ErrOversized
+	}
+	return n, src, nil
+}
@@ -0,0 +1,318 @@
+package beacon
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func testPayload() *BeaconPayload {
+	return &BeaconPayload{
+		Version:    1,
+		Timestamp:  1708444800,
+		MACAddress: "aa:bb:cc:dd:ee:ff",
+		IPAddress:  "192.168.1.100",
+		Hostname:   "test-host",
+		OS:         OSInfo{Name: "Ubuntu 22.04", Kernel: "5.15.0", Arch: "amd64"},
+		Hardware:   HWInfo{CPUModel: "Test CPU", CPUCores: 4, MemoryGB: 16, DiskCount: 1},
+	}
+}
+
+func TestEncodeDecodePacket_RoundTrip(t *testing.T) {
+	secret := "test-shared-secret"
+
+	for name, opts := range map[string]*PacketOptions{
+		"nil options":  nil,
+		"zero options": {},
+		"encrypted":    {Encrypt: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			original := testPayload()
+
+			packet, err := EncodePacket(original, secret, opts)
+			if err != nil {
+				t.Fatalf("encode failed: %v", err)
+			}
+
+			decoded, err := DecodePacket(packet, secret, opts)
+			if err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+
+			if decoded.Hostname != original.Hostname {
+				t.Errorf("Hostname: got %s, want %s", decoded.Hostname, original.Hostname)
+			}
+			if decoded.MACAddress != original.MACAddress {
+				t.Errorf("MACAddress: got %s, want %s", decoded.MACAddress, original.MACAddress)
+			}
+			if decoded.Hardware.CPUCores != original.Hardware.CPUCores {
+				t.Errorf("CPUCores: got %d, want %d", decoded.Hardware.CPUCores, original.Hardware.CPUCores)
+			}
+		})
+	}
+}
+
+func TestEncodePacket_WireFormat(t *testing.T) {
+	secret := "test-shared-secret"
+
+	packet, err := EncodePacket(testPayload(), secret, nil)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	// The default framing is HMAC || msgpack, readable by older receivers
+	if !VerifyHMAC(packet[:HMACSize], packet[HMACSize:], secret) {
+		t.Fatal("expected packet to carry a leading HMAC over the payload")
+	}
+}
+
+func TestEncodePacket_EncryptedHidesPayload(t *testing.T) {
+	secret := "test-shared-secret"
+
+	packet, err := EncodePacket(testPayload(), secret, &PacketOptions{Encrypt: true})
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	if bytes.Contains(packet, []byte("test-host")) || bytes.Contains(packet, []byte("192.168.1.100")) {
+		t.Error("encrypted packet leaks payload fields in plaintext")
+	}
+
+	// Receivers detect encryption on their own, whatever their options
+	decoded, err := DecodePacket(packet, secret, nil)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if decoded.Hostname != "test-host" {
+		t.Errorf("Hostname: got %s, want test-host", decoded.Hostname)
+	}
+}
+
+func TestDecodePacket_EncryptedWrongSecret(t *testing.T) {
+	packet, err := EncodePacket(testPayload(), "correct-secret", &PacketOptions{Encrypt: true})
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	if _, err := DecodePacket(packet, "wrong-secret", nil); !errors.Is(err, ErrHMAC) {
+		t.Errorf("expected ErrHMAC, got %v", err)
+	}
+}
+
+func TestDecodePacket_EncryptedTruncated(t *testing.T) {
+	secret := "test-shared-secret"
+
+	// Correctly signed, but too short to hold a nonce and GCM tag
+	body := []byte{frameEncrypted, 1, 2, 3}
+	packet := append(ComputeHMAC(body, secret), body...)
+
+	if _, err := DecodePacket(packet, secret, nil); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt, got %v", err)
+	}
+}
+
+func TestDecodePacket_LegacyKey(t *testing.T) {
+	secret := "abcd1234"
+	legacy := &PacketOptions{LegacyKey: true}
+
+	oldPacket, err := EncodePacket(testPayload(), secret, legacy)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if !bytes.Equal(oldPacket[:HMACSize], computeHMAC(oldPacket[HMACSize:], LegacyKey(secret))) {
+		t.Fatal("expected a legacy-keyed signature")
+	}
+	newPacket, err := EncodePacket(testPayload(), secret, &PacketOptions{Encrypt: true})
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	// Without the flag, legacy packets are rejected
+	if _, err := DecodePacket(oldPacket, secret, nil); !errors.Is(err, ErrHMAC) {
+		t.Errorf("expected ErrHMAC for a legacy packet, got %v", err)
+	}
+
+	// With it, both are accepted
+	for name, packet := range map[string][]byte{"legacy": oldPacket, "derived": newPacket} {
+		if _, err := DecodePacket(packet, secret, legacy); err != nil {
+			t.Errorf("%s packet: decode failed: %v", name, err)
+		}
+	}
+}
+
+func TestDecodePacket_PreviousSecret(t *testing.T) {
+	rotating := &PacketOptions{PreviousSecret: "old-secret"}
+
+	for name, opts := range map[string]*PacketOptions{
+		"plain":     nil,
+		"encrypted": {Encrypt: true},
+	} {
+		oldPacket, err := EncodePacket(testPayload(), "old-secret", opts)
+		if err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+
+		if _, err := DecodePacket(oldPacket, "new-secret", nil); !errors.Is(err, ErrHMAC) {
+			t.Errorf("%s: expected ErrHMAC without the previous secret, got %v", name, err)
+		}
+		if _, err := DecodePacket(oldPacket, "new-secret", rotating); err != nil {
+			t.Errorf("%s: expected a packet under the previous secret to verify, got %v", name, err)
+		}
+	}
+
+	// Outgoing packets are signed with the current secret only
+	packet, err := EncodePacket(testPayload(), "new-secret", rotating)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if !bytes.Equal(packet[:HMACSize], computeHMAC(packet[HMACSize:], DeriveKey("new-secret"))) {
+		t.Error("expected the packet to be signed with the current secret")
+	}
+	if _, err := DecodePacket(packet, "old-secret", nil); !errors.Is(err, ErrHMAC) {
+		t.Errorf("expected nodes with only the old secret to reject it, got %v", err)
+	}
+}
+
+func TestDecodePacket_WrongSecret(t *testing.T) {
+	packet, err := EncodePacket(testPayload(), "correct-secret", nil)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	if _, err := DecodePacket(packet, "wrong-secret", nil); !errors.Is(err, ErrHMAC) {
+		t.Errorf("expected ErrHMAC, got %v", err)
+	}
+}
+
+func TestDecodePacket_TooSmall(t *testing.T) {
+	if _, err := DecodePacket(make([]byte, HMACSize), "secret", nil); !errors.Is(err, ErrTooSmall) {
+		t.Errorf("expected ErrTooSmall, got %v", err)
+	}
+}
+
+func TestDecodePacket_Tampered(t *testing.T) {
+	secret := "test-shared-secret"
+	packet, err := EncodePacket(testPayload(), secret, nil)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	packet[len(packet)-1] ^= 0xff
+	if _, err := DecodePacket(packet, secret, nil); !errors.Is(err, ErrHMAC) {
+		t.Errorf("expected ErrHMAC, got %v", err)
+	}
+}
+
+// freshPacket encodes testPayload stamped now, adjusted by fn.
+func freshPacket(t *testing.T, secret string, fn func(*BeaconPayload)) []byte {
+	t.Helper()
+	payload := testPayload()
+	payload.Timestamp = time.Now().Unix()
+	if fn != nil {
+		fn(payload)
+	}
+	packet, err := EncodePacket(payload, secret, nil)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	return packet
+}
+
+func TestDecodeVerified_OK(t *testing.T) {
+	payload, err := DecodeVerified(freshPacket(t, "secret", nil), "secret", 0, nil)
+	if err != nil {
+		t.Fatalf("expected a fresh beacon to verify, got %v", err)
+	}
+	if payload.Hostname != "test-host" {
+		t.Errorf("Hostname: got %s, want test-host", payload.Hostname)
+	}
+}
+
+func TestDecodeVerified_TooSmall(t *testing.T) {
+	if _, err := DecodeVerified(make([]byte, HMACSize), "secret", 0, nil); !errors.Is(err, ErrTooSmall) {
+		t.Errorf("expected ErrTooSmall, got %v", err)
+	}
+}
+
+func TestDecodeVerified_HMAC(t *testing.T) {
+	packet := freshPacket(t, "other-secret", nil)
+	if payload, err := DecodeVerified(packet, "secret", 0, nil); !errors.Is(err, ErrHMAC) || payload != nil {
+		t.Errorf("expected ErrHMAC and no payload, got %v, %v", payload, err)
+	}
+}
+
+func TestDecodeVerified_Unmarshal(t *testing.T) {
+	garbage := []byte{0xc1, 0xff, 0x00}
+	packet := append(ComputeHMAC(garbage, "secret"), garbage...)
+	if _, err := DecodeVerified(packet, "secret", 0, nil); !errors.Is(err, ErrUnmarshal) {
+		t.Errorf("expected ErrUnmarshal, got %v", err)
+	}
+}
+
+func TestDecodeVerified_Version(t *testing.T) {
+	packet := freshPacket(t, "secret", func(p *BeaconPayload) { p.Version = MaxVersion + 1 })
+	if _, err := DecodeVerified(packet, "secret", 0, nil); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("expected ErrUnsupportedVersion, got %v", err)
+	}
+}
+
+func TestDecodeVerified_Stale(t *testing.T) {
+	packet := freshPacket(t, "secret", func(p *BeaconPayload) { p.Timestamp -= 120 })
+
+	payload, err := DecodeVerified(packet, "secret", 0, nil)
+	if !errors.Is(err, ErrStaleTimestamp) {
+		t.Fatalf("expected ErrStaleTimestamp, got %v", err)
+	}
+	if payload == nil || payload.Hostname != "test-host" {
+		t.Errorf("expected the stale payload back for logging, got %+v", payload)
+	}
+
+	if _, err := DecodeVerified(packet, "secret", 5*time.Minute, nil); err != nil {
+		t.Errorf("expected a 2m skew to pass with a 5m max age, got %v", err)
+	}
+}
+
+func TestReadPacket_Oversized(t *testing.T) {
+	const maxSize = 64
+
+	recv, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer recv.Close()
+	recv.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	send, err := net.DialUDP("udp4", nil, recv.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer send.Close()
+
+	buf := make([]byte, maxSize+1)
+
+	// A packet of exactly maxSize is accepted
+	if _, err := send.Write(make([]byte, maxSize)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	n, _, err := ReadPacket(recv, buf)
+	if err != nil {
+		t.Fatalf("expected max-size packet to be read, got %v", err)
+	}
+	if n != maxSize {
+		t.Errorf("bytes read: got %d, want %d", n, maxSize)
+	}
+
+	// A larger packet is reported as oversized, with its source
+	if _, err := send.Write(make([]byte, maxSize*2)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	_, src, err := ReadPacket(recv, buf)
+	if !errors.Is(err, ErrOversized) {
+		t.Fatalf("expected ErrOversized, got %v", err)
+	}
+	if src == nil || src.Port != send.LocalAddr().(*net.UDPAddr).Port {
+		t.Errorf("expected source address of the oversized packet, got %v", src)
+	}
+}
@@ -1,6 +1,54 @@
 // Package beacon defines the beacon payload structures and broadcast logic.
 package beacon
 
+import (
+	"errors"
+	"fmt"
+)
+
+// Payload schema versions.
+//
+// Compatibility rules: payloads are msgpack maps keyed by field name, and
+// receivers ignore keys they don't know. A new version may therefore only
+// add fields, each optional (omitempty, zero value meaning "unknown"), so
+// that older receivers still read everything they understand. Fields are
+// never removed, renamed or given a new meaning; that would need a version
+// older receivers refuse outright. Optional fields alone don't need a new
+// version, and mustn't get one: receivers skip payloads newer than
+// MaxVersion, since they can't tell which rule the sender followed, so a
+// needless bump cuts upgraded nodes off from the rest of the fleet.
+//
+// Every field added costs every beacon bytes on the wire and must keep the
+// packet under the receivers' maximum packet size (MaxPacketSize).
+const (
+	// VersionV1 is the original schema.
+	VersionV1 = 1
+	// VersionV2 adds Uptime, Load and Tags, and since then, as optional
+	// fields, Hardware.GPUs, DiskTotalGB and DiskFreeGB.
+	VersionV2 = 2
+	// VersionV3 was sent by builds that bumped the version for
+	// Hardware.GPUs, DiskTotalGB and DiskFreeGB. It means the same as V2
+	// and is accepted from them, but never sent.
+	VersionV3 = 3
+
+	// CurrentVersion is the version this build sends.
+	CurrentVersion = VersionV2
+	// MaxVersion is the newest version this build accepts.
+	MaxVersion = VersionV3
+)
+
+// ErrUnsupportedVersion is returned by CheckVersion for payload versions
+// this build can't interpret.
+var ErrUnsupportedVersion = errors.New("unsupported payload version")
+
+// CheckVersion reports whether a payload of the given version can be used.
+func CheckVersion(version uint8) error {
+	if version < VersionV1 || version > MaxVersion {
+		return fmt.Errorf("%w %d (supported: %d-%d)", ErrUnsupportedVersion, version, VersionV1, MaxVersion)
+	}
+	return nil
+}
+
 // BeaconPayload is the data broadcast by each agent over UDP multicast.
 type BeaconPayload struct {
 	Version    uint8  `msgpack:"version"`
@@ -10,6 +58,28 @@ type BeaconPayload struct {
 	Hostname   string `msgpack:"hostname"`
 	OS         OSInfo `msgpack:"os"`
 	Hardware   HWInfo `msgpack:"hardware"`
+
+	// Departing is set on the final beacon a node sends during a clean
+	// shutdown, telling peers to mark it inactive immediately instead of
+	// waiting for the stale threshold. Omitted from regular beacons.
+	Departing bool `msgpack:"departing,omitempty"`
+
+	// Probe is set by 'lanmon ping': a node that accepts the beacon answers
+	// with a beacon of its own, sent to the probe's source address, instead
+	// of recording the sender. Older nodes record it as a regular beacon.
+	Probe bool `msgpack:"probe,omitempty"`
+
+	// Nonce is fresh random bytes for every beacon sent. Receivers remember
+	// recent nonces and drop repeats, so a captured packet can't be replayed
+	// inside the timestamp window. Empty from senders that predate it.
+	Nonce []byte `msgpack:"nonce,omitempty"`
+
+	// Uptime is the sender's system uptime in seconds (v2).
+	Uptime uint64 `msgpack:"uptime,omitempty"`
+	// Load is the sender's load average, nil where unavailable (v2).
+	Load *LoadInfo `msgpack:"load,omitempty"`
+	// Tags are free-form labels the sender's operator assigned it (v2).
+	Tags []string `msgpack:"tags,omitempty"`
 }
 
 // OSInfo holds operating system metadata.
@@ -19,10 +89,28 @@ type OSInfo struct {
 	Arch   string `msgpack:"arch"`
 }
 
+// LoadInfo holds the 1, 5 and 15 minute load averages.
+type LoadInfo struct {
+	Load1  float64 `msgpack:"load1"`
+	Load5  float64 `msgpack:"load5"`
+	Load15 float64 `msgpack:"load15"`
+}
+
 // HWInfo holds hardware metadata.
 type HWInfo struct {
 	CPUModel  string  `msgpack:"cpu_model"`
 	CPUCores  int     `msgpack:"cpu_cores"`
 	MemoryGB  float64 `msgpack:"memory_gb"`
 	DiskCount int     `msgpack:"disk_count"`
+
+	// DiskTotalGB and DiskFreeGB are the size and free space of the root
+	// filesystem (the system drive on Windows), 0 if unknown (v2). They
+	// supersede DiskCount, a count of mounted partitions kept for older
+	// receivers.
+	DiskTotalGB float64 `msgpack:"disk_total_gb,omitempty"`
+	DiskFreeGB  float64 `msgpack:"disk_free_gb,omitempty"`
+
+	// GPUs names the sender's GPUs and other display adapters, empty if
+	// it has none or they couldn't be listed (v2).
+	GPUs []string `msgpack:"gpus,omitempty"`
 }
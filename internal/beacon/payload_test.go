@@ -1,6 +1,7 @@
 package beacon
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/vmihailenco/msgpack/v5"
@@ -125,3 +126,129 @@ func TestBeaconPayload_SignedPacketRoundTrip(t *testing.T) {
 		t.Errorf("MACAddress: got %s, want aa:bb:cc:dd:ee:ff", decoded.MACAddress)
 	}
 }
+
+// v1Payload mirrors the original schema, as an old receiver sees it.
+type v1Payload struct {
+	Version    uint8  `msgpack:"version"`
+	Timestamp  int64  `msgpack:"timestamp"`
+	MACAddress string `msgpack:"mac_address"`
+	IPAddress  string `msgpack:"ip_address"`
+	Hostname   string `msgpack:"hostname"`
+	OS         OSInfo `msgpack:"os"`
+	Hardware   HWInfo `msgpack:"hardware"`
+}
+
+func TestDecodePacket_V1Payload(t *testing.T) {
+	secret := "test-shared-secret"
+	data, err := msgpack.Marshal(&v1Payload{Version: VersionV1, Timestamp: 1708444800, MACAddress: "aa:bb:cc:dd:ee:ff", Hostname: "old-host"})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	packet := append(ComputeHMAC(data, secret), data...)
+
+	payload, err := DecodePacket(packet, secret, nil)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if err := CheckVersion(payload.Version); err != nil {
+		t.Errorf("v1 payload rejected: %v", err)
+	}
+	if payload.Hostname != "old-host" || payload.Uptime != 0 || payload.Tags != nil {
+		t.Errorf("unexpected v1 decode: %+v", payload)
+	}
+}
+
+func TestV2Payload_ReadableByV1Receivers(t *testing.T) {
+	payload := testPayload()
+	payload.Version = VersionV2
+	payload.Uptime = 3600
+	payload.Tags = []string{"lab", "gpu"}
+
+	data, err := msgpack.Marshal(payload)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var old v1Payload
+	if err := msgpack.Unmarshal(data, &old); err != nil {
+		t.Fatalf("v1 receiver failed to decode v2 payload: %v", err)
+	}
+	if old.Hostname != payload.Hostname || old.Hardware.CPUCores != payload.Hardware.CPUCores {
+		t.Errorf("v1 fields lost: %+v", old)
+	}
+}
+
+// v2Payload mirrors the V2 schema before the optional GPU and disk
+// fields, as a receiver built then sees it.
+type v2Payload struct {
+	v1Payload `msgpack:",inline"`
+	Uptime    uint64    `msgpack:"uptime,omitempty"`
+	Load      *LoadInfo `msgpack:"load,omitempty"`
+	Tags      []string  `msgpack:"tags,omitempty"`
+}
+
+// v2CheckVersion is CheckVersion as V2 receivers have it.
+func v2CheckVersion(version uint8) bool {
+	return version >= VersionV1 && version <= VersionV2
+}
+
+func TestOptionalFields_KeepV2Receivers(t *testing.T) {
+	payload := testPayload()
+	payload.Version = CurrentVersion
+	payload.Tags = []string{"lab"}
+	payload.Hardware.GPUs = []string{"NVIDIA RTX 4090"}
+	payload.Hardware.DiskTotalGB, payload.Hardware.DiskFreeGB = 512, 128
+
+	// What this build sends, V2 receivers accept and read
+	if !v2CheckVersion(payload.Version) {
+		t.Fatalf("V2 receivers skip version %d", payload.Version)
+	}
+	data, err := msgpack.Marshal(payload)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var old v2Payload
+	if err := msgpack.Unmarshal(data, &old); err != nil {
+		t.Fatalf("V2 receiver failed to decode: %v", err)
+	}
+	if old.Hostname != payload.Hostname || len(old.Tags) != 1 || old.Hardware.CPUCores != payload.Hardware.CPUCores {
+		t.Errorf("V2 fields lost: %+v", old)
+	}
+}
+
+func TestV3Payload_DecodesAsV2(t *testing.T) {
+	// Sent by builds that bumped the version for the GPU and disk fields
+	secret := "test-shared-secret"
+	payload := testPayload()
+	payload.Version = VersionV3
+	payload.Hardware.GPUs = []string{"NVIDIA RTX 4090"}
+	payload.Hardware.DiskTotalGB = 512
+
+	packet, err := EncodePacket(payload, secret, nil)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	decoded, err := DecodePacket(packet, secret, nil)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if err := CheckVersion(decoded.Version); err != nil {
+		t.Errorf("V3 payload rejected: %v", err)
+	}
+	if len(decoded.Hardware.GPUs) != 1 || decoded.Hardware.DiskTotalGB != 512 {
+		t.Errorf("optional fields lost: %+v", decoded.Hardware)
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	for _, v := range []uint8{VersionV1, VersionV2, VersionV3} {
+		if err := CheckVersion(v); err != nil {
+			t.Errorf("version %d: unexpected error %v", v, err)
+		}
+	}
+	for _, v := range []uint8{0, MaxVersion + 1, 255} {
+		if err := CheckVersion(v); !errors.Is(err, ErrUnsupportedVersion) {
+			t.Errorf("version %d: expected ErrUnsupportedVersion, got %v", v, err)
+		}
+	}
+}
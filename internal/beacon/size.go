@@ -0,0 +1,73 @@
+package beacon
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog"
+)
+
+// MaxPacketSize is the largest beacon packet, signature included, that
+// receivers accept. Senders and receivers both use it so they can't drift.
+const MaxPacketSize = 4096
+
+// PacketWarnSize is the packet size above which senders warn that their
+// beacons are close to MaxPacketSize.
+const PacketWarnSize = MaxPacketSize * 9 / 10
+
+// ErrPacketTooLarge is returned by EncodeFitting when a packet exceeds
+// MaxPacketSize even without its optional fields.
+var ErrPacketTooLarge = errors.New("beacon packet too large")
+
+// droppableFields are the payload fields EncodeFitting may leave out, least
+// important first.
+var droppableFields = []struct {
+	name string
+	drop func(*BeaconPayload)
+}{
+	{"tags", func(p *BeaconPayload) { p.Tags = nil }},
+	{"gpus", func(p *BeaconPayload) { p.Hardware.GPUs = nil }},
+	{"cpu_model", func(p *BeaconPayload) { p.Hardware.CPUModel = "" }},
+}
+
+// EncodeFitting encodes payload like EncodePacket, making sure the packet
+// fits in MaxPacketSize. If it doesn't, fields are left out one at a time,
+// least important first, and their names are returned in dropped. payload
+// itself is not modified.
+func EncodeFitting(payload *BeaconPayload, secret string, opts *PacketOptions) (packet []byte, dropped []string, err error) {
+	packet, err = EncodePacket(payload, secret, opts)
+	if err != nil || len(packet) <= MaxPacketSize {
+		return packet, nil, err
+	}
+
+	trimmed := *payload
+	for _, f := range droppableFields {
+		f.drop(&trimmed)
+		dropped = append(dropped, f.name)
+		if packet, err = EncodePacket(&trimmed, secret, opts); err != nil {
+			return nil, dropped, err
+		}
+		if len(packet) <= MaxPacketSize {
+			return packet, dropped, nil
+		}
+	}
+	return nil, dropped, fmt.Errorf("%w: %d bytes (max %d)", ErrPacketTooLarge, len(packet), MaxPacketSize)
+}
+
+// WarnPacketSize logs a warning if EncodeFitting had to drop fields from a
+// packet, or if the packet is over PacketWarnSize.
+func WarnPacketSize(log zerolog.Logger, packet []byte, dropped []string) {
+	switch {
+	case len(dropped) > 0:
+		log.Warn().
+			Int("bytes", len(packet)).
+			Int("max_bytes", MaxPacketSize).
+			Strs("dropped", dropped).
+			Msg("Beacon too large, optional fields left out")
+	case len(packet) > PacketWarnSize:
+		log.Warn().
+			Int("bytes", len(packet)).
+			Int("max_bytes", MaxPacketSize).
+			Msg("Beacon is close to the maximum packet size")
+	}
+}
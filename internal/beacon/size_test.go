@@ -0,0 +1,52 @@
+package beacon
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeFitting(t *testing.T) {
+	secret := "test-shared-secret"
+
+	// Small payloads are sent whole
+	packet, dropped, err := EncodeFitting(testPayload(), secret, nil)
+	if err != nil || dropped != nil {
+		t.Fatalf("got dropped %v, err %v", dropped, err)
+	}
+	if len(packet) > PacketWarnSize {
+		t.Errorf("test payload unexpectedly large: %d bytes", len(packet))
+	}
+
+	// Oversized tags and GPU lists are dropped, least important first
+	payload := testPayload()
+	payload.Tags = []string{strings.Repeat("t", MaxPacketSize)}
+	payload.Hardware.GPUs = []string{strings.Repeat("g", MaxPacketSize)}
+	packet, dropped, err = EncodeFitting(payload, secret, nil)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if want := []string{"tags", "gpus"}; !reflect.DeepEqual(dropped, want) {
+		t.Errorf("dropped: got %v, want %v", dropped, want)
+	}
+	if len(packet) > MaxPacketSize {
+		t.Errorf("packet is %d bytes, over %d", len(packet), MaxPacketSize)
+	}
+	if payload.Tags == nil || payload.Hardware.GPUs == nil {
+		t.Error("EncodeFitting modified the caller's payload")
+	}
+	decoded, err := DecodePacket(packet, secret, nil)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if decoded.Hostname != payload.Hostname || decoded.Hardware.CPUModel != payload.Hardware.CPUModel {
+		t.Errorf("required fields lost: %+v", decoded)
+	}
+
+	// A payload too large even without them is refused
+	payload.Hostname = strings.Repeat("h", MaxPacketSize)
+	if _, _, err := EncodeFitting(payload, secret, nil); !errors.Is(err, ErrPacketTooLarge) {
+		t.Errorf("expected ErrPacketTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package beacon
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultTimestampMaxAge is how far a beacon's timestamp may be from the
+// receiver's clock, either way, unless configured otherwise.
+const DefaultTimestampMaxAge = 60 * time.Second
+
+// ErrStaleTimestamp is returned by CheckTimestamp for beacons stamped too
+// far from the receiver's clock.
+var ErrStaleTimestamp = errors.New("stale timestamp")
+
+// Skew returns how far a beacon stamped ts (Unix seconds) is ahead of now,
+// negative if it is behind, to the second.
+func Skew(ts int64, now time.Time) time.Duration {
+	return time.Duration(ts-now.Unix()) * time.Second
+}
+
+// CheckTimestamp returns an error wrapping ErrStaleTimestamp if a beacon
+// stamped ts is more than maxAge ahead of or behind now. Zero maxAge means
+// DefaultTimestampMaxAge.
+func CheckTimestamp(ts int64, now time.Time, maxAge time.Duration) error {
+	if maxAge == 0 {
+		maxAge = DefaultTimestampMaxAge
+	}
+	if skew := Skew(ts, now); skew > maxAge || skew < -maxAge {
+		return fmt.Errorf("%w: sender clock %s off, more than %s", ErrStaleTimestamp, skew, maxAge)
+	}
+	return nil
+}
+
+// ReplayWindow is how long a receiver checking timestamps against maxAge
+// (zero meaning DefaultTimestampMaxAge) must remember nonces. A beacon
+// passes the check from maxAge before to maxAge after its timestamp, so a
+// replay stays acceptable for up to twice that after the original arrives.
+func ReplayWindow(maxAge time.Duration) time.Duration {
+	if maxAge == 0 {
+		maxAge = DefaultTimestampMaxAge
+	}
+	return 2 * maxAge
+}
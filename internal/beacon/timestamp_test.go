@@ -0,0 +1,44 @@
+package beacon
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckTimestamp(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		ts     int64
+		maxAge time.Duration
+		stale  bool
+	}{
+		{now.Unix(), 0, false},
+		{now.Unix() - 60, 0, false},
+		{now.Unix() + 60, 0, false},
+		{now.Unix() - 61, 0, true},
+		{now.Unix() + 61, 0, true},
+		{now.Unix() - 290, 5 * time.Minute, false},
+		{now.Unix() + 301, 5 * time.Minute, true},
+		{now.Unix() - 11, 10 * time.Second, true},
+	}
+	for _, tt := range tests {
+		err := CheckTimestamp(tt.ts, now, tt.maxAge)
+		if got := errors.Is(err, ErrStaleTimestamp); got != tt.stale {
+			t.Errorf("CheckTimestamp(now%+ds, %s): got %v, want stale=%v", tt.ts-now.Unix(), tt.maxAge, err, tt.stale)
+		}
+	}
+}
+
+func TestSkewAndReplayWindow(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	if got := Skew(now.Unix()-90, now); got != -90*time.Second {
+		t.Errorf("Skew: got %s, want -1m30s", got)
+	}
+	if got := ReplayWindow(0); got != 2*DefaultTimestampMaxAge {
+		t.Errorf("ReplayWindow(0): got %s", got)
+	}
+	if got := ReplayWindow(5 * time.Minute); got != 10*time.Minute {
+		t.Errorf("ReplayWindow(5m): got %s", got)
+	}
+}
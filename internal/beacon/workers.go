@@ -0,0 +1,76 @@
+package beacon
+
+import (
+	"net"
+	"sync"
+)
+
+// queuePerWorker is how many packets may wait per worker before Submit
+// starts dropping them.
+const queuePerWorker = 4
+
+// packet is a received datagram waiting for a worker. buf came from the
+// pool's BufferPool; the worker returns it there once handled.
+type packet struct {
+	buf *[]byte
+	n   int
+	src *net.UDPAddr
+}
+
+// WorkerPool handles received packets on a fixed number of goroutines. The
+// read loop Submits each packet to a bounded queue instead of starting a
+// goroutine per packet, so a packet storm costs dropped packets rather than
+// unbounded goroutines and buffers.
+type WorkerPool struct {
+	bufs   *BufferPool
+	handle func(data []byte, src *net.UDPAddr)
+	queue  chan packet
+	wg     sync.WaitGroup
+}
+
+// NewWorkerPool starts workers goroutines calling handle for each submitted
+// packet. Packet buffers are returned to bufs after handle returns, so
+// handle must not keep data.
+func NewWorkerPool(workers int, bufs *BufferPool, handle func(data []byte, src *net.UDPAddr)) *WorkerPool {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &WorkerPool{
+		bufs:   bufs,
+		handle: handle,
+		queue:  make(chan packet, workers*queuePerWorker),
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *WorkerPool) work() {
+	defer p.wg.Done()
+	for pkt := range p.queue {
+		p.handle((*pkt.buf)[:pkt.n], pkt.src)
+		p.bufs.Put(pkt.buf)
+	}
+}
+
+// Submit queues the first n bytes of buf for handling and takes ownership
+// of buf. It never blocks: if the queue is full the packet is dropped, buf
+// goes straight back to the pool, and Submit returns false.
+func (p *WorkerPool) Submit(buf *[]byte, n int, src *net.UDPAddr) bool {
+	select {
+	case p.queue <- packet{buf: buf, n: n, src: src}:
+		return true
+	default:
+		p.bufs.Put(buf)
+		return false
+	}
+}
+
+// Close stops accepting packets and waits for queued ones to be handled.
+// Submit must not be called after Close.
+func (p *WorkerPool) Close() {
+	close(p.queue)
+	p.wg.Wait()
+}
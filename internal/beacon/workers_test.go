@@ -0,0 +1,106 @@
+package beacon
+
+import (
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWorkerPool_HandlesAndDrops(t *testing.T) {
+	bufs := NewBufferPool(64)
+	release := make(chan struct{})
+	var handled atomic.Int32
+	pool := NewWorkerPool(1, bufs, func(data []byte, src *net.UDPAddr) {
+		<-release
+		if string(data) != "beacon" {
+			t.Errorf("handled %q, want beacon", data)
+		}
+		handled.Add(1)
+	})
+
+	submit := func() bool {
+		buf := bufs.Get()
+		n := copy(*buf, "beacon")
+		return pool.Submit(buf, n, &net.UDPAddr{})
+	}
+
+	// One packet in the worker plus a full queue; the next is dropped
+	accepted := 0
+	for i := 0; i < 1+queuePerWorker+1; i++ {
+		if submit() {
+			accepted++
+		}
+	}
+	if accepted > 1+queuePerWorker {
+		t.Errorf("accepted %d packets, want at most %d", accepted, 1+queuePerWorker)
+	}
+
+	close(release)
+	pool.Close()
+	if int(handled.Load()) != accepted {
+		t.Errorf("handled %d packets, want %d", handled.Load(), accepted)
+	}
+}
+
+// BenchmarkReceive_WorkerPool is BenchmarkReceive_Pooled with the goroutine
+// per packet replaced by a worker pool. The goroutines and heap-MB metrics
+// stay flat however large b.N gets, where a goroutine per packet grows with
+// the backlog when handlers can't keep up.
+func BenchmarkReceive_WorkerPool(b *testing.B) {
+	bufs := NewBufferPool(4096)
+	pool := NewWorkerPool(32, bufs, func(data []byte, src *net.UDPAddr) {
+		_ = data[0]
+	})
+	b.ReportAllocs()
+
+	var peakGoroutines int
+	var dropped int
+	for i := 0; i < b.N; i++ {
+		buf := bufs.Get()
+		n := copy(*buf, benchPacket)
+		if !pool.Submit(buf, n, nil) {
+			dropped++
+		}
+		if i%1024 == 0 {
+			peakGoroutines = max(peakGoroutines, runtime.NumGoroutine())
+		}
+	}
+	pool.Close()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	b.ReportMetric(float64(peakGoroutines), "goroutines")
+	b.ReportMetric(float64(mem.HeapInuse)/(1<<20), "heap-MB")
+	b.ReportMetric(float64(dropped)/float64(b.N), "dropped/op")
+}
+
+// BenchmarkReceive_GoroutinePerPacket reports the same metrics for the
+// goroutine-per-packet hand-off the worker pool replaces.
+func BenchmarkReceive_GoroutinePerPacket(b *testing.B) {
+	bufs := NewBufferPool(4096)
+	var wg sync.WaitGroup
+	b.ReportAllocs()
+
+	var peakGoroutines int
+	for i := 0; i < b.N; i++ {
+		buf := bufs.Get()
+		n := copy(*buf, benchPacket)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer bufs.Put(buf)
+			_ = (*buf)[:n][0]
+		}()
+		if i%1024 == 0 {
+			peakGoroutines = max(peakGoroutines, runtime.NumGoroutine())
+		}
+	}
+	wg.Wait()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	b.ReportMetric(float64(peakGoroutines), "goroutines")
+	b.ReportMetric(float64(mem.HeapInuse)/(1<<20), "heap-MB")
+}
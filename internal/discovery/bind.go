@@ -0,0 +1,68 @@
+package discovery
+
+import (
+	"net"
+	"slices"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/sysinfo"
+)
+
+// restrictListener keeps the node from ingesting beacons from networks it
+// doesn't beacon on, such as a neighbouring VLAN on a multi-homed host,
+// though conn listens on the wildcard address so it gets broadcasts.
+//
+// When every segment is on one interface, conn is bound to it with
+// SO_BINDTODEVICE, which only Linux has. Otherwise (several interfaces,
+// another OS, or a kernel refusing the option) restrictListener returns
+// the segments' IPv4 networks, and the receiver drops packets from
+// sources outside them. IPv6 segments need no filter: the socket only
+// joins the all-nodes group on their links.
+func restrictListener(conn *net.UDPConn, segs []segment, ignore []string, log zerolog.Logger) []*net.IPNet {
+	var networks []*net.IPNet
+	var ifaces []string
+	for _, seg := range segs {
+		if seg.network != nil {
+			networks = append(networks, seg.network)
+		}
+		name := seg.iface
+		if name == "" {
+			name, _ = sysinfo.InterfaceName(seg.name, ignore)
+		}
+		if name != "" && !slices.Contains(ifaces, name) {
+			ifaces = append(ifaces, name)
+		}
+	}
+
+	if len(ifaces) != 1 {
+		log.Info().
+			Strs("interfaces", ifaces).
+			Msg("Segments aren't on a single interface, dropping beacons from outside their networks")
+		return networks
+	}
+	if err := bindToDevice(conn, ifaces[0]); err != nil {
+		log.Warn().
+			Err(err).
+			Str("interface", ifaces[0]).
+			Msg("Can't bind listener to interface, dropping beacons from outside its networks instead")
+		return networks
+	}
+	log.Info().Str("interface", ifaces[0]).Msg("Listener bound to interface")
+	return nil
+}
+
+// inNetworks reports whether a packet from ip may be handled: it's in
+// one of networks, or networks is empty. Loopback sources are always
+// allowed, for 'lanmon ping' on the node's own host.
+func inNetworks(ip net.IP, networks []*net.IPNet) bool {
+	if len(networks) == 0 || ip.IsLoopback() {
+		return true
+	}
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// bindToDevice makes conn receive (and send) only through the named
+// interface, with SO_BINDTODEVICE. Kernels before 5.7 require
+// CAP_NET_RAW for it.
+func bindToDevice(conn *net.UDPConn, name string) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, name)
+	}); err != nil {
+		return err
+	}
+	if sockErr != nil {
+		return fmt.Errorf("SO_BINDTODEVICE %s: %w", name, sockErr)
+	}
+	return nil
+}
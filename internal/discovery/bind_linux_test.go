@@ -0,0 +1,44 @@
+package discovery
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBindToDevice(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+
+	if err := bindToDevice(conn, "lanmon-test0"); err == nil {
+		t.Error("expected an error binding to a missing interface")
+	}
+
+	err = bindToDevice(conn, "lo")
+	if errors.Is(err, syscall.EPERM) {
+		t.Skip("skipping: binding to an interface needs CAP_NET_RAW on this kernel")
+	}
+	if err != nil {
+		t.Fatalf("binding to lo: %v", err)
+	}
+
+	// Loopback traffic still arrives on a socket bound to lo
+	sender, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer sender.Close()
+	if _, err := sender.Write([]byte("x")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	buf := make([]byte, 1)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := conn.ReadFromUDP(buf); err != nil {
+		t.Errorf("reading on the bound socket: %v", err)
+	}
+}
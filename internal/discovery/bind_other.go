@@ -0,0 +1,14 @@
+//go:build !linux
+
+package discovery
+
+import (
+	"errors"
+	"net"
+)
+
+// bindToDevice isn't available outside Linux; restrictListener falls back
+// to filtering by source address.
+func bindToDevice(conn *net.UDPConn, name string) error {
+	return errors.New("binding to an interface is only supported on Linux")
+}
@@ -0,0 +1,55 @@
+package discovery
+
+import (
+	"net"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestInNetworks(t *testing.T) {
+	_, vlan1, _ := net.ParseCIDR("10.0.1.0/24")
+	_, vlan2, _ := net.ParseCIDR("10.0.2.0/24")
+	networks := []*net.IPNet{vlan1, vlan2}
+
+	for ip, want := range map[string]bool{
+		"10.0.1.7":  true,
+		"10.0.2.7":  true,
+		"10.0.3.7":  false,
+		"127.0.0.1": true,
+	} {
+		if got := inNetworks(net.ParseIP(ip), networks); got != want {
+			t.Errorf("%s: got %v, want %v", ip, got, want)
+		}
+	}
+	if !inNetworks(net.ParseIP("192.168.0.1"), nil) {
+		t.Error("expected every source to be handled without networks")
+	}
+}
+
+func TestRestrictListener_FallsBackToNetworks(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+
+	_, vlan1, _ := net.ParseCIDR("10.0.1.0/24")
+	_, vlan2, _ := net.ParseCIDR("10.0.2.0/24")
+	for name, segs := range map[string][]segment{
+		// Can't be bound to: the interface doesn't exist
+		"one interface": {
+			{name: "10.0.1.0/24", iface: "lanmon-test0", network: vlan1},
+			{name: "10.0.2.0/24", iface: "lanmon-test0", network: vlan2},
+		},
+		"two interfaces": {
+			{name: "eth0", iface: "lanmon-test0", network: vlan1},
+			{name: "eth1", iface: "lanmon-test1", network: vlan2},
+		},
+	} {
+		networks := restrictListener(conn, segs, nil, zerolog.Nop())
+		if len(networks) != 2 || networks[0] != vlan1 || networks[1] != vlan2 {
+			t.Errorf("%s: got networks %v, want %v and %v", name, networks, vlan1, vlan2)
+		}
+	}
+}
@@ -1,91 +1,552 @@
 package discovery
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"math"
 	"net"
+	"runtime/debug"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
-	"github.com/vmihailenco/msgpack/v5"
+	"golang.org/x/net/ipv6"
 
 	"lanmon/internal/beacon"
 	"lanmon/internal/hosts"
+	"lanmon/internal/metrics"
 	"lanmon/internal/store"
 	"lanmon/internal/sysinfo"
-
 )
 
-const (
-	maxPacketSize   = 4096
-	timestampMaxAge = 60 // seconds
-)
+// DefaultWorkers is the packet handler count when Options.Workers is 0.
+const DefaultWorkers = 32
+
+// AutoRange is the network range that is resolved to the local network when
+// the node starts.
+const AutoRange = "auto"
+
+// allNodesIPv6 is the link-local all-nodes multicast group, IPv6's stand-in
+// for a subnet broadcast address.
+var allNodesIPv6 = net.ParseIP("ff02::1")
+
+// Options configures a discovery node.
+type Options struct {
+	// NetworkRanges are the networks to beacon on, one segment each. A
+	// range selects the local interface to beacon from and the broadcast
+	// address to send to; an IPv6 range beacons to the all-nodes multicast
+	// group (ff02::1) on the matching interface instead. Ranges must all be
+	// of one address family. AutoRange ("auto") stands for the IPv4 network
+	// of the default interface (see sysinfo.AutoRange). Ignored when
+	// Interfaces is set.
+	NetworkRanges []string
+	// Interface, if set, pins range mode to the named interface: beacons
+	// carry its address in each range (or its network stands for "auto")
+	// instead of the first matching interface's, which is ambiguous when
+	// several have addresses in overlapping ranges. The ranges still decide
+	// the broadcast addresses.
+	Interface string
+	// Interfaces enables per-interface mode for multi-homed hosts: one
+	// broadcast loop per named interface, each advertising that interface's
+	// own IP/MAC to its own segment. A single "auto" entry selects every
+	// up, non-loopback interface with an IPv4 address.
+	Interfaces []string
+	// IgnoreInterfaces are glob patterns (case-insensitive) for interfaces
+	// never to beacon from, such as "docker*", even if they match a range
+	// or "auto". Interfaces named explicitly are used regardless.
+	IgnoreInterfaces []string
+	Port             int
+	Interval         time.Duration
+	// Jitter spreads broadcasts out: each cycle sleeps Interval plus or
+	// minus a random amount up to Jitter, so nodes started together don't
+	// beacon in lockstep. Zero keeps a fixed period.
+	Jitter time.Duration
+	// Schedule, if set, supplies the interval and jitter in place of
+	// Interval and Jitter, so they can be changed while the node runs.
+	Schedule *Schedule
+	Secret   string
+	// PreviousSecret is a secret being rotated out, still accepted on
+	// receive (see beacon.PacketOptions).
+	PreviousSecret string
+	// TimestampMaxAge is how far a beacon's timestamp may be from our
+	// clock, either way, before it's dropped as stale (default
+	// beacon.DefaultTimestampMaxAge).
+	TimestampMaxAge time.Duration
+	// VerifySourcePort drops packets whose UDP source port isn't Port
+	// before any HMAC work. Nodes always beacon (and depart) from their
+	// listen port, but the deprecated agent sends from an ephemeral port and
+	// doesn't get through with this on.
+	VerifySourcePort bool
+	// Tags are sent in every beacon for peers to display and group by.
+	Tags []string
+	// MaxPacketsPerMin is how many packets a single source IP may send per
+	// minute before the rest are dropped unread. Zero disables the limit.
+	MaxPacketsPerMin int
+	// Workers is how many goroutines handle received packets (default
+	// DefaultWorkers). Packets arriving faster than they can be handled
+	// are dropped.
+	Workers int
+	// EncryptPayload encrypts outgoing beacons (see beacon.PacketOptions).
+	// Incoming beacons are accepted either way.
+	EncryptPayload bool
+	// CompressPayload compresses outgoing beacons where that makes them
+	// smaller (see beacon.PacketOptions). Incoming beacons are accepted
+	// either way.
+	CompressPayload bool
+	// LegacyKey keeps using the pre-KDF shared secret key while older nodes
+	// remain (see beacon.PacketOptions).
+	LegacyKey bool
+	// ListenAllInterfaces handles beacons arriving on any interface, as
+	// nodes did before the listener was restricted to the segments'
+	// interface or networks (see restrictListener).
+	ListenAllInterfaces bool
+	// DebugPackets logs every dropped packet at warn level with the reason,
+	// source, size, first bytes and, for stale beacons, the clock skew.
+	// Drops that are normally silent or debug-only are included, so this is
+	// for diagnosing discovery rather than everyday use.
+	DebugPackets bool
+	// Hosts controls how /etc/hosts is rewritten as peers are discovered.
+	// Nil leaves the hosts file alone.
+	Hosts *hosts.Options
+}
 
-// StartNode begins the P2P discovery node (broadcast + listen).
-func StartNode(networkRange string, port int, interval time.Duration, secret string, db *store.Store, log zerolog.Logger) error {
-	// Auto-detect interface and info matching the network range
-	info, err := sysinfo.Collect(networkRange)
-	if err != nil {
-		return fmt.Errorf("auto-detecting interface: %w", err)
+func (o Options) schedule() *Schedule {
+	if o.Schedule != nil {
+		return o.Schedule
 	}
+	return NewSchedule(o.Interval, o.Jitter)
+}
 
-	log.Info().
-		Str("interface_ip", info.IPAddress).
-		Str("mac", info.MACAddress).
-		Str("network_range", networkRange).
-		Msg("Node interface detected")
+func (o Options) packetOptions() *beacon.PacketOptions {
+	return &beacon.PacketOptions{
+		Encrypt:        o.EncryptPayload,
+		Compress:       o.CompressPayload,
+		LegacyKey:      o.LegacyKey,
+		PreviousSecret: o.PreviousSecret,
+	}
+}
 
-	// Calculate broadcast address
-	_, ipNet, err := net.ParseCIDR(networkRange)
+// staticInfoTTL is how long slow-changing system information (CPU, memory,
+// OS...) is reused between beacons before being collected again.
+const staticInfoTTL = 10 * time.Minute
+
+// segment is one network the node beacons on.
+type segment struct {
+	name    string // network range or interface name, for logging
+	target  *net.UDPAddr
+	collect func() (*sysinfo.SystemInfo, error)
+	// iface and why record the interface beaconed from and why it was
+	// chosen, for logging. iface is empty when the range picks it afresh
+	// for each beacon.
+	iface, why string
+	// network is the IPv4 network beaconed on, nil for IPv6.
+	network *net.IPNet
+}
+
+// StartNode begins the P2P discovery node (broadcast + listen) and blocks
+// until ctx is cancelled. On cancellation it stops the broadcast loops, sends
+// a departure beacon on every segment so peers mark this node inactive right
+// away, then closes the socket and waits for the listener to exit.
+func StartNode(ctx context.Context, opts Options, db *store.Store, log zerolog.Logger) error {
+	segs, err := segments(opts)
 	if err != nil {
-		return fmt.Errorf("parsing network range: %w", err)
+		return err
 	}
-	broadcastIP := getBroadcastIP(ipNet)
-	broadcastAddr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf("%s:%d", broadcastIP, port))
-	if err != nil {
-		return fmt.Errorf("resolving broadcast address: %w", err)
+
+	// Every local MAC we beacon with counts as "self" on receive
+	self := make(map[string]bool)
+	for _, seg := range segs {
+		info, err := seg.collect()
+		if err != nil {
+			return fmt.Errorf("auto-detecting interface: %w", err)
+		}
+		self[info.MACAddress] = true
+
+		iface := seg.iface
+		if iface == "" {
+			iface, _ = sysinfo.InterfaceName(seg.name, opts.IgnoreInterfaces)
+		}
+		log.Info().
+			Str("segment", seg.name).
+			Str("interface", iface).
+			Str("reason", seg.why).
+			Str("interface_ip", info.IPAddress).
+			Str("mac", info.MACAddress).
+			Str("broadcast_target", seg.target.String()).
+			Msg("Node interface detected")
 	}
 
 	// Create UDP connection for both sending and receiving
-	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero, Port: port})
+	network, laddr := "udp4", &net.UDPAddr{IP: net.IPv4zero, Port: opts.Port}
+	if isIPv6Range(opts) {
+		network, laddr = "udp6", &net.UDPAddr{IP: net.IPv6unspecified, Port: opts.Port}
+	}
+	conn, err := net.ListenUDP(network, laddr)
 	if err != nil {
-		return fmt.Errorf("listening on UDP port %d: %w", port, err)
+		return fmt.Errorf("listening on UDP port %d: %w", opts.Port, err)
+	}
+	if network == "udp6" {
+		if err := joinAllNodes(conn, segs); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	r := newReceiver(self, opts, db, log)
+	if !opts.ListenAllInterfaces {
+		r.networks = restrictListener(conn, segs, opts.IgnoreInterfaces, log)
 	}
-	// Note: We don't defer conn.Close() here because it's a long-running node,
-	// and we might want to manage it differently if we added graceful shutdown.
 
 	log.Info().
-		Str("broadcast_target", broadcastAddr.String()).
-		Int("port", port).
-		Dur("interval", interval).
+		Int("segments", len(segs)).
+		Int("port", opts.Port).
+		Dur("interval", opts.schedule().Interval()).
 		Msg("P2P Discovery node started")
 
-	// Start listener in a goroutine
-	go listen(conn, info.MACAddress, secret, db, log)
+	err = run(ctx, conn, segs, r)
 
-	// Start broadcast loop
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+	log.Info().Msg("P2P Discovery node stopped")
+	return err
+}
 
-	// Initial broadcast
-	broadcast(conn, broadcastAddr, secret, networkRange, log)
+// run drives the listener and broadcast loops on conn until ctx is
+// cancelled, and owns conn: it is closed before run returns. If the
+// listener dies or the broadcasts stall first, run stops early and returns
+// an error wrapping ErrStopped, without sending a departure beacon.
+func run(ctx context.Context, conn *net.UDPConn, segs []segment, r *receiver) error {
+	opts, log := r.opts, r.log
+	sched := opts.schedule()
+	opts.Schedule = sched
+	r.conn, r.segs = conn, segs
+
+	listenErr := make(chan error, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				listenErr <- fmt.Errorf("listener panicked: %v", v)
+			}
+		}()
+		listenErr <- r.listen(conn)
+	}()
+
+	// Start one broadcast loop per segment
+	loopCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	h := newHealth()
+	var wg sync.WaitGroup
+	for _, seg := range segs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			broadcastLoop(loopCtx, conn, seg, opts, h, log)
+		}()
+	}
+
+	// Watch for the listener exiting or the broadcasts stalling
+	var failure error
+	listening := true
+	check := time.NewTimer(sched.Interval())
+	defer check.Stop()
+watch:
+	for {
+		select {
+		case <-ctx.Done():
+			break watch
+		case err := <-listenErr:
+			listening = false
+			if err == nil {
+				err = errors.New("listener exited")
+			}
+			failure = fmt.Errorf("%w: %w", ErrStopped, err)
+			break watch
+		case <-check.C:
+			interval := sched.Interval()
+			if since := h.sinceSent(); since > stallIntervals*interval {
+				failure = fmt.Errorf("%w: no beacon sent in %s", ErrStopped, since.Round(time.Second))
+				break watch
+			}
+			check.Reset(interval)
+		}
+	}
+	stop()
+	wg.Wait()
+
+	// Depart from the listen socket so the beacon carries our listen port
+	// and passes peers' source port check
+	if failure == nil {
+		if err := sendDeparture(conn, segs, opts, log); err != nil {
+			log.Warn().Err(err).Msg("Failed to send departure beacon, peers will expire this node")
+		}
+	}
+
+	conn.Close()
+	if listening {
+		<-listenErr
+	}
+	return failure
+}
+
+// segments resolves the networks to beacon on: one segment per network
+// range, or per interface in per-interface mode. The segments share one
+// cached collector, since only their addresses differ.
+func segments(opts Options) ([]segment, error) {
+	collector := sysinfo.NewCachedCollector(staticInfoTTL)
+	if len(opts.Interfaces) == 0 {
+		if len(opts.NetworkRanges) == 0 {
+			return nil, fmt.Errorf("no network range configured")
+		}
+		ranges, err := resolveRanges(opts.NetworkRanges, opts.Interface, opts.IgnoreInterfaces)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := rangesFamily(ranges); err != nil {
+			return nil, err
+		}
+
+		segs := make([]segment, 0, len(ranges))
+		for _, networkRange := range ranges {
+			seg, err := rangeSegment(networkRange, opts, collector)
+			if err != nil {
+				return nil, err
+			}
+			segs = append(segs, seg)
+		}
+		return segs, nil
+	}
+
+	names, why := opts.Interfaces, "listed in interfaces"
+	if len(names) == 1 && names[0] == "auto" {
+		names, why = nil, "up interface with IPv4 (interfaces = auto)"
+	}
+	ifaces, err := sysinfo.Interfaces(names, opts.IgnoreInterfaces)
+	if err != nil {
+		return nil, fmt.Errorf("resolving interfaces: %w", err)
+	}
+	segs := interfaceSegments(ifaces, opts.Port, collector)
+	for i := range segs {
+		segs[i].why = why
+	}
+	return segs, nil
+}
+
+// resolveRanges returns ranges with AutoRange replaced by the network of
+// iface or, if that's empty, of the default interface, and any duplicate
+// that makes dropped.
+func resolveRanges(ranges []string, iface string, ignore []string) ([]string, error) {
+	resolved := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		if r == AutoRange {
+			var err error
+			if r, err = sysinfo.AutoRange(iface, ignore); err != nil {
+				return nil, err
+			}
+		}
+		if !slices.Contains(resolved, r) {
+			resolved = append(resolved, r)
+		}
+	}
+	return resolved, nil
+}
+
+// rangeSegment beacons on networkRange from the interface with an address
+// in it, or from opts.Interface if that's set. The address is looked up
+// for each beacon, so it follows DHCP changes.
+func rangeSegment(networkRange string, opts Options, collector *sysinfo.CachedCollector) (segment, error) {
+	_, ipNet, err := net.ParseCIDR(networkRange)
+	if err != nil {
+		return segment{}, fmt.Errorf("parsing network range: %w", err)
+	}
+
+	seg := segment{
+		name: networkRange,
+		why:  "has an address in the network range",
+		collect: func() (*sysinfo.SystemInfo, error) {
+			return collector.Collect(networkRange, opts.IgnoreInterfaces)
+		},
+	}
+	if opts.Interface != "" {
+		seg.iface, seg.why = opts.Interface, "set by interface"
+		seg.collect = func() (*sysinfo.SystemInfo, error) {
+			iface, err := sysinfo.InterfaceInRange(opts.Interface, networkRange)
+			if err != nil {
+				return nil, err
+			}
+			return collector.CollectInterface(iface), nil
+		}
+	}
+
+	zone := ""
+	if ipNet.IP.To4() == nil {
+		// Link-local multicast must name the link to send on
+		if zone = seg.iface; zone == "" {
+			if zone, err = sysinfo.InterfaceName(networkRange, opts.IgnoreInterfaces); err != nil {
+				return segment{}, err
+			}
+		}
+	} else {
+		seg.network = ipNet
+	}
+	seg.target = rangeTarget(ipNet, opts.Port, zone)
+	return seg, nil
+}
+
+// rangesFamily reports whether ranges are all IPv6 (true) or all IPv4
+// (false). The node listens on a single socket, so mixing is an error.
+func rangesFamily(ranges []string) (ipv6 bool, err error) {
+	for i, r := range ranges {
+		_, ipNet, err := net.ParseCIDR(r)
+		if err != nil {
+			return false, fmt.Errorf("parsing network range: %w", err)
+		}
+		is6 := ipNet.IP.To4() == nil
+		if i > 0 && is6 != ipv6 {
+			return false, fmt.Errorf("network ranges mix IPv4 and IPv6 (%s, %s)", ranges[0], r)
+		}
+		ipv6 = is6
+	}
+	return ipv6, nil
+}
+
+// isIPv6Range reports whether the node beacons on IPv6 network ranges.
+// Per-interface mode is IPv4 only.
+func isIPv6Range(opts Options) bool {
+	if len(opts.Interfaces) > 0 {
+		return false
+	}
+	ipv6, err := rangesFamily(opts.NetworkRanges)
+	return err == nil && ipv6
+}
 
-	for range ticker.C {
-		broadcast(conn, broadcastAddr, secret, networkRange, log)
+// rangeTarget is where beacons for ipNet go: the subnet broadcast address
+// for IPv4, or the all-nodes group on the zone's link for IPv6.
+func rangeTarget(ipNet *net.IPNet, port int, zone string) *net.UDPAddr {
+	if ipNet.IP.To4() == nil {
+		return &net.UDPAddr{IP: allNodesIPv6, Port: port, Zone: zone}
 	}
+	return &net.UDPAddr{IP: getBroadcastIP(ipNet), Port: port}
+}
 
+// joinAllNodes joins the IPv6 all-nodes group on each segment's link, so
+// the socket receives peers' beacons, and sends through that link.
+func joinAllNodes(conn *net.UDPConn, segs []segment) error {
+	pc := ipv6.NewPacketConn(conn)
+	for _, seg := range segs {
+		iface, err := net.InterfaceByName(seg.target.Zone)
+		if err != nil {
+			return fmt.Errorf("finding interface %s: %w", seg.target.Zone, err)
+		}
+		if err := pc.JoinGroup(iface, &net.UDPAddr{IP: allNodesIPv6}); err != nil {
+			return fmt.Errorf("joining %s on %s: %w", allNodesIPv6, iface.Name, err)
+		}
+		if err := pc.SetMulticastInterface(iface); err != nil {
+			return fmt.Errorf("setting multicast interface %s: %w", iface.Name, err)
+		}
+	}
 	return nil
 }
 
-func broadcast(conn *net.UDPConn, addr *net.UDPAddr, secret string, networkRange string, log zerolog.Logger) {
-	info, err := sysinfo.Collect(networkRange)
+func interfaceSegments(ifaces []sysinfo.Interface, port int, collector *sysinfo.CachedCollector) []segment {
+	segs := make([]segment, 0, len(ifaces))
+	for _, iface := range ifaces {
+		segs = append(segs, segment{
+			name:    iface.Name,
+			iface:   iface.Name,
+			network: iface.Network,
+			target:  &net.UDPAddr{IP: getBroadcastIP(iface.Network), Port: port},
+			collect: func() (*sysinfo.SystemInfo, error) {
+				return collector.CollectInterface(iface), nil
+			},
+		})
+	}
+	return segs
+}
+
+func broadcastLoop(ctx context.Context, conn *net.UDPConn, seg segment, opts Options, h *health, log zerolog.Logger) {
+	sched := opts.schedule()
+	timer := time.NewTimer(sched.next())
+	defer timer.Stop()
+
+	// Initial broadcast
+	if broadcast(conn, seg, opts, log) {
+		h.sent()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			if broadcast(conn, seg, opts, log) {
+				h.sent()
+			}
+			timer.Reset(sched.next())
+		}
+	}
+}
+
+// broadcast sends one beacon on seg and reports whether it went out.
+func broadcast(conn *net.UDPConn, seg segment, opts Options, log zerolog.Logger) bool {
+	info, err := seg.collect()
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to collect system info for broadcast")
-		return
+		log.Error().Err(err).Str("segment", seg.name).Msg("Failed to collect system info for broadcast")
+		return false
+	}
+
+	packet, dropped, err := beacon.EncodeFitting(newPayload(info, opts.Tags), opts.Secret, opts.packetOptions())
+	if err != nil {
+		log.Error().Err(err).Msg("Marshaling payload failed")
+		return false
+	}
+	beacon.WarnPacketSize(log, packet, dropped)
+
+	_, err = conn.WriteToUDP(packet, seg.target)
+	if err != nil {
+		log.Error().Err(err).Str("target", seg.target.String()).Msg("Failed to send broadcast beacon")
+		return false
+	}
+
+	log.Debug().
+		Str("target", seg.target.String()).
+		Int("bytes", len(packet)).
+		Msg("Beacon broadcasted")
+	return true
+}
+
+// sendDeparture broadcasts a final, HMAC-signed departure beacon on every
+// segment. Callers should treat a failure as non-fatal: peers will still
+// expire the node after the stale threshold.
+func sendDeparture(conn *net.UDPConn, segs []segment, opts Options, log zerolog.Logger) error {
+	var errs []error
+	for _, seg := range segs {
+		info, err := seg.collect()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("collecting system info for %s: %w", seg.name, err))
+			continue
+		}
+
+		payload := newPayload(info, opts.Tags)
+		payload.Departing = true
+
+		packet, _, err := beacon.EncodeFitting(payload, opts.Secret, opts.packetOptions())
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if _, err := conn.WriteToUDP(packet, seg.target); err != nil {
+			errs = append(errs, fmt.Errorf("writing departure beacon to %s: %w", seg.target, err))
+			continue
+		}
+
+		log.Info().Str("target", seg.target.String()).Msg("Departure beacon sent")
 	}
+	return errors.Join(errs...)
+}
 
-	payload := &beacon.BeaconPayload{
-		Version:    1,
+func newPayload(info *sysinfo.SystemInfo, tags []string) *beacon.BeaconPayload {
+	return &beacon.BeaconPayload{
+		Nonce:      beacon.NewNonce(),
+		Version:    beacon.CurrentVersion,
 		Timestamp:  time.Now().Unix(),
 		MACAddress: info.MACAddress,
 		IPAddress:  info.IPAddress,
@@ -96,77 +557,222 @@ func broadcast(conn *net.UDPConn, addr *net.UDPAddr, secret string, networkRange
 			Arch:   info.Arch,
 		},
 		Hardware: beacon.HWInfo{
-			CPUModel:  info.CPUModel,
-			CPUCores:  info.CPUCores,
-			MemoryGB:  info.MemoryGB,
-			DiskCount: info.DiskCount,
+			CPUModel:    info.CPUModel,
+			CPUCores:    info.CPUCores,
+			MemoryGB:    info.MemoryGB,
+			DiskCount:   info.DiskCount,
+			DiskTotalGB: info.DiskTotalGB,
+			DiskFreeGB:  info.DiskFreeGB,
+			GPUs:        info.GPUs,
 		},
+		Uptime: info.Uptime,
+		Load:   loadInfo(info),
+		Tags:   tags,
 	}
+}
 
-	data, err := msgpack.Marshal(payload)
-	if err != nil {
-		log.Error().Err(err).Msg("Marshaling payload failed")
-		return
+// loadInfo returns info's load averages, or nil if none were collected.
+func loadInfo(info *sysinfo.SystemInfo) *beacon.LoadInfo {
+	if info.Load1 == 0 && info.Load5 == 0 && info.Load15 == 0 {
+		return nil
 	}
+	return &beacon.LoadInfo{Load1: info.Load1, Load5: info.Load5, Load15: info.Load15}
+}
 
-	hmacSig := beacon.ComputeHMAC(data, secret)
-	packet := append(hmacSig, data...)
+// receiver is the receive side of a node: the listener and the state its
+// packet handlers share.
+type receiver struct {
+	self   map[string]bool // local MACs, whose beacons are ignored
+	opts   Options
+	db     *store.Store
+	nonces *beacon.NonceCache
+	log    zerolog.Logger
+
+	rate *rateTracker
+
+	// conn and segs let the receiver answer probes; run sets them. A
+	// receiver without them ignores probes.
+	conn *net.UDPConn
+	segs []segment
+	// networks, if set, are the only source networks handled (see
+	// restrictListener).
+	networks []*net.IPNet
+}
 
-	_, err = conn.WriteToUDP(packet, addr)
-	if err != nil {
-		log.Error().Err(err).Str("target", addr.String()).Msg("Failed to send broadcast beacon")
-		return
-	}
+func newReceiver(self map[string]bool, opts Options, db *store.Store, log zerolog.Logger) *receiver {
+	return &receiver{
+		self:   self,
+		opts:   opts,
+		db:     db,
+		nonces: beacon.NewNonceCache(beacon.ReplayWindow(opts.TimestampMaxAge)),
+		log:    log,
 
-	log.Debug().
-		Str("target", addr.String()).
-		Int("bytes", len(packet)).
-		Msg("Beacon broadcasted")
+		rate: newRateTracker(opts.MaxPacketsPerMin),
+	}
 }
 
-func listen(conn *net.UDPConn, selfMAC string, secret string, db *store.Store, log zerolog.Logger) {
-	buf := make([]byte, maxPacketSize)
+// listen reads packets until conn is closed, returning nil, or until
+// maxReadErrors reads in a row fail.
+func (r *receiver) listen(conn *net.UDPConn) error {
+	log := r.log
+
+	workers := r.opts.Workers
+	if workers == 0 {
+		workers = DefaultWorkers
+	}
+
+	// One spare byte lets ReadPacket detect datagrams over beacon.MaxPacketSize
+	pool := beacon.NewBufferPool(beacon.MaxPacketSize + 1)
+	handlers := beacon.NewWorkerPool(workers, pool, r.handlePacket)
+	defer handlers.Close()
+
+	readErrors := 0
 	for {
-		n, src, err := conn.ReadFromUDP(buf)
+		buf := pool.Get()
+		n, src, err := beacon.ReadPacket(conn, *buf)
+		if errors.Is(err, beacon.ErrOversized) {
+			r.drop(log.Warn(), "size", (*buf)[:n], src).
+				Int("max_bytes", beacon.MaxPacketSize).
+				Msg("Oversized packet, truncated")
+			pool.Put(buf)
+			continue
+		}
+		if errors.Is(err, net.ErrClosed) {
+			// StartNode closed the socket on shutdown
+			pool.Put(buf)
+			return nil
+		}
 		if err != nil {
+			pool.Put(buf)
+			if readErrors++; readErrors >= maxReadErrors {
+				return fmt.Errorf("reading from UDP: %w", err)
+			}
 			log.Error().Err(err).Msg("Error reading from UDP")
 			continue
 		}
+		readErrors = 0
+
+		if !sourcePortAllowed(src, r.opts) {
+			r.drop(log.Debug(), "source_port", (*buf)[:n], src).Msg("Dropping packet from unexpected source port")
+			pool.Put(buf)
+			continue
+		}
 
-		packet := make([]byte, n)
-		copy(packet, buf[:n])
+		if !inNetworks(src.IP, r.networks) {
+			r.drop(log.Debug(), "network", (*buf)[:n], src).Msg("Dropping packet from outside the node's networks")
+			pool.Put(buf)
+			continue
+		}
+
+		if !r.rate.allow(src.IP.String(), time.Now()) {
+			metrics.RateLimited.Inc()
+			r.drop(log.Debug(), "rate_limit", (*buf)[:n], src).Msg("Rate limit exceeded, dropping packet")
+			pool.Put(buf)
+			continue
+		}
 
-		go handlePacket(packet, src, selfMAC, secret, db, log)
+		if !handlers.Submit(buf, n, src) {
+			log.Debug().Str("src", src.String()).Msg("Packet handlers busy, dropping packet")
+		}
 	}
 }
 
-func handlePacket(packet []byte, src *net.UDPAddr, selfMAC string, secret string, db *store.Store, log zerolog.Logger) {
-	if len(packet) <= beacon.HMACSize {
-		return
+// recoverPacket, deferred by handlePacket, logs a panic raised while
+// handling a packet from src and lets the worker carry on, so one bad
+// packet can't take down the node.
+func recoverPacket(log zerolog.Logger, src *net.UDPAddr) {
+	if v := recover(); v != nil {
+		log.Error().
+			Interface("panic", v).
+			Str("src", src.String()).
+			Str("stack", string(debug.Stack())).
+			Msg("Recovered from panic handling packet")
+	}
+}
+
+// debugHeadBytes is how much of a dropped packet DebugPackets logs.
+const debugHeadBytes = 32
+
+// drop returns the event to log a packet dropped for reason on. Normally
+// that's ev, which may be nil to drop silently, with the source added; with
+// DebugPackets it's a warning carrying the packet's details.
+func (r *receiver) drop(ev *zerolog.Event, reason string, packet []byte, src *net.UDPAddr) *zerolog.Event {
+	if !r.opts.DebugPackets {
+		return ev.Str("src", src.String())
 	}
+	return r.log.Warn().
+		Str("reason", reason).
+		Str("src", src.String()).
+		Int("bytes", len(packet)).
+		Hex("head", packet[:min(len(packet), debugHeadBytes)])
+}
+
+// sourcePortAllowed reports whether a packet from src passes the optional
+// source port check.
+func sourcePortAllowed(src *net.UDPAddr, opts Options) bool {
+	return !opts.VerifySourcePort || src.Port == opts.Port
+}
+
+func (r *receiver) handlePacket(packet []byte, src *net.UDPAddr) {
+	log, db := r.log, r.db
+	defer recoverPacket(log, src)
+
+	metrics.BeaconsReceived.Inc()
 
-	sig := packet[:beacon.HMACSize]
-	data := packet[beacon.HMACSize:]
+	payload, err := beacon.DecodeVerified(packet, r.opts.Secret, r.opts.TimestampMaxAge, r.opts.packetOptions())
+	switch {
+	case errors.Is(err, beacon.ErrTooSmall):
+		r.drop(nil, "size", packet, src).Msg("Packet too small")
+		return
+	case errors.Is(err, beacon.ErrHMAC):
+		metrics.HMACFailures.Inc()
+		r.drop(log.Warn(), "hmac", packet, src).Msg("HMAC validation failed")
+		return
+	case errors.Is(err, beacon.ErrDecrypt):
+		r.drop(log.Warn(), "decrypt", packet, src).Msg("Failed to decrypt beacon")
+		return
+	case errors.Is(err, beacon.ErrInflate):
+		r.drop(log.Warn(), "inflate", packet, src).Msg("Failed to inflate beacon")
+		return
+	case errors.Is(err, beacon.ErrUnsupportedVersion):
+		r.drop(log.Debug(), "version", packet, src).Err(err).Msg("Skipping beacon")
+		return
+	case errors.Is(err, beacon.ErrStaleTimestamp):
+		metrics.StaleDrops.Inc()
+		r.drop(log.Warn(), "timestamp", packet, src).
+			Str("hostname", payload.Hostname).
+			Int64("skew_seconds", int64(beacon.Skew(payload.Timestamp, time.Now())/time.Second)).
+			Msg("Stale timestamp in beacon")
+		return
+	case err != nil:
+		r.drop(log.Error(), "decode", packet, src).Err(err).Msg("Failed to unmarshal beacon")
+		return
+	}
 
-	if !beacon.VerifyHMAC(sig, data, secret) {
-		log.Warn().Str("src", src.String()).Msg("HMAC validation failed")
+	// Ignore beacons from self, though 'lanmon ping' may probe its own host
+	if r.self[payload.MACAddress] && !payload.Probe {
 		return
 	}
 
-	var payload beacon.BeaconPayload
-	if err := msgpack.Unmarshal(data, &payload); err != nil {
-		log.Error().Err(err).Str("src", src.String()).Msg("Failed to unmarshal beacon")
+	if len(payload.Nonce) > 0 && r.nonces.Seen(payload.Nonce) {
+		r.drop(log.Warn(), "replay", packet, src).Str("hostname", payload.Hostname).Msg("Replayed beacon")
 		return
 	}
 
-	// Ignore beacons from self
-	if payload.MACAddress == selfMAC {
+	if payload.Probe {
+		r.answer(src)
 		return
 	}
 
-	now := time.Now().Unix()
-	if math.Abs(float64(now-payload.Timestamp)) > timestampMaxAge {
-		log.Warn().Str("src", src.String()).Msg("Stale timestamp in beacon")
+	if payload.Departing {
+		if err := db.MarkInactive(payload.MACAddress); err != nil {
+			log.Debug().Err(err).Str("src", src.String()).Msg("Ignoring departure beacon")
+			return
+		}
+		log.Info().
+			Str("hostname", payload.Hostname).
+			Str("ip", payload.IPAddress).
+			Msg("Peer departed")
 		return
 	}
 
@@ -175,17 +781,43 @@ func handlePacket(packet []byte, src *net.UDPAddr, selfMAC string, secret string
 		Str("ip", payload.IPAddress).
 		Msg("Peer discovered")
 
-	if err := db.Upsert(payload); err != nil {
+	if err := db.UpsertFrom(*payload, src.IP); err != nil {
 		log.Error().Err(err).Msg("Database write error")
 		return
 	}
 
 	// Sync /etc/hosts for resolution
-	if err := hosts.Sync(db); err != nil {
-		log.Warn().Err(err).Msg("Failed to sync /etc/hosts (permission denied?)")
+	if r.opts.Hosts != nil {
+		if err := hosts.Sync(db, *r.opts.Hosts, log); err != nil {
+			log.Warn().Err(err).Msg("Failed to sync /etc/hosts (permission denied?)")
+		}
 	}
 }
 
+// answer replies to a probe from src with a beacon of our own, sent from
+// the listen socket like any other.
+func (r *receiver) answer(src *net.UDPAddr) {
+	log := r.log
+	if r.conn == nil || len(r.segs) == 0 {
+		return
+	}
+
+	info, err := r.segs[0].collect()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to collect system info for probe answer")
+		return
+	}
+	packet, _, err := beacon.EncodeFitting(newPayload(info, r.opts.Tags), r.opts.Secret, r.opts.packetOptions())
+	if err != nil {
+		log.Error().Err(err).Msg("Marshaling payload failed")
+		return
+	}
+	if _, err := r.conn.WriteToUDP(packet, src); err != nil {
+		log.Warn().Err(err).Str("src", src.String()).Msg("Failed to answer probe")
+		return
+	}
+	log.Debug().Str("src", src.String()).Msg("Answered probe")
+}
 
 func getBroadcastIP(n *net.IPNet) net.IP {
 	ip := n.IP.To4()
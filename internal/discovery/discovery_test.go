@@ -0,0 +1,695 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/beacon"
+	"lanmon/internal/hosts"
+	"lanmon/internal/metrics"
+	"lanmon/internal/store"
+	"lanmon/internal/sysinfo"
+)
+
+const testSecret = "test-shared-secret"
+
+var (
+	selfMACs = map[string]bool{"aa:bb:cc:dd:ee:00": true}
+	testOpts = Options{Port: 5678, Secret: testSecret}
+)
+
+func testStore(t *testing.T) *store.Store {
+	t.Helper()
+	s, err := store.New(filepath.Join(t.TempDir(), "test.db"), zerolog.Nop())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func samplePayload(mac, hostname, ip string) *beacon.BeaconPayload {
+	return &beacon.BeaconPayload{
+		Version:    1,
+		Timestamp:  time.Now().Unix(),
+		MACAddress: mac,
+		IPAddress:  ip,
+		Hostname:   hostname,
+	}
+}
+
+func TestHandlePacket_Departure(t *testing.T) {
+	db := testStore(t)
+
+	mac := "aa:bb:cc:dd:ee:01"
+	if err := db.Upsert(*samplePayload(mac, "peer1", "192.168.1.10")); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+
+	payload := samplePayload(mac, "peer1", "192.168.1.10")
+	payload.Departing = true
+	packet, err := beacon.EncodePacket(payload, testSecret, nil)
+	if err != nil {
+		t.Fatalf("encoding packet: %v", err)
+	}
+
+	src := &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5678}
+	newReceiver(selfMACs, testOpts, db, zerolog.Nop()).handlePacket(packet, src)
+
+	records, err := db.GetAll()
+	if err != nil {
+		t.Fatalf("getall failed: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	if records[0].Active {
+		t.Error("expected departed host to be inactive")
+	}
+}
+
+func TestHandlePacket_ManagedHosts(t *testing.T) {
+	hostsPath := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(hostsPath, []byte("127.0.0.1 localhost\n"), 0644); err != nil {
+		t.Fatalf("write hosts: %v", err)
+	}
+	packet, err := beacon.EncodePacket(samplePayload("aa:bb:cc:dd:ee:01", "peer1", "192.168.1.10"), testSecret, nil)
+	if err != nil {
+		t.Fatalf("encoding packet: %v", err)
+	}
+	src := &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5678}
+
+	// Unmanaged (testOpts has no Hosts): the peer is stored, nothing written
+	db := testStore(t)
+	newReceiver(selfMACs, testOpts, db, zerolog.Nop()).handlePacket(packet, src)
+	if records, _ := db.GetAll(); len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+
+	// Managed: the peer lands in the hosts file
+	opts := testOpts
+	opts.Hosts = &hosts.Options{Path: hostsPath}
+	newReceiver(selfMACs, opts, testStore(t), zerolog.Nop()).handlePacket(packet, src)
+	data, err := os.ReadFile(hostsPath)
+	if err != nil {
+		t.Fatalf("read hosts: %v", err)
+	}
+	if !strings.Contains(string(data), "peer1") {
+		t.Errorf("expected peer1 in hosts file, got:\n%s", data)
+	}
+}
+
+func TestHandlePacket_DepartureBadHMAC(t *testing.T) {
+	db := testStore(t)
+
+	mac := "aa:bb:cc:dd:ee:01"
+	if err := db.Upsert(*samplePayload(mac, "peer1", "192.168.1.10")); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+
+	payload := samplePayload(mac, "peer1", "192.168.1.10")
+	payload.Departing = true
+	packet, err := beacon.EncodePacket(payload, "wrong-secret", nil)
+	if err != nil {
+		t.Fatalf("encoding packet: %v", err)
+	}
+
+	src := &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5678}
+	newReceiver(selfMACs, testOpts, db, zerolog.Nop()).handlePacket(packet, src)
+
+	records, err := db.GetAll()
+	if err != nil {
+		t.Fatalf("getall failed: %v", err)
+	}
+	if !records[0].Active {
+		t.Error("expected host to stay active after forged departure")
+	}
+}
+
+func TestHandlePacket_DepartureUnknownHost(t *testing.T) {
+	db := testStore(t)
+
+	payload := samplePayload("aa:bb:cc:dd:ee:02", "peer2", "192.168.1.11")
+	payload.Departing = true
+	packet, err := beacon.EncodePacket(payload, testSecret, nil)
+	if err != nil {
+		t.Fatalf("encoding packet: %v", err)
+	}
+
+	src := &net.UDPAddr{IP: net.ParseIP("192.168.1.11"), Port: 5678}
+	newReceiver(selfMACs, testOpts, db, zerolog.Nop()).handlePacket(packet, src)
+
+	records, err := db.GetAll()
+	if err != nil {
+		t.Fatalf("getall failed: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("expected departure from unknown host not to create a record, got %d", len(records))
+	}
+}
+
+func TestHandlePacket_ReplayedDeparture(t *testing.T) {
+	db := testStore(t)
+	r := newReceiver(selfMACs, testOpts, db, zerolog.Nop())
+
+	mac := "aa:bb:cc:dd:ee:01"
+	if err := db.Upsert(*samplePayload(mac, "peer1", "192.168.1.10")); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+
+	payload := samplePayload(mac, "peer1", "192.168.1.10")
+	payload.Departing = true
+	payload.Nonce = beacon.NewNonce()
+	packet, err := beacon.EncodePacket(payload, testSecret, nil)
+	if err != nil {
+		t.Fatalf("encoding packet: %v", err)
+	}
+	src := &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5678}
+	r.handlePacket(packet, src)
+
+	// The host comes back, then a captured copy of its departure is replayed
+	if err := db.Upsert(*samplePayload(mac, "peer1", "192.168.1.10")); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	r.handlePacket(packet, src)
+
+	records, err := db.GetAll()
+	if err != nil {
+		t.Fatalf("getall failed: %v", err)
+	}
+	if !records[0].Active {
+		t.Error("expected replayed departure to be rejected")
+	}
+}
+
+func TestHandlePacket_UnknownVersion(t *testing.T) {
+	db := testStore(t)
+
+	mac := "aa:bb:cc:dd:ee:01"
+	if err := db.Upsert(*samplePayload(mac, "peer1", "192.168.1.10")); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+
+	payload := samplePayload(mac, "peer1", "192.168.1.10")
+	payload.Version = beacon.MaxVersion + 1
+	payload.Departing = true
+	packet, err := beacon.EncodePacket(payload, testSecret, nil)
+	if err != nil {
+		t.Fatalf("encoding packet: %v", err)
+	}
+
+	src := &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5678}
+	newReceiver(selfMACs, testOpts, db, zerolog.Nop()).handlePacket(packet, src)
+
+	records, err := db.GetAll()
+	if err != nil {
+		t.Fatalf("getall failed: %v", err)
+	}
+	if !records[0].Active {
+		t.Error("expected beacon with unknown version to be skipped")
+	}
+}
+
+func TestHandlePacket_Metrics(t *testing.T) {
+	r := newReceiver(selfMACs, testOpts, testStore(t), zerolog.Nop())
+	src := &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5678}
+
+	received := testutil.ToFloat64(metrics.BeaconsReceived)
+	hmacFailures := testutil.ToFloat64(metrics.HMACFailures)
+	staleDrops := testutil.ToFloat64(metrics.StaleDrops)
+
+	forged, _ := beacon.EncodePacket(samplePayload("aa:bb:cc:dd:ee:01", "peer1", "192.168.1.10"), "wrong-secret", nil)
+	r.handlePacket(forged, src)
+
+	// A stale departure is dropped before it reaches the store or /etc/hosts
+	stale := samplePayload("aa:bb:cc:dd:ee:01", "peer1", "192.168.1.10")
+	stale.Timestamp -= 10 * int64(beacon.DefaultTimestampMaxAge/time.Second)
+	stale.Departing = true
+	packet, _ := beacon.EncodePacket(stale, testSecret, nil)
+	r.handlePacket(packet, src)
+
+	if got := testutil.ToFloat64(metrics.BeaconsReceived) - received; got != 2 {
+		t.Errorf("beacons received: got %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(metrics.HMACFailures) - hmacFailures; got != 1 {
+		t.Errorf("HMAC failures: got %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.StaleDrops) - staleDrops; got != 1 {
+		t.Errorf("stale drops: got %v, want 1", got)
+	}
+}
+
+func TestHandlePacket_TimestampMaxAge(t *testing.T) {
+	skewed := samplePayload("aa:bb:cc:dd:ee:01", "peer1", "192.168.1.10")
+	skewed.Timestamp -= 120
+	packet, _ := beacon.EncodePacket(skewed, testSecret, nil)
+	src := &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5678}
+
+	db := testStore(t)
+	newReceiver(selfMACs, testOpts, db, zerolog.Nop()).handlePacket(packet, src)
+	if records, _ := db.GetAll(); len(records) != 0 {
+		t.Fatal("expected a beacon 2m old to be dropped by default")
+	}
+
+	opts := testOpts
+	opts.TimestampMaxAge = 5 * time.Minute
+	newReceiver(selfMACs, opts, db, zerolog.Nop()).handlePacket(packet, src)
+	if records, _ := db.GetAll(); len(records) != 1 {
+		t.Error("expected a beacon 2m old to be accepted with a 5m max age")
+	}
+}
+
+func TestHandlePacket_PreviousSecret(t *testing.T) {
+	packet, _ := beacon.EncodePacket(samplePayload("aa:bb:cc:dd:ee:01", "peer1", "192.168.1.10"), "old-secret", nil)
+	src := &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5678}
+
+	db := testStore(t)
+	newReceiver(selfMACs, testOpts, db, zerolog.Nop()).handlePacket(packet, src)
+	if records, _ := db.GetAll(); len(records) != 0 {
+		t.Fatal("expected a beacon under another secret to be dropped")
+	}
+
+	opts := testOpts
+	opts.PreviousSecret = "old-secret"
+	newReceiver(selfMACs, opts, db, zerolog.Nop()).handlePacket(packet, src)
+	if records, _ := db.GetAll(); len(records) != 1 {
+		t.Error("expected a beacon under the previous secret to be accepted")
+	}
+}
+
+func TestHandlePacket_DebugPackets(t *testing.T) {
+	var logs strings.Builder
+	opts := testOpts
+	opts.DebugPackets = true
+	r := newReceiver(selfMACs, opts, testStore(t), zerolog.New(&logs))
+	src := &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5678}
+
+	stale := samplePayload("aa:bb:cc:dd:ee:01", "peer1", "192.168.1.10")
+	stale.Timestamp -= 300
+	packet, _ := beacon.EncodePacket(stale, testSecret, nil)
+	r.handlePacket(packet, src)
+	r.handlePacket([]byte{0xde, 0xad}, src)
+
+	for _, want := range []string{
+		`"reason":"timestamp"`, `"skew_seconds":-300`, `"hostname":"peer1"`,
+		`"reason":"size"`, `"head":"dead"`, `"bytes":2`, `"src":"192.168.1.10:5678"`,
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("expected %s in debug log:\n%s", want, logs.String())
+		}
+	}
+
+	// Without it, a packet too small to be a beacon is dropped silently
+	logs.Reset()
+	newReceiver(selfMACs, testOpts, testStore(t), zerolog.New(&logs)).handlePacket([]byte{0xde, 0xad}, src)
+	if logs.Len() != 0 {
+		t.Errorf("expected no log without DebugPackets, got %s", logs.String())
+	}
+}
+
+func TestInterfaceSegments_BroadcastTargets(t *testing.T) {
+	ifaces := []sysinfo.Interface{
+		{
+			Name:       "eth0",
+			MACAddress: "aa:bb:cc:dd:ee:01",
+			IPAddress:  "10.0.1.5",
+			Network:    &net.IPNet{IP: net.ParseIP("10.0.1.5").To4(), Mask: net.CIDRMask(24, 32)},
+		},
+		{
+			Name:       "eth1",
+			MACAddress: "aa:bb:cc:dd:ee:02",
+			IPAddress:  "10.51.240.182",
+			Network:    &net.IPNet{IP: net.ParseIP("10.51.240.182").To4(), Mask: net.CIDRMask(23, 32)},
+		},
+	}
+
+	segs := interfaceSegments(ifaces, 5678, sysinfo.NewCachedCollector(time.Minute))
+	if len(segs) != 2 {
+		t.Fatalf("expected 2 segments, got %d", len(segs))
+	}
+
+	want := []string{"10.0.1.255:5678", "10.51.241.255:5678"}
+	for i, seg := range segs {
+		if seg.target.String() != want[i] {
+			t.Errorf("segment %s target: got %s, want %s", seg.name, seg.target, want[i])
+		}
+	}
+}
+
+func TestBroadcast_PerInterfacePayload(t *testing.T) {
+	ifaces := []sysinfo.Interface{
+		{Name: "eth0", MACAddress: "aa:bb:cc:dd:ee:01", IPAddress: "10.0.1.5",
+			Network: &net.IPNet{IP: net.ParseIP("10.0.1.5").To4(), Mask: net.CIDRMask(24, 32)}},
+		{Name: "eth1", MACAddress: "aa:bb:cc:dd:ee:02", IPAddress: "10.0.2.5",
+			Network: &net.IPNet{IP: net.ParseIP("10.0.2.5").To4(), Mask: net.CIDRMask(24, 32)}},
+	}
+
+	sender, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer sender.Close()
+
+	for i, seg := range interfaceSegments(ifaces, 0, sysinfo.NewCachedCollector(time.Minute)) {
+		// Redirect each segment's broadcast to a loopback receiver
+		recv, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		defer recv.Close()
+		seg.target = recv.LocalAddr().(*net.UDPAddr)
+
+		broadcast(sender, seg, testOpts, zerolog.Nop())
+
+		recv.SetReadDeadline(time.Now().Add(2 * time.Second))
+		buf := make([]byte, beacon.MaxPacketSize)
+		n, _, err := recv.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatalf("segment %s: reading beacon: %v", seg.name, err)
+		}
+
+		payload, err := beacon.DecodePacket(buf[:n], testSecret, nil)
+		if err != nil {
+			t.Fatalf("segment %s: decoding beacon: %v", seg.name, err)
+		}
+		if payload.IPAddress != ifaces[i].IPAddress {
+			t.Errorf("segment %s IP: got %s, want %s", seg.name, payload.IPAddress, ifaces[i].IPAddress)
+		}
+		if payload.MACAddress != ifaces[i].MACAddress {
+			t.Errorf("segment %s MAC: got %s, want %s", seg.name, payload.MACAddress, ifaces[i].MACAddress)
+		}
+	}
+}
+
+func TestSourcePortAllowed(t *testing.T) {
+	ip := net.ParseIP("192.168.1.10")
+	verify := Options{Port: 5678, VerifySourcePort: true}
+	permissive := Options{Port: 5678}
+
+	tests := []struct {
+		name string
+		port int
+		opts Options
+		want bool
+	}{
+		{"matching port", 5678, verify, true},
+		{"mismatched port", 40123, verify, false},
+		{"mismatched port, check disabled", 40123, permissive, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &net.UDPAddr{IP: ip, Port: tt.port}
+			if got := sourcePortAllowed(src, tt.opts); got != tt.want {
+				t.Errorf("sourcePortAllowed(%d): got %v, want %v", tt.port, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextDelay(t *testing.T) {
+	if got := nextDelay(30*time.Second, 0); got != 30*time.Second {
+		t.Errorf("no jitter: got %s, want 30s", got)
+	}
+
+	interval, jitter := 30*time.Second, 5*time.Second
+	for i := 0; i < 1000; i++ {
+		if got := nextDelay(interval, jitter); got < interval-jitter || got > interval+jitter {
+			t.Fatalf("delay %s outside [%s, %s]", got, interval-jitter, interval+jitter)
+		}
+	}
+
+	// Jitter as long as the interval would allow back-to-back broadcasts
+	for _, jitter := range []time.Duration{interval, 2 * interval} {
+		for i := 0; i < 1000; i++ {
+			if got := nextDelay(interval, jitter); got < interval/2 || got > interval+interval/2 {
+				t.Fatalf("jitter %s: delay %s outside [%s, %s]", jitter, got, interval/2, interval+interval/2)
+			}
+		}
+	}
+}
+
+func TestSchedule_Set(t *testing.T) {
+	sched := NewSchedule(30*time.Second, 0)
+	if got := sched.next(); got != 30*time.Second {
+		t.Errorf("next: got %s, want 30s", got)
+	}
+
+	// Options without a Schedule get one from Interval and Jitter
+	if got := (Options{Interval: time.Minute}).schedule().next(); got != time.Minute {
+		t.Errorf("default schedule: got %s, want 1m", got)
+	}
+
+	opts := Options{Interval: time.Minute, Schedule: sched}
+	sched.Set(10*time.Second, 0)
+	if got := opts.schedule().next(); got != 10*time.Second {
+		t.Errorf("after Set: got %s, want 10s", got)
+	}
+	if got := sched.Interval(); got != 10*time.Second {
+		t.Errorf("Interval: got %s, want 10s", got)
+	}
+}
+
+func TestRateTracker(t *testing.T) {
+	now := time.Now()
+	rt := newRateTracker(2)
+
+	for i := 0; i < 2; i++ {
+		if !rt.allow("10.0.0.1", now) {
+			t.Fatalf("packet %d: expected allowed", i+1)
+		}
+	}
+	if rt.allow("10.0.0.1", now) {
+		t.Error("expected third packet in the window to be limited")
+	}
+	if !rt.allow("10.0.0.2", now) {
+		t.Error("expected another source to have its own count")
+	}
+	if !rt.allow("10.0.0.1", now.Add(time.Minute)) {
+		t.Error("expected the count to reset after a minute")
+	}
+
+	unlimited := newRateTracker(0)
+	for i := 0; i < 1000; i++ {
+		if !unlimited.allow("10.0.0.1", now) {
+			t.Fatal("expected no limit with limit 0")
+		}
+	}
+}
+
+func TestRun_ShutdownSendsDepartureAndCloses(t *testing.T) {
+	recv, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer recv.Close()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	ifaces := []sysinfo.Interface{{Name: "eth0", MACAddress: "aa:bb:cc:dd:ee:00", IPAddress: "10.0.1.5",
+		Network: &net.IPNet{IP: net.ParseIP("10.0.1.5").To4(), Mask: net.CIDRMask(24, 32)}}}
+	segs := interfaceSegments(ifaces, 0, sysinfo.NewCachedCollector(time.Minute))
+	segs[0].target = recv.LocalAddr().(*net.UDPAddr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		opts := Options{Interval: time.Hour, Secret: testSecret}
+		if err := run(ctx, conn, segs, newReceiver(selfMACs, opts, testStore(t), zerolog.Nop())); err != nil {
+			t.Errorf("run: %v", err)
+		}
+	}()
+
+	recv.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, beacon.MaxPacketSize)
+	if _, _, err := recv.ReadFromUDP(buf); err != nil {
+		t.Fatalf("reading initial beacon: %v", err)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("run did not return after cancellation")
+	}
+
+	n, src, err := recv.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("reading departure beacon: %v", err)
+	}
+	payload, err := beacon.DecodePacket(buf[:n], testSecret, nil)
+	if err != nil {
+		t.Fatalf("decoding departure beacon: %v", err)
+	}
+	if !payload.Departing {
+		t.Error("expected a departure beacon after cancellation")
+	}
+	if src.Port != conn.LocalAddr().(*net.UDPAddr).Port {
+		t.Errorf("departure source port: got %d, want the listen port", src.Port)
+	}
+
+	if _, err := conn.WriteToUDP([]byte("x"), recv.LocalAddr().(*net.UDPAddr)); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected conn to be closed, got %v", err)
+	}
+}
+
+func TestRun_StalledBroadcasts(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	// A segment whose system info can never be collected sends nothing
+	segs := []segment{{
+		name:    "broken",
+		target:  conn.LocalAddr().(*net.UDPAddr),
+		collect: func() (*sysinfo.SystemInfo, error) { return nil, errors.New("no interface") },
+	}}
+
+	done := make(chan error, 1)
+	go func() {
+		opts := Options{Interval: 10 * time.Millisecond, Secret: testSecret}
+		done <- run(context.Background(), conn, segs, newReceiver(selfMACs, opts, testStore(t), zerolog.Nop()))
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrStopped) {
+			t.Errorf("expected ErrStopped, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("run did not notice the stalled broadcasts")
+	}
+}
+
+func TestHandlePacket_RecoversPanic(t *testing.T) {
+	// A nil store makes handling a valid beacon panic
+	r := newReceiver(selfMACs, testOpts, nil, zerolog.Nop())
+	packet, err := beacon.EncodePacket(samplePayload("aa:bb:cc:dd:ee:01", "peer1", "192.168.1.10"), testSecret, nil)
+	if err != nil {
+		t.Fatalf("encoding packet: %v", err)
+	}
+
+	r.handlePacket(packet, &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5678})
+}
+
+func TestRangeTarget(t *testing.T) {
+	_, v4Net, _ := net.ParseCIDR("10.51.240.0/23")
+	_, v6Net, _ := net.ParseCIDR("fd00:51::/64")
+
+	if got := rangeTarget(v4Net, 5678, ""); got.String() != "10.51.241.255:5678" {
+		t.Errorf("IPv4 target: got %s", got)
+	}
+	if got := rangeTarget(v6Net, 5678, "eth0"); got.String() != "[ff02::1%eth0]:5678" {
+		t.Errorf("IPv6 target: got %s", got)
+	}
+}
+
+func TestIsIPv6Range(t *testing.T) {
+	tests := []struct {
+		opts Options
+		want bool
+	}{
+		{Options{NetworkRanges: []string{"10.51.240.0/23"}}, false},
+		{Options{NetworkRanges: []string{"fd00:51::/64"}}, true},
+		{Options{NetworkRanges: []string{"fd00:51::/64", "fd00:52::/64"}}, true},
+		{Options{NetworkRanges: []string{"fd00:51::/64"}, Interfaces: []string{"auto"}}, false},
+		{Options{NetworkRanges: []string{"not-a-cidr"}}, false},
+	}
+	for _, tt := range tests {
+		if got := isIPv6Range(tt.opts); got != tt.want {
+			t.Errorf("isIPv6Range(%+v): got %v, want %v", tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestRangesFamily(t *testing.T) {
+	if v6, err := rangesFamily([]string{"10.0.1.0/24", "10.0.2.0/24", "192.168.7.0/24"}); err != nil || v6 {
+		t.Errorf("IPv4 ranges: got %v, %v", v6, err)
+	}
+	if _, err := rangesFamily([]string{"10.0.1.0/24", "fd00:51::/64"}); err == nil {
+		t.Error("expected error for mixed address families")
+	}
+	if _, err := rangesFamily([]string{"10.0.1.0/24", "bogus"}); err == nil {
+		t.Error("expected error for invalid range")
+	}
+}
+
+func TestResolveRanges(t *testing.T) {
+	explicit := []string{"10.0.1.0/24", "10.0.2.0/24"}
+	got, err := resolveRanges(explicit, "", nil)
+	if err != nil || !slices.Equal(got, explicit) {
+		t.Errorf("explicit ranges: got %v, %v; want them unchanged", got, err)
+	}
+
+	local, err := sysinfo.AutoRange("", nil)
+	if err != nil {
+		t.Skipf("skipping auto: %v", err)
+	}
+	got, err = resolveRanges([]string{AutoRange, local}, "", nil)
+	if err != nil {
+		t.Fatalf("resolveRanges: %v", err)
+	}
+	if !slices.Equal(got, []string{local}) {
+		t.Errorf("auto: got %v, want [%s] once", got, local)
+	}
+}
+
+func TestSegments_PinnedInterface(t *testing.T) {
+	opts := Options{
+		NetworkRanges: []string{"10.0.1.0/24", "fd00:51::/64"},
+		Interface:     "lanmon-test0",
+		Port:          5678,
+	}
+	for _, networkRange := range opts.NetworkRanges {
+		seg, err := rangeSegment(networkRange, opts, sysinfo.NewCachedCollector(time.Minute))
+		if err != nil {
+			t.Fatalf("%s: rangeSegment: %v", networkRange, err)
+		}
+		if seg.iface != opts.Interface {
+			t.Errorf("%s: interface %q, want %q", networkRange, seg.iface, opts.Interface)
+		}
+		// The range still decides where beacons go
+		if networkRange == "fd00:51::/64" && seg.target.String() != "[ff02::1%lanmon-test0]:5678" {
+			t.Errorf("%s: target %s, want the all-nodes group on the pinned link", networkRange, seg.target)
+		}
+		if networkRange == "10.0.1.0/24" && seg.target.String() != "10.0.1.255:5678" {
+			t.Errorf("%s: target %s, want the range's broadcast address", networkRange, seg.target)
+		}
+		if _, err := seg.collect(); err == nil || !strings.Contains(err.Error(), opts.Interface) {
+			t.Errorf("%s: collect on a missing interface: got %v", networkRange, err)
+		}
+	}
+}
+
+func TestSegments_RangePerVLAN(t *testing.T) {
+	opts := Options{NetworkRanges: []string{"10.0.1.0/24", "10.0.2.0/23"}, Port: 5678}
+	segs, err := segments(opts)
+	if err != nil {
+		t.Fatalf("segments: %v", err)
+	}
+
+	want := []string{"10.0.1.255:5678", "10.0.3.255:5678"}
+	if len(segs) != len(want) {
+		t.Fatalf("expected %d segments, got %d", len(want), len(segs))
+	}
+	for i, seg := range segs {
+		if seg.name != opts.NetworkRanges[i] || seg.target.String() != want[i] {
+			t.Errorf("segment %d: got %s -> %s, want %s -> %s", i, seg.name, seg.target, opts.NetworkRanges[i], want[i])
+		}
+	}
+}
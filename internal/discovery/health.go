@@ -0,0 +1,44 @@
+package discovery
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStopped is returned by StartNode when discovery stops on its own
+// rather than because its context was cancelled: the listener died, or no
+// beacon could be sent for stallIntervals broadcast intervals. The caller
+// may start it again.
+var ErrStopped = errors.New("discovery stopped")
+
+const (
+	// stallIntervals is how many broadcast intervals may pass without a
+	// beacon sent before discovery counts as stalled.
+	stallIntervals = 3
+
+	// maxReadErrors is how many socket read errors in a row the listener
+	// tolerates before giving up.
+	maxReadErrors = 50
+)
+
+// health records when a beacon was last sent, for the stall check.
+type health struct {
+	lastSent atomic.Int64 // UnixNano
+}
+
+func newHealth() *health {
+	h := new(health)
+	h.sent()
+	return h
+}
+
+// sent records a successful broadcast.
+func (h *health) sent() {
+	h.lastSent.Store(time.Now().UnixNano())
+}
+
+// sinceSent returns how long ago the last beacon was sent.
+func (h *health) sinceSent() time.Duration {
+	return time.Since(time.Unix(0, h.lastSent.Load()))
+}
@@ -0,0 +1,98 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"lanmon/internal/beacon"
+)
+
+// ErrNoAnswer is returned by Ping when no answer arrives in time.
+var ErrNoAnswer = errors.New("no answer")
+
+// PingResult describes a node's answer to a probe.
+type PingResult struct {
+	From *net.UDPAddr
+	RTT  time.Duration
+	// Payload is the answer as decoded, nil unless it got as far as the
+	// timestamp check (see beacon.DecodeVerified).
+	Payload *beacon.BeaconPayload
+	// Skew is how far the node's clock is ahead of ours, to the second.
+	Skew time.Duration
+	// Err is why the answer would be dropped by a node here (an HMAC,
+	// decryption, version or timestamp failure), nil if it would be kept.
+	Err error
+}
+
+// Ping sends a single probe beacon to host on opts.Port and waits up to
+// timeout for the node there to answer. The node only answers a probe that
+// passes its own HMAC, timestamp and replay checks, and the answer is put
+// through the same checks here, so an answer that verifies means beacons
+// get through in both directions. The probe carries this host's system
+// information from the first configured segment.
+func Ping(host string, opts Options, timeout time.Duration) (*PingResult, error) {
+	segs, err := segments(opts)
+	if err != nil {
+		return nil, err
+	}
+	info, err := segs[0].collect()
+	if err != nil {
+		return nil, fmt.Errorf("collecting system info: %w", err)
+	}
+	probe := newPayload(info, opts.Tags)
+	probe.Probe = true
+
+	target, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, strconv.Itoa(opts.Port)))
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", host, err)
+	}
+	return ping(target, probe, opts, timeout)
+}
+
+// ping sends probe to target from an ephemeral port and reads the answer.
+func ping(target *net.UDPAddr, probe *beacon.BeaconPayload, opts Options, timeout time.Duration) (*PingResult, error) {
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, fmt.Errorf("opening UDP socket: %w", err)
+	}
+	defer conn.Close()
+
+	packet, _, err := beacon.EncodeFitting(probe, opts.Secret, opts.packetOptions())
+	if err != nil {
+		return nil, fmt.Errorf("encoding probe: %w", err)
+	}
+
+	sent := time.Now()
+	if _, err := conn.WriteToUDP(packet, target); err != nil {
+		return nil, fmt.Errorf("sending probe to %s: %w", target, err)
+	}
+
+	conn.SetReadDeadline(sent.Add(timeout))
+	buf := make([]byte, beacon.MaxPacketSize+1)
+	for {
+		n, src, err := beacon.ReadPacket(conn, buf)
+		if errors.Is(err, beacon.ErrOversized) {
+			continue
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, fmt.Errorf("%w from %s within %s", ErrNoAnswer, target, timeout)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading answer: %w", err)
+		}
+		if !src.IP.Equal(target.IP) {
+			continue
+		}
+
+		res := &PingResult{From: src, RTT: time.Since(sent)}
+		res.Payload, res.Err = beacon.DecodeVerified(buf[:n], opts.Secret, opts.TimestampMaxAge, opts.packetOptions())
+		if res.Payload != nil {
+			res.Skew = beacon.Skew(res.Payload.Timestamp, time.Now())
+		}
+		return res, nil
+	}
+}
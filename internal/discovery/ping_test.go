@@ -0,0 +1,118 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/beacon"
+	"lanmon/internal/sysinfo"
+)
+
+// startPingTarget runs a node on a loopback port until the test ends and
+// returns its address.
+func startPingTarget(t *testing.T, opts Options) *net.UDPAddr {
+	t.Helper()
+
+	// The node's own broadcasts go to a socket nobody reads
+	sink, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { sink.Close() })
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	ifaces := []sysinfo.Interface{{Name: "eth0", MACAddress: "aa:bb:cc:dd:ee:00", IPAddress: "10.0.1.5",
+		Network: &net.IPNet{IP: net.ParseIP("10.0.1.5").To4(), Mask: net.CIDRMask(24, 32)}}}
+	segs := interfaceSegments(ifaces, 0, sysinfo.NewCachedCollector(time.Minute))
+	segs[0].target = sink.LocalAddr().(*net.UDPAddr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	db := testStore(t)
+	go func() {
+		defer close(done)
+		opts.Interval = time.Hour
+		run(ctx, conn, segs, newReceiver(selfMACs, opts, db, zerolog.Nop()))
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		if hosts, err := db.GetAll(); err != nil || len(hosts) != 0 {
+			t.Errorf("expected the probe not to be recorded, got %v (%v)", hosts, err)
+		}
+	})
+	return conn.LocalAddr().(*net.UDPAddr)
+}
+
+func TestPing_Answered(t *testing.T) {
+	target := startPingTarget(t, Options{Secret: testSecret})
+
+	probe := samplePayload("aa:bb:cc:dd:ee:01", "pinger", "10.0.1.6")
+	probe.Probe = true
+	res, err := ping(target, probe, Options{Secret: testSecret}, 2*time.Second)
+	if err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	if res.Err != nil {
+		t.Fatalf("expected the answer to verify, got %v", res.Err)
+	}
+	if res.Payload.MACAddress != "aa:bb:cc:dd:ee:00" || res.Payload.Probe {
+		t.Errorf("unexpected answer: %+v", res.Payload)
+	}
+	if res.From.Port != target.Port {
+		t.Errorf("answer source port: got %d, want the listen port %d", res.From.Port, target.Port)
+	}
+}
+
+func TestPing_WrongSecret(t *testing.T) {
+	target := startPingTarget(t, Options{Secret: testSecret})
+
+	probe := samplePayload("aa:bb:cc:dd:ee:01", "pinger", "10.0.1.6")
+	probe.Probe = true
+	_, err := ping(target, probe, Options{Secret: "other-secret"}, 200*time.Millisecond)
+	if !errors.Is(err, ErrNoAnswer) {
+		t.Errorf("expected ErrNoAnswer, got %v", err)
+	}
+}
+
+func TestPing_StaleAnswer(t *testing.T) {
+	// A node with a skewed clock: answers anything, but stamped an hour ago
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 2048)
+		_, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		answer := samplePayload("aa:bb:cc:dd:ee:00", "skewed", "10.0.1.5")
+		answer.Timestamp -= 3600
+		packet, _ := beacon.EncodePacket(answer, testSecret, nil)
+		conn.WriteToUDP(packet, src)
+	}()
+
+	probe := samplePayload("aa:bb:cc:dd:ee:01", "pinger", "10.0.1.6")
+	probe.Probe = true
+	res, err := ping(conn.LocalAddr().(*net.UDPAddr), probe, Options{Secret: testSecret}, 2*time.Second)
+	if err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	if !errors.Is(res.Err, beacon.ErrStaleTimestamp) {
+		t.Errorf("expected ErrStaleTimestamp, got %v", res.Err)
+	}
+	if res.Skew > -time.Hour+time.Minute || res.Skew < -time.Hour-time.Minute {
+		t.Errorf("skew: got %s, want about -1h", res.Skew)
+	}
+}
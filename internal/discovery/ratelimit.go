@@ -0,0 +1,34 @@
+package discovery
+
+import (
+	"time"
+)
+
+// rateTracker tracks per-source-IP packet counts for rate limiting. Counts
+// are kept per fixed one-minute window and reset wholesale when it ends.
+// It is only used from the read loop, so it needs no locking.
+type rateTracker struct {
+	limit     int
+	counts    map[string]int
+	resetTime time.Time
+}
+
+// newRateTracker returns a tracker allowing limit packets per source IP per
+// minute. A limit of zero or less disables rate limiting.
+func newRateTracker(limit int) *rateTracker {
+	return &rateTracker{limit: limit, counts: make(map[string]int)}
+}
+
+// allow records a packet from ip at now and reports whether it is within
+// the limit.
+func (t *rateTracker) allow(ip string, now time.Time) bool {
+	if t.limit <= 0 {
+		return true
+	}
+	if !now.Before(t.resetTime) {
+		clear(t.counts)
+		t.resetTime = now.Add(time.Minute)
+	}
+	t.counts[ip]++
+	return t.counts[ip] <= t.limit
+}
@@ -0,0 +1,55 @@
+package discovery
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Schedule holds the broadcast interval and jitter. Broadcast loops read it
+// before every wait, so Set takes effect from the next broadcast on.
+type Schedule struct {
+	mu       sync.Mutex
+	interval time.Duration
+	jitter   time.Duration
+}
+
+// NewSchedule returns a Schedule with the given interval and jitter.
+func NewSchedule(interval, jitter time.Duration) *Schedule {
+	return &Schedule{interval: interval, jitter: jitter}
+}
+
+// Set changes the interval and jitter.
+func (s *Schedule) Set(interval, jitter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interval, s.jitter = interval, jitter
+}
+
+// Interval returns the current broadcast interval.
+func (s *Schedule) Interval() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.interval
+}
+
+// next returns how long to wait before the next broadcast.
+func (s *Schedule) next() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return nextDelay(s.interval, s.jitter)
+}
+
+// nextDelay returns how long to wait before the next broadcast: interval
+// shifted by a uniformly random amount in [-jitter, +jitter]. Config
+// validation keeps jitter shorter than interval; should a longer one get
+// here, it is cut to half the interval rather than broadcast back to back.
+func nextDelay(interval, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return interval
+	}
+	if jitter >= interval {
+		jitter = interval / 2
+	}
+	return interval - jitter + rand.N(2*jitter+1)
+}
@@ -0,0 +1,18 @@
+package hosts
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// flushResolverCache makes macOS pick up hosts file changes right away;
+// mDNSResponder otherwise keeps serving cached answers.
+func flushResolverCache() error {
+	if out, err := exec.Command("dscacheutil", "-flushcache").CombinedOutput(); err != nil {
+		return fmt.Errorf("dscacheutil: %w: %s", err, out)
+	}
+	if out, err := exec.Command("killall", "-HUP", "mDNSResponder").CombinedOutput(); err != nil {
+		return fmt.Errorf("signalling mDNSResponder: %w: %s", err, out)
+	}
+	return nil
+}
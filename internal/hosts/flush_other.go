@@ -0,0 +1,9 @@
+//go:build !darwin
+
+package hosts
+
+// flushResolverCache is a no-op where the resolver reads the hosts file
+// on every lookup.
+func flushResolverCache() error {
+	return nil
+}
@@ -3,24 +3,111 @@ package hosts
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
+	"github.com/rs/zerolog"
+
 	"lanmon/internal/store"
 )
 
+// DefaultPath is the hosts file Sync manages unless Options.Path is set.
+const DefaultPath = "/etc/hosts"
+
+// lockSuffix names the lock file Sync holds while it rewrites the hosts file.
+// The hosts file itself can't be locked, as each sync replaces it.
+const lockSuffix = ".lanmon.lock"
+
+// BackupSuffix is appended to the hosts file path to name the copy Sync
+// keeps of the file as it was before its last change.
+const BackupSuffix = ".lanmon.bak"
+
 const (
-	hostsPath    = "/etc/hosts"
 	beginMarker = "# BEGIN LANMON MANAGED HOSTS"
 	endMarker   = "# END LANMON MANAGED HOSTS"
 )
 
-// Sync updates /etc/hosts with all active hosts from the database.
-func Sync(db *store.Store) error {
+// Hostname collision strategies, used when several hosts report the same
+// hostname.
+const (
+	// CollisionSkip writes none of the colliding hosts.
+	CollisionSkip = "skip"
+	// CollisionSuffix keeps the name for the earliest-discovered host and
+	// appends -2, -3, ... to the others.
+	CollisionSuffix = "suffix"
+	// CollisionNewest writes only the most recently seen host.
+	CollisionNewest = "newest"
+)
+
+// Options controls how the managed section is generated.
+type Options struct {
+	// Collision is the hostname collision strategy; empty means CollisionNewest.
+	Collision string
+	// Path is the hosts file to manage; empty means DefaultPath.
+	Path string
+	// Domain, if set, is appended to each hostname (e.g. "lan" or ".lan"),
+	// and the entry lists the qualified name before the bare one.
+	Domain string
+	// DryRun logs the managed section Sync would write instead of writing it.
+	DryRun bool
+}
+
+func (o Options) path() string {
+	if o.Path == "" {
+		return DefaultPath
+	}
+	return o.Path
+}
+
+// ValidateCollision checks that s names a known collision strategy.
+func ValidateCollision(s string) error {
+	switch s {
+	case "", CollisionSkip, CollisionSuffix, CollisionNewest:
+		return nil
+	}
+	return fmt.Errorf("unknown hosts collision strategy %q (want skip, suffix or newest)", s)
+}
+
+// Sync updates the hosts file (/etc/hosts unless opts.Path is set) with
+// all active hosts from the database. Before replacing the file it saves the
+// current contents next to it with BackupSuffix; both writes go through a
+// temp file and rename, so a failed or interrupted write leaves the original
+// intact.
+//
+// Syncs are serialized with an flock on a lock file next to the hosts file,
+// across goroutines and lanmon processes. Editors don't take that lock, so
+// Sync also gives up, leaving the file alone, if it changes while the new
+// contents are being built; the next sync picks the edit up.
+func Sync(db *store.Store, opts Options, log zerolog.Logger) error {
+	if err := ValidateCollision(opts.Collision); err != nil {
+		return err
+	}
+
+	hostsPath := opts.path()
+
 	// Check if we have root permissions (usually needed for /etc/hosts)
-	if os.Geteuid() != 0 {
-		return fmt.Errorf("insufficient permissions to modify /etc/hosts (must be root)")
+	if hostsPath == DefaultPath && !opts.DryRun && os.Geteuid() != 0 {
+		return fmt.Errorf("insufficient permissions to modify %s (must be root)", hostsPath)
+	}
+
+	// Rewrite the file a symlink points to rather than replacing the
+	// link: on macOS /etc/hosts links to /private/etc/hosts
+	if resolved, err := filepath.EvalSymlinks(hostsPath); err == nil {
+		hostsPath = resolved
+	}
+
+	if !opts.DryRun {
+		// Lock before reading the store, so the last sync to write also
+		// saw the latest hosts
+		unlock, err := lockFile(hostsPath + lockSuffix)
+		if err != nil {
+			return fmt.Errorf("locking %s: %w", hostsPath, err)
+		}
+		defer unlock()
 	}
 
 	hosts, err := db.GetAll()
@@ -28,20 +115,61 @@ func Sync(db *store.Store) error {
 		return fmt.Errorf("getting hosts from db: %w", err)
 	}
 
-	file, err := os.Open(hostsPath)
+	original, err := os.ReadFile(hostsPath)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", hostsPath, err)
+	}
+	info, err := os.Stat(hostsPath)
 	if err != nil {
-		return fmt.Errorf("opening %s: %w", hostsPath, err)
+		return fmt.Errorf("reading %s: %w", hostsPath, err)
+	}
+
+	entries := managedEntries(hosts, opts, log)
+	content := replaceManagedSection(original, entries)
+
+	if opts.DryRun {
+		log.Info().
+			Str("path", hostsPath).
+			Strs("entries", entries).
+			Msg("Dry run, hosts file not written")
+		return nil
 	}
-	defer file.Close()
 
+	if bytes.Equal(content, original) {
+		return nil
+	}
+
+	if err := writeFileAtomic(hostsPath+BackupSuffix, original, info); err != nil {
+		return fmt.Errorf("backing up %s: %w", hostsPath, err)
+	}
+
+	if current, err := os.ReadFile(hostsPath); err != nil || !bytes.Equal(current, original) {
+		return fmt.Errorf("%s changed during sync, not overwriting it", hostsPath)
+	}
+	if err := writeFileAtomic(hostsPath, content, info); err != nil {
+		return fmt.Errorf("writing %s: %w", hostsPath, err)
+	}
+
+	if opts.path() != DefaultPath {
+		return nil
+	}
+	if err := flushResolverCache(); err != nil {
+		log.Warn().Err(err).Msg("Failed to flush the resolver cache; new hosts entries may take a while to apply")
+	}
+	return nil
+}
+
+// replaceManagedSection returns the hosts file contents with any existing
+// managed section dropped and a new one holding entries appended.
+func replaceManagedSection(original []byte, entries []string) []byte {
 	var newLines []string
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(original))
 	inManagedSection := false
 
 	for scanner.Scan() {
 		line := scanner.Text()
 		trimmed := strings.TrimSpace(line)
-		
+
 		if strings.HasPrefix(trimmed, beginMarker) {
 			inManagedSection = true
 			continue
@@ -58,16 +186,7 @@ func Sync(db *store.Store) error {
 	// Build the new managed section
 	var managedLines []string
 	managedLines = append(managedLines, beginMarker)
-	
-	for _, h := range hosts {
-
-		if h.Beacon.Hostname != "" && h.Beacon.IPAddress != "" {
-			// Avoid duplicate entries if multiple IPs map to same name 
-			// (though in this system it's 1:1)
-			entry := fmt.Sprintf("%-16s %s", h.Beacon.IPAddress, h.Beacon.Hostname)
-			managedLines = append(managedLines, entry)
-		}
-	}
+	managedLines = append(managedLines, entries...)
 	managedLines = append(managedLines, endMarker)
 
 	// Append managed section to the end of preserved lines
@@ -77,13 +196,175 @@ func Sync(db *store.Store) error {
 	}
 	newLines = append(newLines, managedLines...)
 
-	// Write back
-	content := strings.Join(newLines, "\n") + "\n"
-	err = os.WriteFile(hostsPath, []byte(content), 0644)
+	return []byte(strings.Join(newLines, "\n") + "\n")
+}
+
+// writeFileAtomic writes data to a temp file in path's directory and
+// renames it over path, so readers see either the old or the new file.
+// The new file takes its mode and, where supported, ownership from like.
+func writeFileAtomic(path string, data []byte, like os.FileInfo) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
 	if err != nil {
-		return fmt.Errorf("writing %s: %w", hostsPath, err)
+		return err
 	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
 
-	return nil
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), like.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := copyOwner(tmp.Name(), like); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// managedEntries returns the "<ip> <hostname>" lines for the managed
+// section, resolving hostname collisions with the configured strategy.
+// Collisions are logged whatever the strategy. Every name and every IP
+// appears at most once, and the output depends only on the records, not
+// on their order.
+func managedEntries(hosts []store.HostRecord, opts Options, log zerolog.Logger) []string {
+	strategy := opts.Collision
+	domain := strings.TrimPrefix(opts.Domain, ".")
+
+	// Index usable records by hostname, one per IP address
+	byName := make(map[string][]int)
+	for _, i := range ipWinners(hosts, log) {
+		h := hosts[i]
+		byName[h.Beacon.Hostname] = append(byName[h.Beacon.Hostname], i)
+	}
+
+	// Suffixed names must not clash with a host that really has that name
+	taken := make(map[string]bool, len(byName))
+	groups := make([]string, 0, len(byName))
+	for name := range byName {
+		taken[name] = true
+		groups = append(groups, name)
+	}
+	sort.Strings(groups)
+
+	// names[i] is the name to write for hosts[i]; absent means skip
+	names := make(map[int]string)
+	for _, name := range groups {
+		idx := byName[name]
+		if len(idx) == 1 {
+			names[idx[0]] = name
+			continue
+		}
+
+		macs := make([]string, len(idx))
+		for j, i := range idx {
+			macs[j] = hosts[i].Beacon.MACAddress
+		}
+		log.Warn().
+			Str("hostname", name).
+			Strs("macs", macs).
+			Str("strategy", strategy).
+			Msg("Hostname collision in /etc/hosts")
+
+		switch strategy {
+		case CollisionSkip:
+		case CollisionSuffix:
+			// Earliest-discovered keeps the bare name
+			sort.SliceStable(idx, func(a, b int) bool {
+				ha, hb := hosts[idx[a]], hosts[idx[b]]
+				if !ha.FirstSeen.Equal(hb.FirstSeen) {
+					return ha.FirstSeen.Before(hb.FirstSeen)
+				}
+				return ha.Beacon.MACAddress < hb.Beacon.MACAddress
+			})
+			names[idx[0]] = name
+			n := 2
+			for _, i := range idx[1:] {
+				for taken[fmt.Sprintf("%s-%d", name, n)] {
+					n++
+				}
+				names[i] = fmt.Sprintf("%s-%d", name, n)
+				taken[names[i]] = true
+				n++
+			}
+		default: // CollisionNewest
+			newest := idx[0]
+			for _, i := range idx[1:] {
+				h, best := hosts[i], hosts[newest]
+				// Equal LastSeen falls back to the IP tie-break
+				if h.LastSeen.After(best.LastSeen) ||
+					(h.LastSeen.Equal(best.LastSeen) && beats(h, best)) {
+					newest = i
+				}
+			}
+			names[newest] = name
+		}
+	}
+
+	var entries []string
+	for i, h := range hosts {
+		name, ok := names[i]
+		if !ok {
+			continue
+		}
+		if domain != "" {
+			name = name + "." + domain + " " + name
+		}
+		entries = append(entries, fmt.Sprintf("%-16s %s", h.Beacon.IPAddress, name))
+	}
+	return entries
 }
 
+// ipWinners returns the indexes of the usable hosts, keeping one host per
+// IP address so a conflict can't put two names on one address. Active
+// hosts win over inactive ones, then the earliest discovered, then the
+// lowest MAC, so the winner doesn't change from one sync to the next.
+func ipWinners(hosts []store.HostRecord, log zerolog.Logger) []int {
+	winner := make(map[string]int)
+	for i, h := range hosts {
+		if h.Beacon.Hostname == "" || h.Beacon.IPAddress == "" {
+			continue
+		}
+		ip := h.Beacon.IPAddress
+		w, ok := winner[ip]
+		if !ok {
+			winner[ip] = i
+			continue
+		}
+		kept, skipped := w, i
+		if beats(h, hosts[w]) {
+			kept, skipped = i, w
+			winner[ip] = i
+		}
+		log.Debug().
+			Str("ip", ip).
+			Str("kept", hosts[kept].Beacon.MACAddress).
+			Str("skipped", hosts[skipped].Beacon.MACAddress).
+			Msg("Several hosts claim the same IP address in /etc/hosts")
+	}
+
+	idx := make([]int, 0, len(winner))
+	for _, i := range winner {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+	return idx
+}
+
+// beats reports whether a should be written instead of b for a shared IP.
+func beats(a, b store.HostRecord) bool {
+	if a.Active != b.Active {
+		return a.Active
+	}
+	if !a.FirstSeen.Equal(b.FirstSeen) {
+		return a.FirstSeen.Before(b.FirstSeen)
+	}
+	return a.Beacon.MACAddress < b.Beacon.MACAddress
+}
@@ -0,0 +1,391 @@
+package hosts
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/beacon"
+	"lanmon/internal/store"
+)
+
+func record(mac, hostname, ip string, firstSeen, lastSeen time.Time) store.HostRecord {
+	return store.HostRecord{
+		Beacon: beacon.BeaconPayload{
+			MACAddress: mac,
+			Hostname:   hostname,
+			IPAddress:  ip,
+		},
+		FirstSeen: firstSeen,
+		LastSeen:  lastSeen,
+		Active:    true,
+	}
+}
+
+// collidingHosts has two hosts named "web" (the second discovered first but
+// seen less recently) and one unique host.
+func collidingHosts() []store.HostRecord {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []store.HostRecord{
+		record("aa:00:00:00:00:01", "web", "10.0.0.1", base.Add(time.Hour), base.Add(3*time.Hour)),
+		record("aa:00:00:00:00:02", "db", "10.0.0.2", base, base.Add(time.Hour)),
+		record("aa:00:00:00:00:03", "web", "10.0.0.3", base, base.Add(2*time.Hour)),
+	}
+}
+
+func TestManagedEntries_NoCollision(t *testing.T) {
+	base := time.Now()
+	hosts := []store.HostRecord{
+		record("aa:00:00:00:00:01", "web", "10.0.0.1", base, base),
+		record("aa:00:00:00:00:02", "", "10.0.0.2", base, base),
+	}
+
+	got := managedEntries(hosts, Options{Collision: CollisionNewest}, zerolog.Nop())
+	want := []string{"10.0.0.1         web"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestManagedEntries_Strategies(t *testing.T) {
+	tests := []struct {
+		strategy string
+		want     []string
+	}{
+		{CollisionSkip, []string{
+			"10.0.0.2         db",
+		}},
+		{CollisionSuffix, []string{
+			"10.0.0.1         web-2",
+			"10.0.0.2         db",
+			"10.0.0.3         web",
+		}},
+		{CollisionNewest, []string{
+			"10.0.0.1         web",
+			"10.0.0.2         db",
+		}},
+		{"", []string{
+			"10.0.0.1         web",
+			"10.0.0.2         db",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			got := managedEntries(collidingHosts(), Options{Collision: tt.strategy}, zerolog.Nop())
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManagedEntries_SharedIP(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stale := record("aa:00:00:00:00:01", "old", "10.0.0.5", base, base)
+	stale.Active = false
+	hosts := []store.HostRecord{
+		stale,
+		record("aa:00:00:00:00:03", "web2", "10.0.0.5", base.Add(2*time.Hour), base.Add(3*time.Hour)),
+		record("aa:00:00:00:00:02", "web1", "10.0.0.5", base.Add(time.Hour), base.Add(time.Hour)),
+		record("aa:00:00:00:00:04", "db", "10.0.0.6", base, base),
+	}
+
+	// The earliest-discovered active host keeps the address, whatever the order
+	want := []string{
+		"10.0.0.5         web1",
+		"10.0.0.6         db",
+	}
+	for _, order := range [][]int{{0, 1, 2, 3}, {3, 2, 1, 0}} {
+		var in []store.HostRecord
+		for _, i := range order {
+			in = append(in, hosts[i])
+		}
+		got := managedEntries(in, Options{}, zerolog.Nop())
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("order %v: got %q, want %q", order, got, want)
+		}
+	}
+}
+
+func TestManagedEntries_SuffixThreeWay(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hosts := []store.HostRecord{
+		record("aa:00:00:00:00:01", "node", "10.0.0.1", base.Add(2*time.Hour), base),
+		record("aa:00:00:00:00:02", "node", "10.0.0.2", base, base),
+		record("aa:00:00:00:00:03", "node", "10.0.0.3", base.Add(time.Hour), base),
+	}
+
+	got := managedEntries(hosts, Options{Collision: CollisionSuffix}, zerolog.Nop())
+	want := []string{
+		"10.0.0.1         node-3",
+		"10.0.0.2         node",
+		"10.0.0.3         node-2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestManagedEntries_SuffixSkipsTakenNames(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hosts := []store.HostRecord{
+		record("aa:00:00:00:00:01", "web", "10.0.0.1", base, base),
+		record("aa:00:00:00:00:02", "web", "10.0.0.2", base.Add(time.Hour), base),
+		record("aa:00:00:00:00:03", "web-2", "10.0.0.3", base, base),
+	}
+
+	got := managedEntries(hosts, Options{Collision: CollisionSuffix}, zerolog.Nop())
+	want := []string{
+		"10.0.0.1         web",
+		"10.0.0.2         web-3",
+		"10.0.0.3         web-2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestManagedEntries_Deterministic(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Cloned VMs: same hostname, seen at the same moment
+	a := record("aa:00:00:00:00:02", "clone", "10.0.0.2", base.Add(time.Hour), base)
+	b := record("aa:00:00:00:00:01", "clone", "10.0.0.1", base.Add(time.Hour), base)
+
+	for _, strategy := range []string{CollisionNewest, CollisionSuffix} {
+		first := managedEntries([]store.HostRecord{a, b}, Options{Collision: strategy}, zerolog.Nop())
+		second := managedEntries([]store.HostRecord{b, a}, Options{Collision: strategy}, zerolog.Nop())
+		sort.Strings(first)
+		sort.Strings(second)
+		if !reflect.DeepEqual(first, second) {
+			t.Errorf("%s: output depends on record order: %q vs %q", strategy, first, second)
+		}
+
+		seen := make(map[string]bool)
+		for _, line := range first {
+			if seen[line] {
+				t.Errorf("%s: duplicate line %q", strategy, line)
+			}
+			seen[line] = true
+		}
+	}
+
+	got := managedEntries([]store.HostRecord{a, b}, Options{}, zerolog.Nop())
+	if want := []string{"10.0.0.1         clone"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("newest with a tie: got %q, want %q", got, want)
+	}
+}
+
+func testStore(t *testing.T, dir string) *store.Store {
+	t.Helper()
+	db, err := store.New(filepath.Join(dir, "test.db"), zerolog.Nop())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.Upsert(beacon.BeaconPayload{MACAddress: "aa:00:00:00:00:01", Hostname: "web", IPAddress: "10.0.0.1"})
+	return db
+}
+
+func TestSync_CustomPathAndDomain(t *testing.T) {
+	dir := t.TempDir()
+	db := testStore(t, dir)
+
+	path := filepath.Join(dir, "hosts")
+	original := "127.0.0.1 localhost\n" +
+		beginMarker + "\n10.0.0.9 stale\n" + endMarker + "\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{Path: path, Domain: ".lan"}
+	if err := Sync(db, opts, zerolog.Nop()); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "127.0.0.1 localhost\n\n" +
+		beginMarker + "\n" +
+		"10.0.0.1         web.lan web\n" +
+		endMarker + "\n"
+	if string(got) != want {
+		t.Errorf("hosts file:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	backup, err := os.ReadFile(path + BackupSuffix)
+	if err != nil {
+		t.Fatalf("reading backup: %v", err)
+	}
+	if string(backup) != original {
+		t.Errorf("backup: got %q, want the original %q", backup, original)
+	}
+}
+
+func TestSync_Symlink(t *testing.T) {
+	dir := t.TempDir()
+	db := testStore(t, dir)
+
+	// As on macOS, where /etc/hosts links to /private/etc/hosts
+	target := filepath.Join(dir, "private-hosts")
+	if err := os.WriteFile(target, []byte("127.0.0.1 localhost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "hosts")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Sync(db, Options{Path: link}, zerolog.Nop()); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("expected %s to still be a symlink, got %v, %v", link, fi, err)
+	}
+	got, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "10.0.0.1         web") {
+		t.Errorf("expected the link target to be updated, got:\n%s", got)
+	}
+}
+
+func TestSync_DryRun(t *testing.T) {
+	dir := t.TempDir()
+	db := testStore(t, dir)
+
+	path := filepath.Join(dir, "hosts")
+	original := "127.0.0.1 localhost\n"
+	os.WriteFile(path, []byte(original), 0644)
+
+	if err := Sync(db, Options{Path: path, DryRun: true}, zerolog.Nop()); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	if got, _ := os.ReadFile(path); string(got) != original {
+		t.Errorf("dry run modified the hosts file: %q", got)
+	}
+	if _, err := os.Stat(path + BackupSuffix); !os.IsNotExist(err) {
+		t.Errorf("dry run wrote a backup (stat error %v)", err)
+	}
+}
+
+func TestSync_FailedWriteLeavesOriginal(t *testing.T) {
+	dir := t.TempDir()
+	db := testStore(t, dir)
+
+	path := filepath.Join(dir, "hosts")
+	original := "127.0.0.1 localhost\n"
+	os.WriteFile(path, []byte(original), 0644)
+
+	// A non-empty directory where the backup belongs can't be renamed
+	// over, even by root, so the sync fails partway through
+	if err := os.MkdirAll(filepath.Join(path+BackupSuffix, "blocker"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Sync(db, Options{Path: path}, zerolog.Nop()); err == nil {
+		t.Fatal("expected sync to fail")
+	}
+
+	if got, _ := os.ReadFile(path); string(got) != original {
+		t.Errorf("original hosts file changed after failed sync: %q", got)
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp-") {
+			t.Errorf("temp file %s left behind", e.Name())
+		}
+	}
+}
+
+func TestSync_PreservesMode(t *testing.T) {
+	dir := t.TempDir()
+	db := testStore(t, dir)
+
+	path := filepath.Join(dir, "hosts")
+	os.WriteFile(path, []byte("127.0.0.1 localhost\n"), 0640)
+	os.Chmod(path, 0640) // umask may have trimmed it
+
+	if err := Sync(db, Options{Path: path}, zerolog.Nop()); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0640 {
+		t.Errorf("mode: got %v, want 0640", fi.Mode().Perm())
+	}
+}
+
+func TestSync_Concurrent(t *testing.T) {
+	dir := t.TempDir()
+	db := testStore(t, dir)
+
+	path := filepath.Join(dir, "hosts")
+	os.WriteFile(path, []byte("127.0.0.1 localhost\n"), 0644)
+
+	const workers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mac := fmt.Sprintf("bb:00:00:00:00:%02x", i)
+			db.Upsert(beacon.BeaconPayload{MACAddress: mac, Hostname: fmt.Sprintf("host%d", i), IPAddress: fmt.Sprintf("10.0.1.%d", i)})
+			if err := Sync(db, Options{Path: path}, zerolog.Nop()); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("sync failed: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(got)
+	if n := strings.Count(content, beginMarker); n != 1 {
+		t.Errorf("expected one managed section, found %d begin markers", n)
+	}
+	if n := strings.Count(content, "localhost"); n != 1 {
+		t.Errorf("expected the unmanaged line once, found it %d times", n)
+	}
+	// The last sync ran after every upsert, so it wrote every host
+	for i := range workers {
+		if !strings.Contains(content, fmt.Sprintf(" host%d\n", i)) {
+			t.Errorf("host%d missing from hosts file", i)
+		}
+	}
+}
+
+func TestValidateCollision(t *testing.T) {
+	for _, s := range []string{"", CollisionSkip, CollisionSuffix, CollisionNewest} {
+		if err := ValidateCollision(s); err != nil {
+			t.Errorf("ValidateCollision(%q): unexpected error %v", s, err)
+		}
+	}
+	if err := ValidateCollision("random"); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+}
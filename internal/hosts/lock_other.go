@@ -0,0 +1,15 @@
+//go:build !unix
+
+package hosts
+
+import "os"
+
+// lockFile is a no-op where flock isn't available.
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
+
+// copyOwner is a no-op where files don't carry unix ownership.
+func copyOwner(path string, like os.FileInfo) error {
+	return nil
+}
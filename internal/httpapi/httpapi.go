@@ -0,0 +1,206 @@
+// Package httpapi serves lanmon's host data as JSON over HTTP, for
+// dashboards and other tooling.
+package httpapi
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/auth"
+	"lanmon/internal/store"
+)
+
+// Options configures the HTTP API server.
+type Options struct {
+	// Addr is the listen address, e.g. "127.0.0.1:8080".
+	Addr string
+	// Auth checks every request. Nil serves requests without
+	// authentication, which is only sensible on a loopback address.
+	Auth auth.Authenticator
+	// TLS, if set, serves HTTPS. Needed for client certificate auth.
+	TLS *tls.Config
+}
+
+// Server is a running HTTP API server.
+type Server struct {
+	srv      *http.Server
+	listener net.Listener
+}
+
+// KeyPushedRequest is the optional body of POST /hosts/{mac}/key-pushed.
+type KeyPushedRequest struct {
+	User        string `json:"user"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// Start listens on opts.Addr and serves the API in the background:
+//
+//	GET  /hosts                  all host records
+//	GET  /hosts/active           active host records
+//	GET  /hosts/{mac}/history    when a host's beacons were seen
+//	POST /hosts/{mac}/key-pushed mark a host's SSH key as pushed
+func Start(opts Options, db *store.Store, log zerolog.Logger) (*Server, error) {
+	h := &handler{db: db, auth: opts.Auth, log: log}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /hosts", h.require(auth.Read, h.listHosts))
+	mux.HandleFunc("GET /hosts/active", h.require(auth.Read, h.listActiveHosts))
+	mux.HandleFunc("GET /hosts/{mac}/history", h.require(auth.Read, h.getHistory))
+	mux.HandleFunc("POST /hosts/{mac}/key-pushed", h.require(auth.Write, h.markKeyPushed))
+
+	listener, err := net.Listen("tcp", opts.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", opts.Addr, err)
+	}
+	if opts.TLS != nil {
+		listener = tls.NewListener(listener, opts.TLS)
+	}
+
+	s := &Server{
+		srv: &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		listener: listener,
+	}
+
+	log.Info().Str("addr", listener.Addr().String()).Bool("tls", opts.TLS != nil).Msg("HTTP API started")
+
+	go func() {
+		if err := s.srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("HTTP API server error")
+		}
+	}()
+
+	return s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests
+// to finish, or for ctx to expire.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+type handler struct {
+	db   *store.Store
+	auth auth.Authenticator
+	log  zerolog.Logger
+}
+
+// require wraps next with an authentication and authorization check.
+func (h *handler) require(need auth.Access, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.auth != nil {
+			err := auth.Authorize(h.auth, credentials(r), need)
+			switch {
+			case errors.Is(err, auth.ErrForbidden):
+				writeError(w, http.StatusForbidden, err)
+				return
+			case err != nil:
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(w, http.StatusUnauthorized, auth.ErrUnauthenticated)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// credentials extracts a bearer token and TLS client certificates.
+func credentials(r *http.Request) auth.Credentials {
+	var c auth.Credentials
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		c.Token = strings.TrimSpace(token)
+	}
+	if r.TLS != nil {
+		c.PeerCertificates = r.TLS.PeerCertificates
+	}
+	return c
+}
+
+func (h *handler) listHosts(w http.ResponseWriter, r *http.Request) {
+	hosts, err := h.db.GetAll()
+	if err != nil {
+		h.log.Error().Err(err).Msg("Fetching hosts for HTTP API")
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeHosts(w, hosts)
+}
+
+func (h *handler) listActiveHosts(w http.ResponseWriter, r *http.Request) {
+	hosts, err := h.db.GetActive()
+	if err != nil {
+		h.log.Error().Err(err).Msg("Fetching active hosts for HTTP API")
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeHosts(w, hosts)
+}
+
+func (h *handler) getHistory(w http.ResponseWriter, r *http.Request) {
+	times, err := h.db.GetHistory(r.PathValue("mac"))
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+		return
+	case err != nil:
+		h.log.Error().Err(err).Msg("Fetching host history for HTTP API")
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, times)
+}
+
+func (h *handler) markKeyPushed(w http.ResponseWriter, r *http.Request) {
+	var req KeyPushedRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+	}
+
+	err := h.db.MarkKeyPushed(r.PathValue("mac"), req.User, req.Fingerprint)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// writeHosts writes a host list, as [] rather than null when empty.
+func writeHosts(w http.ResponseWriter, hosts []store.HostRecord) {
+	if hosts == nil {
+		hosts = []store.HostRecord{}
+	}
+	writeJSON(w, http.StatusOK, hosts)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
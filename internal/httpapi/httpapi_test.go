@@ -0,0 +1,189 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/auth"
+	"lanmon/internal/beacon"
+	"lanmon/internal/store"
+)
+
+func testStore(t *testing.T) *store.Store {
+	t.Helper()
+	s, err := store.New(filepath.Join(t.TempDir(), "test.db"), zerolog.Nop())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func startServer(t *testing.T, db *store.Store, a auth.Authenticator) string {
+	t.Helper()
+	srv, err := Start(Options{Addr: "127.0.0.1:0", Auth: a}, db, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("starting server: %v", err)
+	}
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+	return "http://" + srv.Addr().String()
+}
+
+func do(t *testing.T, method, url, token, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("building request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func decodeHosts(t *testing.T, resp *http.Response) []store.HostRecord {
+	t.Helper()
+	var hosts []store.HostRecord
+	if err := json.NewDecoder(resp.Body).Decode(&hosts); err != nil {
+		t.Fatalf("decoding hosts: %v", err)
+	}
+	return hosts
+}
+
+func TestListHosts(t *testing.T) {
+	db := testStore(t)
+	db.Upsert(beacon.BeaconPayload{MACAddress: "aa:bb:cc:dd:ee:01", Hostname: "web1"})
+	db.Upsert(beacon.BeaconPayload{MACAddress: "aa:bb:cc:dd:ee:02", Hostname: "web2"})
+	db.MarkInactive("aa:bb:cc:dd:ee:02")
+	base := startServer(t, db, nil)
+
+	resp := do(t, "GET", base+"/hosts", "", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /hosts: status %d", resp.StatusCode)
+	}
+	if hosts := decodeHosts(t, resp); len(hosts) != 2 {
+		t.Errorf("GET /hosts: got %d hosts, want 2", len(hosts))
+	}
+
+	resp = do(t, "GET", base+"/hosts/active", "", "")
+	if hosts := decodeHosts(t, resp); len(hosts) != 1 || hosts[0].Beacon.Hostname != "web1" {
+		t.Errorf("GET /hosts/active: got %+v", hosts)
+	}
+}
+
+func TestListHosts_EmptyIsArray(t *testing.T) {
+	base := startServer(t, testStore(t), nil)
+
+	resp := do(t, "GET", base+"/hosts/active", "", "")
+	var raw json.RawMessage
+	json.NewDecoder(resp.Body).Decode(&raw)
+	if string(raw) != "[]" {
+		t.Errorf("got %s, want []", raw)
+	}
+}
+
+func TestMarkKeyPushed(t *testing.T) {
+	db := testStore(t)
+	db.Upsert(beacon.BeaconPayload{MACAddress: "aa:bb:cc:dd:ee:01", Hostname: "web1"})
+	base := startServer(t, db, nil)
+
+	resp := do(t, "POST", base+"/hosts/aa:bb:cc:dd:ee:01/key-pushed", "", `{"user":"alice"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	records, _ := db.GetAll()
+	if !records[0].SSHKeyPushed || records[0].SSHKeyUser != "alice" {
+		t.Errorf("expected key pushed for alice, got %+v", records[0])
+	}
+
+	resp = do(t, "POST", base+"/hosts/aa:bb:cc:dd:ee:99/key-pushed", "", "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown MAC: got status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestGetHistory(t *testing.T) {
+	db := testStore(t)
+	db.Upsert(beacon.BeaconPayload{MACAddress: "aa:bb:cc:dd:ee:01", Hostname: "web1"})
+	db.Upsert(beacon.BeaconPayload{MACAddress: "aa:bb:cc:dd:ee:01", Hostname: "web1"})
+	base := startServer(t, db, nil)
+
+	resp := do(t, "GET", base+"/hosts/aa:bb:cc:dd:ee:01/history", "", "")
+	var times []time.Time
+	if err := json.NewDecoder(resp.Body).Decode(&times); err != nil {
+		t.Fatalf("decoding history: %v", err)
+	}
+	if len(times) != 2 {
+		t.Errorf("got %d observations, want 2", len(times))
+	}
+
+	resp = do(t, "GET", base+"/hosts/aa:bb:cc:dd:ee:99/history", "", "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown MAC: got status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	tokensPath := filepath.Join(t.TempDir(), "tokens")
+	os.WriteFile(tokensPath, []byte("reader-token read\nwriter-token write\n"), 0600)
+	tokens, err := auth.LoadTokensFile(tokensPath)
+	if err != nil {
+		t.Fatalf("loading tokens: %v", err)
+	}
+
+	db := testStore(t)
+	db.Upsert(beacon.BeaconPayload{MACAddress: "aa:bb:cc:dd:ee:01", Hostname: "web1"})
+	base := startServer(t, db, tokens)
+	pushURL := base + "/hosts/aa:bb:cc:dd:ee:01/key-pushed"
+
+	tests := []struct {
+		name, method, url, token string
+		want                     int
+	}{
+		{"no token", "GET", base + "/hosts", "", http.StatusUnauthorized},
+		{"unknown token", "GET", base + "/hosts", "bogus", http.StatusUnauthorized},
+		{"reader reads", "GET", base + "/hosts", "reader-token", http.StatusOK},
+		{"reader writes", "POST", pushURL, "reader-token", http.StatusForbidden},
+		{"writer writes", "POST", pushURL, "writer-token", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := do(t, tt.method, tt.url, tt.token, ""); resp.StatusCode != tt.want {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	srv, err := Start(Options{Addr: "127.0.0.1:0"}, testStore(t), zerolog.Nop())
+	if err != nil {
+		t.Fatalf("starting server: %v", err)
+	}
+	addr := srv.Addr().String()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	if conn, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+		conn.Close()
+		t.Error("expected dial to fail after shutdown")
+	}
+}
@@ -2,33 +2,26 @@
 package listener
 
 import (
+	"errors"
 	"fmt"
-	"math"
 	"net"
+	"runtime/debug"
 	"time"
 
 	"github.com/rs/zerolog"
-	"github.com/vmihailenco/msgpack/v5"
 	"golang.org/x/net/ipv4"
 
 	"lanmon/internal/beacon"
+	"lanmon/internal/metrics"
 	"lanmon/internal/store"
 )
 
-const (
-	maxPacketSize    = 4096
-	timestampMaxAge  = 60 // seconds
-	maxPacketsPerMin = 5
-)
-
-// rateTracker tracks per-source-IP packet counts for rate limiting.
-type rateTracker struct {
-	counts    map[string]int
-	resetTime time.Time
-}
-
-// StartListener joins the UDP multicast group and processes incoming beacon packets.
-func StartListener(ifaceName, multicastGroup string, port int, sharedSecret string, db *store.Store, log zerolog.Logger) error {
+// StartListener joins the UDP multicast group and processes incoming beacon
+// packets on the given number of handler goroutines. Packets are verified
+// with sharedSecret and the receive side of opts (see beacon.PacketOptions),
+// and beacons stamped more than maxAge from the local clock are dropped
+// (zero means beacon.DefaultTimestampMaxAge).
+func StartListener(ifaceName, multicastGroup string, port int, sharedSecret string, opts *beacon.PacketOptions, maxAge time.Duration, workers int, db *store.Store, log zerolog.Logger) error {
 	group := net.ParseIP(multicastGroup)
 	if group == nil {
 		return fmt.Errorf("invalid multicast group: %s", multicastGroup)
@@ -63,7 +56,7 @@ func StartListener(ifaceName, multicastGroup string, port int, sharedSecret stri
 		}
 	}
 
-	if err := conn.SetReadBuffer(maxPacketSize * 10); err != nil {
+	if err := conn.SetReadBuffer(beacon.MaxPacketSize * 10); err != nil {
 		log.Warn().Err(err).Msg("Failed to set read buffer")
 	}
 
@@ -73,10 +66,28 @@ func StartListener(ifaceName, multicastGroup string, port int, sharedSecret stri
 		Int("port", port).
 		Msg("Listener started, waiting for beacons")
 
-	buf := make([]byte, maxPacketSize)
+	nonces := beacon.NewNonceCache(beacon.ReplayWindow(maxAge))
+
+	// One spare byte lets ReadPacket detect datagrams over beacon.MaxPacketSize
+	pool := beacon.NewBufferPool(beacon.MaxPacketSize + 1)
+	handlers := beacon.NewWorkerPool(workers, pool, func(packet []byte, src *net.UDPAddr) {
+		handlePacket(packet, src, sharedSecret, opts, maxAge, nonces, db, log)
+	})
+	defer handlers.Close()
+
 	for {
-		n, src, err := conn.ReadFromUDP(buf)
+		buf := pool.Get()
+		n, src, err := beacon.ReadPacket(conn, *buf)
+		if errors.Is(err, beacon.ErrOversized) {
+			pool.Put(buf)
+			log.Warn().
+				Str("src", src.String()).
+				Int("max_bytes", beacon.MaxPacketSize).
+				Msg("Oversized packet, truncated")
+			continue
+		}
 		if err != nil {
+			pool.Put(buf)
 			log.Error().Err(err).Msg("Error reading from UDP")
 			continue
 		}
@@ -86,45 +97,63 @@ func StartListener(ifaceName, multicastGroup string, port int, sharedSecret stri
 			Int("bytes", n).
 			Msg("Packet received")
 
-		packet := make([]byte, n)
-		copy(packet, buf[:n])
-
-		go handlePacket(packet, src, sharedSecret, db, log)
+		if !handlers.Submit(buf, n, src) {
+			log.Debug().Str("src", src.String()).Msg("Packet handlers busy, dropping packet")
+		}
 	}
 }
 
-func handlePacket(packet []byte, src *net.UDPAddr, secret string, db *store.Store, log zerolog.Logger) {
+func handlePacket(packet []byte, src *net.UDPAddr, secret string, opts *beacon.PacketOptions, maxAge time.Duration, nonces *beacon.NonceCache, db *store.Store, log zerolog.Logger) {
 	srcAddr := src.String()
+	defer recoverPacket(log, srcAddr)
 
-	if len(packet) <= beacon.HMACSize {
+	metrics.BeaconsReceived.Inc()
+
+	payload, err := beacon.DecodeVerified(packet, secret, maxAge, opts)
+	switch {
+	case errors.Is(err, beacon.ErrTooSmall):
 		log.Warn().Str("src", srcAddr).Msg("Packet too small")
 		return
-	}
-
-	sig := packet[:beacon.HMACSize]
-	data := packet[beacon.HMACSize:]
-
-	if !beacon.VerifyHMAC(sig, data, secret) {
+	case errors.Is(err, beacon.ErrHMAC):
+		metrics.HMACFailures.Inc()
 		log.Warn().
 			Str("src", srcAddr).
 			Msg("HMAC validation failed")
 		return
-	}
-
-	var payload beacon.BeaconPayload
-	if err := msgpack.Unmarshal(data, &payload); err != nil {
-		log.Error().Err(err).Str("src", srcAddr).Msg("Failed to unmarshal beacon")
+	case errors.Is(err, beacon.ErrDecrypt):
+		log.Warn().Str("src", srcAddr).Msg("Failed to decrypt beacon")
 		return
-	}
-
-	now := time.Now().Unix()
-	if math.Abs(float64(now-payload.Timestamp)) > timestampMaxAge {
+	case errors.Is(err, beacon.ErrInflate):
+		log.Warn().Str("src", srcAddr).Msg("Failed to inflate beacon")
+		return
+	case errors.Is(err, beacon.ErrUnsupportedVersion):
+		log.Debug().Err(err).Str("src", srcAddr).Msg("Skipping beacon")
+		return
+	case errors.Is(err, beacon.ErrStaleTimestamp):
+		metrics.StaleDrops.Inc()
+		now := time.Now()
 		log.Warn().
 			Str("src", srcAddr).
 			Int64("payload_ts", payload.Timestamp).
-			Int64("server_ts", now).
+			Int64("server_ts", now.Unix()).
+			Int64("skew_seconds", int64(beacon.Skew(payload.Timestamp, now)/time.Second)).
 			Msg("Stale timestamp")
 		return
+	case err != nil:
+		log.Error().Err(err).Str("src", srcAddr).Msg("Failed to unmarshal beacon")
+		return
+	}
+
+	if len(payload.Nonce) > 0 && nonces.Seen(payload.Nonce) {
+		log.Warn().Str("src", srcAddr).Msg("Replayed beacon")
+		return
+	}
+
+	if payload.Departing {
+		if err := db.MarkInactive(payload.MACAddress); err != nil {
+			log.Debug().Err(err).Str("src", srcAddr).Msg("Ignoring departure beacon")
+		}
+		return
 	}
 
 	log.Info().
@@ -132,7 +161,20 @@ func handlePacket(packet []byte, src *net.UDPAddr, secret string, db *store.Stor
 		Str("ip", payload.IPAddress).
 		Msg("New host discovered")
 
-	if err := db.Upsert(payload); err != nil {
+	if err := db.UpsertFrom(*payload, src.IP); err != nil {
 		log.Error().Err(err).Msg("Database write error")
 	}
 }
+
+// recoverPacket, deferred by handlePacket, logs a panic raised while
+// handling a packet from src and lets the worker carry on, so one bad
+// packet can't take down the server.
+func recoverPacket(log zerolog.Logger, src string) {
+	if v := recover(); v != nil {
+		log.Error().
+			Interface("panic", v).
+			Str("src", src).
+			Str("stack", string(debug.Stack())).
+			Msg("Recovered from panic handling packet")
+	}
+}
@@ -0,0 +1,103 @@
+package listener
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/beacon"
+	"lanmon/internal/store"
+)
+
+const testSecret = "test-shared-secret"
+
+func TestHandlePacket_RecoversPanic(t *testing.T) {
+	src := &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5678}
+	nonces := beacon.NewNonceCache(time.Minute)
+
+	// Validly signed garbage is rejected without a panic
+	garbage := []byte{0xc1, 0xff, 0x00}
+	handlePacket(append(beacon.ComputeHMAC(garbage, testSecret), garbage...), src, testSecret, nil, 0, nonces, nil, zerolog.Nop())
+
+	// A valid beacon with no store to write to panics in Upsert; the
+	// handler must recover and return
+	payload := &beacon.BeaconPayload{
+		Version:    1,
+		Timestamp:  time.Now().Unix(),
+		MACAddress: "aa:bb:cc:dd:ee:01",
+		IPAddress:  "192.168.1.10",
+		Hostname:   "peer1",
+	}
+	packet, err := beacon.EncodePacket(payload, testSecret, nil)
+	if err != nil {
+		t.Fatalf("encoding packet: %v", err)
+	}
+	handlePacket(packet, src, testSecret, nil, 0, nonces, nil, zerolog.Nop())
+}
+
+func TestHandlePacket_BadCompressedBody(t *testing.T) {
+	src := &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5678}
+	var logs bytes.Buffer
+
+	// A signed compressed frame (0xe2) whose gzip stream is cut short
+	body := []byte{0xe2, 0x1f, 0x8b, 0x00}
+	handlePacket(append(beacon.ComputeHMAC(body, testSecret), body...), src, testSecret, nil, 0,
+		beacon.NewNonceCache(time.Minute), nil, zerolog.New(&logs))
+
+	out := logs.String()
+	if !strings.Contains(out, `"level":"warn"`) || !strings.Contains(out, "Failed to inflate beacon") {
+		t.Errorf("expected an inflate warning, got %s", out)
+	}
+	if strings.Contains(out, `"level":"error"`) {
+		t.Errorf("hostile input logged as an error: %s", out)
+	}
+}
+
+func TestHandlePacket_ReceiveOptions(t *testing.T) {
+	src := &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5678}
+	db, err := store.New(filepath.Join(t.TempDir(), "test.db"), zerolog.Nop())
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	defer db.Close()
+
+	const previous = "previous-shared-secret"
+	recv := &beacon.PacketOptions{PreviousSecret: previous, LegacyKey: true}
+	for i, send := range []struct {
+		secret string
+		opts   *beacon.PacketOptions
+	}{
+		{previous, nil},
+		{testSecret, &beacon.PacketOptions{LegacyKey: true}},
+	} {
+		payload := &beacon.BeaconPayload{
+			Version:    1,
+			Timestamp:  time.Now().Unix(),
+			MACAddress: fmt.Sprintf("aa:bb:cc:dd:ee:%02x", i),
+			IPAddress:  "192.168.1.10",
+			Hostname:   "peer",
+		}
+		packet, err := beacon.EncodePacket(payload, send.secret, send.opts)
+		if err != nil {
+			t.Fatalf("encoding packet: %v", err)
+		}
+
+		// Without the options the packet fails verification
+		var logs bytes.Buffer
+		handlePacket(packet, src, testSecret, nil, 0, beacon.NewNonceCache(time.Minute), db, zerolog.New(&logs))
+		if !strings.Contains(logs.String(), "HMAC validation failed") {
+			t.Errorf("packet %d without options: expected an HMAC failure, got %s", i, logs.String())
+		}
+
+		handlePacket(packet, src, testSecret, recv, 0, beacon.NewNonceCache(time.Minute), db, zerolog.Nop())
+		if records, _ := db.GetAll(); len(records) != i+1 {
+			t.Errorf("packet %d with options: got %d hosts stored, want %d", i, len(records), i+1)
+		}
+	}
+}
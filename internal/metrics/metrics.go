@@ -0,0 +1,132 @@
+// Package metrics exports lanmon's Prometheus metrics. The beacon counters
+// live in the default registry and are updated by the packet handlers;
+// host counts are read from the store on each scrape.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/store"
+)
+
+var (
+	// BeaconsReceived counts packets handed to a beacon handler, valid or not.
+	BeaconsReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lanmon_beacons_received_total",
+		Help: "Beacon packets received.",
+	})
+	// HMACFailures counts packets dropped for a bad signature.
+	HMACFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lanmon_beacon_hmac_failures_total",
+		Help: "Beacon packets dropped because HMAC validation failed.",
+	})
+	// StaleDrops counts beacons dropped for a timestamp outside the accepted window.
+	StaleDrops = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lanmon_beacon_stale_drops_total",
+		Help: "Beacons dropped because their timestamp was too old or too far ahead.",
+	})
+	// RateLimited counts packets dropped by receive rate limiting.
+	RateLimited = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lanmon_beacon_rate_limited_total",
+		Help: "Beacon packets dropped by rate limiting.",
+	})
+	// DiscoveryRestarts counts restarts of the discovery subsystem after it
+	// stopped on its own.
+	DiscoveryRestarts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lanmon_discovery_restarts_total",
+		Help: "Times the discovery subsystem was restarted after stopping unexpectedly.",
+	})
+)
+
+var (
+	hostsActiveDesc = prometheus.NewDesc("lanmon_hosts_active", "Hosts currently marked active.", nil, nil)
+	hostsTotalDesc  = prometheus.NewDesc("lanmon_hosts_total", "Hosts known to the store, active or not.", nil, nil)
+)
+
+// hostCollector reports host counts from the store at scrape time, so the
+// gauges can't drift from what 'lanmon list' shows.
+type hostCollector struct {
+	db  *store.Store
+	log zerolog.Logger
+}
+
+func (c hostCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- hostsActiveDesc
+	ch <- hostsTotalDesc
+}
+
+func (c hostCollector) Collect(ch chan<- prometheus.Metric) {
+	records, err := c.db.GetAll()
+	if err != nil {
+		c.log.Warn().Err(err).Msg("Failed to read hosts for metrics")
+		return
+	}
+
+	var active int
+	for _, r := range records {
+		if r.Active {
+			active++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(hostsActiveDesc, prometheus.GaugeValue, float64(active))
+	ch <- prometheus.MustNewConstMetric(hostsTotalDesc, prometheus.GaugeValue, float64(len(records)))
+}
+
+// Handler serves the default registry plus host counts from db.
+func Handler(db *store.Store, log zerolog.Logger) http.Handler {
+	hostReg := prometheus.NewRegistry()
+	hostReg.MustRegister(hostCollector{db: db, log: log})
+
+	gatherers := prometheus.Gatherers{prometheus.DefaultGatherer, hostReg}
+	return promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{})
+}
+
+// Server serves /metrics on its own address.
+type Server struct {
+	srv      *http.Server
+	listener net.Listener
+}
+
+// Start listens on addr and serves /metrics in the background.
+func Start(addr string, db *store.Store, log zerolog.Logger) (*Server, error) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", Handler(db, log))
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", addr, err)
+	}
+
+	s := &Server{
+		srv:      &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second},
+		listener: ln,
+	}
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("Metrics server error")
+		}
+	}()
+
+	log.Info().Str("addr", ln.Addr().String()).Msg("Metrics endpoint listening")
+	return s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Shutdown stops the server, waiting for in-flight scrapes until ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/beacon"
+	"lanmon/internal/store"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	db, err := store.New(filepath.Join(t.TempDir(), "test.db"), zerolog.Nop())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer db.Close()
+
+	db.Upsert(beacon.BeaconPayload{MACAddress: "aa:bb:cc:dd:ee:01", Hostname: "web1"})
+	db.Upsert(beacon.BeaconPayload{MACAddress: "aa:bb:cc:dd:ee:02", Hostname: "web2"})
+	db.MarkInactive("aa:bb:cc:dd:ee:02")
+	HMACFailures.Inc()
+
+	srv, err := Start("127.0.0.1:0", db, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("starting server: %v", err)
+	}
+	defer srv.Shutdown(context.Background())
+
+	resp, err := http.Get("http://" + srv.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	for _, want := range []string{
+		"lanmon_hosts_active 1\n",
+		"lanmon_hosts_total 2\n",
+		"lanmon_beacon_hmac_failures_total 1\n",
+		"lanmon_beacons_received_total 0\n",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"sort"
+
+	"lanmon/internal/store"
+)
+
+// Group is a named bucket of hosts.
+type Group struct {
+	Key   string
+	Hosts []store.HostRecord
+}
+
+// unknownGroup collects hosts whose grouping field is missing or unparsable.
+const unknownGroup = "unknown"
+
+// untaggedGroup collects hosts without tags or labels in GroupByTag.
+const untaggedGroup = "untagged"
+
+// GroupBySubnet buckets hosts by the network portion of their IP address,
+// e.g. "10.51.240.0/24" or "fd00:1:2:3::/64": IPv4 addresses at prefixLen,
+// IPv6 ones at prefixLen6. Groups are ordered numerically by network
+// address, IPv4 before IPv6, with unparsable addresses last.
+func GroupBySubnet(hosts []store.HostRecord, prefixLen, prefixLen6 int) ([]Group, error) {
+	if prefixLen < 0 || prefixLen > 32 {
+		return nil, fmt.Errorf("invalid prefix length %d (must be 0-32)", prefixLen)
+	}
+	if prefixLen6 < 0 || prefixLen6 > 128 {
+		return nil, fmt.Errorf("invalid IPv6 prefix length %d (must be 0-128)", prefixLen6)
+	}
+	mask, mask6 := net.CIDRMask(prefixLen, 32), net.CIDRMask(prefixLen6, 128)
+
+	networks := make(map[string]net.IP)
+	groups := groupBy(hosts, func(h store.HostRecord) string {
+		ip, m := net.ParseIP(h.Beacon.IPAddress), mask6
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, m = ip4, mask
+		}
+		if ip == nil {
+			return unknownGroup
+		}
+		network := &net.IPNet{IP: ip.Mask(m), Mask: m}
+		key := network.String()
+		networks[key] = network.IP
+		return key
+	})
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		a, b := networks[groups[i].Key], networks[groups[j].Key]
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return bytes.Compare(a, b) < 0
+	})
+	return groups, nil
+}
+
+// GroupByOS buckets hosts by reported OS name, ordered alphabetically.
+func GroupByOS(hosts []store.HostRecord) []Group {
+	groups := groupBy(hosts, func(h store.HostRecord) string {
+		if h.Beacon.OS.Name == "" {
+			return unknownGroup
+		}
+		return h.Beacon.OS.Name
+	})
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Key < groups[j].Key })
+	return groups
+}
+
+// GroupByTag buckets hosts by the tags in their beacons and their local
+// labels, as "key=value", ordered alphabetically with untagged hosts last.
+// A host with several tags or labels is in each of their groups.
+func GroupByTag(hosts []store.HostRecord) []Group {
+	groups := groupByAll(hosts, func(h store.HostRecord) []string {
+		tags := slices.Clone(h.Beacon.Tags)
+		for key, value := range h.Labels {
+			tags = append(tags, key+"="+value)
+		}
+		slices.Sort(tags)
+		tags = slices.Compact(tags)
+		if len(tags) == 0 {
+			return []string{untaggedGroup}
+		}
+		return tags
+	})
+	sort.SliceStable(groups, func(i, j int) bool {
+		a, b := groups[i].Key, groups[j].Key
+		if a == untaggedGroup || b == untaggedGroup {
+			return b == untaggedGroup && a != untaggedGroup
+		}
+		return a < b
+	})
+	return groups
+}
+
+// groupBy buckets hosts by key, keeping each bucket in input order.
+func groupBy(hosts []store.HostRecord, key func(store.HostRecord) string) []Group {
+	return groupByAll(hosts, func(h store.HostRecord) []string { return []string{key(h)} })
+}
+
+// groupByAll is groupBy for hosts that may go in several buckets, one for
+// each of their keys.
+func groupByAll(hosts []store.HostRecord, keys func(store.HostRecord) []string) []Group {
+	index := make(map[string]int)
+	var groups []Group
+	for _, h := range hosts {
+		for _, k := range keys(h) {
+			i, ok := index[k]
+			if !ok {
+				i = len(groups)
+				index[k] = i
+				groups = append(groups, Group{Key: k})
+			}
+			groups[i].Hosts = append(groups[i].Hosts, h)
+		}
+	}
+	return groups
+}
+
+// GroupedTables writes one host table per group, headed by the group key
+// and its host count. wide adds the Notes column as in WideHostTable.
+func GroupedTables(w io.Writer, groups []Group, wide bool) {
+	for i, g := range groups {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "  %s (%d)\n\n", g.Key, len(g.Hosts))
+		hostTable(w, g.Hosts, 1, wide)
+	}
+}
+
+// NumberedGroupedTables is GroupedTables numbering hosts on from one group
+// to the next, so the numbers index Flatten(groups).
+func NumberedGroupedTables(w io.Writer, groups []Group, wide bool) {
+	first := 1
+	for i, g := range groups {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "  %s (%d)\n\n", g.Key, len(g.Hosts))
+		hostTable(w, g.Hosts, first, wide)
+		first += len(g.Hosts)
+	}
+}
+
+// Flatten returns the hosts of groups in group order.
+func Flatten(groups []Group) []store.HostRecord {
+	var hosts []store.HostRecord
+	for _, g := range groups {
+		hosts = append(hosts, g.Hosts...)
+	}
+	return hosts
+}
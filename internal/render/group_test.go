@@ -0,0 +1,188 @@
+package render
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"lanmon/internal/beacon"
+	"lanmon/internal/store"
+)
+
+func host(hostname, ip, osName string) store.HostRecord {
+	return store.HostRecord{
+		Beacon: beacon.BeaconPayload{
+			Hostname:  hostname,
+			IPAddress: ip,
+			OS:        beacon.OSInfo{Name: osName},
+		},
+		Active: true,
+	}
+}
+
+func keys(groups []Group) []string {
+	var k []string
+	for _, g := range groups {
+		k = append(k, g.Key)
+	}
+	return k
+}
+
+func TestGroupBySubnet(t *testing.T) {
+	hosts := []store.HostRecord{
+		host("a", "10.0.10.5", "Ubuntu"),
+		host("b", "10.0.9.7", "Ubuntu"),
+		host("c", "10.0.10.6", "Debian"),
+		host("d", "not-an-ip", "Debian"),
+		host("e", "10.0.9.200", "Debian"),
+	}
+
+	groups, err := GroupBySubnet(hosts, 24, 64)
+	if err != nil {
+		t.Fatalf("group by subnet: %v", err)
+	}
+
+	// Numeric, not lexical, ordering: 10.0.9.0 before 10.0.10.0
+	want := []string{"10.0.9.0/24", "10.0.10.0/24", "unknown"}
+	got := keys(groups)
+	if len(got) != len(want) {
+		t.Fatalf("groups: got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("group %d: got %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	if len(groups[0].Hosts) != 2 || groups[0].Hosts[0].Beacon.Hostname != "b" || groups[0].Hosts[1].Beacon.Hostname != "e" {
+		t.Errorf("10.0.9.0/24 hosts: got %+v", groups[0].Hosts)
+	}
+	if len(groups[1].Hosts) != 2 {
+		t.Errorf("10.0.10.0/24 count: got %d, want 2", len(groups[1].Hosts))
+	}
+}
+
+func TestGroupBySubnet_PrefixLength(t *testing.T) {
+	hosts := []store.HostRecord{
+		host("a", "10.51.240.10", ""),
+		host("b", "10.51.241.20", ""),
+	}
+
+	groups, err := GroupBySubnet(hosts, 23, 64)
+	if err != nil {
+		t.Fatalf("group by subnet: %v", err)
+	}
+	if len(groups) != 1 || groups[0].Key != "10.51.240.0/23" {
+		t.Errorf("expected a single 10.51.240.0/23 group, got %v", keys(groups))
+	}
+
+	groups, err = GroupBySubnet(hosts, 24, 64)
+	if err != nil {
+		t.Fatalf("group by subnet: %v", err)
+	}
+	if len(groups) != 2 {
+		t.Errorf("expected 2 groups at /24, got %v", keys(groups))
+	}
+}
+
+func TestGroupBySubnet_IPv6(t *testing.T) {
+	hosts := []store.HostRecord{
+		host("a", "fd00:1:2:3::10", ""),
+		host("b", "10.0.9.7", ""),
+		host("c", "fd00:1:2:3:aaaa::1", ""),
+		host("d", "2001:db8::1", ""),
+		host("e", "::ffff:10.0.9.8", ""),
+	}
+
+	groups, err := GroupBySubnet(hosts, 24, 64)
+	if err != nil {
+		t.Fatalf("group by subnet: %v", err)
+	}
+	// IPv4 first, IPv4-mapped addresses counted as IPv4
+	want := []string{"10.0.9.0/24", "2001:db8::/64", "fd00:1:2:3::/64"}
+	if got := keys(groups); !reflect.DeepEqual(got, want) {
+		t.Fatalf("groups: got %v, want %v", got, want)
+	}
+	if len(groups[0].Hosts) != 2 || len(groups[2].Hosts) != 2 {
+		t.Errorf("counts: got %d and %d, want 2 and 2", len(groups[0].Hosts), len(groups[2].Hosts))
+	}
+
+	groups, err = GroupBySubnet(hosts, 24, 80)
+	if err != nil {
+		t.Fatalf("group by subnet: %v", err)
+	}
+	if got := keys(groups); len(got) != 4 {
+		t.Errorf("expected the fd00 hosts split at /80, got %v", got)
+	}
+}
+
+func TestGroupBySubnet_InvalidPrefix(t *testing.T) {
+	if _, err := GroupBySubnet(nil, 33, 64); err == nil {
+		t.Error("expected error for prefix length 33")
+	}
+	if _, err := GroupBySubnet(nil, 24, 129); err == nil {
+		t.Error("expected error for IPv6 prefix length 129")
+	}
+}
+
+func TestGroupByTag(t *testing.T) {
+	web := host("a", "10.0.0.1", "")
+	web.Beacon.Tags = []string{"web", "prod"}
+	db := host("b", "10.0.0.2", "")
+	db.Beacon.Tags = []string{"prod", "prod"}
+	db.Labels = map[string]string{"role": "db"}
+	plain := host("c", "10.0.0.3", "")
+
+	groups := GroupByTag([]store.HostRecord{web, db, plain})
+	want := []string{"prod", "role=db", "web", "untagged"}
+	if got := keys(groups); !reflect.DeepEqual(got, want) {
+		t.Fatalf("groups: got %v, want %v", got, want)
+	}
+	// A host is in each of its groups, once
+	if len(groups[0].Hosts) != 2 || groups[0].Hosts[0].Beacon.Hostname != "a" || groups[0].Hosts[1].Beacon.Hostname != "b" {
+		t.Errorf("prod hosts: got %+v", groups[0].Hosts)
+	}
+	if len(groups[3].Hosts) != 1 || groups[3].Hosts[0].Beacon.Hostname != "c" {
+		t.Errorf("untagged hosts: got %+v", groups[3].Hosts)
+	}
+}
+
+func TestGroupByOS(t *testing.T) {
+	hosts := []store.HostRecord{
+		host("a", "10.0.0.1", "Ubuntu"),
+		host("b", "10.0.0.2", "Debian"),
+		host("c", "10.0.0.3", "Ubuntu"),
+	}
+
+	groups := GroupByOS(hosts)
+	got := keys(groups)
+	if len(got) != 2 || got[0] != "Debian" || got[1] != "Ubuntu" {
+		t.Fatalf("groups: got %v, want [Debian Ubuntu]", got)
+	}
+	if len(groups[1].Hosts) != 2 {
+		t.Errorf("Ubuntu count: got %d, want 2", len(groups[1].Hosts))
+	}
+}
+
+func TestNumberedGroupedTables(t *testing.T) {
+	groups := GroupByOS([]store.HostRecord{
+		host("a", "10.0.0.1", "Ubuntu"),
+		host("b", "10.0.0.2", "Debian"),
+		host("c", "10.0.0.3", "Ubuntu"),
+	})
+
+	var buf bytes.Buffer
+	NumberedGroupedTables(&buf, groups, false)
+	out := buf.String()
+
+	// Debian's b is 1; Ubuntu's a and c carry on at 2 and 3
+	for _, row := range []string{"  1    b ", "  2    a ", "  3    c "} {
+		if !strings.Contains(out, row) {
+			t.Errorf("expected row %q in:\n%s", row, out)
+		}
+	}
+	if got := hostnames(Flatten(groups)); !reflect.DeepEqual(got, []string{"b", "a", "c"}) {
+		t.Errorf("Flatten: got %v, want [b a c]", got)
+	}
+}
@@ -0,0 +1,19 @@
+package render
+
+import (
+	"encoding/json"
+	"io"
+
+	"lanmon/internal/store"
+)
+
+// HostsJSON writes hosts as an indented JSON array of full host records,
+// with timestamps in RFC 3339. No hosts is written as [] rather than null.
+func HostsJSON(w io.Writer, hosts []store.HostRecord) error {
+	if hosts == nil {
+		hosts = []store.HostRecord{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(hosts)
+}
@@ -0,0 +1,58 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"lanmon/internal/beacon"
+	"lanmon/internal/store"
+)
+
+func TestHostsJSON_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := HostsJSON(&buf, nil); err != nil {
+		t.Fatalf("HostsJSON: %v", err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != "[]" {
+		t.Errorf("got %q, want []", got)
+	}
+}
+
+func TestHostsJSON_Fields(t *testing.T) {
+	seen := time.Date(2024, 2, 20, 16, 0, 0, 0, time.UTC)
+	hosts := []store.HostRecord{{
+		Beacon:       beacon.BeaconPayload{MACAddress: "aa:bb:cc:dd:ee:01", Hostname: "web1"},
+		FirstSeen:    seen,
+		LastSeen:     seen,
+		PacketCount:  7,
+		SSHKeyPushed: true,
+		Active:       true,
+	}}
+
+	var buf bytes.Buffer
+	if err := HostsJSON(&buf, hosts); err != nil {
+		t.Fatalf("HostsJSON: %v", err)
+	}
+
+	var decoded []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("output is not a JSON array: %v", err)
+	}
+	if len(decoded) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(decoded))
+	}
+
+	rec := decoded[0]
+	if rec["last_seen"] != "2024-02-20T16:00:00Z" {
+		t.Errorf("last_seen: got %v, want RFC 3339", rec["last_seen"])
+	}
+	if rec["packet_count"] != float64(7) || rec["ssh_key_pushed"] != true {
+		t.Errorf("missing counters or key status: %v", rec)
+	}
+	if b, ok := rec["beacon"].(map[string]any); !ok || b["Hostname"] != "web1" {
+		t.Errorf("beacon: got %v", rec["beacon"])
+	}
+}
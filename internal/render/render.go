@@ -0,0 +1,217 @@
+// Package render formats host records for terminal output.
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"lanmon/internal/beacon"
+	"lanmon/internal/store"
+)
+
+// HostTable writes hosts as a numbered table. Numbers start at 1 and match
+// the slice order, so callers can map a chosen index back to a host.
+func HostTable(w io.Writer, hosts []store.HostRecord) {
+	hostTable(w, hosts, 1, false)
+}
+
+// WideHostTable is HostTable with a Notes column showing the start of each
+// host's notes.
+func WideHostTable(w io.Writer, hosts []store.HostRecord) {
+	hostTable(w, hosts, 1, true)
+}
+
+// notesWidth is the width of the wide table's Notes column.
+const notesWidth = 40
+
+// hostTable writes hosts numbered from first, with a Notes column if wide.
+func hostTable(w io.Writer, hosts []store.HostRecord, first int, wide bool) {
+	// The GPU and Labels columns only appear when some host has any
+	showGPUs, showLabels := false, false
+	for _, host := range hosts {
+		showGPUs = showGPUs || len(host.Beacon.Hardware.GPUs) > 0
+		showLabels = showLabels || len(host.Labels) > 0
+	}
+
+	fmt.Fprintf(w, "  %-4s %-20s %-17s %-18s %-25s %-10s %-19s %-7s %-14s %-11s %-12s",
+		"#", "Hostname", "IP Address", "MAC Address", "OS", "Last Seen", "Uptime (discovered)", "Up", "Load 1/5/15", "Disk Free", "Key")
+	if showGPUs {
+		fmt.Fprintf(w, " %-20s", "GPUs")
+	}
+	if showLabels {
+		fmt.Fprintf(w, " %-30s", "Labels")
+	}
+	if wide {
+		fmt.Fprintf(w, " %-*s", notesWidth, "Notes")
+	}
+	fmt.Fprintf(w, "\n  %s %s %s %s %s %s %s %s %s %s %s",
+		strings.Repeat("─", 4),
+		strings.Repeat("─", 20),
+		strings.Repeat("─", 17),
+		strings.Repeat("─", 18),
+		strings.Repeat("─", 25),
+		strings.Repeat("─", 10),
+		strings.Repeat("─", 19),
+		strings.Repeat("─", 7),
+		strings.Repeat("─", 14),
+		strings.Repeat("─", 11),
+		strings.Repeat("─", 12))
+	if showGPUs {
+		fmt.Fprintf(w, " %s", strings.Repeat("─", 20))
+	}
+	if showLabels {
+		fmt.Fprintf(w, " %s", strings.Repeat("─", 30))
+	}
+	if wide {
+		fmt.Fprintf(w, " %s", strings.Repeat("─", notesWidth))
+	}
+	fmt.Fprintln(w)
+
+	for i, host := range hosts {
+		keyStatus := "✗"
+		if host.SSHKeyPushed {
+			keyStatus = "✓"
+			if host.SSHKeyUser != "" {
+				keyStatus += " " + Truncate(host.SSHKeyUser, 10)
+			}
+		}
+
+		hostname := Truncate(host.Beacon.Hostname, 20)
+		osName := Truncate(host.Beacon.OS.Name, 25)
+
+		uptime := "-"
+		if host.ContinuousSince != nil {
+			uptime = FormatUptime(time.Since(*host.ContinuousSince))
+		}
+
+		ip := host.Beacon.IPAddress
+		if host.IPMismatch() {
+			ip += " !"
+		}
+		if host.Conflict {
+			ip += " *"
+		}
+
+		fmt.Fprintf(w, "  %-4d %-20s %-17s %-18s %-25s %-10s %-19s %-7s %-14s %-11s %-12s",
+			first+i,
+			hostname,
+			ip,
+			host.Beacon.MACAddress,
+			osName,
+			host.LastSeen.Format("15:04:05"),
+			uptime,
+			formatSystemUptime(host.Beacon.Uptime),
+			formatLoad(host.Beacon.Load),
+			formatDiskFree(host.Beacon.Hardware),
+			keyStatus,
+		)
+		if showGPUs {
+			fmt.Fprintf(w, " %-20s", formatGPUs(host.Beacon.Hardware.GPUs))
+		}
+		if showLabels {
+			fmt.Fprintf(w, " %-30s", Truncate(FormatLabels(host.Labels), 30))
+		}
+		if wide {
+			fmt.Fprintf(w, " %s", formatNotes(host.Notes))
+		}
+		fmt.Fprintln(w)
+	}
+
+	for _, host := range hosts {
+		if host.IPMismatch() {
+			fmt.Fprintf(w, "\n  ! %s reports %s but its beacons come from %s\n",
+				host.Beacon.Hostname, host.Beacon.IPAddress, host.ObservedIP)
+		}
+		if host.Conflict {
+			fmt.Fprintf(w, "\n  * %s (%s) shares %s with another active host\n",
+				host.Beacon.Hostname, host.Beacon.MACAddress, host.Beacon.IPAddress)
+		}
+	}
+}
+
+// formatDiskFree renders a host's free root filesystem space and the share
+// of the disk it is, e.g. "45G (23%)", or "-" if unknown.
+func formatDiskFree(hw beacon.HWInfo) string {
+	if hw.DiskTotalGB <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0fG (%.0f%%)", hw.DiskFreeGB, hw.DiskFreeGB/hw.DiskTotalGB*100)
+}
+
+// formatGPUs renders a host's GPUs as a count and the first model, e.g.
+// "4x NVIDIA A100-SXM4…", or "-" for none.
+func formatGPUs(gpus []string) string {
+	if len(gpus) == 0 {
+		return "-"
+	}
+	return Truncate(fmt.Sprintf("%dx %s", len(gpus), gpus[0]), 20)
+}
+
+// FormatLabels renders labels as "key=value" pairs sorted by key and
+// joined with commas, e.g. "env=staging,role=web", or "-" for none.
+func FormatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "-"
+	}
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// formatNotes renders the first line of notes for the Notes column, or "-"
+// if there are none. Notes that go on past it end in "…".
+func formatNotes(notes string) string {
+	if notes == "" {
+		return "-"
+	}
+	line, _, more := strings.Cut(notes, "\n")
+	line = strings.TrimSpace(line)
+	if more && len(line) < notesWidth {
+		return line + "…"
+	}
+	return Truncate(line, notesWidth)
+}
+
+// FormatUptime renders a duration compactly, e.g. "3d4h", "5h12m" or "7m".
+func FormatUptime(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "<1m"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+}
+
+// formatSystemUptime renders the uptime a host reports, "-" if unknown.
+func formatSystemUptime(seconds uint64) string {
+	if seconds == 0 {
+		return "-"
+	}
+	return FormatUptime(time.Duration(seconds) * time.Second)
+}
+
+// formatLoad renders load averages as "0.5/0.4/0.3", "-" if unknown.
+func formatLoad(l *beacon.LoadInfo) string {
+	if l == nil {
+		return "-"
+	}
+	return Truncate(fmt.Sprintf("%.1f/%.1f/%.1f", l.Load1, l.Load5, l.Load15), 14)
+}
+
+// Truncate shortens s to maxLen characters, marking the cut with an ellipsis.
+func Truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen-1] + "…"
+}
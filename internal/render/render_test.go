@@ -0,0 +1,190 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"lanmon/internal/beacon"
+	"lanmon/internal/store"
+)
+
+func TestHostTable_UptimeAndLoad(t *testing.T) {
+	hosts := []store.HostRecord{
+		{Beacon: beacon.BeaconPayload{
+			Hostname: "busy",
+			Uptime:   uint64((3*24*time.Hour + 4*time.Hour).Seconds()),
+			Load:     &beacon.LoadInfo{Load1: 2.5, Load5: 1.25, Load15: 0.75},
+		}},
+		{Beacon: beacon.BeaconPayload{Hostname: "old-agent"}},
+	}
+
+	var buf bytes.Buffer
+	HostTable(&buf, hosts)
+	lines := strings.Split(buf.String(), "\n")
+
+	if busy := lines[2]; !strings.Contains(busy, "3d4h") || !strings.Contains(busy, "2.5/1.2/0.8") {
+		t.Errorf("expected uptime and load in row:\n%s", busy)
+	}
+	if old := lines[3]; !strings.Contains(old, "old-agent") || strings.Count(old, " - ") < 2 {
+		t.Errorf("expected placeholders for a host without uptime or load:\n%s", old)
+	}
+}
+
+func TestHostTable_IPMismatch(t *testing.T) {
+	hosts := []store.HostRecord{
+		{Beacon: beacon.BeaconPayload{Hostname: "honest", IPAddress: "10.0.0.1"}, ObservedIP: "10.0.0.1"},
+		{Beacon: beacon.BeaconPayload{Hostname: "natted", IPAddress: "10.0.0.2"}, ObservedIP: "10.0.0.99"},
+	}
+
+	var buf bytes.Buffer
+	HostTable(&buf, hosts)
+	lines := strings.Split(buf.String(), "\n")
+
+	if strings.Contains(lines[2], "!") {
+		t.Errorf("unexpected mismatch flag:\n%s", lines[2])
+	}
+	if !strings.Contains(lines[3], "10.0.0.2 !") {
+		t.Errorf("expected mismatch flag:\n%s", lines[3])
+	}
+	if !strings.Contains(buf.String(), "natted reports 10.0.0.2 but its beacons come from 10.0.0.99") {
+		t.Errorf("expected mismatch note:\n%s", buf.String())
+	}
+}
+
+func TestHostTable_Conflict(t *testing.T) {
+	hosts := []store.HostRecord{
+		{Beacon: beacon.BeaconPayload{Hostname: "web1", MACAddress: "aa:00:00:00:00:01", IPAddress: "10.0.0.5"}, Conflict: true},
+		{Beacon: beacon.BeaconPayload{Hostname: "web2", MACAddress: "aa:00:00:00:00:02", IPAddress: "10.0.0.5"}, Conflict: true},
+		{Beacon: beacon.BeaconPayload{Hostname: "db", IPAddress: "10.0.0.6"}},
+	}
+
+	var buf bytes.Buffer
+	HostTable(&buf, hosts)
+	lines := strings.Split(buf.String(), "\n")
+
+	for _, line := range lines[2:4] {
+		if !strings.Contains(line, "10.0.0.5 *") {
+			t.Errorf("expected conflict flag:\n%s", line)
+		}
+	}
+	if strings.Contains(lines[4], "*") {
+		t.Errorf("unexpected conflict flag:\n%s", lines[4])
+	}
+	if !strings.Contains(buf.String(), "web2 (aa:00:00:00:00:02) shares 10.0.0.5 with another active host") {
+		t.Errorf("expected conflict note:\n%s", buf.String())
+	}
+}
+
+func TestHostTable_GPUs(t *testing.T) {
+	plain := []store.HostRecord{{Beacon: beacon.BeaconPayload{Hostname: "web"}}}
+	var buf bytes.Buffer
+	HostTable(&buf, plain)
+	if strings.Contains(buf.String(), "GPUs") {
+		t.Errorf("unexpected GPU column without GPUs:\n%s", buf.String())
+	}
+
+	gpu := beacon.BeaconPayload{Hostname: "trainer"}
+	gpu.Hardware.GPUs = []string{"NVIDIA A100-SXM4-40GB", "NVIDIA A100-SXM4-40GB"}
+	buf.Reset()
+	HostTable(&buf, append(plain, store.HostRecord{Beacon: gpu}))
+	lines := strings.Split(buf.String(), "\n")
+
+	if !strings.Contains(lines[0], "GPUs") {
+		t.Errorf("expected GPU column:\n%s", lines[0])
+	}
+	if !strings.HasSuffix(strings.TrimSpace(lines[2]), " -") {
+		t.Errorf("expected no GPUs for web:\n%s", lines[2])
+	}
+	if !strings.Contains(lines[3], "2x NVIDIA A100-SXM4…") {
+		t.Errorf("expected GPU summary for trainer:\n%s", lines[3])
+	}
+}
+
+func TestHostTable_Labels(t *testing.T) {
+	plain := []store.HostRecord{{Beacon: beacon.BeaconPayload{Hostname: "web"}}}
+	var buf bytes.Buffer
+	HostTable(&buf, plain)
+	if strings.Contains(buf.String(), "Labels") {
+		t.Errorf("unexpected Labels column without labels:\n%s", buf.String())
+	}
+
+	labeled := store.HostRecord{
+		Beacon: beacon.BeaconPayload{Hostname: "db"},
+		Labels: map[string]string{"role": "db", "env": "staging"},
+	}
+	buf.Reset()
+	HostTable(&buf, append(plain, labeled))
+	lines := strings.Split(buf.String(), "\n")
+
+	if !strings.Contains(lines[0], "Labels") {
+		t.Errorf("expected Labels column:\n%s", lines[0])
+	}
+	if !strings.Contains(lines[3], "env=staging,role=db") {
+		t.Errorf("expected sorted labels for db:\n%s", lines[3])
+	}
+}
+
+func TestWideHostTable_Notes(t *testing.T) {
+	hosts := []store.HostRecord{
+		{Beacon: beacon.BeaconPayload{Hostname: "web"}},
+		{Beacon: beacon.BeaconPayload{Hostname: "db"}, Notes: "flaky PSU\nreplace before the move"},
+		{Beacon: beacon.BeaconPayload{Hostname: "nas"}, Notes: strings.Repeat("x", 50)},
+	}
+
+	var buf bytes.Buffer
+	HostTable(&buf, hosts)
+	if strings.Contains(buf.String(), "Notes") {
+		t.Errorf("unexpected Notes column in the plain table:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	WideHostTable(&buf, hosts)
+	lines := strings.Split(buf.String(), "\n")
+	if !strings.Contains(lines[0], "Notes") {
+		t.Errorf("expected Notes column:\n%s", lines[0])
+	}
+	if !strings.HasSuffix(lines[2], " -") {
+		t.Errorf("expected - for a host without notes:\n%s", lines[2])
+	}
+	if !strings.HasSuffix(lines[3], " flaky PSU…") || strings.Contains(buf.String(), "replace") {
+		t.Errorf("expected only the first line of db's notes:\n%s", lines[3])
+	}
+	if !strings.HasSuffix(lines[4], " "+strings.Repeat("x", 39)+"…") {
+		t.Errorf("expected nas's notes cut at the column width:\n%s", lines[4])
+	}
+}
+
+func TestFormatDiskFree(t *testing.T) {
+	tests := []struct {
+		hw   beacon.HWInfo
+		want string
+	}{
+		{beacon.HWInfo{DiskTotalGB: 200, DiskFreeGB: 46.4}, "46G (23%)"},
+		{beacon.HWInfo{DiskTotalGB: 50, DiskFreeGB: 0.2}, "0G (0%)"},
+		{beacon.HWInfo{DiskCount: 3}, "-"},
+	}
+	for _, tt := range tests {
+		if got := formatDiskFree(tt.hw); got != tt.want {
+			t.Errorf("formatDiskFree(%+v): got %q, want %q", tt.hw, got, tt.want)
+		}
+	}
+}
+
+func TestFormatUptime(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{30 * time.Second, "<1m"},
+		{7 * time.Minute, "7m"},
+		{5*time.Hour + 12*time.Minute, "5h12m"},
+		{76 * time.Hour, "3d4h"},
+	}
+	for _, tt := range tests {
+		if got := FormatUptime(tt.d); got != tt.want {
+			t.Errorf("FormatUptime(%v): got %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
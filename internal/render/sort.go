@@ -0,0 +1,77 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"lanmon/internal/store"
+)
+
+// SortKey is a host table ordering.
+type SortKey string
+
+const (
+	SortHostname SortKey = "hostname"
+	SortIP       SortKey = "ip"
+	SortLastSeen SortKey = "last-seen" // most recent first
+	SortOS       SortKey = "os"
+)
+
+// ParseSortKey parses a --sort value. Empty selects SortLastSeen.
+func ParseSortKey(s string) (SortKey, error) {
+	switch key := SortKey(s); key {
+	case "":
+		return SortLastSeen, nil
+	case SortHostname, SortIP, SortLastSeen, SortOS:
+		return key, nil
+	}
+	return "", fmt.Errorf("unknown sort key %q (want hostname, ip, last-seen or os)", s)
+}
+
+// SortHosts sorts hosts in place. IP addresses compare numerically, so
+// 192.168.1.2 comes before 192.168.1.10, with unparsable addresses last.
+// Ties are broken by hostname.
+func SortHosts(hosts []store.HostRecord, key SortKey) {
+	byName := func(a, b store.HostRecord) bool {
+		return strings.ToLower(a.Beacon.Hostname) < strings.ToLower(b.Beacon.Hostname)
+	}
+
+	var less func(a, b store.HostRecord) bool
+	switch key {
+	case SortHostname:
+		less = byName
+	case SortIP:
+		less = func(a, b store.HostRecord) bool {
+			ipA, ipB := net.ParseIP(a.Beacon.IPAddress), net.ParseIP(b.Beacon.IPAddress)
+			if ipA == nil || ipB == nil {
+				if (ipA == nil) != (ipB == nil) {
+					return ipB == nil
+				}
+				return byName(a, b)
+			}
+			if c := bytes.Compare(ipA.To16(), ipB.To16()); c != 0 {
+				return c < 0
+			}
+			return byName(a, b)
+		}
+	case SortOS:
+		less = func(a, b store.HostRecord) bool {
+			if a.Beacon.OS.Name != b.Beacon.OS.Name {
+				return a.Beacon.OS.Name < b.Beacon.OS.Name
+			}
+			return byName(a, b)
+		}
+	default:
+		less = func(a, b store.HostRecord) bool {
+			if !a.LastSeen.Equal(b.LastSeen) {
+				return a.LastSeen.After(b.LastSeen)
+			}
+			return byName(a, b)
+		}
+	}
+
+	sort.SliceStable(hosts, func(i, j int) bool { return less(hosts[i], hosts[j]) })
+}
@@ -0,0 +1,60 @@
+package render
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"lanmon/internal/store"
+)
+
+func hostnames(hosts []store.HostRecord) []string {
+	var names []string
+	for _, h := range hosts {
+		names = append(names, h.Beacon.Hostname)
+	}
+	return names
+}
+
+func TestSortHosts(t *testing.T) {
+	now := time.Now()
+	seen := func(h store.HostRecord, ago time.Duration) store.HostRecord {
+		h.LastSeen = now.Add(-ago)
+		return h
+	}
+	hosts := []store.HostRecord{
+		seen(host("web-10", "192.168.1.10", "Ubuntu"), 3*time.Second),
+		seen(host("Alpha", "192.168.1.2", "Debian"), 1*time.Second),
+		seen(host("bogus", "not-an-ip", "Ubuntu"), 2*time.Second),
+		seen(host("db", "10.0.0.1", "Debian"), 5*time.Second),
+	}
+
+	tests := []struct {
+		key  SortKey
+		want []string
+	}{
+		{SortHostname, []string{"Alpha", "bogus", "db", "web-10"}},
+		{SortIP, []string{"db", "Alpha", "web-10", "bogus"}},
+		{SortLastSeen, []string{"Alpha", "bogus", "web-10", "db"}},
+		{SortOS, []string{"Alpha", "db", "bogus", "web-10"}},
+	}
+	for _, tt := range tests {
+		sorted := append([]store.HostRecord(nil), hosts...)
+		SortHosts(sorted, tt.key)
+		if got := hostnames(sorted); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestParseSortKey(t *testing.T) {
+	if key, err := ParseSortKey(""); err != nil || key != SortLastSeen {
+		t.Errorf("empty: got %q, %v; want last-seen", key, err)
+	}
+	if key, err := ParseSortKey("ip"); err != nil || key != SortIP {
+		t.Errorf("ip: got %q, %v", key, err)
+	}
+	if _, err := ParseSortKey("mac"); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+}
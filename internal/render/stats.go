@@ -0,0 +1,41 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"lanmon/internal/store"
+)
+
+// StatsTable writes a summary of stats: the host and beacon totals, then
+// hosts per OS, most common first.
+func StatsTable(w io.Writer, stats store.Stats) {
+	fmt.Fprintf(w, "  %-28s %d\n", "Hosts", stats.TotalHosts)
+	fmt.Fprintf(w, "  %-28s %d\n", "Active hosts", stats.ActiveHosts)
+	fmt.Fprintf(w, "  %-28s %d\n", "Keys pushed", stats.KeysPushed)
+	fmt.Fprintf(w, "  %-28s %d\n", "Beacons received (this run)", stats.BeaconsReceived)
+	fmt.Fprintf(w, "  %-28s %d\n", "Discovery restarts", stats.DiscoveryRestarts)
+
+	if len(stats.ByOS) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(stats.ByOS))
+	for name := range stats.ByOS {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if stats.ByOS[names[i]] != stats.ByOS[names[j]] {
+			return stats.ByOS[names[i]] > stats.ByOS[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	fmt.Fprintf(w, "\n  %-28s %s\n", "OS", "Hosts")
+	fmt.Fprintf(w, "  %s %s\n", strings.Repeat("─", 28), strings.Repeat("─", 5))
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-28s %d\n", Truncate(name, 28), stats.ByOS[name])
+	}
+}
@@ -0,0 +1,36 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"lanmon/internal/store"
+)
+
+func TestStatsTable(t *testing.T) {
+	var buf bytes.Buffer
+	StatsTable(&buf, store.Stats{
+		TotalHosts:        5,
+		ActiveHosts:       4,
+		KeysPushed:        2,
+		BeaconsReceived:   120,
+		DiscoveryRestarts: 2,
+		ByOS:              map[string]int{"Debian 12": 1, "Ubuntu 22.04": 3, "unknown": 1},
+	})
+	out := buf.String()
+
+	for _, want := range []string{"Hosts                        5", "Beacons received (this run)  120", "Discovery restarts           2"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+
+	// Most common OS first, ties by name
+	ubuntu := strings.Index(out, "Ubuntu 22.04")
+	debian := strings.Index(out, "Debian 12")
+	unknown := strings.Index(out, "unknown")
+	if !(ubuntu < debian && debian < unknown) {
+		t.Errorf("unexpected OS order:\n%s", out)
+	}
+}
@@ -0,0 +1,65 @@
+package rpc
+
+import (
+	"fmt"
+
+	"lanmon/internal/auth"
+)
+
+// readOnlyService is Service as served to read-only callers: the methods
+// that only read host data pass through, and those that modify it fail
+// with auth.ErrForbidden. Methods added to Service aren't served here
+// until they're added below, so a new write can't slip through.
+type readOnlyService struct {
+	s *Service
+}
+
+// forbidden is the error write methods return to read-only callers. It
+// reaches the client as text, like every net/rpc error.
+func forbidden(method string) error {
+	return fmt.Errorf("%w: %s needs read-write access", auth.ErrForbidden, method)
+}
+
+func (r readOnlyService) Ping(args *PingArgs, reply *PingReply) error {
+	return r.s.Ping(args, reply)
+}
+
+func (r readOnlyService) Stats(args *StatsArgs, reply *StatsReply) error {
+	return r.s.Stats(args, reply)
+}
+
+func (r readOnlyService) ListActiveHosts(args *ListActiveHostsArgs, reply *ListActiveHostsReply) error {
+	return r.s.ListActiveHosts(args, reply)
+}
+
+func (r readOnlyService) QueryHosts(args *QueryHostsArgs, reply *QueryHostsReply) error {
+	return r.s.QueryHosts(args, reply)
+}
+
+func (r readOnlyService) GetHistory(args *GetHistoryArgs, reply *GetHistoryReply) error {
+	return r.s.GetHistory(args, reply)
+}
+
+func (r readOnlyService) MarkKeyPushed(args *MarkKeyPushedArgs, reply *MarkKeyPushedReply) error {
+	return forbidden("MarkKeyPushed")
+}
+
+func (r readOnlyService) ClearKeyPushed(args *ClearKeyPushedArgs, reply *ClearKeyPushedReply) error {
+	return forbidden("ClearKeyPushed")
+}
+
+func (r readOnlyService) RemoveHost(args *RemoveHostArgs, reply *RemoveHostReply) error {
+	return forbidden("RemoveHost")
+}
+
+func (r readOnlyService) SetLabel(args *SetLabelArgs, reply *SetLabelReply) error {
+	return forbidden("SetLabel")
+}
+
+func (r readOnlyService) RemoveLabel(args *RemoveLabelArgs, reply *RemoveLabelReply) error {
+	return forbidden("RemoveLabel")
+}
+
+func (r readOnlyService) SetNotes(args *SetNotesArgs, reply *SetNotesReply) error {
+	return forbidden("SetNotes")
+}
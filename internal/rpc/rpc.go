@@ -2,20 +2,58 @@
 package rpc
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	netrpc "net/rpc"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
 
+	"lanmon/internal/auth"
 	"lanmon/internal/store"
 )
 
+// Version, Commit and BuildDate describe the build, as reported by Ping
+// and Stats. The lanmon binary sets them at startup.
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
 // Service is the RPC service exposed by the server.
 type Service struct {
-	store *store.Store
-	log   zerolog.Logger
+	store   *store.Store
+	log     zerolog.Logger
+	started time.Time
+}
+
+// PingArgs is the request for Ping.
+type PingArgs struct{}
+
+// PingReply is the response for Ping.
+type PingReply struct {
+	Version   string
+	Commit    string
+	BuildDate string
+	Uptime    time.Duration
+	HostCount int
+}
+
+// StatsArgs is the request for Stats.
+type StatsArgs struct{}
+
+// StatsReply is the response for Stats.
+type StatsReply struct {
+	Stats store.Stats
+	// Version, Commit and BuildDate identify the node's build.
+	Version   string
+	Commit    string
+	BuildDate string
 }
 
 // ListActiveHostsArgs is the request for ListActiveHosts.
@@ -26,9 +64,21 @@ type ListActiveHostsReply struct {
 	Hosts []store.HostRecord
 }
 
+// QueryHostsArgs is the request for QueryHosts.
+type QueryHostsArgs struct {
+	Options store.QueryOptions
+}
+
+// QueryHostsReply is the response for QueryHosts.
+type QueryHostsReply struct {
+	Hosts []store.HostRecord
+}
+
 // MarkKeyPushedArgs is the request for MarkKeyPushed.
 type MarkKeyPushedArgs struct {
-	MAC string
+	MAC         string
+	User        string
+	Fingerprint string
 }
 
 // MarkKeyPushedReply is the response for MarkKeyPushed.
@@ -36,6 +86,99 @@ type MarkKeyPushedReply struct {
 	Success bool
 }
 
+// ClearKeyPushedArgs is the request for ClearKeyPushed.
+type ClearKeyPushedArgs struct {
+	MAC string
+}
+
+// ClearKeyPushedReply is the response for ClearKeyPushed.
+type ClearKeyPushedReply struct {
+	Success bool
+}
+
+// RemoveHostArgs is the request for RemoveHost.
+type RemoveHostArgs struct {
+	MAC string
+}
+
+// RemoveHostReply is the response for RemoveHost.
+type RemoveHostReply struct {
+	Success bool
+}
+
+// SetLabelArgs is the request for SetLabel.
+type SetLabelArgs struct {
+	MAC   string
+	Key   string
+	Value string
+}
+
+// SetLabelReply is the response for SetLabel.
+type SetLabelReply struct {
+	Success bool
+}
+
+// RemoveLabelArgs is the request for RemoveLabel.
+type RemoveLabelArgs struct {
+	MAC string
+	Key string
+}
+
+// RemoveLabelReply is the response for RemoveLabel.
+type RemoveLabelReply struct {
+	Success bool
+}
+
+// SetNotesArgs is the request for SetNotes.
+type SetNotesArgs struct {
+	MAC   string
+	Notes string
+}
+
+// SetNotesReply is the response for SetNotes.
+type SetNotesReply struct {
+	Success bool
+}
+
+// GetHistoryArgs is the request for GetHistory.
+type GetHistoryArgs struct {
+	MAC string
+}
+
+// GetHistoryReply is the response for GetHistory.
+type GetHistoryReply struct {
+	Times []time.Time
+}
+
+// Ping reports that the server is up, with its version, uptime and the
+// number of hosts in its database.
+func (s *Service) Ping(args *PingArgs, reply *PingReply) error {
+	hosts, err := s.store.GetAll()
+	if err != nil {
+		return fmt.Errorf("counting hosts: %w", err)
+	}
+	reply.Version = Version
+	reply.Commit = Commit
+	reply.BuildDate = BuildDate
+	reply.Uptime = time.Since(s.started)
+	reply.HostCount = len(hosts)
+	return nil
+}
+
+// Stats returns aggregate host and beacon counts, and the node's build.
+// Beacon counts cover the node's current run only.
+func (s *Service) Stats(args *StatsArgs, reply *StatsReply) error {
+	stats, err := s.store.Stats()
+	if err != nil {
+		return fmt.Errorf("computing stats: %w", err)
+	}
+	reply.Stats = stats
+	reply.Version = Version
+	reply.Commit = Commit
+	reply.BuildDate = BuildDate
+	return nil
+}
+
 // ListActiveHosts returns all active host records.
 func (s *Service) ListActiveHosts(args *ListActiveHostsArgs, reply *ListActiveHostsReply) error {
 	hosts, err := s.store.GetActive()
@@ -46,65 +189,238 @@ func (s *Service) ListActiveHosts(args *ListActiveHostsArgs, reply *ListActiveHo
 	return nil
 }
 
+// QueryHosts returns one page of host records, filtered as in
+// store.Query.
+func (s *Service) QueryHosts(args *QueryHostsArgs, reply *QueryHostsReply) error {
+	hosts, err := s.store.Query(args.Options)
+	if err != nil {
+		return fmt.Errorf("querying hosts: %w", err)
+	}
+	reply.Hosts = hosts
+	return nil
+}
+
 // MarkKeyPushed marks the SSH key as pushed for the given MAC address.
 func (s *Service) MarkKeyPushed(args *MarkKeyPushedArgs, reply *MarkKeyPushedReply) error {
-	if err := s.store.MarkKeyPushed(args.MAC); err != nil {
+	if err := s.store.MarkKeyPushed(args.MAC, args.User, args.Fingerprint); err != nil {
 		return fmt.Errorf("marking key pushed: %w", err)
 	}
 	reply.Success = true
 	return nil
 }
 
-// StartServer starts the Unix socket RPC server.
-func StartServer(socketPath string, db *store.Store, log zerolog.Logger) error {
-	service := &Service{store: db, log: log}
+// ClearKeyPushed records that the SSH key was removed from the given MAC
+// address.
+func (s *Service) ClearKeyPushed(args *ClearKeyPushedArgs, reply *ClearKeyPushedReply) error {
+	if err := s.store.ClearKeyPushed(args.MAC); err != nil {
+		return fmt.Errorf("clearing key status: %w", err)
+	}
+	reply.Success = true
+	return nil
+}
+
+// RemoveHost deletes the host record for the given MAC address.
+func (s *Service) RemoveHost(args *RemoveHostArgs, reply *RemoveHostReply) error {
+	if err := s.store.RemoveHost(args.MAC); err != nil {
+		return fmt.Errorf("removing host: %w", err)
+	}
+	reply.Success = true
+	return nil
+}
+
+// SetLabel sets a label on the host with the given MAC address.
+func (s *Service) SetLabel(args *SetLabelArgs, reply *SetLabelReply) error {
+	if err := s.store.SetLabel(args.MAC, args.Key, args.Value); err != nil {
+		return fmt.Errorf("setting label: %w", err)
+	}
+	reply.Success = true
+	return nil
+}
 
-	server := netrpc.NewServer()
-	if err := server.Register(service); err != nil {
-		return fmt.Errorf("registering RPC service: %w", err)
+// RemoveLabel removes a label from the host with the given MAC address.
+func (s *Service) RemoveLabel(args *RemoveLabelArgs, reply *RemoveLabelReply) error {
+	if err := s.store.RemoveLabel(args.MAC, args.Key); err != nil {
+		return fmt.Errorf("removing label: %w", err)
 	}
+	reply.Success = true
+	return nil
+}
 
-	// Remove existing socket file if present
-	os.Remove(socketPath)
+// SetNotes replaces the notes on the host with the given MAC address.
+func (s *Service) SetNotes(args *SetNotesArgs, reply *SetNotesReply) error {
+	if err := s.store.SetNotes(args.MAC, args.Notes); err != nil {
+		return fmt.Errorf("setting notes: %w", err)
+	}
+	reply.Success = true
+	return nil
+}
 
-	listener, err := net.Listen("unix", socketPath)
+// GetHistory returns when beacons from the given MAC address were seen.
+func (s *Service) GetHistory(args *GetHistoryArgs, reply *GetHistoryReply) error {
+	times, err := s.store.GetHistory(args.MAC)
 	if err != nil {
-		return fmt.Errorf("listening on %s: %w", socketPath, err)
+		return fmt.Errorf("fetching history: %w", err)
 	}
+	reply.Times = times
+	return nil
+}
 
-	// Set socket permissions
-	if err := os.Chmod(socketPath, 0660); err != nil {
-		log.Warn().Err(err).Msg("Failed to set socket permissions")
+// Server is a running RPC server.
+type Server struct {
+	listener net.Listener
+	network  string
+	address  string
+	done     chan struct{}
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// StartServer starts the RPC server on addr, a Unix socket path or a
+// "tcp://host:port" address (see ParseAddr). TCP clients must present a
+// token that a accepts, which can't be nil, and read-only ones may only
+// call the methods that read host data. Unix socket clients are trusted
+// by the socket's permissions, with read-write access, and a is ignored.
+func StartServer(addr string, a auth.Authenticator, db *store.Store, log zerolog.Logger) (*Server, error) {
+	network, address := ParseAddr(addr)
+	if chain, ok := a.(auth.Any); ok && len(chain) == 0 {
+		// Accepts no one, so as good as missing
+		a = nil
+	}
+	if network == "tcp" && a == nil {
+		return nil, fmt.Errorf("serving RPC on %s: authentication is required over TCP", addr)
+	}
+	if network != "tcp" {
+		a = nil
 	}
 
-	log.Info().Str("socket", socketPath).Msg("RPC server started")
+	service := &Service{store: db, log: log, started: time.Now()}
 
+	servers := make(map[auth.Role]*netrpc.Server, 2)
+	for role, rcvr := range map[auth.Role]any{auth.RoleReadWrite: service, auth.RoleReadOnly: readOnlyService{service}} {
+		servers[role] = netrpc.NewServer()
+		if err := servers[role].RegisterName("Service", rcvr); err != nil {
+			return nil, fmt.Errorf("registering RPC service: %w", err)
+		}
+	}
+
+	if network == "unix" {
+		// Remove existing socket file if present
+		os.Remove(address)
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", addr, err)
+	}
+
+	if network == "unix" {
+		// Set socket permissions
+		if err := os.Chmod(address, 0660); err != nil {
+			log.Warn().Err(err).Msg("Failed to set socket permissions")
+		}
+		log.Info().Str("socket", address).Msg("RPC server started")
+	} else {
+		log.Info().Str("addr", listener.Addr().String()).Msg("RPC server started on TCP")
+	}
+
+	s := &Server{
+		listener: listener,
+		network:  network,
+		address:  address,
+		done:     make(chan struct{}),
+		conns:    make(map[net.Conn]struct{}),
+	}
 	go func() {
+		defer close(s.done)
 		for {
 			conn, err := listener.Accept()
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
 			if err != nil {
 				log.Error().Err(err).Msg("RPC accept error")
 				continue
 			}
-			go server.ServeConn(conn)
+			if !s.track(conn) {
+				conn.Close()
+				return
+			}
+			go func() {
+				defer s.untrack(conn)
+				serveConn(servers, conn, a, db, log)
+			}()
 		}
 	}()
 
-	return nil
+	return s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close stops accepting connections, closes the ones being served and,
+// for a Unix socket, removes the socket file.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	err := s.listener.Close()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	<-s.done
+	if s.network == "unix" {
+		os.Remove(s.address)
+	}
+	return err
+}
+
+// track records a connection being served. It returns false once the
+// server is closed.
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+	conn.Close()
 }
 
 // Client is a client for the lanmon RPC service.
 type Client struct {
-	client *netrpc.Client
+	client  *netrpc.Client
+	network string
+	address string
+	token   string
 }
 
-// NewClient dials the Unix socket and returns an RPC client.
-func NewClient(socketPath string) (*Client, error) {
-	conn, err := net.Dial("unix", socketPath)
+// NewClient connects to the RPC server at addr, a Unix socket path or a
+// "tcp://host:port" address, and returns an RPC client. token is sent to
+// TCP servers and ignored for Unix sockets.
+func NewClient(addr, token string) (*Client, error) {
+	network, address := ParseAddr(addr)
+	conn, err := dial(context.Background(), network, address, token)
 	if err != nil {
-		return nil, fmt.Errorf("connecting to RPC socket %s: %w", socketPath, err)
+		return nil, fmt.Errorf("connecting to RPC server %s: %w", addr, err)
 	}
-	return &Client{client: netrpc.NewClient(conn)}, nil
+	return &Client{client: netrpc.NewClient(conn), network: network, address: address, token: token}, nil
 }
 
 // Close closes the RPC client connection.
@@ -112,6 +428,26 @@ func (c *Client) Close() error {
 	return c.client.Close()
 }
 
+// Ping checks that the server is reachable and reports its status.
+func (c *Client) Ping() (*PingReply, error) {
+	args := &PingArgs{}
+	reply := &PingReply{}
+	if err := c.client.Call("Service.Ping", args, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// Stats fetches aggregate host and beacon counts, and the server's build.
+func (c *Client) Stats() (*StatsReply, error) {
+	args := &StatsArgs{}
+	reply := &StatsReply{}
+	if err := c.client.Call("Service.Stats", args, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
 // ListActiveHosts fetches all active hosts from the server.
 func (c *Client) ListActiveHosts() ([]store.HostRecord, error) {
 	args := &ListActiveHostsArgs{}
@@ -122,9 +458,66 @@ func (c *Client) ListActiveHosts() ([]store.HostRecord, error) {
 	return reply.Hosts, nil
 }
 
-// MarkKeyPushed tells the server to mark a host's SSH key as pushed.
-func (c *Client) MarkKeyPushed(mac string) error {
-	args := &MarkKeyPushedArgs{MAC: mac}
+// QueryHosts fetches one page of hosts selected by opts. Servers older
+// than this call reject it; ListActiveHosts works with any server.
+func (c *Client) QueryHosts(opts store.QueryOptions) ([]store.HostRecord, error) {
+	args := &QueryHostsArgs{Options: opts}
+	reply := &QueryHostsReply{}
+	if err := c.client.Call("Service.QueryHosts", args, reply); err != nil {
+		return nil, err
+	}
+	return reply.Hosts, nil
+}
+
+// MarkKeyPushed tells the server to mark a host's SSH key, identified by
+// its fingerprint, as pushed for user.
+func (c *Client) MarkKeyPushed(mac, user, fingerprint string) error {
+	args := &MarkKeyPushedArgs{MAC: mac, User: user, Fingerprint: fingerprint}
 	reply := &MarkKeyPushedReply{}
 	return c.client.Call("Service.MarkKeyPushed", args, reply)
 }
+
+// ClearKeyPushed tells the server the SSH key is no longer on a host.
+func (c *Client) ClearKeyPushed(mac string) error {
+	args := &ClearKeyPushedArgs{MAC: mac}
+	reply := &ClearKeyPushedReply{}
+	return c.client.Call("Service.ClearKeyPushed", args, reply)
+}
+
+// RemoveHost tells the server to delete a host record.
+func (c *Client) RemoveHost(mac string) error {
+	args := &RemoveHostArgs{MAC: mac}
+	reply := &RemoveHostReply{}
+	return c.client.Call("Service.RemoveHost", args, reply)
+}
+
+// SetLabel tells the server to set the label key=value on a host.
+func (c *Client) SetLabel(mac, key, value string) error {
+	args := &SetLabelArgs{MAC: mac, Key: key, Value: value}
+	reply := &SetLabelReply{}
+	return c.client.Call("Service.SetLabel", args, reply)
+}
+
+// RemoveLabel tells the server to remove the label key from a host.
+func (c *Client) RemoveLabel(mac, key string) error {
+	args := &RemoveLabelArgs{MAC: mac, Key: key}
+	reply := &RemoveLabelReply{}
+	return c.client.Call("Service.RemoveLabel", args, reply)
+}
+
+// SetNotes tells the server to replace a host's notes; empty clears them.
+func (c *Client) SetNotes(mac, notes string) error {
+	args := &SetNotesArgs{MAC: mac, Notes: notes}
+	reply := &SetNotesReply{}
+	return c.client.Call("Service.SetNotes", args, reply)
+}
+
+// GetHistory fetches when beacons from a host were seen, oldest first.
+func (c *Client) GetHistory(mac string) ([]time.Time, error) {
+	args := &GetHistoryArgs{MAC: mac}
+	reply := &GetHistoryReply{}
+	if err := c.client.Call("Service.GetHistory", args, reply); err != nil {
+		return nil, err
+	}
+	return reply.Times, nil
+}
@@ -0,0 +1,230 @@
+package rpc
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/beacon"
+	"lanmon/internal/store"
+)
+
+func startTestServer(t *testing.T) (*store.Store, *Client) {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := store.New(filepath.Join(dir, "test.db"), zerolog.Nop())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	socket := filepath.Join(dir, "rpc.sock")
+	srv, err := StartServer(socket, nil, db, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("starting server: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	client, err := NewClient(socket, "")
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return db, client
+}
+
+func TestSubscribe(t *testing.T) {
+	db, client := startTestServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, err := client.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+
+	// The stream is registered asynchronously; keep upserting until it shows
+	mac := "aa:bb:cc:dd:ee:01"
+	var ev store.Event
+	for got := false; !got; {
+		db.Upsert(beacon.BeaconPayload{MACAddress: mac, Hostname: "web1"})
+		select {
+		case ev = <-events:
+			got = true
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("no event received")
+		}
+	}
+	if ev.Host.Beacon.MACAddress != mac {
+		t.Errorf("event for %q, want %q", ev.Host.Beacon.MACAddress, mac)
+	}
+
+	// RPC calls still work alongside the stream
+	if err := client.MarkKeyPushed(mac, "alice", ""); err != nil {
+		t.Fatalf("mark key pushed failed: %v", err)
+	}
+	for ev = range events {
+		if ev.Type == store.EventKeyPushed {
+			break
+		}
+	}
+	if ev.Type != store.EventKeyPushed || ev.Host.SSHKeyUser != "alice" {
+		t.Errorf("expected key_pushed event for alice, got %s %+v", ev.Type, ev.Host)
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestPing(t *testing.T) {
+	db, client := startTestServer(t)
+
+	for _, mac := range []string{"aa:bb:cc:dd:ee:01", "aa:bb:cc:dd:ee:02"} {
+		if err := db.Upsert(beacon.BeaconPayload{MACAddress: mac}); err != nil {
+			t.Fatalf("upsert: %v", err)
+		}
+	}
+
+	reply, err := client.Ping()
+	if err != nil {
+		t.Fatalf("ping failed: %v", err)
+	}
+	if reply.Version != Version {
+		t.Errorf("Version: got %q, want %q", reply.Version, Version)
+	}
+	if reply.HostCount != 2 {
+		t.Errorf("HostCount: got %d, want 2", reply.HostCount)
+	}
+	if reply.Uptime <= 0 {
+		t.Errorf("Uptime: got %s, want > 0", reply.Uptime)
+	}
+}
+
+func TestStats(t *testing.T) {
+	db, client := startTestServer(t)
+
+	db.Upsert(beacon.BeaconPayload{MACAddress: "aa:bb:cc:dd:ee:01", OS: beacon.OSInfo{Name: "Debian 12"}})
+	if err := db.MarkKeyPushed("aa:bb:cc:dd:ee:01", "alice", ""); err != nil {
+		t.Fatalf("mark key pushed: %v", err)
+	}
+
+	reply, err := client.Stats()
+	if err != nil {
+		t.Fatalf("stats failed: %v", err)
+	}
+	if reply.Version != Version || reply.Commit != Commit || reply.BuildDate != BuildDate {
+		t.Errorf("build: got %s %s %s, want %s %s %s",
+			reply.Version, reply.Commit, reply.BuildDate, Version, Commit, BuildDate)
+	}
+	stats := reply.Stats
+	if stats.TotalHosts != 1 || stats.KeysPushed != 1 || stats.BeaconsReceived != 1 || stats.ByOS["Debian 12"] != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestQueryHosts(t *testing.T) {
+	db, client := startTestServer(t)
+
+	for _, mac := range []string{"aa:bb:cc:dd:ee:01", "aa:bb:cc:dd:ee:02", "aa:bb:cc:dd:ee:03"} {
+		db.Upsert(beacon.BeaconPayload{MACAddress: mac, Hostname: "web", IPAddress: "10.0.0.1"})
+	}
+
+	hosts, err := client.QueryHosts(store.QueryOptions{ActiveOnly: true, Match: "WEB", Offset: 1, Limit: 1})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(hosts) != 1 || hosts[0].Beacon.MACAddress != "aa:bb:cc:dd:ee:02" {
+		t.Errorf("unexpected page: %+v", hosts)
+	}
+}
+
+func TestLabelsAndNotes(t *testing.T) {
+	db, client := startTestServer(t)
+	db.Upsert(beacon.BeaconPayload{MACAddress: "aa:bb:cc:dd:ee:01"})
+
+	if err := client.SetLabel("aa:bb:cc:dd:ee:01", "role", "web"); err != nil {
+		t.Fatalf("set label: %v", err)
+	}
+	hosts, _ := db.GetAll()
+	if hosts[0].Labels["role"] != "web" {
+		t.Errorf("expected role=web, got %v", hosts[0].Labels)
+	}
+
+	if err := client.RemoveLabel("aa:bb:cc:dd:ee:01", "role"); err != nil {
+		t.Fatalf("remove label: %v", err)
+	}
+	hosts, _ = db.GetAll()
+	if len(hosts[0].Labels) != 0 {
+		t.Errorf("expected no labels, got %v", hosts[0].Labels)
+	}
+
+	if err := client.SetLabel("aa:bb:cc:dd:ee:02", "role", "web"); err == nil {
+		t.Error("expected error for unknown host")
+	}
+
+	if err := client.SetNotes("aa:bb:cc:dd:ee:01", "flaky PSU"); err != nil {
+		t.Fatalf("set notes: %v", err)
+	}
+	hosts, _ = db.GetAll()
+	if hosts[0].Notes != "flaky PSU" {
+		t.Errorf("notes: got %q", hosts[0].Notes)
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	dir := t.TempDir()
+	db, err := store.New(filepath.Join(dir, "test.db"), zerolog.Nop())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer db.Close()
+
+	socket := filepath.Join(dir, "rpc.sock")
+	srv, err := StartServer(socket, nil, db, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("starting server: %v", err)
+	}
+	client, err := NewClient(socket, "")
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	defer client.Close()
+	events, err := client.Subscribe(context.Background())
+	if err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+	// Make sure the session is being served before closing
+	if _, err := client.Ping(); err != nil {
+		t.Fatalf("ping failed: %v", err)
+	}
+
+	if err := srv.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	// Connected clients are cut off rather than left hanging
+	if _, err := client.Ping(); err == nil {
+		t.Error("expected ping on a closed server to fail")
+	}
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("expected the event stream to close")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("event stream still open after Close")
+	}
+
+	// New clients fail straight away
+	start := time.Now()
+	if _, err := NewClient(socket, ""); err == nil {
+		t.Error("expected dial after Close to fail")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("dial after Close took %s", elapsed)
+	}
+}
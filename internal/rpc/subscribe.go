@@ -0,0 +1,119 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	netrpc "net/rpc"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/auth"
+	"lanmon/internal/store"
+)
+
+// subscribeHello opens a connection as an event stream rather than an RPC
+// session. A gob-encoded RPC request can't start with it, so both share
+// the socket.
+const subscribeHello = "SUBSCRIBE\n"
+
+// bufferedConn is a conn whose first bytes were already read into r.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// serveConn serves one connection, either as an RPC session or, if it
+// opens with subscribeHello, as an event stream. If a is set, the client
+// must pass the token handshake first, and its role picks the server from
+// servers; otherwise it has read-write access.
+func serveConn(servers map[auth.Role]*netrpc.Server, conn net.Conn, a auth.Authenticator, db *store.Store, log zerolog.Logger) {
+	r := bufio.NewReader(conn)
+	role := auth.RoleReadWrite
+	if a != nil {
+		var err error
+		if role, err = authenticate(conn, r, a); err != nil {
+			log.Warn().Err(err).Str("remote", conn.RemoteAddr().String()).Msg("RPC client failed authentication")
+			conn.Close()
+			return
+		}
+	}
+	if hello, err := r.Peek(len(subscribeHello)); err == nil && string(hello) == subscribeHello {
+		r.Discard(len(subscribeHello))
+		streamEvents(conn, r, db, log)
+		return
+	}
+	server, ok := servers[role]
+	if !ok {
+		conn.Close()
+		return
+	}
+	server.ServeConn(bufferedConn{Conn: conn, r: r})
+}
+
+// streamEvents writes store events to conn as newline-delimited JSON until
+// the client hangs up.
+func streamEvents(conn net.Conn, r io.Reader, db *store.Store, log zerolog.Logger) {
+	defer conn.Close()
+
+	events, unsubscribe := db.Subscribe()
+	defer unsubscribe()
+
+	// Clients send nothing more, so a read returning means they've gone
+	go func() {
+		io.Copy(io.Discard, r)
+		unsubscribe()
+	}()
+
+	log.Debug().Msg("Event subscriber connected")
+	enc := json.NewEncoder(conn)
+	for ev := range events {
+		if err := enc.Encode(ev); err != nil {
+			break
+		}
+	}
+	log.Debug().Msg("Event subscriber disconnected")
+}
+
+// Subscribe opens a stream of host events from the server. The channel is
+// closed when ctx is done or the server goes away.
+func (c *Client) Subscribe(ctx context.Context) (<-chan store.Event, error) {
+	conn, err := dial(ctx, c.network, c.address, c.token)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to RPC server %s: %w", c.address, err)
+	}
+	if _, err := io.WriteString(conn, subscribeHello); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("subscribing: %w", err)
+	}
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+
+	events := make(chan store.Event)
+	go func() {
+		defer close(events)
+		defer stop()
+		defer conn.Close()
+
+		dec := json.NewDecoder(conn)
+		for {
+			var ev store.Event
+			if err := dec.Decode(&ev); err != nil {
+				return
+			}
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
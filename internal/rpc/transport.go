@@ -0,0 +1,116 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"lanmon/internal/auth"
+)
+
+// tcpScheme prefixes RPC addresses served over TCP, e.g. "tcp://0.0.0.0:5679".
+const tcpScheme = "tcp://"
+
+// authTimeout bounds how long a TCP client may take to present its token.
+const authTimeout = 5 * time.Second
+
+// Token handshake. TCP clients open with authHello and the token on one
+// line, and the server answers authOK or closes the connection after
+// authDenied. Unix socket clients skip it: the socket's permissions
+// already decide who may connect.
+const (
+	authHello  = "AUTH "
+	authOK     = "OK\n"
+	authDenied = "DENIED\n"
+)
+
+// ErrUnauthorized is returned by NewClient when the server rejects the token.
+var ErrUnauthorized = errors.New("RPC token rejected")
+
+// ParseAddr splits an RPC address into the network and address to listen
+// on or dial. "tcp://host:port" selects TCP; anything else is a Unix
+// socket path, optionally written as "unix:///path".
+func ParseAddr(addr string) (network, address string) {
+	if rest, ok := strings.CutPrefix(addr, tcpScheme); ok {
+		return "tcp", rest
+	}
+	return "unix", strings.TrimPrefix(addr, "unix://")
+}
+
+// authenticate reads a client's token line from r, resolves it to a role
+// through a and answers it. It returns an error, after telling the
+// client, if a doesn't accept the token.
+func authenticate(conn net.Conn, r *bufio.Reader, a auth.Authenticator) (auth.Role, error) {
+	conn.SetReadDeadline(time.Now().Add(authTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return 0, fmt.Errorf("reading token: %w", err)
+	}
+	got, ok := strings.CutPrefix(strings.TrimSuffix(line, "\n"), authHello)
+	if !ok {
+		io.WriteString(conn, authDenied)
+		return 0, ErrUnauthorized
+	}
+	role, err := a.Authenticate(auth.Credentials{Token: got})
+	if err != nil {
+		io.WriteString(conn, authDenied)
+		return 0, fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+	if _, err := io.WriteString(conn, authOK); err != nil {
+		return 0, err
+	}
+	return role, nil
+}
+
+// dial connects to an RPC address, presenting token if it is a TCP one.
+func dial(ctx context.Context, network, address, token string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	if network != "tcp" {
+		return conn, nil
+	}
+	if err := sendToken(conn, token); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// sendToken performs the client side of the token handshake.
+func sendToken(conn net.Conn, token string) error {
+	if strings.Contains(token, "\n") {
+		return fmt.Errorf("RPC token must not contain a newline")
+	}
+	conn.SetDeadline(time.Now().Add(authTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	if _, err := io.WriteString(conn, authHello+token+"\n"); err != nil {
+		return fmt.Errorf("sending token: %w", err)
+	}
+	// Read byte by byte so nothing after the answer is consumed
+	var answer []byte
+	buf := make([]byte, 1)
+	for len(answer) < len(authDenied) {
+		if _, err := conn.Read(buf); err != nil {
+			return fmt.Errorf("reading token answer: %w", err)
+		}
+		answer = append(answer, buf[0])
+		if buf[0] == '\n' {
+			break
+		}
+	}
+	if string(answer) != authOK {
+		return ErrUnauthorized
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"lanmon/internal/auth"
+	"lanmon/internal/beacon"
+	"lanmon/internal/store"
+)
+
+func TestParseAddr(t *testing.T) {
+	tests := []struct {
+		addr, network, address string
+	}{
+		{"/run/lanmon/server.sock", "unix", "/run/lanmon/server.sock"},
+		{"unix:///run/lanmon/server.sock", "unix", "/run/lanmon/server.sock"},
+		{"tcp://0.0.0.0:5679", "tcp", "0.0.0.0:5679"},
+	}
+	for _, tt := range tests {
+		network, address := ParseAddr(tt.addr)
+		if network != tt.network || address != tt.address {
+			t.Errorf("ParseAddr(%q): got %s %s, want %s %s", tt.addr, network, address, tt.network, tt.address)
+		}
+	}
+}
+
+func TestTCPTransport_Token(t *testing.T) {
+	db, err := store.New(filepath.Join(t.TempDir(), "test.db"), zerolog.Nop())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer db.Close()
+	db.Upsert(beacon.BeaconPayload{MACAddress: "aa:bb:cc:dd:ee:01"})
+
+	if _, err := StartServer("tcp://127.0.0.1:0", nil, db, zerolog.Nop()); err == nil {
+		t.Fatal("expected TCP server without a token to be refused")
+	}
+	if _, err := StartServer("tcp://127.0.0.1:0", auth.Any{}, db, zerolog.Nop()); err == nil {
+		t.Fatal("expected TCP server with no authenticators to be refused")
+	}
+	srv, err := StartServer("tcp://127.0.0.1:0", auth.NewStaticToken("s3cret"), db, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("starting server: %v", err)
+	}
+	defer srv.Close()
+	addr := "tcp://" + srv.Addr().String()
+
+	for _, token := range []string{"", "wrong"} {
+		if _, err := NewClient(addr, token); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("token %q: expected ErrUnauthorized, got %v", token, err)
+		}
+	}
+
+	client, err := NewClient(addr, "s3cret")
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	defer client.Close()
+
+	hosts, err := client.ListActiveHosts()
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(hosts) != 1 {
+		t.Errorf("expected 1 host, got %d", len(hosts))
+	}
+
+	// Event streams authenticate the same way
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.Subscribe(ctx); err != nil {
+		t.Errorf("subscribe failed: %v", err)
+	}
+}
+
+func TestTCPTransport_Roles(t *testing.T) {
+	dir := t.TempDir()
+	db, err := store.New(filepath.Join(dir, "test.db"), zerolog.Nop())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer db.Close()
+	mac := "aa:bb:cc:dd:ee:01"
+	db.Upsert(beacon.BeaconPayload{MACAddress: mac})
+
+	tokensPath := filepath.Join(dir, "tokens")
+	if err := os.WriteFile(tokensPath, []byte("viewer read\nadmin write\n"), 0600); err != nil {
+		t.Fatalf("write tokens file: %v", err)
+	}
+	tokens, err := auth.LoadTokensFile(tokensPath)
+	if err != nil {
+		t.Fatalf("load tokens file: %v", err)
+	}
+	srv, err := StartServer("tcp://127.0.0.1:0", tokens, db, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("starting server: %v", err)
+	}
+	defer srv.Close()
+	addr := "tcp://" + srv.Addr().String()
+
+	viewer, err := NewClient(addr, "viewer")
+	if err != nil {
+		t.Fatalf("connecting as viewer: %v", err)
+	}
+	defer viewer.Close()
+
+	if _, err := viewer.ListActiveHosts(); err != nil {
+		t.Errorf("read-only list failed: %v", err)
+	}
+	if _, err := viewer.GetHistory(mac); err != nil {
+		t.Errorf("read-only history failed: %v", err)
+	}
+	writes := map[string]func() error{
+		"RemoveHost":     func() error { return viewer.RemoveHost(mac) },
+		"MarkKeyPushed":  func() error { return viewer.MarkKeyPushed(mac, "bob", "") },
+		"ClearKeyPushed": func() error { return viewer.ClearKeyPushed(mac) },
+		"SetLabel":       func() error { return viewer.SetLabel(mac, "env", "prod") },
+		"RemoveLabel":    func() error { return viewer.RemoveLabel(mac, "env") },
+		"SetNotes":       func() error { return viewer.SetNotes(mac, "pwned") },
+	}
+	for name, call := range writes {
+		if err := call(); err == nil || !strings.Contains(err.Error(), auth.ErrForbidden.Error()) {
+			t.Errorf("read-only %s: expected forbidden, got %v", name, err)
+		}
+	}
+	records, _ := db.GetAll()
+	if len(records) != 1 || records[0].SSHKeyPushed || records[0].Notes != "" || len(records[0].Labels) != 0 {
+		t.Errorf("read-only caller changed the host: %+v", records)
+	}
+
+	admin, err := NewClient(addr, "admin")
+	if err != nil {
+		t.Fatalf("connecting as admin: %v", err)
+	}
+	defer admin.Close()
+	if err := admin.SetNotes(mac, "rack 4"); err != nil {
+		t.Errorf("read-write SetNotes failed: %v", err)
+	}
+	if err := admin.RemoveHost(mac); err != nil {
+		t.Errorf("read-write RemoveHost failed: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package sshpush
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyPolicy decides how a host missing from known_hosts is treated.
+type HostKeyPolicy string
+
+const (
+	// HostKeyStrict rejects hosts that aren't in known_hosts.
+	HostKeyStrict HostKeyPolicy = "strict"
+	// HostKeyTOFU trusts a host's key the first time it is seen and records
+	// it in known_hosts (trust on first use).
+	HostKeyTOFU HostKeyPolicy = "tofu"
+	// HostKeyInsecure accepts any host key without checking known_hosts.
+	HostKeyInsecure HostKeyPolicy = "insecure"
+)
+
+// ValidateHostKeyPolicy checks a configured host key policy. Empty means
+// the default, HostKeyTOFU.
+func ValidateHostKeyPolicy(policy string) error {
+	switch HostKeyPolicy(policy) {
+	case "", HostKeyStrict, HostKeyTOFU, HostKeyInsecure:
+		return nil
+	}
+	return fmt.Errorf("unknown host key policy %q (want %q, %q or %q)", policy, HostKeyStrict, HostKeyTOFU, HostKeyInsecure)
+}
+
+// HostKeyChangedError is returned when a host offers a different key from
+// the one recorded in known_hosts, which is what a man-in-the-middle looks
+// like (or a reinstalled host).
+type HostKeyChangedError struct {
+	Host string
+	// Known lists the recorded keys as "fingerprint (file:line)".
+	Known []string
+	// Offered is the fingerprint of the key the host presented.
+	Offered string
+}
+
+func (e *HostKeyChangedError) Error() string {
+	return fmt.Sprintf("HOST KEY CHANGED for %s, possible man-in-the-middle attack: known_hosts has %s, host offered %s",
+		e.Host, strings.Join(e.Known, ", "), e.Offered)
+}
+
+func newHostKeyChangedError(hostname string, keyErr *knownhosts.KeyError, offered ssh.PublicKey) *HostKeyChangedError {
+	e := &HostKeyChangedError{Host: hostname, Offered: ssh.FingerprintSHA256(offered)}
+	for _, k := range keyErr.Want {
+		e.Known = append(e.Known, fmt.Sprintf("%s (%s:%d)", ssh.FingerprintSHA256(k.Key), k.Filename, k.Line))
+	}
+	return e
+}
+
+// Options holds the settings shared by every sshpush operation.
+type Options struct {
+	// KnownHostsPath is the known_hosts file host keys are checked against
+	// and recorded in. Empty disables host key checking.
+	KnownHostsPath string
+	// HostKeyPolicy decides what happens for hosts not in KnownHostsPath.
+	// Empty means HostKeyTOFU.
+	HostKeyPolicy HostKeyPolicy
+	// DialRetries is how many more times PushKey dials a host after a
+	// transient failure such as a timeout. Zero means a single attempt.
+	DialRetries int
+	// Log receives retry attempts. The zero Logger discards them.
+	Log zerolog.Logger
+	// Timeout bounds establishing each SSH connection. Zero means
+	// DefaultTimeout.
+	Timeout time.Duration
+	// Sudo makes PushKey edit authorized_keys through sudo -n, for
+	// accounts that can't write to the target home directory themselves.
+	Sudo bool
+	// AuthorizedKeysFile is where keys are pushed to and revoked from, in
+	// the form checked by ValidateAuthorizedKeysFile. Empty means
+	// DefaultAuthorizedKeysFile.
+	AuthorizedKeysFile string
+	// Identity, if set, is checked against the host before PushKey
+	// changes anything on it. Nil skips the check.
+	Identity *Identity
+}
+
+// DefaultTimeout is the connection timeout used when Options.Timeout is
+// not set.
+const DefaultTimeout = 10 * time.Second
+
+// timeout returns Timeout, or DefaultTimeout if it is not set.
+func (o Options) timeout() time.Duration {
+	if o.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return o.Timeout
+}
+
+// getHostKeyCallback returns an SSH host key callback checking keys against
+// the known_hosts file, which is created if it doesn't exist yet. Hosts
+// that aren't in it are handled according to the policy.
+func getHostKeyCallback(opts Options) (ssh.HostKeyCallback, error) {
+	if opts.KnownHostsPath == "" || opts.HostKeyPolicy == HostKeyInsecure {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	if _, err := os.Stat(opts.KnownHostsPath); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(opts.KnownHostsPath), 0700); err != nil {
+			return nil, fmt.Errorf("creating known_hosts directory: %w", err)
+		}
+		f, err := os.OpenFile(opts.KnownHostsPath, os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("creating known_hosts file: %w", err)
+		}
+		f.Close()
+	}
+
+	callback, err := knownhosts.New(opts.KnownHostsPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading known_hosts: %w", err)
+	}
+	return wrapKnownHostsCallback(callback, opts.KnownHostsPath, opts.HostKeyPolicy), nil
+}
+
+// wrapKnownHostsCallback wraps the strict knownhosts callback to apply the
+// policy to unknown hosts: HostKeyTOFU adds them to known_hosts,
+// HostKeyStrict rejects them. Hosts whose key changed are always rejected.
+func wrapKnownHostsCallback(callback ssh.HostKeyCallback, knownHostsPath string, policy HostKeyPolicy) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			return newHostKeyChangedError(hostname, keyErr, key)
+		}
+
+		// No existing key — this is a new host
+		if policy == HostKeyStrict {
+			host, port, splitErr := net.SplitHostPort(remote.String())
+			if splitErr != nil {
+				host, port = remote.String(), "22"
+			}
+			return fmt.Errorf("host %s is not in %s and host_key_policy is strict; "+
+				"check its key and add it with: ssh-keyscan -p %s %s >> %s",
+				hostname, knownHostsPath, port, host, knownHostsPath)
+		}
+
+		line := knownhosts.Line([]string{knownhosts.Normalize(remote.String())}, key)
+		f, err := os.OpenFile(knownHostsPath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+		if err != nil {
+			return fmt.Errorf("opening known_hosts for writing: %w", err)
+		}
+		defer f.Close()
+		_, err = fmt.Fprintln(f, line)
+		return err
+	}
+}
@@ -0,0 +1,133 @@
+package sshpush
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func testHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("converting public key: %v", err)
+	}
+	return key
+}
+
+func TestWrapKnownHostsCallback_Policies(t *testing.T) {
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 22}
+	key := testHostKey(t)
+
+	known := func(string, net.Addr, ssh.PublicKey) error { return nil }
+	unknown := func(string, net.Addr, ssh.PublicKey) error { return &knownhosts.KeyError{} }
+	changed := func(string, net.Addr, ssh.PublicKey) error {
+		return &knownhosts.KeyError{Want: []knownhosts.KnownKey{{Key: testHostKey(t)}}}
+	}
+
+	tests := []struct {
+		name     string
+		policy   HostKeyPolicy
+		callback ssh.HostKeyCallback
+		wantErr  bool
+		recorded bool
+	}{
+		{"strict known", HostKeyStrict, known, false, false},
+		{"strict unknown", HostKeyStrict, unknown, true, false},
+		{"strict changed", HostKeyStrict, changed, true, false},
+		{"tofu unknown", HostKeyTOFU, unknown, false, true},
+		{"default unknown", "", unknown, false, true},
+		{"tofu changed", HostKeyTOFU, changed, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "known_hosts")
+			err := wrapKnownHostsCallback(tt.callback, path, tt.policy)("10.0.0.5:22", remote, key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, tt.wantErr)
+			}
+
+			data, _ := os.ReadFile(path)
+			if recorded := strings.Contains(string(data), "10.0.0.5"); recorded != tt.recorded {
+				t.Errorf("key recorded: got %v, want %v", recorded, tt.recorded)
+			}
+		})
+	}
+}
+
+func TestWrapKnownHostsCallback_StrictHint(t *testing.T) {
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 2222}
+	unknown := func(string, net.Addr, ssh.PublicKey) error { return &knownhosts.KeyError{} }
+
+	err := wrapKnownHostsCallback(unknown, "/etc/lanmon/known_hosts", HostKeyStrict)("10.0.0.5:2222", remote, testHostKey(t))
+	if err == nil || !strings.Contains(err.Error(), "ssh-keyscan -p 2222 10.0.0.5 >> /etc/lanmon/known_hosts") {
+		t.Errorf("expected an ssh-keyscan hint, got %v", err)
+	}
+}
+
+func TestWrapKnownHostsCallback_KeyChanged(t *testing.T) {
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 22}
+	oldKey, newKey := testHostKey(t), testHostKey(t)
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(remote.String())}, oldKey)
+	if err := os.WriteFile(path, []byte(line+"\n"), 0600); err != nil {
+		t.Fatalf("writing known_hosts: %v", err)
+	}
+	callback, err := getHostKeyCallback(Options{KnownHostsPath: path})
+	if err != nil {
+		t.Fatalf("getHostKeyCallback: %v", err)
+	}
+
+	err = callback("10.0.0.5:22", remote, newKey)
+	var changed *HostKeyChangedError
+	if !errors.As(fmt.Errorf("ssh: handshake failed: %w", err), &changed) {
+		t.Fatalf("expected HostKeyChangedError, got %v", err)
+	}
+	if changed.Offered != ssh.FingerprintSHA256(newKey) {
+		t.Errorf("offered: got %s, want %s", changed.Offered, ssh.FingerprintSHA256(newKey))
+	}
+	if len(changed.Known) != 1 || !strings.HasPrefix(changed.Known[0], ssh.FingerprintSHA256(oldKey)+" ("+path+":1)") {
+		t.Errorf("known: got %q, want the old fingerprint at %s:1", changed.Known, path)
+	}
+}
+
+func TestGetHostKeyCallback_Insecure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	callback, err := getHostKeyCallback(Options{KnownHostsPath: path, HostKeyPolicy: HostKeyInsecure})
+	if err != nil {
+		t.Fatalf("getHostKeyCallback: %v", err)
+	}
+
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 22}
+	if err := callback("10.0.0.5:22", remote, testHostKey(t)); err != nil {
+		t.Errorf("expected any key to be accepted, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected known_hosts to be left alone, got %v", err)
+	}
+}
+
+func TestValidateHostKeyPolicy(t *testing.T) {
+	for _, policy := range []string{"", "strict", "tofu", "insecure"} {
+		if err := ValidateHostKeyPolicy(policy); err != nil {
+			t.Errorf("ValidateHostKeyPolicy(%q): %v", policy, err)
+		}
+	}
+	if err := ValidateHostKeyPolicy("trusting"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
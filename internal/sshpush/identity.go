@@ -0,0 +1,103 @@
+package sshpush
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Identity is what a host is expected to be, as seen in discovery. Empty
+// fields are not checked.
+type Identity struct {
+	Hostname string
+	MAC      string
+}
+
+// IdentityMismatchError is returned when the host reached at an address
+// isn't the one discovery saw there, such as another machine that has
+// since taken over the IP.
+type IdentityMismatchError struct {
+	Host string
+	Want Identity
+	// Hostname and MACs are what the host reported. MACs is empty if
+	// they couldn't be read.
+	Hostname string
+	MACs     []string
+}
+
+func (e *IdentityMismatchError) Error() string {
+	got := fmt.Sprintf("hostname %q", e.Hostname)
+	if len(e.MACs) > 0 {
+		got += " and MACs " + strings.Join(e.MACs, ", ")
+	}
+	return fmt.Sprintf("host at %s is not %s (%s): it reports %s", e.Host, e.Want.Hostname, e.Want.MAC, got)
+}
+
+// identityCommand prints the hostname, then the MAC addresses from sysfs
+// on Linux or ifconfig elsewhere.
+const identityCommand = `hostname; cat /sys/class/net/*/address 2>/dev/null || ifconfig -a 2>/dev/null`
+
+// checkIdentity compares the host on the other end of client with want.
+func checkIdentity(client *ssh.Client, host string, want Identity) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("creating SSH session: %w", err)
+	}
+	defer session.Close()
+
+	// ifconfig may be missing, failing the command; what hostname printed
+	// is still worth checking
+	output, _ := session.Output(identityCommand)
+	hostname, macs := parseIdentity(string(output))
+	if hostname == "" {
+		return fmt.Errorf("reading the identity of %s: no hostname reported", host)
+	}
+	if !matchIdentity(hostname, macs, want) {
+		return &IdentityMismatchError{Host: host, Want: want, Hostname: hostname, MACs: macs}
+	}
+	return nil
+}
+
+// parseIdentity splits identityCommand output into the hostname on its
+// first line and the MAC addresses found after it, lowercased and without
+// duplicates or the all-zero loopback address.
+func parseIdentity(output string) (hostname string, macs []string) {
+	first, rest, _ := strings.Cut(output, "\n")
+	seen := make(map[string]bool)
+	for _, field := range strings.Fields(rest) {
+		hw, err := net.ParseMAC(field)
+		if err != nil || len(hw) != 6 {
+			continue
+		}
+		mac := hw.String()
+		if mac != "00:00:00:00:00:00" && !seen[mac] {
+			seen[mac] = true
+			macs = append(macs, mac)
+		}
+	}
+	return strings.TrimSpace(first), macs
+}
+
+// matchIdentity reports whether a host reporting hostname and macs is
+// want. Hostnames match without their domain and ignoring case, so
+// "web-1" is "WEB-1.lan". The MAC only counts if some were read.
+func matchIdentity(hostname string, macs []string, want Identity) bool {
+	short := func(s string) string {
+		s, _, _ = strings.Cut(s, ".")
+		return strings.ToLower(s)
+	}
+	if want.Hostname != "" && short(hostname) != short(want.Hostname) {
+		return false
+	}
+	if want.MAC == "" || len(macs) == 0 {
+		return true
+	}
+	for _, mac := range macs {
+		if strings.EqualFold(mac, want.MAC) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package sshpush
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseIdentity(t *testing.T) {
+	linux := "web-1\n00:00:00:00:00:00\nAA:BB:CC:DD:EE:01\naa:bb:cc:dd:ee:01\n52:54:00:12:34:56\n"
+	hostname, macs := parseIdentity(linux)
+	if hostname != "web-1" {
+		t.Errorf("hostname: got %q", hostname)
+	}
+	if want := []string{"aa:bb:cc:dd:ee:01", "52:54:00:12:34:56"}; !reflect.DeepEqual(macs, want) {
+		t.Errorf("macs: got %v, want %v", macs, want)
+	}
+
+	// macOS has no sysfs; ifconfig's ether lines carry the MACs
+	darwin := "mac-mini.local\nen0: flags=8863<UP,BROADCAST> mtu 1500\n\tether a4:83:e7:01:02:03\n\tinet 10.0.0.7 netmask 0xffffff00\n"
+	hostname, macs = parseIdentity(darwin)
+	if hostname != "mac-mini.local" || !reflect.DeepEqual(macs, []string{"a4:83:e7:01:02:03"}) {
+		t.Errorf("darwin: got %q, %v", hostname, macs)
+	}
+}
+
+func TestMatchIdentity(t *testing.T) {
+	want := Identity{Hostname: "web-1", MAC: "AA:BB:CC:DD:EE:01"}
+	tests := []struct {
+		hostname string
+		macs     []string
+		ok       bool
+	}{
+		{"web-1", []string{"52:54:00:12:34:56", "aa:bb:cc:dd:ee:01"}, true},
+		{"WEB-1.lan", []string{"aa:bb:cc:dd:ee:01"}, true},
+		{"web-1", nil, true}, // MACs unreadable: the hostname has to do
+		{"web-2", []string{"aa:bb:cc:dd:ee:01"}, false},
+		{"web-1", []string{"aa:bb:cc:dd:ee:99"}, false},
+	}
+	for _, tt := range tests {
+		if got := matchIdentity(tt.hostname, tt.macs, want); got != tt.ok {
+			t.Errorf("matchIdentity(%q, %v): got %v, want %v", tt.hostname, tt.macs, got, tt.ok)
+		}
+	}
+}
+
+func TestIdentityMismatchError(t *testing.T) {
+	err := &IdentityMismatchError{
+		Host:     "10.0.0.5",
+		Want:     Identity{Hostname: "web-1", MAC: "aa:bb:cc:dd:ee:01"},
+		Hostname: "squatter",
+		MACs:     []string{"aa:bb:cc:dd:ee:99"},
+	}
+	for _, part := range []string{"10.0.0.5", "web-1", "squatter", "aa:bb:cc:dd:ee:99"} {
+		if !strings.Contains(err.Error(), part) {
+			t.Errorf("error %q does not mention %s", err, part)
+		}
+	}
+}
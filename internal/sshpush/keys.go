@@ -0,0 +1,77 @@
+package sshpush
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Supported key types for generated key pairs.
+const (
+	KeyTypeRSA     = "rsa"
+	KeyTypeEd25519 = "ed25519"
+)
+
+// ValidateKeyType checks a configured key type. Empty means "infer".
+func ValidateKeyType(keyType string) error {
+	switch keyType {
+	case "", KeyTypeRSA, KeyTypeEd25519:
+		return nil
+	}
+	return fmt.Errorf("unknown key type %q (want %q or %q)", keyType, KeyTypeRSA, KeyTypeEd25519)
+}
+
+// ResolveKeyType decides which key type pubKeyPath holds or should hold.
+// An explicitly configured type wins; otherwise an existing public key is
+// sniffed for its algorithm, and a missing one is guessed from its file name
+// (id_ed25519.pub), falling back to RSA.
+func ResolveKeyType(pubKeyPath, configured string) string {
+	if configured != "" {
+		return configured
+	}
+	if data, err := os.ReadFile(pubKeyPath); err == nil {
+		if strings.HasPrefix(strings.TrimSpace(string(data)), "ssh-ed25519 ") {
+			return KeyTypeEd25519
+		}
+		return KeyTypeRSA
+	}
+	if strings.Contains(filepath.Base(pubKeyPath), "ed25519") {
+		return KeyTypeEd25519
+	}
+	return KeyTypeRSA
+}
+
+// Fingerprint returns the SHA256 fingerprint of the public key at
+// pubKeyPath, as printed by ssh-keygen -l ("SHA256:...").
+func Fingerprint(pubKeyPath string) (string, error) {
+	data, err := os.ReadFile(pubKeyPath)
+	if err != nil {
+		return "", fmt.Errorf("reading public key %s: %w", pubKeyPath, err)
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return "", fmt.Errorf("parsing public key %s: %w", pubKeyPath, err)
+	}
+	return ssh.FingerprintSHA256(key), nil
+}
+
+// PrivateKeyPath derives the private key path from its public key path,
+// following ssh-keygen's "<name>.pub" convention.
+func PrivateKeyPath(pubKeyPath string) string {
+	return strings.TrimSuffix(pubKeyPath, ".pub")
+}
+
+// KeygenArgs returns the ssh-keygen arguments that create an unencrypted
+// key pair of the given type at privKeyPath (and privKeyPath + ".pub").
+func KeygenArgs(keyType, privKeyPath string) ([]string, error) {
+	switch keyType {
+	case KeyTypeRSA:
+		return []string{"-t", "rsa", "-b", "4096", "-f", privKeyPath, "-N", ""}, nil
+	case KeyTypeEd25519:
+		return []string{"-t", "ed25519", "-f", privKeyPath, "-N", ""}, nil
+	}
+	return nil, ValidateKeyType(keyType)
+}
@@ -0,0 +1,121 @@
+package sshpush
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultAuthorizedKeysFile is where keys are pushed when
+// Options.AuthorizedKeysFile is not set.
+const DefaultAuthorizedKeysFile = "~/.ssh/authorized_keys"
+
+// ValidateAuthorizedKeysFile checks a configured authorized_keys location.
+// Like sshd's AuthorizedKeysFile it may use %h for the user's home, %u for
+// their name and %% for a literal %, and a relative path is taken from the
+// home directory; ~/ is accepted for %h/. Empty means the default.
+func ValidateAuthorizedKeysFile(pattern string) error {
+	if strings.HasPrefix(pattern, "~") && !strings.HasPrefix(pattern, "~/") {
+		return fmt.Errorf("authorized keys file %q: only ~/ is supported, not ~user", pattern)
+	}
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' {
+			continue
+		}
+		if i+1 == len(pattern) || !strings.ContainsRune("hu%", rune(pattern[i+1])) {
+			return fmt.Errorf("authorized keys file %q: unknown token at %q (want %%h, %%u or %%%%)", pattern, pattern[i:])
+		}
+		i++
+	}
+	return nil
+}
+
+// normalizeKeysFile rewrites pattern so that files in the home directory
+// start with %h/: ~/ and relative paths included, and empty as the default.
+func normalizeKeysFile(pattern string) string {
+	if pattern == "" {
+		pattern = DefaultAuthorizedKeysFile
+	}
+	if rest, ok := strings.CutPrefix(pattern, "~/"); ok {
+		return "%h/" + rest
+	}
+	if !strings.HasPrefix(pattern, "/") && !strings.HasPrefix(pattern, "%h") {
+		return "%h/" + pattern
+	}
+	return pattern
+}
+
+// keysFileInHome reports whether pattern puts the file in the user's home
+// directory, where the user must own it and its directory, as opposed to
+// a central location such as /etc/ssh/authorized_keys/%u.
+func keysFileInHome(pattern string) bool {
+	return strings.HasPrefix(normalizeKeysFile(pattern), "%h/")
+}
+
+// expandKeysFile expands the tokens of pattern, putting home in for %h
+// as is and user in for %u through quote. user must be a plain login name
+// (see checkUser), so that %u is a single path component.
+func expandKeysFile(pattern, user, home string, quote func(string) string) (string, error) {
+	if err := checkUser(user); err != nil {
+		return "", err
+	}
+	pattern = normalizeKeysFile(pattern)
+
+	var b, literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			b.WriteString(quote(literal.String()))
+			literal.Reset()
+		}
+	}
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i+1 == len(pattern) {
+			literal.WriteByte(pattern[i])
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 'h':
+			flush()
+			b.WriteString(home)
+		case 'u':
+			literal.WriteString(user)
+		default:
+			literal.WriteByte(pattern[i])
+		}
+	}
+	flush()
+	return b.String(), nil
+}
+
+// findKeysFile returns the start of a remote shell command, setting $keys
+// to user's authorized_keys as located by pattern. If pattern involves the
+// home directory, it is looked up on the remote host and kept in $home:
+// ~user expands from its user database, so custom homes, /Users on macOS
+// and system accounts all work. For an unknown user ~user stays as is,
+// and the command prints NO_HOME and fails.
+func findKeysFile(user, pattern string) (string, error) {
+	keys, err := expandKeysFile(pattern, user, `"$home"`, shellQuote)
+	if err != nil {
+		return "", err
+	}
+	var cmd string
+	if strings.Contains(normalizeKeysFile(pattern), "%h") {
+		cmd = fmt.Sprintf(`home=~%s; case $home in '~'*) echo 'NO_HOME'; exit 1;; esac; `, user)
+	}
+	return cmd + "keys=" + keys + "; ", nil
+}
+
+// authorizedKeysPath names user's authorized_keys in messages. The real
+// path is only known on the remote host; see findKeysFile.
+func authorizedKeysPath(user, pattern string) (string, error) {
+	return expandKeysFile(pattern, user, "~"+user, func(s string) string { return s })
+}
+
+// errNoHome reports a user without a home directory on host, given the
+// output of a command started with findKeysFile.
+func errNoHome(output []byte, host, user string) error {
+	if strings.TrimSpace(string(output)) != "NO_HOME" {
+		return nil
+	}
+	return fmt.Errorf("user %s has no home directory on %s", user, host)
+}
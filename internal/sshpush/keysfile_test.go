@@ -0,0 +1,105 @@
+package sshpush
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateAuthorizedKeysFile(t *testing.T) {
+	for _, ok := range []string{"", "~/.ssh/authorized_keys2", ".ssh/keys", "%h/.ssh/keys", "/etc/ssh/authorized_keys/%u", "/srv/100%%/%u"} {
+		if err := ValidateAuthorizedKeysFile(ok); err != nil {
+			t.Errorf("%q: unexpected error %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"~bob/.ssh/authorized_keys", "/etc/ssh/%d/keys", "/etc/ssh/keys%"} {
+		if err := ValidateAuthorizedKeysFile(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestAuthorizedKeysPath(t *testing.T) {
+	tests := map[string]string{
+		"":                            "~bob/.ssh/authorized_keys",
+		"~/.ssh/authorized_keys2":     "~bob/.ssh/authorized_keys2",
+		".ssh/keys":                   "~bob/.ssh/keys",
+		"/etc/ssh/authorized_keys/%u": "/etc/ssh/authorized_keys/bob",
+		"/srv/100%%/%u":               "/srv/100%/bob",
+	}
+	for pattern, want := range tests {
+		if got, err := authorizedKeysPath("bob", pattern); err != nil || got != want {
+			t.Errorf("%q: got %s (%v), want %s", pattern, got, err, want)
+		}
+		if got := keysFileInHome(pattern); got != strings.HasPrefix(want, "~") {
+			t.Errorf("%q: keysFileInHome got %v", pattern, got)
+		}
+	}
+}
+
+func TestExpandKeysFile_UserIsOneComponent(t *testing.T) {
+	for _, bad := range []string{"../../etc/cron.d/x", "bob/../root", "a b", "$(id)", ""} {
+		for _, pattern := range []string{"/etc/ssh/authorized_keys/%u", ""} {
+			if _, err := expandKeysFile(pattern, bad, "/home/x", shellQuote); !errors.Is(err, ErrInvalidUser) {
+				t.Errorf("%q in %q: expected ErrInvalidUser, got %v", bad, pattern, err)
+			}
+			if path, err := authorizedKeysPath(bad, pattern); !errors.Is(err, ErrInvalidUser) {
+				t.Errorf("%q in %q: message path %q, expected ErrInvalidUser, got %v", bad, pattern, path, err)
+			}
+			if cmd, err := findKeysFile(bad, pattern); !errors.Is(err, ErrInvalidUser) || cmd != "" {
+				t.Errorf("%q in %q: expected ErrInvalidUser and no command, got %v:\n%s", bad, pattern, err, cmd)
+			}
+		}
+	}
+}
+
+func TestPushCommand_CentralKeysFile(t *testing.T) {
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "authorized_keys", "%u")
+
+	// No home lookup, so even users without one work
+	cmd, authKeysFile, _ := pushCommand("lanmon-no-such-user", Options{AuthorizedKeysFile: pattern})
+	if strings.Contains(cmd, "NO_HOME") || strings.Contains(cmd, "chown") {
+		t.Errorf("central keys file should need no home or chown:\n%s", cmd)
+	}
+	if want := filepath.Join(dir, "authorized_keys", "lanmon-no-such-user"); authKeysFile != want {
+		t.Errorf("authorized_keys: got %s, want %s", authKeysFile, want)
+	}
+
+	c := exec.Command("sh", "-c", cmd)
+	c.Stdin = strings.NewReader("ssh-ed25519 AAAA central\n")
+	if out, err := c.CombinedOutput(); err != nil || strings.TrimSpace(string(out)) != "KEY_ADDED" {
+		t.Fatalf("push command: %v: %s", err, out)
+	}
+	fi, err := os.Stat(authKeysFile)
+	if err != nil {
+		t.Fatalf("keys file not created: %v", err)
+	}
+	if fi.Mode().Perm()&0022 != 0 {
+		t.Errorf("keys file is writable by others: %v", fi.Mode().Perm())
+	}
+	if di, _ := os.Stat(filepath.Dir(authKeysFile)); di.Mode().Perm() == 0700 {
+		t.Error("central directory should keep its default mode")
+	}
+}
+
+func TestAppendKeyCommand_KeysInHome(t *testing.T) {
+	// A file straight in the home directory must leave the home's mode
+	home := t.TempDir()
+	if err := os.Chmod(home, 0755); err != nil {
+		t.Fatal(err)
+	}
+	keys := filepath.Join(home, "authorized_keys2")
+	if got := runAppendKey(t, home, keys, "ssh-ed25519 AAAA home"); got != "KEY_ADDED" {
+		t.Fatalf("push: got %q", got)
+	}
+	if fi, _ := os.Stat(home); fi.Mode().Perm() != 0755 {
+		t.Errorf("home mode changed to %v", fi.Mode().Perm())
+	}
+	if fi, _ := os.Stat(keys); fi.Mode().Perm() != 0600 {
+		t.Errorf("keys mode: got %v, want 0600", fi.Mode().Perm())
+	}
+}
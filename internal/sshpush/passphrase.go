@@ -0,0 +1,90 @@
+package sshpush
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// Decrypted signers are cached by path so that pushing to many hosts at
+// once asks for a key's passphrase only once.
+var (
+	signersMu sync.Mutex
+	signers   = make(map[string]ssh.Signer)
+)
+
+// loadSigner reads and parses a private key, asking for its passphrase if
+// it is encrypted.
+func loadSigner(privKeyPath string) (ssh.Signer, error) {
+	signersMu.Lock()
+	defer signersMu.Unlock()
+
+	if signer, ok := signers[privKeyPath]; ok {
+		return signer, nil
+	}
+
+	privKeyData, err := os.ReadFile(privKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading private key %s: %w", privKeyPath, err)
+	}
+
+	// Handles RSA, ECDSA and ed25519 keys alike
+	signer, err := ssh.ParsePrivateKey(privKeyData)
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		if err != nil {
+			return nil, fmt.Errorf("parsing private key: %w", err)
+		}
+		return signer, nil
+	}
+
+	passphrase, err := readPassphrase(privKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	signer, err = ssh.ParsePrivateKeyWithPassphrase(privKeyData, passphrase)
+
+	// Zero passphrase from memory
+	for i := range passphrase {
+		passphrase[i] = 0
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("decrypting private key %s: %w", privKeyPath, err)
+	}
+	signers[privKeyPath] = signer
+	return signer, nil
+}
+
+// readPassphrase prompts for a key's passphrase on the terminal. Without
+// one it defers to the program named by SSH_ASKPASS, as ssh itself does.
+func readPassphrase(privKeyPath string) ([]byte, error) {
+	prompt := fmt.Sprintf("Enter passphrase for %s: ", privKeyPath)
+
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, prompt)
+		passphrase, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, fmt.Errorf("reading passphrase: %w", err)
+		}
+		return passphrase, nil
+	}
+
+	askpass := os.Getenv("SSH_ASKPASS")
+	if askpass == "" {
+		return nil, fmt.Errorf("private key %s is passphrase-protected: run interactively or set SSH_ASKPASS", privKeyPath)
+	}
+
+	out, err := exec.Command(askpass, prompt).Output()
+	if err != nil {
+		return nil, fmt.Errorf("running SSH_ASKPASS %s: %w", askpass, err)
+	}
+	return bytes.TrimRight(out, "\r\n"), nil
+}
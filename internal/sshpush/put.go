@@ -0,0 +1,124 @@
+package sshpush
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// PutFile copies localPath to remotePath on the host over SSH, using the
+// scp protocol and public key authentication. The remote file gets the
+// local file's permission bits. Errors reported by the remote side, such
+// as an unwritable directory, are returned as they were reported.
+func PutFile(host string, port int, user, pubKeyPath, localPath, remotePath string, opts Options) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", localPath, err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", localPath, err)
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", localPath)
+	}
+
+	hostKeyCallback, err := getHostKeyCallback(opts)
+	if err != nil {
+		return fmt.Errorf("setting up host key verification: %w", err)
+	}
+
+	client, err := dialPubKey(fmt.Sprintf("%s:%d", host, port), user, pubKeyPath, hostKeyCallback, opts.timeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("creating SSH session: %w", err)
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("opening remote stdin: %w", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("opening remote stdout: %w", err)
+	}
+
+	if err := session.Start("scp -t " + shellQuote(remotePath)); err != nil {
+		return fmt.Errorf("starting remote scp: %w", err)
+	}
+
+	sendErr := scpSend(stdin, bufio.NewReader(stdout), path.Base(remotePath), fi.Mode().Perm(), fi.Size(), f)
+	stdin.Close()
+	waitErr := session.Wait()
+
+	if sendErr != nil {
+		return fmt.Errorf("copying to %s:%s: %w", host, remotePath, sendErr)
+	}
+	if waitErr != nil {
+		return fmt.Errorf("remote scp failed: %w", waitErr)
+	}
+	return nil
+}
+
+// scpSend plays the source side of the scp protocol for one file: a
+// "C<mode> <size> <name>" header, the contents, then a zero byte, with the
+// sink acknowledging each step.
+func scpSend(w io.Writer, r *bufio.Reader, name string, mode os.FileMode, size int64, content io.Reader) error {
+	if err := scpAck(r); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "C%04o %d %s\n", mode, size, name); err != nil {
+		return err
+	}
+	if err := scpAck(r); err != nil {
+		return err
+	}
+
+	if n, err := io.CopyN(w, content, size); err != nil {
+		return fmt.Errorf("sent %d of %d bytes: %w", n, size, err)
+	}
+	if _, err := w.Write([]byte{0}); err != nil {
+		return err
+	}
+	return scpAck(r)
+}
+
+// scpAck reads one scp response: a zero byte for success, or 1 (warning)
+// or 2 (fatal) followed by a message line.
+func scpAck(r *bufio.Reader) error {
+	code, err := r.ReadByte()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("remote scp exited unexpectedly (is scp installed on the host?)")
+		}
+		return err
+	}
+	if code == 0 {
+		return nil
+	}
+
+	msg, _ := r.ReadString('\n')
+	msg = strings.TrimSpace(msg)
+	if msg == "" {
+		msg = fmt.Sprintf("scp error code %d", code)
+	}
+	return errors.New(msg)
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
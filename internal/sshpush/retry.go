@@ -0,0 +1,53 @@
+package sshpush
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// firstRetryDelay is how long dialWithRetry waits before its first retry;
+// the wait doubles after each one.
+var firstRetryDelay = time.Second
+
+// dialWithRetry dials addr, trying again up to opts.DialRetries times with
+// exponential backoff when the failure looks transient. config, password
+// included, is reused as is, so callers never prompt twice.
+func dialWithRetry(addr string, config *ssh.ClientConfig, opts Options) (*ssh.Client, error) {
+	delay := firstRetryDelay
+	for attempt := 1; ; attempt++ {
+		client, err := ssh.Dial("tcp", addr, config)
+		if err == nil || attempt > opts.DialRetries || !retryable(err) {
+			return client, err
+		}
+
+		opts.Log.Warn().
+			Err(err).
+			Str("addr", addr).
+			Int("attempt", attempt).
+			Int("retries", opts.DialRetries).
+			Dur("delay", delay).
+			Msg("SSH dial failed, retrying")
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// retryable reports whether a dial error may go away on its own: timeouts
+// and refused, reset or unreachable connections. Anything else, notably
+// authentication and host key failures, would only fail again.
+func retryable(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	for _, errno := range []syscall.Errno{syscall.ECONNREFUSED, syscall.ECONNRESET, syscall.EHOSTUNREACH, syscall.ENETUNREACH} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
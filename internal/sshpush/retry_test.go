@@ -0,0 +1,88 @@
+package sshpush
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true},
+		{fmt.Errorf("wrapped: %w", &net.OpError{Op: "read", Err: syscall.ECONNRESET}), true},
+		{&net.OpError{Op: "dial", Err: syscall.EHOSTUNREACH}, true},
+		{&net.DNSError{Err: "timeout", IsTimeout: true}, true},
+		{errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none password]"), false},
+		{&HostKeyChangedError{Host: "web"}, false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("retryable(%v): got %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDialWithRetry_RetriesRefused(t *testing.T) {
+	old := firstRetryDelay
+	firstRetryDelay = time.Millisecond
+	defer func() { firstRetryDelay = old }()
+
+	// Nothing listens on a just-closed port, so every dial is refused
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	var buf bytes.Buffer
+	config := &ssh.ClientConfig{User: "alice", HostKeyCallback: ssh.InsecureIgnoreHostKey(), Timeout: time.Second}
+	_, err = dialWithRetry(addr, config, Options{DialRetries: 2, Log: zerolog.New(&buf)})
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("expected connection refused, got %v", err)
+	}
+	if got := strings.Count(buf.String(), "retrying"); got != 2 {
+		t.Errorf("expected 2 logged retries, got %d:\n%s", got, buf.String())
+	}
+}
+
+func TestDialWithRetry_FatalErrorNotRetried(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	var accepted atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			// Not an SSH server: the handshake fails outright
+			conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+			conn.Close()
+		}
+	}()
+
+	config := &ssh.ClientConfig{User: "alice", HostKeyCallback: ssh.InsecureIgnoreHostKey(), Timeout: time.Second}
+	if _, err := dialWithRetry(ln.Addr().String(), config, Options{DialRetries: 3}); err == nil {
+		t.Fatal("expected dial to fail")
+	}
+	if got := accepted.Load(); got != 1 {
+		t.Errorf("expected a single attempt, got %d connections", got)
+	}
+}
@@ -0,0 +1,110 @@
+package sshpush
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// RevokeKey removes the public key at pubKeyPath from the user's
+// authorized_keys on the host, logging in with that same key. It reports
+// whether the key was there; revoking an absent key is not an error.
+func RevokeKey(host string, port int, user, pubKeyPath string, opts Options) (bool, error) {
+	if err := checkUser(user); err != nil {
+		return false, err
+	}
+	hostKeyCallback, err := getHostKeyCallback(opts)
+	if err != nil {
+		return false, fmt.Errorf("setting up host key verification: %w", err)
+	}
+
+	client, err := dialPubKey(fmt.Sprintf("%s:%d", host, port), user, pubKeyPath, hostKeyCallback, opts.timeout())
+	if err != nil {
+		return false, err
+	}
+	defer client.Close()
+
+	return revokeKey(client, user, pubKeyPath, opts.AuthorizedKeysFile)
+}
+
+// RevokeKeyPassword is RevokeKey for hosts where the key no longer works,
+// logging in with a password instead.
+func RevokeKeyPassword(host string, port int, user, password, pubKeyPath string, opts Options) (bool, error) {
+	if err := checkUser(user); err != nil {
+		return false, err
+	}
+	hostKeyCallback, err := getHostKeyCallback(opts)
+	if err != nil {
+		return false, fmt.Errorf("setting up host key verification: %w", err)
+	}
+
+	addr := fmt.Sprintf("%s:%d", host, port)
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.Password(password)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         opts.timeout(),
+	})
+	if err != nil {
+		return false, fmt.Errorf("SSH dial to %s: %w", addr, err)
+	}
+	defer client.Close()
+
+	return revokeKey(client, user, pubKeyPath, opts.AuthorizedKeysFile)
+}
+
+func revokeKey(client *ssh.Client, user, pubKeyPath, keysFile string) (bool, error) {
+	pubKeyData, err := os.ReadFile(pubKeyPath)
+	if err != nil {
+		return false, fmt.Errorf("reading public key %s: %w", pubKeyPath, err)
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		return false, fmt.Errorf("creating SSH session: %w", err)
+	}
+	defer session.Close()
+
+	cmd, err := findKeysFile(user, keysFile)
+	if err != nil {
+		return false, err
+	}
+	cmd += revokeCommand(strings.TrimSpace(string(pubKeyData)), `"$keys"`)
+	output, err := session.CombinedOutput(cmd)
+	if err := errNoHome(output, client.RemoteAddr().String(), user); err != nil {
+		return false, err
+	}
+	if err != nil {
+		return false, fmt.Errorf("remote command failed: %w\nOutput: %s", err, string(output))
+	}
+
+	switch result := strings.TrimSpace(string(output)); result {
+	case "KEY_REMOVED":
+		return true, nil
+	case "KEY_ABSENT":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected output from remote command: %s", result)
+	}
+}
+
+// revokeCommand builds the remote shell command that drops lines exactly
+// matching pubKey from authKeysFile, a shell word such as "$keys". It
+// rewrites the file in place, rather than replacing it, so its mode and
+// owner are kept. The file is only rewritten once the filtered copy is
+// complete: if mktemp or grep fails, it is left as it was and the command
+// exits non-zero.
+func revokeCommand(pubKey, authKeysFile string) string {
+	key, file := shellQuote(pubKey), authKeysFile
+	return fmt.Sprintf(
+		`if [ -f %[2]s ] && grep -qxF %[1]s %[2]s; then `+
+			`tmp=$(mktemp) && [ -n "$tmp" ] || exit 1; `+
+			`grep -vxF %[1]s %[2]s > "$tmp"; `+
+			`if [ $? -le 1 ] && cat "$tmp" > %[2]s; then rm -f "$tmp"; echo 'KEY_REMOVED'; `+
+			`else rm -f "$tmp"; exit 1; fi; `+
+			`else echo 'KEY_ABSENT'; fi`,
+		key, file,
+	)
+}
@@ -2,20 +2,45 @@
 package sshpush
 
 import (
+	"errors"
 	"fmt"
-	"net"
 	"os"
+	"regexp"
 	"strings"
 	"time"
 
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/crypto/ssh/knownhosts"
 )
 
+// ErrSudoPassword is returned when Options.Sudo is set but sudo on the
+// host wants a password, which PushKey cannot give it.
+var ErrSudoPassword = errors.New("sudo requires a password")
+
+// ErrInvalidUser is returned for a username that isn't a plain login name.
+// The name goes into remote shell commands, in ~user among other places,
+// where quoting it would stop the expansion, so anything else is refused.
+var ErrInvalidUser = errors.New("invalid username")
+
+// loginName matches POSIX login names, with the trailing $ of Samba
+// machine accounts.
+var loginName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*\$?$`)
+
+// checkUser returns ErrInvalidUser unless user is a plain login name.
+func checkUser(user string) error {
+	if !loginName.MatchString(user) {
+		return fmt.Errorf("%w %q: want a login name of letters, digits, _ . and -", ErrInvalidUser, user)
+	}
+	return nil
+}
+
 // PushKey connects to the target host via SSH with password authentication,
 // appends the server's public key to the target user's authorized_keys,
 // and verifies passwordless authentication works.
-func PushKey(host string, port int, user, password, pubKeyPath, knownHostsPath string) error {
+func PushKey(host string, port int, user, password, pubKeyPath string, opts Options) error {
+	if err := checkUser(user); err != nil {
+		return err
+	}
+
 	// Read the local public key
 	pubKeyData, err := os.ReadFile(pubKeyPath)
 	if err != nil {
@@ -24,7 +49,7 @@ func PushKey(host string, port int, user, password, pubKeyPath, knownHostsPath s
 	pubKey := strings.TrimSpace(string(pubKeyData))
 
 	// Setup host key callback
-	hostKeyCallback, err := getHostKeyCallback(knownHostsPath)
+	hostKeyCallback, err := getHostKeyCallback(opts)
 	if err != nil {
 		return fmt.Errorf("setting up host key verification: %w", err)
 	}
@@ -37,42 +62,42 @@ func PushKey(host string, port int, user, password, pubKeyPath, knownHostsPath s
 			ssh.Password(password),
 		},
 		HostKeyCallback: hostKeyCallback,
-		Timeout:         10 * time.Second,
+		Timeout:         opts.timeout(),
 	}
 
-	client, err := ssh.Dial("tcp", addr, config)
+	client, err := dialWithRetry(addr, config, opts)
 	if err != nil {
 		return fmt.Errorf("SSH dial to %s: %w", addr, err)
 	}
 	defer client.Close()
 
-	// Build the remote command to inject the key
-	homeDir := fmt.Sprintf("/home/%s", user)
-	if user == "root" {
-		homeDir = "/root"
+	if opts.Identity != nil {
+		if err := checkIdentity(client, host, *opts.Identity); err != nil {
+			return err
+		}
 	}
 
-	sshDir := fmt.Sprintf("%s/.ssh", homeDir)
-	authKeysFile := fmt.Sprintf("%s/authorized_keys", sshDir)
-
-	// Check for duplicate key before appending
-	cmd := fmt.Sprintf(
-		`mkdir -p %s && chmod 700 %s && `+
-			`(grep -qF '%s' %s 2>/dev/null && echo 'KEY_EXISTS' || `+
-			`(echo '%s' >> %s && chmod 600 %s && chown -R %s:%s %s && echo 'KEY_ADDED'))`,
-		sshDir, sshDir,
-		pubKey, authKeysFile,
-		pubKey, authKeysFile, authKeysFile,
-		user, user, sshDir,
-	)
+	cmd, authKeysFile, err := pushCommand(user, opts)
+	if err != nil {
+		return err
+	}
 
 	session, err := client.NewSession()
 	if err != nil {
 		return fmt.Errorf("creating SSH session: %w", err)
 	}
 	defer session.Close()
+	// The key goes over stdin so nothing in it, such as quotes in its
+	// comment, is ever parsed by the remote shell
+	session.Stdin = strings.NewReader(pubKey + "\n")
 
 	output, err := session.CombinedOutput(cmd)
+	if err := errNoHome(output, host, user); err != nil {
+		return err
+	}
+	if opts.Sudo && strings.Contains(string(output), "sudo: a password is required") {
+		return fmt.Errorf("%w on %s: give %s passwordless sudo (NOPASSWD in sudoers) or push without sudo", ErrSudoPassword, host, user)
+	}
 	if err != nil {
 		return fmt.Errorf("remote command failed: %w\nOutput: %s", err, string(output))
 	}
@@ -86,41 +111,61 @@ func PushKey(host string, port int, user, password, pubKeyPath, knownHostsPath s
 	}
 
 	// Verify passwordless auth works
-	if err := verifyPubKeyAuth(addr, user, pubKeyPath, hostKeyCallback); err != nil {
+	if err := verifyPubKeyAuth(addr, user, pubKeyPath, hostKeyCallback, opts.timeout()); err != nil {
 		return fmt.Errorf("verification failed — key was pushed but pubkey auth did not work: %w", err)
 	}
 
 	return nil
 }
 
-// verifyPubKeyAuth attempts to connect using public key authentication
-// and runs 'echo OK' to verify the setup works.
-func verifyPubKeyAuth(addr, user, pubKeyPath string, hostKeyCallback ssh.HostKeyCallback) error {
-	// Derive private key path from public key path
-	privKeyPath := strings.TrimSuffix(pubKeyPath, ".pub")
-
-	privKeyData, err := os.ReadFile(privKeyPath)
-	if err != nil {
-		return fmt.Errorf("reading private key %s: %w", privKeyPath, err)
+// pushCommand builds the remote shell command that appends the public key
+// on its stdin to the user's authorized_keys unless it is already there,
+// run through sudo -n if opts.Sudo is set. It also returns the
+// authorized_keys path, for error messages. A user that isn't a plain
+// login name is refused with ErrInvalidUser.
+func pushCommand(user string, opts Options) (cmd, authKeysFile string, err error) {
+	if cmd, err = findKeysFile(user, opts.AuthorizedKeysFile); err != nil {
+		return "", "", err
 	}
-
-	signer, err := ssh.ParsePrivateKey(privKeyData)
-	if err != nil {
-		return fmt.Errorf("parsing private key: %w", err)
+	cmd += appendKeyCommand(user, keysFileInHome(opts.AuthorizedKeysFile))
+	if opts.Sudo {
+		cmd = "sudo -n sh -c " + shellQuote(cmd)
 	}
-
-	config := &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: hostKeyCallback,
-		Timeout:         10 * time.Second,
+	if authKeysFile, err = authorizedKeysPath(user, opts.AuthorizedKeysFile); err != nil {
+		return "", "", err
 	}
+	return cmd, authKeysFile, nil
+}
 
-	client, err := ssh.Dial("tcp", addr, config)
+// appendKeyCommand is the part of pushCommand after findKeysFile: it reads
+// the key from stdin and appends it to $keys unless it is already there.
+//
+// In the home directory, sshd wants the file and its directory to belong
+// to the user and not be writable by others, so they are made 600 and 700
+// and handed to "user:", the user and their login group (which need not
+// be named after them). The home directory itself is left alone. Files
+// elsewhere, say under /etc/ssh, keep their owner and only lose group and
+// other write access.
+func appendKeyCommand(user string, inHome bool) string {
+	prepare, finish := `mkdir -p "$dir"`, `chmod go-w "$keys"`
+	if inHome {
+		prepare = `mkdir -p "$dir" && { [ "$dir" = "$home" ] || chmod 700 "$dir"; }`
+		finish = fmt.Sprintf(`chmod 600 "$keys" && chown %[1]s: "$keys" && { [ "$dir" = "$home" ] || chown %[1]s: "$dir"; }`, user)
+	}
+	return fmt.Sprintf(
+		`key=$(cat) && dir=$(dirname "$keys") && %s && `+
+			`(grep -qF -- "$key" "$keys" 2>/dev/null && echo 'KEY_EXISTS' || `+
+			`(printf '%%s\n' "$key" | tee -a "$keys" >/dev/null && %s && echo 'KEY_ADDED'))`,
+		prepare, finish,
+	)
+}
+
+// verifyPubKeyAuth attempts to connect using public key authentication
+// and runs 'echo OK' to verify the setup works.
+func verifyPubKeyAuth(addr, user, pubKeyPath string, hostKeyCallback ssh.HostKeyCallback, timeout time.Duration) error {
+	client, err := dialPubKey(addr, user, pubKeyPath, hostKeyCallback, timeout)
 	if err != nil {
-		return fmt.Errorf("pubkey auth dial: %w", err)
+		return err
 	}
 	defer client.Close()
 
@@ -142,87 +187,53 @@ func verifyPubKeyAuth(addr, user, pubKeyPath string, hostKeyCallback ssh.HostKey
 	return nil
 }
 
-// getHostKeyCallback returns an SSH host key callback.
-// If the known_hosts file exists, it uses strict checking.
-// Otherwise, it uses an accept-all callback (with a warning).
-func getHostKeyCallback(knownHostsPath string) (ssh.HostKeyCallback, error) {
-	if knownHostsPath == "" {
-		return ssh.InsecureIgnoreHostKey(), nil
+// RunCommand runs cmd on the host over SSH, authenticating with the
+// private key belonging to pubKeyPath, and returns its combined output.
+// A command exiting non-zero returns its output along with the error.
+func RunCommand(host string, port int, user, pubKeyPath, cmd string, opts Options) (string, error) {
+	hostKeyCallback, err := getHostKeyCallback(opts)
+	if err != nil {
+		return "", fmt.Errorf("setting up host key verification: %w", err)
 	}
 
-	// If the file doesn't exist, create it and use a TOFU (Trust On First Use) callback
-	if _, err := os.Stat(knownHostsPath); os.IsNotExist(err) {
-		// Create the known_hosts file
-		if err := os.MkdirAll(strings.TrimSuffix(knownHostsPath, "/known_hosts"), 0700); err != nil {
-			return nil, fmt.Errorf("creating known_hosts directory: %w", err)
-		}
-		f, err := os.Create(knownHostsPath)
-		if err != nil {
-			return nil, fmt.Errorf("creating known_hosts file: %w", err)
-		}
-		f.Close()
-
-		// Return a TOFU callback that records the key
-		return tofuCallback(knownHostsPath), nil
+	client, err := dialPubKey(fmt.Sprintf("%s:%d", host, port), user, pubKeyPath, hostKeyCallback, opts.timeout())
+	if err != nil {
+		return "", err
 	}
+	defer client.Close()
 
-	callback, err := knownhosts.New(knownHostsPath)
+	session, err := client.NewSession()
 	if err != nil {
-		return nil, fmt.Errorf("loading known_hosts: %w", err)
+		return "", fmt.Errorf("creating SSH session: %w", err)
 	}
-	return wrapKnownHostsCallback(callback, knownHostsPath), nil
-}
+	defer session.Close()
 
-// tofuCallback returns a callback that accepts any host key and saves it
-// to the known_hosts file (Trust On First Use).
-func tofuCallback(knownHostsPath string) ssh.HostKeyCallback {
-	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
-		line := knownhosts.Line([]string{knownhosts.Normalize(remote.String())}, key)
-		f, err := os.OpenFile(knownHostsPath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
-		if err != nil {
-			return fmt.Errorf("opening known_hosts for writing: %w", err)
-		}
-		defer f.Close()
-		_, err = fmt.Fprintln(f, line)
-		return err
+	output, err := session.CombinedOutput(cmd)
+	if err != nil {
+		return string(output), fmt.Errorf("remote command failed: %w", err)
 	}
+	return string(output), nil
 }
 
-// wrapKnownHostsCallback wraps the strict knownhosts callback to handle
-// unknown hosts by adding them (TOFU for new hosts).
-func wrapKnownHostsCallback(callback ssh.HostKeyCallback, knownHostsPath string) ssh.HostKeyCallback {
-	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
-		err := callback(hostname, remote, key)
-		if err == nil {
-			return nil
-		}
-
-		// If the error is a key-not-found error, add the key (TOFU)
-		var keyErr *knownhosts.KeyError
-		if isKeyError(err, &keyErr) && len(keyErr.Want) == 0 {
-			// No existing key — this is a new host, add it
-			line := knownhosts.Line([]string{knownhosts.Normalize(remote.String())}, key)
-			f, ferr := os.OpenFile(knownHostsPath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
-			if ferr != nil {
-				return fmt.Errorf("opening known_hosts for writing: %w", ferr)
-			}
-			defer f.Close()
-			if _, ferr = fmt.Fprintln(f, line); ferr != nil {
-				return ferr
-			}
-			return nil
-		}
+// dialPubKey connects to addr with public key authentication.
+func dialPubKey(addr, user, pubKeyPath string, hostKeyCallback ssh.HostKeyCallback, timeout time.Duration) (*ssh.Client, error) {
+	signer, err := loadSigner(PrivateKeyPath(pubKeyPath))
+	if err != nil {
+		return nil, err
+	}
 
-		// Key mismatch — this is a potential MITM warning
-		return err
+	config := &ssh.ClientConfig{
+		User: user,
+		Auth: []ssh.AuthMethod{
+			ssh.PublicKeys(signer),
+		},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         timeout,
 	}
-}
 
-// isKeyError checks if the error is a knownhosts.KeyError.
-func isKeyError(err error, target **knownhosts.KeyError) bool {
-	if ke, ok := err.(*knownhosts.KeyError); ok {
-		*target = ke
-		return true
+	client, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, fmt.Errorf("pubkey auth dial: %w", err)
 	}
-	return false
+	return client, nil
 }
@@ -0,0 +1,408 @@
+package sshpush
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// writeEd25519KeyPair writes an OpenSSH-format ed25519 key pair to dir and
+// returns the public key path.
+func writeEd25519KeyPair(t *testing.T, dir string) string {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(priv, "test")
+	if err != nil {
+		t.Fatalf("marshaling private key: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("converting public key: %v", err)
+	}
+
+	privPath := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(privPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("writing private key: %v", err)
+	}
+	if err := os.WriteFile(privPath+".pub", ssh.MarshalAuthorizedKey(sshPub), 0644); err != nil {
+		t.Fatalf("writing public key: %v", err)
+	}
+	return privPath + ".pub"
+}
+
+func TestEd25519KeyPair_PushCommand(t *testing.T) {
+	pubKeyPath := writeEd25519KeyPair(t, t.TempDir())
+
+	if got := ResolveKeyType(pubKeyPath, ""); got != KeyTypeEd25519 {
+		t.Errorf("ResolveKeyType: got %q, want %q", got, KeyTypeEd25519)
+	}
+
+	privData, err := os.ReadFile(PrivateKeyPath(pubKeyPath))
+	if err != nil {
+		t.Fatalf("reading derived private key path: %v", err)
+	}
+	signer, err := ssh.ParsePrivateKey(privData)
+	if err != nil {
+		t.Fatalf("parsing ed25519 private key: %v", err)
+	}
+	if signer.PublicKey().Type() != ssh.KeyAlgoED25519 {
+		t.Errorf("signer type: got %s, want %s", signer.PublicKey().Type(), ssh.KeyAlgoED25519)
+	}
+
+	pubData, _ := os.ReadFile(pubKeyPath)
+	pubKey := strings.TrimSpace(string(pubData))
+
+	_, authKeysFile, _ := pushCommand("alice", Options{})
+	if authKeysFile != "~alice/.ssh/authorized_keys" {
+		t.Errorf("authorized_keys: got %s", authKeysFile)
+	}
+
+	home := t.TempDir()
+	keys := filepath.Join(home, ".ssh", "authorized_keys")
+	if got := runAppendKey(t, home, keys, pubKey); got != "KEY_ADDED" {
+		t.Errorf("first push: got %q, want KEY_ADDED", got)
+	}
+	if got := runAppendKey(t, home, keys, pubKey); got != "KEY_EXISTS" {
+		t.Errorf("second push: got %q, want KEY_EXISTS", got)
+	}
+	if data, _ := os.ReadFile(keys); string(data) != pubKey+"\n" {
+		t.Errorf("authorized_keys: got %q, want the ed25519 key once", data)
+	}
+}
+
+// runAppendKey runs the key-appending half of the push command locally,
+// for authorized_keys at keys in the home directory home, and returns its
+// output.
+func runAppendKey(t *testing.T, home, keys, pubKey string) string {
+	t.Helper()
+	me, err := user.Current()
+	if err != nil {
+		t.Skipf("no current user: %v", err)
+	}
+	cmd := exec.Command("sh", "-c", "home="+shellQuote(home)+"; keys="+shellQuote(keys)+"; "+appendKeyCommand(me.Username, true))
+	cmd.Stdin = strings.NewReader(pubKey + "\n")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("push command failed: %v\n%s", err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestPushCommand_KeyCommentIsNotShell(t *testing.T) {
+	dir := t.TempDir()
+	keys := filepath.Join(dir, ".ssh", "authorized_keys")
+	pwned := filepath.Join(dir, "pwned")
+	pubKey := `ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIExample it's "bob's" key'; touch ` + pwned + `; echo '$(id) \n *`
+
+	cmd, _, _ := pushCommand("bob", Options{})
+	if strings.Contains(cmd, "AAAAC3") {
+		t.Errorf("the key must not be part of the command:\n%s", cmd)
+	}
+
+	if got := runAppendKey(t, dir, keys, pubKey); got != "KEY_ADDED" {
+		t.Fatalf("push: got %q, want KEY_ADDED", got)
+	}
+	if data, _ := os.ReadFile(keys); string(data) != pubKey+"\n" {
+		t.Errorf("authorized_keys: got %q, want the key verbatim", data)
+	}
+	if _, err := os.Stat(pwned); err == nil {
+		t.Error("the key comment was run as a command")
+	}
+	if got := runAppendKey(t, dir, keys, pubKey); got != "KEY_EXISTS" {
+		t.Errorf("second push: got %q, want KEY_EXISTS", got)
+	}
+}
+
+func TestPushCommand_Sudo(t *testing.T) {
+	plain, _, _ := pushCommand("bob", Options{})
+	if strings.Contains(plain, "sudo") {
+		t.Errorf("unexpected sudo without Sudo:\n%s", plain)
+	}
+	if !strings.Contains(plain, `chown bob: "$keys"`) {
+		t.Errorf("expected ownership handed to bob and their login group:\n%s", plain)
+	}
+
+	sudo, _, _ := pushCommand("bob", Options{Sudo: true})
+	if want := "sudo -n sh -c " + shellQuote(plain); sudo != want {
+		t.Errorf("sudo command:\ngot  %s\nwant %s", sudo, want)
+	}
+
+	// The wrapped command must survive a real shell with its quotes intact
+	out, err := exec.Command("sh", "-c", `printf '%s\n' `+shellQuote(plain)).Output()
+	if err != nil {
+		t.Fatalf("sh: %v", err)
+	}
+	if strings.TrimSpace(string(out)) != plain {
+		t.Errorf("quoting changed the command:\n%s", out)
+	}
+}
+
+func TestPushCommand_HomeOutsideHome(t *testing.T) {
+	// The current user's home comes from the user database, wherever it
+	// is: /root when tests run as root, /Users/... on macOS
+	me, err := user.Current()
+	if err != nil {
+		t.Skipf("no current user: %v", err)
+	}
+	cmd, _, _ := pushCommand(me.Username, Options{})
+	if strings.Contains(cmd, "/home/") {
+		t.Errorf("push command assumes /home:\n%s", cmd)
+	}
+
+	find, err := findKeysFile(me.Username, "")
+	if err != nil {
+		t.Fatalf("findKeysFile: %v", err)
+	}
+	out, err := exec.Command("sh", "-c", find+`echo "$keys"`).CombinedOutput()
+	if err != nil {
+		t.Fatalf("resolving home: %v\n%s", err, out)
+	}
+	if got, want := strings.TrimSpace(string(out)), filepath.Join(me.HomeDir, ".ssh", "authorized_keys"); got != want {
+		t.Errorf("authorized_keys: got %s, want %s", got, want)
+	}
+
+	// An unknown user stops the command before anything is created
+	cmd, _, _ = pushCommand("lanmon-no-such-user", Options{})
+	out, err = exec.Command("sh", "-c", cmd).CombinedOutput()
+	if err == nil || errNoHome(out, "host", "lanmon-no-such-user") == nil {
+		t.Errorf("expected NO_HOME for an unknown user, got %v: %s", err, out)
+	}
+}
+
+func TestPushCommand_InjectedUserRefused(t *testing.T) {
+	for _, ok := range []string{"bob", "_svc", "j.doe", "web-01", "HOST$"} {
+		if _, _, err := pushCommand(ok, Options{}); err != nil {
+			t.Errorf("%q: unexpected error %v", ok, err)
+		}
+	}
+
+	pwned := filepath.Join(t.TempDir(), "pwned")
+	for _, bad := range []string{"", "root;touch " + pwned, "bob$(id)", "bob`id`", "bob|sh", "bob bob", "../root", "-oProxy", "bob\n"} {
+		cmd, _, err := pushCommand(bad, Options{Sudo: true})
+		if !errors.Is(err, ErrInvalidUser) || cmd != "" {
+			t.Errorf("%q: expected ErrInvalidUser and no command, got %v:\n%s", bad, err, cmd)
+		}
+		// Refused before connecting or reading the key
+		if err := PushKey("192.0.2.1", 22, bad, "pw", "/nonexistent.pub", Options{}); !errors.Is(err, ErrInvalidUser) {
+			t.Errorf("%q: PushKey: expected ErrInvalidUser, got %v", bad, err)
+		}
+		if _, err := RevokeKey("192.0.2.1", 22, bad, "/nonexistent.pub", Options{}); !errors.Is(err, ErrInvalidUser) {
+			t.Errorf("%q: RevokeKey: expected ErrInvalidUser, got %v", bad, err)
+		}
+		if _, err := RevokeKeyPassword("192.0.2.1", 22, bad, "pw", "/nonexistent.pub", Options{}); !errors.Is(err, ErrInvalidUser) {
+			t.Errorf("%q: RevokeKeyPassword: expected ErrInvalidUser, got %v", bad, err)
+		}
+	}
+}
+
+func TestResolveKeyType(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name       string
+		path       string
+		configured string
+		want       string
+	}{
+		{"configured wins", filepath.Join(dir, "id_rsa.pub"), KeyTypeEd25519, KeyTypeEd25519},
+		{"missing ed25519 file name", filepath.Join(dir, "id_ed25519.pub"), "", KeyTypeEd25519},
+		{"missing other file name", filepath.Join(dir, "lanmon.pub"), "", KeyTypeRSA},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveKeyType(tt.path, tt.configured); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeygenArgs(t *testing.T) {
+	args, err := KeygenArgs(KeyTypeEd25519, "/tmp/id_ed25519")
+	if err != nil {
+		t.Fatalf("KeygenArgs: %v", err)
+	}
+	if !slices.Equal(args[:2], []string{"-t", "ed25519"}) || slices.Contains(args, "-b") {
+		t.Errorf("unexpected ed25519 args: %v", args)
+	}
+
+	if _, err := KeygenArgs("dsa", "/tmp/id_dsa"); err == nil {
+		t.Error("expected error for unsupported key type")
+	}
+}
+
+func TestLoadSigner_Passphrase(t *testing.T) {
+	dir := t.TempDir()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(priv, "test", []byte("hunter2"))
+	if err != nil {
+		t.Fatalf("marshaling private key: %v", err)
+	}
+	// Decrypted keys are cached by path, so each case gets its own copy
+	privPath := filepath.Join(dir, "id_ed25519")
+	otherPath := filepath.Join(dir, "id_ed25519_copy")
+	for _, p := range []string{privPath, otherPath} {
+		if err := os.WriteFile(p, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatalf("writing private key: %v", err)
+		}
+	}
+
+	// Tests don't run on a terminal, so the passphrase comes from SSH_ASKPASS
+	askpass := filepath.Join(dir, "askpass")
+	if err := os.WriteFile(askpass, []byte("#!/bin/sh\necho hunter2\n"), 0700); err != nil {
+		t.Fatalf("writing askpass: %v", err)
+	}
+
+	t.Setenv("SSH_ASKPASS", askpass)
+	if _, err := loadSigner(privPath); err != nil {
+		t.Errorf("expected SSH_ASKPASS passphrase to decrypt key, got %v", err)
+	}
+
+	t.Setenv("SSH_ASKPASS", "")
+	if _, err := loadSigner(otherPath); err == nil || !strings.Contains(err.Error(), "SSH_ASKPASS") {
+		t.Errorf("expected a clear error without a passphrase source, got %v", err)
+	}
+}
+
+// fakeSCPSink plays the remote side of scp -t, accepting one file unless
+// reject is set, in which case it refuses the header with that message.
+func fakeSCPSink(t *testing.T, in io.Reader, out io.Writer, reject string) <-chan string {
+	t.Helper()
+	got := make(chan string, 1)
+	go func() {
+		defer close(got)
+		r := bufio.NewReader(in)
+		out.Write([]byte{0})
+
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if reject != "" {
+			fmt.Fprintf(out, "\x01%s\n", reject)
+			return
+		}
+		out.Write([]byte{0})
+
+		var mode, name string
+		var size int
+		fmt.Sscanf(header, "C%s %d %s", &mode, &size, &name)
+		data := make([]byte, size+1)
+		io.ReadFull(r, data)
+		out.Write([]byte{0})
+		got <- fmt.Sprintf("%s %s %s", mode, name, data[:size])
+	}()
+	return got
+}
+
+func TestSCPSend(t *testing.T) {
+	toSink, fromSource := io.Pipe()
+	toSource, fromSink := io.Pipe()
+	got := fakeSCPSink(t, toSink, fromSink, "")
+
+	content := "key = value\n"
+	err := scpSend(fromSource, bufio.NewReader(toSource), "app.conf", 0640, int64(len(content)), strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("scpSend: %v", err)
+	}
+	if want := "0640 app.conf " + content; <-got != want {
+		t.Errorf("sink received unexpected file, want %q", want)
+	}
+}
+
+func TestSCPSend_RemoteError(t *testing.T) {
+	toSink, fromSource := io.Pipe()
+	toSource, fromSink := io.Pipe()
+	fakeSCPSink(t, toSink, fromSink, "scp: /etc/app/app.conf: Permission denied")
+
+	err := scpSend(fromSource, bufio.NewReader(toSource), "app.conf", 0644, 3, strings.NewReader("abc"))
+	if err == nil || !strings.Contains(err.Error(), "Permission denied") {
+		t.Errorf("expected the remote error, got %v", err)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	if got := shellQuote("/tmp/it's here"); got != `'/tmp/it'\''s here'` {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestRevokeCommand(t *testing.T) {
+	dir := t.TempDir()
+	authKeys := filepath.Join(dir, "authorized_keys")
+	target := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIBBB lanmon@server"
+	others := "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAB alice@laptop\n" +
+		"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAICCC bob@desk\n"
+	os.WriteFile(authKeys, []byte("ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAB alice@laptop\n"+target+"\nssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAICCC bob@desk\n"), 0600)
+
+	run := func() string {
+		out, err := exec.Command("sh", "-c", revokeCommand(target, shellQuote(authKeys))).CombinedOutput()
+		if err != nil {
+			t.Fatalf("revoke command failed: %v\n%s", err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	if got := run(); got != "KEY_REMOVED" {
+		t.Fatalf("first run: got %q, want KEY_REMOVED", got)
+	}
+	data, _ := os.ReadFile(authKeys)
+	if string(data) != others {
+		t.Errorf("authorized_keys after revoke:\n%s\nwant:\n%s", data, others)
+	}
+	if fi, _ := os.Stat(authKeys); fi.Mode().Perm() != 0600 {
+		t.Errorf("mode changed to %v", fi.Mode().Perm())
+	}
+
+	if got := run(); got != "KEY_ABSENT" {
+		t.Errorf("second run: got %q, want KEY_ABSENT", got)
+	}
+
+	os.Remove(authKeys)
+	if got := run(); got != "KEY_ABSENT" {
+		t.Errorf("missing file: got %q, want KEY_ABSENT", got)
+	}
+}
+
+func TestRevokeCommand_MktempFails(t *testing.T) {
+	dir := t.TempDir()
+	authKeys := filepath.Join(dir, "authorized_keys")
+	target := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIBBB lanmon@server"
+	content := "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAB alice@laptop\n" + target + "\n"
+	os.WriteFile(authKeys, []byte(content), 0600)
+
+	// A full or read-only /tmp: mktemp fails and prints nothing
+	bin := filepath.Join(dir, "bin")
+	os.Mkdir(bin, 0755)
+	if err := os.WriteFile(filepath.Join(bin, "mktemp"), []byte("#!/bin/sh\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("sh", "-c", revokeCommand(target, shellQuote(authKeys)))
+	cmd.Env = append(os.Environ(), "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	if out, err := cmd.CombinedOutput(); err == nil || strings.Contains(string(out), "KEY_REMOVED") {
+		t.Errorf("expected the revoke to fail, got %v: %s", err, out)
+	}
+	if data, _ := os.ReadFile(authKeys); string(data) != content {
+		t.Errorf("authorized_keys changed:\n%q\nwant:\n%q", data, content)
+	}
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"net"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"lanmon/internal/beacon"
+)
+
+// maxUpsertBatch caps how many beacons share one transaction, so a burst
+// can't hold the write lock for long.
+const maxUpsertBatch = 256
+
+// upsert is a beacon waiting for writeUpserts, which sends the outcome on
+// done.
+type upsert struct {
+	payload beacon.BeaconPayload
+	src     net.IP
+	now     time.Time
+	done    chan error
+
+	// held is what applyUpsert took from the debounce, to put back if the
+	// transaction fails.
+	held *heldBeacons
+}
+
+// writeUpserts commits beacons until the store is closed. It takes one
+// and then every other already waiting, and writes them in a single
+// transaction: beacons trickling in are committed as soon as they arrive,
+// while a fleet-wide burst costs one commit (and fsync) per batch rather
+// than per beacon. Readers see each batch all at once, and writes outside
+// the batch, like MarkKeyPushed, commit before or after it, never inside.
+func (s *Store) writeUpserts() {
+	defer close(s.writerDone)
+	for {
+		var batch []*upsert
+		select {
+		case req := <-s.upserts:
+			batch = append(batch, req)
+		case <-s.quit:
+			return
+		}
+	drain:
+		for len(batch) < maxUpsertBatch {
+			select {
+			case req := <-s.upserts:
+				batch = append(batch, req)
+			default:
+				break drain
+			}
+		}
+		s.commitUpserts(batch)
+	}
+}
+
+// commitUpserts writes batch in one transaction, then publishes its events
+// in order and reports to each waiting UpsertFrom. If the transaction
+// fails, every beacon in it fails and the held beacons it took are held
+// again.
+func (s *Store) commitUpserts(batch []*upsert) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	records := make([]HostRecord, len(batch))
+	events := make([]EventType, len(batch))
+	err := s.update(func(tx *bolt.Tx) error {
+		for i, req := range batch {
+			var err error
+			if records[i], events[i], err = s.applyUpsert(tx, req); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	for i, req := range batch {
+		if err == nil {
+			s.noteWritten(req.payload, req.now)
+			s.beacons.Add(1)
+			s.publish(events[i], records[i])
+		} else if req.held != nil {
+			s.restoreHeld(req.payload.MACAddress, req.held)
+		}
+		req.done <- err
+	}
+}
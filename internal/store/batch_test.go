@@ -0,0 +1,97 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUpsert_ConcurrentBeaconsAllCommitted(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	// 50 hosts beaconing at once, then once more each
+	var wg sync.WaitGroup
+	for round := range 2 {
+		for i := range 50 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				mac := fmt.Sprintf("aa:bb:cc:dd:ee:%02x", i)
+				if err := s.Upsert(samplePayload(mac, "host", "10.0.0.1")); err != nil {
+					t.Errorf("round %d: upsert %s: %v", round, mac, err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	records, err := s.GetAll()
+	if err != nil {
+		t.Fatalf("getall failed: %v", err)
+	}
+	if len(records) != 50 {
+		t.Fatalf("expected 50 hosts, got %d", len(records))
+	}
+	for _, r := range records {
+		if r.PacketCount != 2 {
+			t.Errorf("%s: packet count %d, want 2", r.Beacon.MACAddress, r.PacketCount)
+		}
+	}
+}
+
+func TestUpsert_AfterClose(t *testing.T) {
+	s, err := New(filepath.Join(t.TempDir(), "test.db"), testLogger())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	s.Close()
+
+	if err := s.Upsert(samplePayload("aa:bb:cc:dd:ee:ff", "host1", "10.0.0.1")); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	// A second Close doesn't panic
+	s.Close()
+}
+
+// Many hosts beaconing at once. PerPacket commits each beacon in its own
+// transaction, as every upsert did before batching; Batched goes through
+// the writer, which commits whatever has queued up in one transaction.
+func benchmarkConcurrentUpsert(b *testing.B, upsertFn func(s *Store, mac string) error) {
+	s, err := New(filepath.Join(b.TempDir(), "bench.db"), testLogger())
+	if err != nil {
+		b.Fatalf("failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	var next atomic.Uint64
+	b.SetParallelism(16)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			mac := fmt.Sprintf("aa:bb:cc:dd:ee:%02x", next.Add(1)%200)
+			if err := upsertFn(s, mac); err != nil {
+				b.Errorf("upsert failed: %v", err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkConcurrentUpsert_PerPacket(b *testing.B) {
+	benchmarkConcurrentUpsert(b, func(s *Store, mac string) error {
+		req := &upsert{payload: samplePayload(mac, "host", "10.0.0.1"), now: time.Now(), done: make(chan error, 1)}
+		s.commitUpserts([]*upsert{req})
+		return <-req.done
+	})
+}
+
+func BenchmarkConcurrentUpsert_Batched(b *testing.B) {
+	benchmarkConcurrentUpsert(b, func(s *Store, mac string) error {
+		return s.Upsert(samplePayload(mac, "host", "10.0.0.1"))
+	})
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// checkConflicts flags active hosts that report the same IP address as
+// another active host, typically two machines handed the same lease by a
+// misconfigured DHCP server, and clears the flag once that stops.
+// Inactive hosts never conflict: their address is only what they last
+// reported. Each new conflict is logged once.
+func (s *Store) checkConflicts() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	var changed []HostRecord
+	err := s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(hostsBucket)
+
+		var keys [][]byte
+		var records []HostRecord
+		byIP := make(map[string][]int)
+		err := b.ForEach(func(k, v []byte) error {
+			var record HostRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return nil
+			}
+			if record.Active && record.Beacon.IPAddress != "" {
+				byIP[record.Beacon.IPAddress] = append(byIP[record.Beacon.IPAddress], len(records))
+			}
+			keys = append(keys, append([]byte(nil), k...))
+			records = append(records, record)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		conflict := make([]bool, len(records))
+		for ip, idx := range byIP {
+			if len(idx) < 2 {
+				continue
+			}
+			var hostnames, macs []string
+			isNew := false
+			for _, i := range idx {
+				conflict[i] = true
+				isNew = isNew || !records[i].Conflict
+				hostnames = append(hostnames, records[i].Beacon.Hostname)
+				macs = append(macs, records[i].Beacon.MACAddress)
+			}
+			if isNew {
+				s.log.Warn().
+					Str("ip", ip).
+					Strs("hostnames", hostnames).
+					Strs("macs", macs).
+					Msg("Several hosts claim the same IP address")
+			}
+		}
+
+		for i, record := range records {
+			if record.Conflict == conflict[i] {
+				continue
+			}
+			record.Conflict = conflict[i]
+			data, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("marshaling host record: %w", err)
+			}
+			if err := b.Put(keys[i], data); err != nil {
+				return err
+			}
+			changed = append(changed, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("checking IP conflicts: %w", err)
+	}
+
+	for _, record := range changed {
+		s.publish(EventUpdated, record)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"testing"
+)
+
+func conflicts(t *testing.T, s *Store) map[string]bool {
+	t.Helper()
+	records, err := s.GetAll()
+	if err != nil {
+		t.Fatalf("getall failed: %v", err)
+	}
+	got := make(map[string]bool)
+	for _, r := range records {
+		got[r.Beacon.MACAddress] = r.Conflict
+	}
+	return got
+}
+
+func TestStore_CheckConflicts(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	s.Upsert(samplePayload("aa:bb:cc:dd:ee:01", "web1", "192.168.1.10"))
+	s.Upsert(samplePayload("aa:bb:cc:dd:ee:02", "web2", "192.168.1.10"))
+	s.Upsert(samplePayload("aa:bb:cc:dd:ee:03", "db", "192.168.1.11"))
+
+	if err := s.checkConflicts(); err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	got := conflicts(t, s)
+	if !got["aa:bb:cc:dd:ee:01"] || !got["aa:bb:cc:dd:ee:02"] || got["aa:bb:cc:dd:ee:03"] {
+		t.Errorf("unexpected conflicts: %v", got)
+	}
+
+	// The flag survives the next beacon
+	s.Upsert(samplePayload("aa:bb:cc:dd:ee:01", "web1", "192.168.1.10"))
+	if !conflicts(t, s)["aa:bb:cc:dd:ee:01"] {
+		t.Error("expected Conflict to survive an upsert")
+	}
+
+	// A host going inactive no longer conflicts, nor does the other
+	if err := s.MarkInactive("aa:bb:cc:dd:ee:02"); err != nil {
+		t.Fatalf("mark inactive failed: %v", err)
+	}
+	if err := s.checkConflicts(); err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	for mac, conflict := range conflicts(t, s) {
+		if conflict {
+			t.Errorf("%s: expected conflict to be cleared", mac)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"lanmon/internal/beacon"
+)
+
+// heldBeacons are the beacons from one host that the write debounce hasn't
+// written yet: the latest payload and source, and when each arrived.
+type heldBeacons struct {
+	payload beacon.BeaconPayload
+	src     net.IP
+	times   []time.Time
+}
+
+// lastWrite is what the last written beacon from a host said, to decide
+// whether the next one can be held.
+type lastWrite struct {
+	at       time.Time
+	ip       string
+	hostname string
+}
+
+// SetFlushInterval debounces beacon writes: once a host's beacon has been
+// written, further beacons from it within d are held in memory, and only
+// written, as one update of LastSeen, PacketCount, the payload and the
+// history, with the host's next beacon after d or by Flush. Zero (the
+// default) writes every beacon.
+//
+// A host's first beacon, its first after being marked inactive and any
+// beacon changing its IP address or hostname are written at once, and
+// expiry flushes before checking LastSeen, so hosts still become active
+// and inactive on time as long as d is shorter than the stale threshold.
+// Held beacons publish no event until they are flushed, are held again if
+// their write fails, and are lost if the process dies first; Close flushes
+// them.
+func (s *Store) SetFlushInterval(d time.Duration) {
+	s.debounceMu.Lock()
+	defer s.debounceMu.Unlock()
+	s.flushInterval = d
+}
+
+// hold keeps a beacon received at now for a later write if the debounce
+// allows, and reports whether it did.
+func (s *Store) hold(payload beacon.BeaconPayload, src net.IP, now time.Time) bool {
+	s.debounceMu.Lock()
+	defer s.debounceMu.Unlock()
+
+	last, ok := s.written[payload.MACAddress]
+	if s.flushInterval <= 0 || !ok || now.Sub(last.at) >= s.flushInterval ||
+		last.ip != payload.IPAddress || last.hostname != payload.Hostname {
+		return false
+	}
+
+	held := s.held[payload.MACAddress]
+	if held == nil {
+		if s.held == nil {
+			s.held = make(map[string]*heldBeacons)
+		}
+		held = &heldBeacons{}
+		s.held[payload.MACAddress] = held
+	}
+	held.payload = payload
+	if src != nil {
+		held.src = src
+	}
+	held.times = append(held.times, now)
+	return true
+}
+
+// noteWritten records that a beacon from payload's host was written at now.
+func (s *Store) noteWritten(payload beacon.BeaconPayload, now time.Time) {
+	s.debounceMu.Lock()
+	defer s.debounceMu.Unlock()
+
+	if s.written == nil {
+		s.written = make(map[string]lastWrite)
+	}
+	s.written[payload.MACAddress] = lastWrite{at: now, ip: payload.IPAddress, hostname: payload.Hostname}
+}
+
+// takeHeld removes and returns the beacons held for mac, nil if none.
+func (s *Store) takeHeld(mac string) *heldBeacons {
+	s.debounceMu.Lock()
+	defer s.debounceMu.Unlock()
+
+	held := s.held[mac]
+	delete(s.held, mac)
+	return held
+}
+
+// restoreHeld puts back beacons taken by takeHeld whose write failed, ahead
+// of any held for mac since.
+func (s *Store) restoreHeld(mac string, held *heldBeacons) {
+	s.debounceMu.Lock()
+	defer s.debounceMu.Unlock()
+
+	if s.held == nil {
+		s.held = make(map[string]*heldBeacons)
+	}
+	if newer := s.held[mac]; newer != nil {
+		newer.times = append(held.times, newer.times...)
+		if newer.src == nil {
+			newer.src = held.src
+		}
+		return
+	}
+	s.held[mac] = held
+}
+
+// forget drops the debounce state of a host marked inactive or deleted,
+// so that its next beacon is written at once.
+func (s *Store) forget(mac string) {
+	s.debounceMu.Lock()
+	defer s.debounceMu.Unlock()
+
+	delete(s.held, mac)
+	delete(s.written, mac)
+}
+
+// applyHeld merges held beacons into record within tx, as applyBeacon and
+// appendHistory would have one at a time. The caller writes record.
+func applyHeld(tx *bolt.Tx, record *HostRecord, held *heldBeacons) error {
+	record.Beacon = held.payload
+	if held.src != nil {
+		record.ObservedIP = held.src.String()
+	}
+	record.LastSeen = held.times[len(held.times)-1]
+	record.PacketCount += uint64(len(held.times))
+	return appendHistory(tx, []byte(held.payload.MACAddress), held.times...)
+}
+
+// Flush writes the beacons held back by the write debounce (see
+// SetFlushInterval) and publishes an update for each host written.
+func (s *Store) Flush() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.flushLocked()
+}
+
+// flushLocked is Flush for callers holding writeMu.
+func (s *Store) flushLocked() error {
+	s.debounceMu.Lock()
+	held := s.held
+	s.held = nil
+	s.debounceMu.Unlock()
+	if len(held) == 0 {
+		return nil
+	}
+
+	var flushed []HostRecord
+	err := s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(hostsBucket)
+		for mac, h := range held {
+			key := []byte(mac)
+			existing := b.Get(key)
+			if existing == nil {
+				// Removed since its beacons were held
+				continue
+			}
+
+			var record HostRecord
+			if err := json.Unmarshal(existing, &record); err != nil {
+				continue
+			}
+			if err := applyHeld(tx, &record, h); err != nil {
+				return fmt.Errorf("recording history: %w", err)
+			}
+			data, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("marshaling host record: %w", err)
+			}
+			if err := b.Put(key, data); err != nil {
+				return err
+			}
+			flushed = append(flushed, record)
+		}
+		return nil
+	})
+	if err != nil {
+		for mac, h := range held {
+			s.restoreHeld(mac, h)
+		}
+		return fmt.Errorf("flushing held beacons: %w", err)
+	}
+
+	for _, record := range flushed {
+		s.publish(EventUpdated, record)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestFlushInterval_HoldsRepeatBeacons(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+	s.SetFlushInterval(time.Hour)
+
+	mac := "aa:bb:cc:dd:ee:ff"
+	for range 5 {
+		if err := s.Upsert(samplePayload(mac, "host1", "192.168.1.10")); err != nil {
+			t.Fatalf("upsert failed: %v", err)
+		}
+	}
+
+	records, _ := s.GetAll()
+	if records[0].PacketCount != 1 {
+		t.Errorf("packet count before flush: got %d, want 1 (the rest held)", records[0].PacketCount)
+	}
+	firstSeen := records[0].LastSeen
+
+	if err := s.Flush(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	records, _ = s.GetAll()
+	if records[0].PacketCount != 5 {
+		t.Errorf("packet count after flush: got %d, want 5", records[0].PacketCount)
+	}
+	if !records[0].LastSeen.After(firstSeen) {
+		t.Error("expected the flush to advance LastSeen")
+	}
+	if history, _ := s.GetHistory(mac); len(history) != 5 {
+		t.Errorf("history after flush: got %d entries, want 5", len(history))
+	}
+}
+
+func TestFlushInterval_CountsSurviveReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	s.SetFlushInterval(time.Hour)
+
+	mac := "aa:bb:cc:dd:ee:ff"
+	for range 3 {
+		s.Upsert(samplePayload(mac, "host1", "192.168.1.10"))
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	for range 2 {
+		s.Upsert(samplePayload(mac, "host1", "192.168.1.10"))
+	}
+	// Close flushes the last two
+	if err := s.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	s, err = New(path, testLogger())
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer s.Close()
+	records, _ := s.GetAll()
+	if len(records) != 1 || records[0].PacketCount != 5 {
+		t.Fatalf("after reload: got %+v, want one host with 5 packets", records)
+	}
+}
+
+func TestFlushInterval_TransitionsWrittenAtOnce(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+	s.SetFlushInterval(time.Hour)
+
+	mac := "aa:bb:cc:dd:ee:ff"
+	s.Upsert(samplePayload(mac, "host1", "192.168.1.10"))
+	s.Upsert(samplePayload(mac, "host1", "192.168.1.10"))
+
+	// An address change isn't held
+	s.Upsert(samplePayload(mac, "host1", "192.168.1.20"))
+	records, _ := s.GetAll()
+	if records[0].Beacon.IPAddress != "192.168.1.20" || records[0].PacketCount != 3 {
+		t.Errorf("after IP change: got %s with %d packets, want 192.168.1.20 with 3",
+			records[0].Beacon.IPAddress, records[0].PacketCount)
+	}
+
+	// A departure keeps the held beacons and the return is written at once
+	s.Upsert(samplePayload(mac, "host1", "192.168.1.20"))
+	if err := s.MarkInactive(mac); err != nil {
+		t.Fatalf("mark inactive failed: %v", err)
+	}
+	s.Upsert(samplePayload(mac, "host1", "192.168.1.20"))
+	records, _ = s.GetAll()
+	if !records[0].Active || records[0].PacketCount != 5 {
+		t.Errorf("after return: active %v with %d packets, want active with 5", records[0].Active, records[0].PacketCount)
+	}
+
+	// Expiry sees the held beacons' LastSeen
+	s.Upsert(samplePayload(mac, "host1", "192.168.1.20"))
+	time.Sleep(20 * time.Millisecond)
+	s.Upsert(samplePayload(mac, "host1", "192.168.1.20"))
+	s.expireStaleHosts(10 * time.Millisecond)
+	records, _ = s.GetAll()
+	if !records[0].Active || records[0].PacketCount != 7 {
+		t.Errorf("after expiry check: active %v with %d packets, want active with 7", records[0].Active, records[0].PacketCount)
+	}
+}
+
+func TestFlushInterval_FailedWriteKeepsHeld(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+	s.SetFlushInterval(time.Hour)
+
+	mac := "aa:bb:cc:dd:ee:ff"
+	for range 3 {
+		s.Upsert(samplePayload(mac, "host1", "192.168.1.10"))
+	}
+
+	// Make every write transaction fail
+	path := s.db.Path()
+	if err := s.db.Close(); err != nil {
+		t.Fatalf("closing the database: %v", err)
+	}
+	heldTimes := func() int {
+		s.debounceMu.Lock()
+		defer s.debounceMu.Unlock()
+		if h := s.held[mac]; h != nil {
+			return len(h.times)
+		}
+		return 0
+	}
+	if err := s.Flush(); err == nil {
+		t.Error("expected Flush to fail")
+	}
+	if n := heldTimes(); n != 2 {
+		t.Errorf("held after failed Flush: got %d, want 2", n)
+	}
+	// An address change takes the held beacons into its own write
+	if err := s.Upsert(samplePayload(mac, "host1", "192.168.1.20")); err == nil {
+		t.Error("expected Upsert to fail")
+	}
+	if n := heldTimes(); n != 2 {
+		t.Errorf("held after failed Upsert: got %d, want 2", n)
+	}
+	if err := s.MarkInactive(mac); err == nil {
+		t.Error("expected MarkInactive to fail")
+	}
+	if n := heldTimes(); n != 2 {
+		t.Errorf("held after failed MarkInactive: got %d, want 2", n)
+	}
+
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("reopening the database: %v", err)
+	}
+	s.db = db
+	if err := s.Upsert(samplePayload(mac, "host1", "192.168.1.20")); err != nil {
+		t.Fatalf("upsert after reopening: %v", err)
+	}
+	records, _ := s.GetAll()
+	if records[0].PacketCount != 4 {
+		t.Errorf("packet count: got %d, want 4", records[0].PacketCount)
+	}
+	if history, _ := s.GetHistory(mac); len(history) != 4 {
+		t.Errorf("history: got %d entries, want 4", len(history))
+	}
+}
+
+// 100 hosts beaconing in turn. With a flush interval, each host's beacons
+// after the first are held, so far fewer bolt pages are written per
+// beacon; compare the page-writes/beacon metric.
+func benchmarkUpsert(b *testing.B, flushInterval time.Duration) {
+	s, err := New(filepath.Join(b.TempDir(), "bench.db"), testLogger())
+	if err != nil {
+		b.Fatalf("failed to create store: %v", err)
+	}
+	defer s.Close()
+	s.SetFlushInterval(flushInterval)
+
+	macs := make([]string, 100)
+	for i := range macs {
+		macs[i] = fmt.Sprintf("aa:bb:cc:dd:ee:%02x", i)
+	}
+	before := s.db.Stats()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mac := macs[i%len(macs)]
+		if err := s.Upsert(samplePayload(mac, "host-"+mac, "10.0.0.1")); err != nil {
+			b.Fatalf("upsert failed: %v", err)
+		}
+	}
+	// Held beacons count once written
+	if err := s.Flush(); err != nil {
+		b.Fatalf("flush failed: %v", err)
+	}
+	b.StopTimer()
+
+	after := s.db.Stats()
+	writes := after.TxStats.GetWrite() - before.TxStats.GetWrite()
+	b.ReportMetric(float64(writes)/float64(b.N), "page-writes/beacon")
+}
+
+func BenchmarkUpsert_EveryBeacon(b *testing.B) {
+	benchmarkUpsert(b, 0)
+}
+
+func BenchmarkUpsert_Debounced(b *testing.B) {
+	benchmarkUpsert(b, time.Minute)
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType says what happened to a host.
+type EventType string
+
+const (
+	// EventDiscovered is a host's first beacon.
+	EventDiscovered EventType = "discovered"
+	// EventUpdated is a beacon from a known host.
+	EventUpdated EventType = "updated"
+	// EventStale is a host marked inactive after going quiet.
+	EventStale EventType = "stale"
+	// EventDeparted is a host marked inactive by its departure beacon.
+	EventDeparted EventType = "departed"
+	// EventKeyPushed is an SSH key recorded as pushed to a host.
+	EventKeyPushed EventType = "key_pushed"
+	// EventKeyRevoked is an SSH key recorded as removed from a host.
+	EventKeyRevoked EventType = "key_revoked"
+	// EventRemoved is a host record deleted from the store.
+	EventRemoved EventType = "removed"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind by
+// before further events to it are dropped.
+const subscriberBuffer = 64
+
+// Event is a change to a host record, as delivered to subscribers.
+type Event struct {
+	Type EventType  `json:"type"`
+	Time time.Time  `json:"time"`
+	Host HostRecord `json:"host"`
+}
+
+// subscribers fans events out to Subscribe callers.
+type subscribers struct {
+	mu   sync.Mutex
+	next int
+	subs map[int]chan Event
+}
+
+// Subscribe returns a channel receiving every host change committed from
+// now on, and a function that ends the subscription and closes the
+// channel. Events are dropped, not queued, for a subscriber that falls
+// too far behind, so a slow reader can't stall the store.
+func (s *Store) Subscribe() (<-chan Event, func()) {
+	s.events.mu.Lock()
+	defer s.events.mu.Unlock()
+
+	if s.events.subs == nil {
+		s.events.subs = make(map[int]chan Event)
+	}
+	id := s.events.next
+	s.events.next++
+	ch := make(chan Event, subscriberBuffer)
+	s.events.subs[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.events.mu.Lock()
+			defer s.events.mu.Unlock()
+			delete(s.events.subs, id)
+			close(ch)
+		})
+	}
+}
+
+// publish delivers an event to every subscriber without blocking.
+func (s *Store) publish(typ EventType, record HostRecord) {
+	s.events.mu.Lock()
+	defer s.events.mu.Unlock()
+
+	ev := Event{Type: typ, Time: time.Now(), Host: record}
+	for _, ch := range s.events.subs {
+		select {
+		case ch <- ev:
+		default:
+			s.log.Warn().
+				Str("event", string(typ)).
+				Str("mac", record.Beacon.MACAddress).
+				Msg("Subscriber too slow, dropping event")
+		}
+	}
+}
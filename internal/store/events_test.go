@@ -0,0 +1,59 @@
+package store
+
+import (
+	"testing"
+)
+
+func TestStore_Subscribe(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	events, unsubscribe := s.Subscribe()
+	defer unsubscribe()
+
+	mac := "aa:bb:cc:dd:ee:ff"
+	s.Upsert(samplePayload(mac, "host1", "192.168.1.10"))
+	s.Upsert(samplePayload(mac, "host1", "192.168.1.10"))
+	s.MarkKeyPushed(mac, "alice", "")
+	s.expireStaleHosts(0)
+	s.MarkInactive(mac) // already inactive: no event
+	s.RemoveHost(mac)
+
+	want := []EventType{EventDiscovered, EventUpdated, EventKeyPushed, EventStale, EventRemoved}
+	for _, typ := range want {
+		ev := <-events
+		if ev.Type != typ {
+			t.Fatalf("got %s event, want %s", ev.Type, typ)
+		}
+		if ev.Host.Beacon.MACAddress != mac {
+			t.Errorf("%s event for %q, want %q", typ, ev.Host.Beacon.MACAddress, mac)
+		}
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("unexpected %s event", ev.Type)
+	default:
+	}
+}
+
+func TestStore_SubscribeSlowReaderDoesNotBlock(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	events, unsubscribe := s.Subscribe()
+
+	// Nobody reads: writes must still go through
+	for range subscriberBuffer + 10 {
+		if err := s.Upsert(samplePayload("aa:bb:cc:dd:ee:ff", "host1", "192.168.1.10")); err != nil {
+			t.Fatalf("upsert failed: %v", err)
+		}
+	}
+	if len(events) != subscriberBuffer {
+		t.Errorf("buffered events: got %d, want %d", len(events), subscriberBuffer)
+	}
+
+	unsubscribe()
+	unsubscribe() // safe to call twice
+	for range events {
+	}
+}
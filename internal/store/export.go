@@ -0,0 +1,144 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Export writes every host record to w as a JSON array, one record per
+// line, without loading them all into memory first.
+func (s *Store) Export(w io.Writer) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	first := true
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(hostsBucket).ForEach(func(k, v []byte) error {
+			// Stored records are already JSON; re-encode through HostRecord
+			// so corrupt ones are skipped rather than exported
+			var record HostRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				s.log.Warn().Err(err).Str("key", string(k)).Msg("Skipping corrupt record")
+				return nil
+			}
+			data, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("marshaling record %s: %w", k, err)
+			}
+
+			sep := ",\n"
+			if first {
+				sep, first = "\n", false
+			}
+			if _, err := io.WriteString(w, sep); err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("exporting hosts: %w", err)
+	}
+	_, err = io.WriteString(w, "\n]\n")
+	return err
+}
+
+// Import merges host records from a JSON array, as written by Export, into
+// the store. A record replaces the stored one for its MAC only if its
+// LastSeen is newer. Entries that aren't valid records, or whose MAC or IP
+// address doesn't parse, are skipped with a warning; only a malformed
+// array aborts the import.
+func (s *Store) Import(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return fmt.Errorf("importing hosts: expected a JSON array of host records")
+	}
+
+	var imported, kept, skipped int
+	for i := 0; dec.More(); i++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return fmt.Errorf("importing hosts: entry %d: %w", i, err)
+		}
+
+		var record HostRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			s.log.Warn().Err(err).Int("entry", i).Msg("Skipping malformed host record")
+			skipped++
+			continue
+		}
+		if err := validateRecord(record); err != nil {
+			s.log.Warn().Err(err).Int("entry", i).Msg("Skipping invalid host record")
+			skipped++
+			continue
+		}
+
+		newer, err := s.putIfNewer(record)
+		if err != nil {
+			return fmt.Errorf("importing hosts: %w", err)
+		}
+		if newer {
+			imported++
+		} else {
+			kept++
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("importing hosts: %w", err)
+	}
+
+	s.log.Info().
+		Int("imported", imported).
+		Int("kept_existing", kept).
+		Int("skipped", skipped).
+		Msg("Host import finished")
+	return nil
+}
+
+// validateRecord checks the fields Import relies on.
+func validateRecord(r HostRecord) error {
+	if _, err := net.ParseMAC(r.Beacon.MACAddress); err != nil {
+		return fmt.Errorf("invalid MAC address %q", r.Beacon.MACAddress)
+	}
+	if r.Beacon.IPAddress != "" && net.ParseIP(r.Beacon.IPAddress) == nil {
+		return fmt.Errorf("invalid IP address %q", r.Beacon.IPAddress)
+	}
+	if r.LastSeen.IsZero() {
+		return errors.New("missing last_seen")
+	}
+	return nil
+}
+
+// putIfNewer stores record unless the stored record for its MAC was seen
+// at the same time or later. It reports whether record was stored.
+func (s *Store) putIfNewer(record HostRecord) (bool, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	stored := false
+	err := s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(hostsBucket)
+		key := []byte(record.Beacon.MACAddress)
+
+		if existing := b.Get(key); existing != nil {
+			var current HostRecord
+			if err := json.Unmarshal(existing, &current); err == nil && !record.LastSeen.After(current.LastSeen) {
+				return nil
+			}
+		}
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("marshaling host record: %w", err)
+		}
+		stored = true
+		return b.Put(key, data)
+	})
+	return stored, err
+}
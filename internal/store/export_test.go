@@ -0,0 +1,110 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore_ExportImportRoundTrip(t *testing.T) {
+	src, cleanupSrc := testStore(t)
+	defer cleanupSrc()
+	dst, cleanupDst := testStore(t)
+	defer cleanupDst()
+
+	src.Upsert(samplePayload("aa:bb:cc:dd:ee:01", "host1", "192.168.1.10"))
+	src.Upsert(samplePayload("aa:bb:cc:dd:ee:02", "host2", "192.168.1.11"))
+	src.MarkKeyPushed("aa:bb:cc:dd:ee:01", "alice", "")
+
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	var exported []HostRecord
+	if err := json.Unmarshal(buf.Bytes(), &exported); err != nil {
+		t.Fatalf("export is not a JSON array of records: %v", err)
+	}
+
+	if err := dst.Import(&buf); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	records, _ := dst.GetAll()
+	if len(records) != 2 {
+		t.Fatalf("expected 2 imported records, got %d", len(records))
+	}
+	if records[0].SSHKeyUser != "alice" {
+		t.Errorf("SSHKeyUser: got %q, want alice", records[0].SSHKeyUser)
+	}
+}
+
+func TestStore_ExportEmpty(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	var buf bytes.Buffer
+	if err := s.Export(&buf); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != "[\n]" {
+		t.Errorf("got %q, want an empty array", got)
+	}
+}
+
+func TestStore_ImportKeepsNewer(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	s.Upsert(samplePayload("aa:bb:cc:dd:ee:01", "current", "192.168.1.10"))
+	records, _ := s.GetAll()
+	seen := records[0].LastSeen
+
+	older := records[0]
+	older.Beacon.Hostname = "older"
+	older.LastSeen = seen.Add(-time.Hour)
+	newer := older
+	newer.Beacon.MACAddress = "aa:bb:cc:dd:ee:02"
+	newer.Beacon.Hostname = "fresh"
+
+	data, _ := json.Marshal([]HostRecord{older, newer})
+	if err := s.Import(bytes.NewReader(data)); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+
+	records, _ = s.GetAll()
+	names := map[string]string{}
+	for _, r := range records {
+		names[r.Beacon.MACAddress] = r.Beacon.Hostname
+	}
+	if names["aa:bb:cc:dd:ee:01"] != "current" {
+		t.Errorf("older import overwrote newer record: %q", names["aa:bb:cc:dd:ee:01"])
+	}
+	if names["aa:bb:cc:dd:ee:02"] != "fresh" {
+		t.Errorf("expected new host to be imported, got %q", names["aa:bb:cc:dd:ee:02"])
+	}
+}
+
+func TestStore_ImportSkipsMalformed(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	input := `[
+		{"beacon": {"MACAddress": "not-a-mac", "IPAddress": "10.0.0.1"}, "last_seen": "2024-01-01T00:00:00Z"},
+		{"beacon": {"MACAddress": "aa:bb:cc:dd:ee:01", "IPAddress": "999.0.0.1"}, "last_seen": "2024-01-01T00:00:00Z"},
+		{"beacon": "oops"},
+		{"beacon": {"MACAddress": "aa:bb:cc:dd:ee:02", "IPAddress": "10.0.0.2"}, "last_seen": "2024-01-01T00:00:00Z"}
+	]`
+	if err := s.Import(strings.NewReader(input)); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+
+	records, _ := s.GetAll()
+	if len(records) != 1 || records[0].Beacon.MACAddress != "aa:bb:cc:dd:ee:02" {
+		t.Errorf("expected only the valid record to be imported, got %+v", records)
+	}
+
+	if err := s.Import(strings.NewReader(`{"not": "an array"}`)); err == nil {
+		t.Error("expected error for input that isn't an array")
+	}
+}
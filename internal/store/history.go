@@ -0,0 +1,60 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// historyBucket holds, per MAC address, the times the host's beacons were
+// stored, oldest first, as big-endian Unix nanoseconds.
+var historyBucket = []byte("history")
+
+// HistorySize is how many observations are kept per host.
+const HistorySize = 500
+
+const historyEntrySize = 8
+
+// appendHistory records observations of key at times within tx, dropping
+// the oldest beyond HistorySize. It costs one Put of at most
+// HistorySize*8 bytes, in the same transaction as the host update.
+func appendHistory(tx *bolt.Tx, key []byte, times ...time.Time) error {
+	if len(times) > HistorySize {
+		times = times[len(times)-HistorySize:]
+	}
+	b := tx.Bucket(historyBucket)
+	old := b.Get(key)
+	if n := len(old) / historyEntrySize; n+len(times) > HistorySize {
+		old = old[(n+len(times)-HistorySize)*historyEntrySize:]
+	}
+
+	// old belongs to bolt and is only valid for the transaction: copy it
+	buf := make([]byte, len(old), len(old)+len(times)*historyEntrySize)
+	copy(buf, old)
+	for _, t := range times {
+		buf = binary.BigEndian.AppendUint64(buf, uint64(t.UnixNano()))
+	}
+	return b.Put(key, buf)
+}
+
+// GetHistory returns when beacons from a host were stored, oldest first,
+// covering at most the last HistorySize observations.
+func (s *Store) GetHistory(mac string) ([]time.Time, error) {
+	var times []time.Time
+	err := s.db.View(func(tx *bolt.Tx) error {
+		key := []byte(mac)
+		if tx.Bucket(hostsBucket).Get(key) == nil {
+			return fmt.Errorf("host %s %w", mac, ErrNotFound)
+		}
+
+		data := tx.Bucket(historyBucket).Get(key)
+		times = make([]time.Time, 0, len(data)/historyEntrySize)
+		for i := 0; i+historyEntrySize <= len(data); i += historyEntrySize {
+			times = append(times, time.Unix(0, int64(binary.BigEndian.Uint64(data[i:]))))
+		}
+		return nil
+	})
+	return times, err
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ValidateLabelKey checks that key can be used as a label key: non-empty,
+// without "=" or whitespace, so "key=value" can always be split back.
+func ValidateLabelKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("label key must not be empty")
+	}
+	if strings.ContainsAny(key, "= \t\r\n") {
+		return fmt.Errorf("label key %q must not contain '=' or whitespace", key)
+	}
+	return nil
+}
+
+// SetLabel sets the label key to value on the host with the given MAC,
+// replacing any previous value.
+func (s *Store) SetLabel(mac, key, value string) error {
+	if err := ValidateLabelKey(key); err != nil {
+		return err
+	}
+	return s.updateLabels(mac, func(labels map[string]string) {
+		labels[key] = value
+	})
+}
+
+// RemoveLabel removes the label key from the host with the given MAC. A
+// label that isn't set is not an error.
+func (s *Store) RemoveLabel(mac, key string) error {
+	return s.updateLabels(mac, func(labels map[string]string) {
+		delete(labels, key)
+	})
+}
+
+// updateLabels applies change to a host's labels.
+func (s *Store) updateLabels(mac string, change func(map[string]string)) error {
+	record, err := s.updateLocal(mac, func(record *HostRecord) {
+		if record.Labels == nil {
+			record.Labels = make(map[string]string)
+		}
+		change(record.Labels)
+		if len(record.Labels) == 0 {
+			record.Labels = nil
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	s.log.Info().
+		Str("mac", mac).
+		Str("hostname", record.Beacon.Hostname).
+		Interface("labels", record.Labels).
+		Msg("Host labels changed")
+	return nil
+}
+
+// updateLocal applies change to the stored record for mac, for edits to
+// local-only fields, and publishes the result.
+func (s *Store) updateLocal(mac string, change func(*HostRecord)) (HostRecord, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	var record HostRecord
+	err := s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(hostsBucket)
+		key := []byte(mac)
+
+		existing := b.Get(key)
+		if existing == nil {
+			return fmt.Errorf("host %s %w", mac, ErrNotFound)
+		}
+
+		if err := json.Unmarshal(existing, &record); err != nil {
+			return fmt.Errorf("unmarshaling record: %w", err)
+		}
+
+		change(&record)
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("marshaling record: %w", err)
+		}
+		return b.Put(key, data)
+	})
+	if err != nil {
+		return HostRecord{}, err
+	}
+
+	s.publish(EventUpdated, record)
+	return record, nil
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestStore_Labels(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	mac := "aa:bb:cc:dd:ee:ff"
+	s.Upsert(samplePayload(mac, "host1", "192.168.1.10"))
+
+	if err := s.SetLabel(mac, "role", "web"); err != nil {
+		t.Fatalf("set label failed: %v", err)
+	}
+	if err := s.SetLabel(mac, "env", "staging"); err != nil {
+		t.Fatalf("set label failed: %v", err)
+	}
+
+	// Beacons don't touch labels
+	s.Upsert(samplePayload(mac, "host1", "192.168.1.10"))
+
+	records, err := s.GetAll()
+	if err != nil {
+		t.Fatalf("getall failed: %v", err)
+	}
+	want := map[string]string{"role": "web", "env": "staging"}
+	if !reflect.DeepEqual(records[0].Labels, want) {
+		t.Errorf("labels after upsert: got %v, want %v", records[0].Labels, want)
+	}
+
+	s.RemoveLabel(mac, "role")
+	s.RemoveLabel(mac, "env")
+	records, _ = s.GetAll()
+	if records[0].Labels != nil {
+		t.Errorf("expected no labels, got %v", records[0].Labels)
+	}
+}
+
+func TestStore_SetLabelErrors(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	if err := s.SetLabel("nonexistent", "role", "web"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	s.Upsert(samplePayload("aa:bb:cc:dd:ee:ff", "host1", "192.168.1.10"))
+	for _, key := range []string{"", "role=web", "my role"} {
+		if err := s.SetLabel("aa:bb:cc:dd:ee:ff", key, "x"); err == nil {
+			t.Errorf("SetLabel(%q): expected error", key)
+		}
+	}
+}
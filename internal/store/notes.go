@@ -0,0 +1,21 @@
+package store
+
+import "strings"
+
+// SetNotes replaces the notes on the host with the given MAC. Surrounding
+// whitespace is trimmed; empty notes clear them.
+func (s *Store) SetNotes(mac, notes string) error {
+	notes = strings.TrimSpace(notes)
+	record, err := s.updateLocal(mac, func(record *HostRecord) {
+		record.Notes = notes
+	})
+	if err != nil {
+		return err
+	}
+
+	s.log.Info().
+		Str("mac", mac).
+		Str("hostname", record.Beacon.Hostname).
+		Msg("Host notes changed")
+	return nil
+}
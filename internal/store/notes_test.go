@@ -0,0 +1,49 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore_SetNotes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	mac := "aa:bb:cc:dd:ee:ff"
+	s.Upsert(samplePayload(mac, "host1", "192.168.1.10"))
+	if err := s.SetNotes(mac, "  flaky PSU, reboot before use\n"); err != nil {
+		t.Fatalf("set notes failed: %v", err)
+	}
+	s.Upsert(samplePayload(mac, "host1", "192.168.1.10"))
+
+	// Notes survive beacons and a reopen
+	s.Close()
+	if s, err = New(path, testLogger()); err != nil {
+		t.Fatalf("reopening store: %v", err)
+	}
+	defer s.Close()
+
+	records, err := s.GetAll()
+	if err != nil {
+		t.Fatalf("getall failed: %v", err)
+	}
+	if records[0].Notes != "flaky PSU, reboot before use" {
+		t.Errorf("notes: got %q", records[0].Notes)
+	}
+
+	if err := s.SetNotes(mac, ""); err != nil {
+		t.Fatalf("clear notes failed: %v", err)
+	}
+	records, _ = s.GetAll()
+	if records[0].Notes != "" {
+		t.Errorf("expected notes cleared, got %q", records[0].Notes)
+	}
+
+	if err := s.SetNotes("nonexistent", "x"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
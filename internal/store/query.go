@@ -0,0 +1,65 @@
+package store
+
+import (
+	"encoding/json"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// QueryOptions selects and pages the records Query returns.
+type QueryOptions struct {
+	// ActiveOnly skips inactive hosts.
+	ActiveOnly bool
+	// Match, if set, keeps only hosts whose hostname or reported IP
+	// address contains it, ignoring case.
+	Match string
+	// Offset skips that many matching records.
+	Offset int
+	// Limit caps the number of records returned; zero means no limit.
+	Limit int
+}
+
+// matches reports whether record passes the ActiveOnly and Match filters.
+func (o QueryOptions) matches(record HostRecord, match string) bool {
+	if o.ActiveOnly && !record.Active {
+		return false
+	}
+	if match == "" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(record.Beacon.Hostname), match) ||
+		strings.Contains(record.Beacon.IPAddress, match)
+}
+
+// Query returns the records selected by opts, in MAC address order. It
+// walks the bucket with a cursor and stops as soon as the page is full,
+// so only the records on the page are kept in memory.
+func (s *Store) Query(opts QueryOptions) ([]HostRecord, error) {
+	match := strings.ToLower(opts.Match)
+	skip := opts.Offset
+	var records []HostRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(hostsBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var record HostRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				s.log.Warn().Err(err).Str("key", string(k)).Msg("Skipping corrupt record")
+				continue
+			}
+			if !opts.matches(record, match) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			records = append(records, record)
+			if opts.Limit > 0 && len(records) == opts.Limit {
+				return nil
+			}
+		}
+		return nil
+	})
+	return records, err
+}
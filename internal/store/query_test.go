@@ -0,0 +1,51 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+func macs(records []HostRecord) []string {
+	out := make([]string, len(records))
+	for i, r := range records {
+		out[i] = r.Beacon.MACAddress
+	}
+	return out
+}
+
+func TestStore_Query(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	for i := 1; i <= 5; i++ {
+		s.Upsert(samplePayload(fmt.Sprintf("aa:bb:cc:dd:ee:0%d", i), fmt.Sprintf("Web%d", i), fmt.Sprintf("192.168.1.%d", 10+i)))
+	}
+	s.Upsert(samplePayload("aa:bb:cc:dd:ee:06", "db1", "10.0.0.6"))
+	if err := s.MarkInactive("aa:bb:cc:dd:ee:02"); err != nil {
+		t.Fatalf("mark inactive failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts QueryOptions
+		want []string
+	}{
+		{"all", QueryOptions{}, []string{"aa:bb:cc:dd:ee:01", "aa:bb:cc:dd:ee:02", "aa:bb:cc:dd:ee:03", "aa:bb:cc:dd:ee:04", "aa:bb:cc:dd:ee:05", "aa:bb:cc:dd:ee:06"}},
+		{"first page", QueryOptions{Limit: 2}, []string{"aa:bb:cc:dd:ee:01", "aa:bb:cc:dd:ee:02"}},
+		{"active page", QueryOptions{ActiveOnly: true, Offset: 1, Limit: 2}, []string{"aa:bb:cc:dd:ee:03", "aa:bb:cc:dd:ee:04"}},
+		{"hostname match", QueryOptions{Match: "web", Offset: 3}, []string{"aa:bb:cc:dd:ee:04", "aa:bb:cc:dd:ee:05"}},
+		{"ip match", QueryOptions{Match: "10.0.0."}, []string{"aa:bb:cc:dd:ee:06"}},
+		{"past the end", QueryOptions{Offset: 10}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Query(tt.opts)
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			if fmt.Sprint(macs(got)) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", macs(got), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+	bolt "go.etcd.io/bbolt"
+	berrors "go.etcd.io/bbolt/errors"
+)
+
+// readOnlyWait is how long NewReadOnly waits for the database lock before
+// giving up with ErrLocked.
+const readOnlyWait = 100 * time.Millisecond
+
+// NewReadOnly opens the BoltDB file at path for reading only, for tools
+// such as export and stats. Writes to the store fail with ErrReadOnly.
+//
+// Readers share the file's lock with each other but not with a writer, so
+// while a node has the database open NewReadOnly fails with ErrLocked;
+// the node's data is then only available over RPC.
+func NewReadOnly(path string, log zerolog.Logger) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{ReadOnly: true, Timeout: readOnlyWait})
+	if errors.Is(err, berrors.ErrTimeout) {
+		return nil, fmt.Errorf("database at %s is %w; is a node running?", path, ErrLocked)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening database %s read-only: %w", path, err)
+	}
+	return newStore(db, log, true), nil
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewReadOnly_WhileWritableOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	if ro, err := NewReadOnly(path, testLogger()); !errors.Is(err, ErrLocked) {
+		if err == nil {
+			ro.Close()
+		}
+		t.Fatalf("opening read-only beside a writable store: expected ErrLocked, got %v", err)
+	}
+}
+
+func TestNewReadOnly_Unlocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	s.Upsert(samplePayload("aa:bb:cc:dd:ee:ff", "host1", "192.168.1.10"))
+	s.Close()
+
+	ro, err := NewReadOnly(path, testLogger())
+	if err != nil {
+		t.Fatalf("opening read-only: %v", err)
+	}
+	if records, _ := ro.GetAll(); len(records) != 1 {
+		t.Errorf("expected 1 host, got %d", len(records))
+	}
+
+	if err := ro.Upsert(samplePayload("aa:bb:cc:dd:ee:ff", "host1", "192.168.1.10")); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("read-only Upsert: expected ErrReadOnly, got %v", err)
+	}
+	if err := ro.MarkKeyPushed("aa:bb:cc:dd:ee:ff", "root", ""); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("read-only MarkKeyPushed: expected ErrReadOnly, got %v", err)
+	}
+
+	// No writer was started, so Close has nothing to wait for
+	done := make(chan error, 1)
+	go func() { done <- ro.Close() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("closing read-only store: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close on a read-only store did not return")
+	}
+
+	if _, err := NewReadOnly(filepath.Join(t.TempDir(), "missing.db"), testLogger()); err == nil {
+		t.Error("expected an error opening a missing database")
+	}
+}
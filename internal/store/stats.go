@@ -0,0 +1,52 @@
+package store
+
+// Stats summarizes the host database.
+type Stats struct {
+	TotalHosts  int
+	ActiveHosts int
+	KeysPushed  int
+	// BeaconsReceived counts beacons stored since this Store was opened.
+	// It is kept in memory only, so it starts again from zero when the
+	// node restarts.
+	BeaconsReceived uint64
+	// DiscoveryRestarts counts restarts of the node's discovery subsystem
+	// since this Store was opened (see NoteDiscoveryRestart).
+	DiscoveryRestarts uint64
+	// ByOS counts hosts per OS name; hosts without one count as "unknown".
+	ByOS map[string]int
+}
+
+// Stats returns aggregate counts over every host record.
+func (s *Store) Stats() (Stats, error) {
+	records, err := s.GetAll()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats := Stats{
+		TotalHosts:        len(records),
+		BeaconsReceived:   s.beacons.Load(),
+		DiscoveryRestarts: s.restarts.Load(),
+		ByOS:              make(map[string]int),
+	}
+	for _, r := range records {
+		if r.Active {
+			stats.ActiveHosts++
+		}
+		if r.SSHKeyPushed {
+			stats.KeysPushed++
+		}
+		name := r.Beacon.OS.Name
+		if name == "" {
+			name = "unknown"
+		}
+		stats.ByOS[name]++
+	}
+	return stats, nil
+}
+
+// NoteDiscoveryRestart counts a restart of the node's discovery subsystem,
+// reported by Stats alongside the beacon count.
+func (s *Store) NoteDiscoveryRestart() {
+	s.restarts.Add(1)
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStore_Stats(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	s.Upsert(samplePayload("aa:bb:cc:dd:ee:01", "web1", "192.168.1.10"))
+	s.Upsert(samplePayload("aa:bb:cc:dd:ee:01", "web1", "192.168.1.10"))
+	s.Upsert(samplePayload("aa:bb:cc:dd:ee:02", "web2", "192.168.1.11"))
+	noOS := samplePayload("aa:bb:cc:dd:ee:03", "printer", "192.168.1.12")
+	noOS.OS.Name = ""
+	s.Upsert(noOS)
+
+	if err := s.MarkKeyPushed("aa:bb:cc:dd:ee:01", "alice", ""); err != nil {
+		t.Fatalf("mark key pushed failed: %v", err)
+	}
+	if err := s.MarkInactive("aa:bb:cc:dd:ee:02"); err != nil {
+		t.Fatalf("mark inactive failed: %v", err)
+	}
+	s.NoteDiscoveryRestart()
+
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatalf("stats failed: %v", err)
+	}
+
+	want := Stats{
+		TotalHosts:        3,
+		ActiveHosts:       2,
+		KeysPushed:        1,
+		BeaconsReceived:   4,
+		DiscoveryRestarts: 1,
+		ByOS:              map[string]int{"Ubuntu 22.04": 2, "unknown": 1},
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+}
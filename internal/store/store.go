@@ -3,18 +3,39 @@ package store
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog"
 	bolt "go.etcd.io/bbolt"
+	berrors "go.etcd.io/bbolt/errors"
 
 	"lanmon/internal/beacon"
 )
 
 var hostsBucket = []byte("hosts")
 
+// ErrNotFound is returned when no record exists for a MAC address.
+var ErrNotFound = errors.New("not found")
+
+// ErrClosed is returned by writes to a closed store.
+var ErrClosed = errors.New("store closed")
+
+// ErrReadOnly is returned by writes to a store opened with NewReadOnly.
+var ErrReadOnly = errors.New("store is read-only")
+
+// ErrLocked is returned by New and NewReadOnly when another process has
+// the database open for writing.
+var ErrLocked = errors.New("locked by another lanmon process")
+
+// openTimeout is how long New waits for another process to release the
+// database before giving up with ErrLocked.
+var openTimeout = 5 * time.Second
+
 // HostRecord represents a discovered host in the database.
 type HostRecord struct {
 	Beacon         beacon.BeaconPayload `json:"beacon"`
@@ -23,152 +44,409 @@ type HostRecord struct {
 	PacketCount    uint64               `json:"packet_count"`
 	SSHKeyPushed   bool                 `json:"ssh_key_pushed"`
 	SSHKeyPushedAt *time.Time           `json:"ssh_key_pushed_at,omitempty"`
+	SSHKeyUser     string               `json:"ssh_key_user,omitempty"`
 	Active         bool                 `json:"active"`
+
+	// PushedKeyFingerprint is the SHA256 fingerprint of the public key last
+	// pushed to the host, so hosts still holding an old key stand out after
+	// a key rotation. Empty when the key was recorded without one.
+	PushedKeyFingerprint string `json:"pushed_key_fingerprint,omitempty"`
+
+	// ObservedIP is the source address the last beacon actually came from,
+	// as opposed to the self-reported Beacon.IPAddress. Empty when unknown.
+	ObservedIP string `json:"observed_ip,omitempty"`
+
+	// ContinuousSince is when the host last became active. Unlike FirstSeen,
+	// it is cleared whenever the host is marked inactive and set again on
+	// the next beacon, so it measures the current uninterrupted stretch.
+	ContinuousSince *time.Time `json:"continuous_since,omitempty"`
+
+	// Conflict is set while another active host reports the same IP
+	// address. It is updated by the periodic checks started by RunExpiry.
+	Conflict bool `json:"conflict,omitempty"`
+
+	// Labels are key=value annotations set locally with SetLabel, such as
+	// role=web. They are never broadcast, and beacons don't touch them.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Notes is free-form text an operator attached with SetNotes, such as
+	// "flaky PSU, reboot before use". Like Labels, it is local only.
+	Notes string `json:"notes,omitempty"`
+}
+
+// IPMismatch reports whether the host's beacons come from an address other
+// than the one it reports, which may mean NAT, a multi-homed host or a
+// spoofed beacon.
+func (r HostRecord) IPMismatch() bool {
+	if r.ObservedIP == "" {
+		return false
+	}
+	return !net.ParseIP(r.ObservedIP).Equal(net.ParseIP(r.Beacon.IPAddress))
 }
 
 // Store wraps a bbolt database for host records.
 type Store struct {
-	db  *bolt.DB
-	mu  sync.RWMutex
-	log zerolog.Logger
+	db     *bolt.DB
+	log    zerolog.Logger
+	events subscribers
+
+	// readOnly is set by NewReadOnly.
+	readOnly bool
+
+	// writeMu is held around each write transaction and the event it
+	// publishes. Bolt already runs one write transaction at a time; the
+	// lock is there so subscribers get events in commit order. Reads take
+	// no Go-level lock: each View sees a consistent snapshot and never
+	// waits for writers, so discovery traffic can't starve the CLI.
+	writeMu sync.Mutex
+
+	// beacons counts successful UpsertFrom calls, for Stats.
+	beacons atomic.Uint64
+	// restarts counts NoteDiscoveryRestart calls, for Stats.
+	restarts atomic.Uint64
+
+	// expiryMu guards the thresholds RunExpiry applies (see SetExpiry).
+	expiryMu       sync.Mutex
+	staleThreshold time.Duration
+	purgeThreshold time.Duration
+
+	// upserts carries beacons to the writeUpserts goroutine, which stops
+	// once quit is closed and then closes writerDone.
+	upserts    chan *upsert
+	quit       chan struct{}
+	closeOnce  sync.Once
+	writerDone chan struct{}
+
+	// debounceMu guards the write debounce (see SetFlushInterval): the
+	// beacons held per MAC and the last one written.
+	debounceMu    sync.Mutex
+	flushInterval time.Duration
+	held          map[string]*heldBeacons
+	written       map[string]lastWrite
 }
 
-// New opens or creates a BoltDB file at the given path.
+// New opens or creates a BoltDB file at the given path. Bolt allows one
+// process at a time to open a database, so New fails with ErrLocked while
+// another lanmon process, typically a running node, has it open.
 func New(path string, log zerolog.Logger) (*Store, error) {
-	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: openTimeout})
+	if errors.Is(err, berrors.ErrTimeout) {
+		return nil, fmt.Errorf("database at %s is %w (waited %s); is a node already running?", path, ErrLocked, openTimeout)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("opening database %s: %w", path, err)
 	}
 
-	// Ensure the hosts bucket exists
+	// Ensure the buckets exist
 	err = db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(hostsBucket)
-		return err
+		for _, name := range [][]byte{hostsBucket, historyBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return fmt.Errorf("creating %s bucket: %w", name, err)
+			}
+		}
+		return nil
 	})
 	if err != nil {
 		db.Close()
-		return nil, fmt.Errorf("creating hosts bucket: %w", err)
+		return nil, err
 	}
 
-	return &Store{db: db, log: log}, nil
+	return newStore(db, log, false), nil
 }
 
-// Close closes the underlying BoltDB.
+// newStore wraps an open database and, unless it is read-only, starts the
+// upsert writer.
+func newStore(db *bolt.DB, log zerolog.Logger, readOnly bool) *Store {
+	s := &Store{
+		db:         db,
+		log:        log,
+		readOnly:   readOnly,
+		upserts:    make(chan *upsert),
+		quit:       make(chan struct{}),
+		writerDone: make(chan struct{}),
+	}
+	if readOnly {
+		close(s.writerDone)
+	} else {
+		go s.writeUpserts()
+	}
+	return s
+}
+
+// Close writes any beacons held back by the debounce, stops the upsert
+// writer and closes the underlying BoltDB. Upserts after Close return
+// ErrClosed.
 func (s *Store) Close() error {
+	if err := s.Flush(); err != nil {
+		s.log.Error().Err(err).Msg("Database error flushing held beacons")
+	}
+	s.closeOnce.Do(func() { close(s.quit) })
+	<-s.writerDone
 	return s.db.Close()
 }
 
+// update runs fn in a write transaction, or fails with ErrReadOnly.
+func (s *Store) update(fn func(*bolt.Tx) error) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	return s.db.Update(fn)
+}
+
 // Upsert inserts or updates a host record keyed by MAC address.
 func (s *Store) Upsert(payload beacon.BeaconPayload) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	return s.UpsertFrom(payload, nil)
+}
 
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(hostsBucket)
-		key := []byte(payload.MACAddress)
+// UpsertFrom is Upsert for a beacon received from src, which is recorded
+// as the host's ObservedIP. A nil src leaves ObservedIP as it was. With a
+// flush interval set, the write may be held back (see SetFlushInterval).
+// Beacons arriving together are committed in one transaction (see
+// writeUpserts); UpsertFrom returns once its beacon is committed.
+func (s *Store) UpsertFrom(payload beacon.BeaconPayload, src net.IP) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	now := time.Now()
+	if s.hold(payload, src, now) {
+		s.beacons.Add(1)
+		return nil
+	}
 
-		now := time.Now()
-		var record HostRecord
+	req := &upsert{payload: payload, src: src, now: now, done: make(chan error, 1)}
+	select {
+	case s.upserts <- req:
+	case <-s.quit:
+		return ErrClosed
+	}
+	return <-req.done
+}
 
-		existing := b.Get(key)
-		if existing != nil {
-			if err := json.Unmarshal(existing, &record); err != nil {
-				s.log.Warn().Err(err).Str("mac", payload.MACAddress).Msg("Failed to unmarshal existing record, overwriting")
-			}
-			record.Beacon = payload
-			record.LastSeen = now
-			record.PacketCount++
-			record.Active = true
-
-			s.log.Debug().
-				Str("mac", payload.MACAddress).
-				Str("hostname", payload.Hostname).
-				Msg("Host updated")
-		} else {
-			record = HostRecord{
-				Beacon:      payload,
-				FirstSeen:   now,
-				LastSeen:    now,
-				PacketCount: 1,
-				Active:      true,
+// applyUpsert writes one beacon within tx and returns the updated record
+// and the event it makes.
+func (s *Store) applyUpsert(tx *bolt.Tx, req *upsert) (HostRecord, EventType, error) {
+	payload, src, now := req.payload, req.src, req.now
+	b := tx.Bucket(hostsBucket)
+	key := []byte(payload.MACAddress)
+
+	var record HostRecord
+	event := EventUpdated
+	existing := b.Get(key)
+	if existing != nil {
+		if err := json.Unmarshal(existing, &record); err != nil {
+			s.log.Warn().Err(err).Str("mac", payload.MACAddress).Msg("Failed to unmarshal existing record, overwriting")
+		}
+		wasMismatch := record.IPMismatch()
+		if held := s.takeHeld(payload.MACAddress); held != nil {
+			req.held = held
+			if err := applyHeld(tx, &record, held); err != nil {
+				return record, event, fmt.Errorf("recording history: %w", err)
 			}
-
-			s.log.Info().
-				Str("mac", payload.MACAddress).
-				Str("hostname", payload.Hostname).
-				Str("ip", payload.IPAddress).
-				Str("os", payload.OS.Name).
-				Msg("New host discovered")
+		}
+		applyBeacon(&record, payload, src, now)
+		if record.IPMismatch() && !wasMismatch {
+			s.warnMismatch(record)
 		}
 
-		data, err := json.Marshal(record)
-		if err != nil {
-			return fmt.Errorf("marshaling host record: %w", err)
+		s.log.Debug().
+			Str("mac", payload.MACAddress).
+			Str("hostname", payload.Hostname).
+			Msg("Host updated")
+	} else {
+		event = EventDiscovered
+		applyBeacon(&record, payload, src, now)
+		if record.IPMismatch() {
+			s.warnMismatch(record)
 		}
 
-		return b.Put(key, data)
-	})
+		s.log.Info().
+			Str("mac", payload.MACAddress).
+			Str("hostname", payload.Hostname).
+			Str("ip", payload.IPAddress).
+			Str("os", payload.OS.Name).
+			Msg("New host discovered")
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return record, event, fmt.Errorf("marshaling host record: %w", err)
+	}
+
+	if err := appendHistory(tx, key, now); err != nil {
+		return record, event, fmt.Errorf("recording history: %w", err)
+	}
+	return record, event, b.Put(key, data)
+}
+
+// applyBeacon merges a beacon received at now from src into record. Only
+// the fields a beacon determines are written: the payload itself, when
+// and from where it was seen, and the activity state. FirstSeen is set
+// once. Everything else is local state (key status, labels, conflict
+// flag...) and survives any number of beacons; a nil src keeps the last
+// ObservedIP.
+func applyBeacon(record *HostRecord, payload beacon.BeaconPayload, src net.IP, now time.Time) {
+	if record.FirstSeen.IsZero() {
+		record.FirstSeen = now
+	}
+	if !record.Active || record.ContinuousSince == nil {
+		record.ContinuousSince = &now
+	}
+	record.Beacon = payload
+	if src != nil {
+		record.ObservedIP = src.String()
+	}
+	record.LastSeen = now
+	record.PacketCount++
+	record.Active = true
+}
+
+// warnMismatch logs a host starting to beacon from an address other than
+// the one it reports. It isn't repeated while the mismatch persists.
+func (s *Store) warnMismatch(record HostRecord) {
+	s.log.Warn().
+		Str("mac", record.Beacon.MACAddress).
+		Str("hostname", record.Beacon.Hostname).
+		Str("reported_ip", record.Beacon.IPAddress).
+		Str("observed_ip", record.ObservedIP).
+		Msg("Beacon source address differs from reported IP")
 }
 
 // GetAll returns all host records.
 func (s *Store) GetAll() ([]HostRecord, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	var records []HostRecord
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(hostsBucket)
-		return b.ForEach(func(k, v []byte) error {
-			var record HostRecord
-			if err := json.Unmarshal(v, &record); err != nil {
-				s.log.Warn().Err(err).Str("key", string(k)).Msg("Skipping corrupt record")
-				return nil
-			}
-			records = append(records, record)
-			return nil
-		})
-	})
-	return records, err
+	return s.Query(QueryOptions{})
 }
 
 // GetActive returns only active host records.
 func (s *Store) GetActive() ([]HostRecord, error) {
-	all, err := s.GetAll()
+	return s.Query(QueryOptions{ActiveOnly: true})
+}
+
+// MarkKeyPushed marks a host's SSH key as pushed for the given remote user.
+// fingerprint identifies the key; it may be empty if unknown.
+func (s *Store) MarkKeyPushed(mac, user, fingerprint string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	var record HostRecord
+	err := s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(hostsBucket)
+		key := []byte(mac)
+
+		existing := b.Get(key)
+		if existing == nil {
+			return fmt.Errorf("host %s %w", mac, ErrNotFound)
+		}
+
+		if err := json.Unmarshal(existing, &record); err != nil {
+			return fmt.Errorf("unmarshaling record: %w", err)
+		}
+
+		now := time.Now()
+		record.SSHKeyPushed = true
+		record.SSHKeyPushedAt = &now
+		record.SSHKeyUser = user
+		record.PushedKeyFingerprint = fingerprint
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("marshaling record: %w", err)
+		}
+
+		s.log.Info().
+			Str("mac", mac).
+			Str("hostname", record.Beacon.Hostname).
+			Str("user", user).
+			Str("fingerprint", fingerprint).
+			Msg("SSH key pushed")
+
+		return b.Put(key, data)
+	})
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var active []HostRecord
-	for _, r := range all {
-		if r.Active {
-			active = append(active, r)
+	s.publish(EventKeyPushed, record)
+	return nil
+}
+
+// ClearKeyPushed records that the SSH key is no longer on a host.
+func (s *Store) ClearKeyPushed(mac string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	var record HostRecord
+	err := s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(hostsBucket)
+		key := []byte(mac)
+
+		existing := b.Get(key)
+		if existing == nil {
+			return fmt.Errorf("host %s %w", mac, ErrNotFound)
+		}
+
+		if err := json.Unmarshal(existing, &record); err != nil {
+			return fmt.Errorf("unmarshaling record: %w", err)
 		}
+
+		record.SSHKeyPushed = false
+		record.SSHKeyPushedAt = nil
+		record.SSHKeyUser = ""
+		record.PushedKeyFingerprint = ""
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("marshaling record: %w", err)
+		}
+
+		s.log.Info().
+			Str("mac", mac).
+			Str("hostname", record.Beacon.Hostname).
+			Msg("SSH key revoked")
+
+		return b.Put(key, data)
+	})
+	if err != nil {
+		return err
 	}
-	return active, nil
+
+	s.publish(EventKeyRevoked, record)
+	return nil
 }
 
-// MarkKeyPushed marks a host's SSH key as pushed.
-func (s *Store) MarkKeyPushed(mac string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// MarkInactive immediately marks a host as inactive, e.g. when it announces
+// a clean shutdown with a departure beacon.
+func (s *Store) MarkInactive(mac string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
 
-	return s.db.Update(func(tx *bolt.Tx) error {
+	var record HostRecord
+	var taken *heldBeacons
+	changed := false
+	err := s.update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(hostsBucket)
 		key := []byte(mac)
 
 		existing := b.Get(key)
 		if existing == nil {
-			return fmt.Errorf("host %s not found", mac)
+			return fmt.Errorf("host %s %w", mac, ErrNotFound)
 		}
 
-		var record HostRecord
 		if err := json.Unmarshal(existing, &record); err != nil {
 			return fmt.Errorf("unmarshaling record: %w", err)
 		}
 
-		now := time.Now()
-		record.SSHKeyPushed = true
-		record.SSHKeyPushedAt = &now
+		if !record.Active {
+			return nil
+		}
+		// Beacons held before the departure still count
+		if taken = s.takeHeld(mac); taken != nil {
+			if err := applyHeld(tx, &record, taken); err != nil {
+				return fmt.Errorf("recording history: %w", err)
+			}
+		}
+		changed = true
+		record.Active = false
+		record.ContinuousSince = nil
 
 		data, err := json.Marshal(record)
 		if err != nil {
@@ -178,32 +456,111 @@ func (s *Store) MarkKeyPushed(mac string) error {
 		s.log.Info().
 			Str("mac", mac).
 			Str("hostname", record.Beacon.Hostname).
-			Msg("SSH key pushed")
+			Msg("Host departed, marked inactive")
 
 		return b.Put(key, data)
 	})
+	if err != nil {
+		if taken != nil {
+			s.restoreHeld(mac, taken)
+		}
+		return err
+	}
+
+	s.forget(mac)
+	if changed {
+		s.publish(EventDeparted, record)
+	}
+	return nil
+}
+
+// RemoveHost deletes a host record, e.g. for a decommissioned machine that
+// would otherwise linger until it expires.
+func (s *Store) RemoveHost(mac string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	var record HostRecord
+	err := s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(hostsBucket)
+		key := []byte(mac)
+
+		existing := b.Get(key)
+		if existing == nil {
+			return fmt.Errorf("host %s %w", mac, ErrNotFound)
+		}
+		if err := json.Unmarshal(existing, &record); err != nil {
+			// Still deletable; subscribers get what we know
+			record.Beacon.MACAddress = mac
+		}
+
+		s.log.Info().Str("mac", mac).Msg("Host removed")
+		if err := tx.Bucket(historyBucket).Delete(key); err != nil {
+			return err
+		}
+		return b.Delete(key)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.forget(mac)
+	s.publish(EventRemoved, record)
+	return nil
 }
 
 // RunExpiry starts a background goroutine that marks hosts as inactive
-// if their LastSeen exceeds the given threshold. Runs at the given check interval.
-func (s *Store) RunExpiry(checkInterval, threshold time.Duration) {
+// if their LastSeen exceeds the given threshold, and, if purgeThreshold is
+// non-zero, deletes inactive hosts not seen for purgeThreshold. Each run
+// also updates the Conflict flags. Runs at the given check interval; the
+// thresholds can be changed later with SetExpiry.
+func (s *Store) RunExpiry(checkInterval, threshold, purgeThreshold time.Duration) {
+	s.SetExpiry(threshold, purgeThreshold)
 	go func() {
 		ticker := time.NewTicker(checkInterval)
 		defer ticker.Stop()
 
 		for range ticker.C {
+			threshold, purgeThreshold := s.expiry()
 			s.expireStaleHosts(threshold)
+			if err := s.checkConflicts(); err != nil {
+				s.log.Error().Err(err).Msg("Database error during conflict check")
+			}
+			if purgeThreshold > 0 {
+				if _, err := s.PurgeOlderThan(purgeThreshold); err != nil {
+					s.log.Error().Err(err).Msg("Database error during purge")
+				}
+			}
 		}
 	}()
 }
 
+// SetExpiry changes the thresholds used by RunExpiry from its next check.
+func (s *Store) SetExpiry(threshold, purgeThreshold time.Duration) {
+	s.expiryMu.Lock()
+	defer s.expiryMu.Unlock()
+	s.staleThreshold, s.purgeThreshold = threshold, purgeThreshold
+}
+
+func (s *Store) expiry() (threshold, purgeThreshold time.Duration) {
+	s.expiryMu.Lock()
+	defer s.expiryMu.Unlock()
+	return s.staleThreshold, s.purgeThreshold
+}
+
 func (s *Store) expireStaleHosts(threshold time.Duration) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	// Held beacons carry the latest LastSeen
+	if err := s.flushLocked(); err != nil {
+		s.log.Error().Err(err).Msg("Database error during expiry check")
+	}
 
 	cutoff := time.Now().Add(-threshold)
 
-	err := s.db.Update(func(tx *bolt.Tx) error {
+	var expired []HostRecord
+	err := s.update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(hostsBucket)
 		return b.ForEach(func(k, v []byte) error {
 			var record HostRecord
@@ -213,6 +570,7 @@ func (s *Store) expireStaleHosts(threshold time.Duration) {
 
 			if record.Active && record.LastSeen.Before(cutoff) {
 				record.Active = false
+				record.ContinuousSince = nil
 
 				s.log.Info().
 					Str("mac", record.Beacon.MACAddress).
@@ -224,6 +582,7 @@ func (s *Store) expireStaleHosts(threshold time.Duration) {
 				if err != nil {
 					return nil
 				}
+				expired = append(expired, record)
 				return b.Put(k, data)
 			}
 			return nil
@@ -231,5 +590,69 @@ func (s *Store) expireStaleHosts(threshold time.Duration) {
 	})
 	if err != nil {
 		s.log.Error().Err(err).Msg("Database error during expiry check")
+		return
+	}
+
+	for _, record := range expired {
+		s.forget(record.Beacon.MACAddress)
+		s.publish(EventStale, record)
+	}
+}
+
+// PurgeOlderThan deletes inactive hosts last seen more than d ago, along
+// with their history, and returns how many were deleted. Active hosts are
+// kept however old their LastSeen.
+func (s *Store) PurgeOlderThan(d time.Duration) (int, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	cutoff := time.Now().Add(-d)
+
+	var purged []HostRecord
+	err := s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(hostsBucket)
+		history := tx.Bucket(historyBucket)
+
+		// Deleting while iterating with ForEach is unsafe in bolt, so
+		// collect the keys first
+		var keys [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var record HostRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return nil
+			}
+			if !record.Active && record.LastSeen.Before(cutoff) {
+				keys = append(keys, append([]byte(nil), k...))
+				purged = append(purged, record)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+			if err := history.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("purging hosts: %w", err)
+	}
+
+	for _, record := range purged {
+		s.log.Info().
+			Str("mac", record.Beacon.MACAddress).
+			Str("hostname", record.Beacon.Hostname).
+			Time("last_seen", record.LastSeen).
+			Msg("Host purged")
+		s.forget(record.Beacon.MACAddress)
+		s.publish(EventRemoved, record)
 	}
+	return len(purged), nil
 }
@@ -1,12 +1,20 @@
 package store
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/rs/zerolog"
+	bolt "go.etcd.io/bbolt"
 
 	"lanmon/internal/beacon"
 )
@@ -50,6 +58,23 @@ func samplePayload(mac, hostname, ip string) beacon.BeaconPayload {
 	}
 }
 
+func TestNew_Locked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path, testLogger())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	defer func(d time.Duration) { openTimeout = d }(openTimeout)
+	openTimeout = 50 * time.Millisecond
+
+	_, err = New(path, testLogger())
+	if !errors.Is(err, ErrLocked) || !strings.Contains(err.Error(), path) {
+		t.Errorf("expected ErrLocked naming %s, got %v", path, err)
+	}
+}
+
 func TestStore_UpsertAndGetAll(t *testing.T) {
 	s, cleanup := testStore(t)
 	defer cleanup()
@@ -148,7 +173,7 @@ func TestStore_MarkKeyPushed(t *testing.T) {
 	mac := "aa:bb:cc:dd:ee:ff"
 	s.Upsert(samplePayload(mac, "host1", "192.168.1.10"))
 
-	if err := s.MarkKeyPushed(mac); err != nil {
+	if err := s.MarkKeyPushed(mac, "alice", "SHA256:abc"); err != nil {
 		t.Fatalf("mark key pushed failed: %v", err)
 	}
 
@@ -163,13 +188,41 @@ func TestStore_MarkKeyPushed(t *testing.T) {
 	if records[0].SSHKeyPushedAt == nil {
 		t.Error("expected SSHKeyPushedAt to be set")
 	}
+	if records[0].SSHKeyUser != "alice" {
+		t.Errorf("SSHKeyUser: got %q, want alice", records[0].SSHKeyUser)
+	}
+	if records[0].PushedKeyFingerprint != "SHA256:abc" {
+		t.Errorf("PushedKeyFingerprint: got %q, want SHA256:abc", records[0].PushedKeyFingerprint)
+	}
+}
+
+func TestStore_ClearKeyPushed(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	mac := "aa:bb:cc:dd:ee:ff"
+	s.Upsert(samplePayload(mac, "host1", "192.168.1.10"))
+	s.MarkKeyPushed(mac, "alice", "SHA256:abc")
+
+	if err := s.ClearKeyPushed(mac); err != nil {
+		t.Fatalf("clear key pushed failed: %v", err)
+	}
+
+	records, _ := s.GetAll()
+	if records[0].SSHKeyPushed || records[0].SSHKeyPushedAt != nil || records[0].SSHKeyUser != "" || records[0].PushedKeyFingerprint != "" {
+		t.Errorf("expected key status cleared, got %+v", records[0])
+	}
+
+	if err := s.ClearKeyPushed("nonexistent"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
 }
 
 func TestStore_MarkKeyPushed_NotFound(t *testing.T) {
 	s, cleanup := testStore(t)
 	defer cleanup()
 
-	if err := s.MarkKeyPushed("nonexistent"); err == nil {
+	if err := s.MarkKeyPushed("nonexistent", "root", ""); err == nil {
 		t.Error("expected error for nonexistent MAC")
 	}
 }
@@ -192,3 +245,392 @@ func TestStore_Expiry(t *testing.T) {
 		t.Error("expected host to be inactive after expiry")
 	}
 }
+
+func TestStore_SetExpiry(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	s.Upsert(samplePayload("aa:bb:cc:dd:ee:ff", "host1", "192.168.1.10"))
+	s.RunExpiry(10*time.Millisecond, time.Hour, 0)
+
+	// Lowering the threshold applies from the next check
+	s.SetExpiry(0, 0)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		records, err := s.GetAll()
+		if err != nil {
+			t.Fatalf("getall failed: %v", err)
+		}
+		if !records[0].Active {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected host to expire after SetExpiry")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStore_MarkInactive(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	mac := "aa:bb:cc:dd:ee:ff"
+	s.Upsert(samplePayload(mac, "host1", "192.168.1.10"))
+
+	if err := s.MarkInactive(mac); err != nil {
+		t.Fatalf("mark inactive failed: %v", err)
+	}
+
+	records, err := s.GetAll()
+	if err != nil {
+		t.Fatalf("getall failed: %v", err)
+	}
+	if records[0].Active {
+		t.Error("expected host to be inactive")
+	}
+
+	if err := s.MarkInactive("nonexistent"); err == nil {
+		t.Error("expected error for nonexistent MAC")
+	}
+}
+
+func TestStore_RemoveHost(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	s.Upsert(samplePayload("aa:bb:cc:dd:ee:01", "host1", "192.168.1.10"))
+	s.Upsert(samplePayload("aa:bb:cc:dd:ee:02", "host2", "192.168.1.11"))
+
+	if err := s.RemoveHost("aa:bb:cc:dd:ee:01"); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+
+	records, err := s.GetAll()
+	if err != nil {
+		t.Fatalf("getall failed: %v", err)
+	}
+	if len(records) != 1 || records[0].Beacon.MACAddress != "aa:bb:cc:dd:ee:02" {
+		t.Errorf("expected only host2 to remain, got %+v", records)
+	}
+
+	if err := s.RemoveHost("aa:bb:cc:dd:ee:01"); err == nil {
+		t.Error("expected error removing an already removed host")
+	}
+}
+
+func TestStore_ContinuousSince(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	mac := "aa:bb:cc:dd:ee:ff"
+	payload := samplePayload(mac, "host1", "192.168.1.10")
+
+	get := func() HostRecord {
+		t.Helper()
+		records, err := s.GetAll()
+		if err != nil {
+			t.Fatalf("getall failed: %v", err)
+		}
+		return records[0]
+	}
+
+	s.Upsert(payload)
+	first := get()
+	if first.ContinuousSince == nil {
+		t.Fatal("expected ContinuousSince to be set on first beacon")
+	}
+
+	// Staying active preserves the original timestamp
+	time.Sleep(10 * time.Millisecond)
+	s.Upsert(payload)
+	if got := get().ContinuousSince; got == nil || !got.Equal(*first.ContinuousSince) {
+		t.Errorf("ContinuousSince changed while active: got %v, want %v", got, first.ContinuousSince)
+	}
+
+	// Going inactive clears it
+	s.expireStaleHosts(0)
+	if got := get().ContinuousSince; got != nil {
+		t.Errorf("expected ContinuousSince to be cleared when inactive, got %v", got)
+	}
+
+	// Coming back starts a new stretch; FirstSeen is unchanged
+	time.Sleep(10 * time.Millisecond)
+	s.Upsert(payload)
+	again := get()
+	if again.ContinuousSince == nil || !again.ContinuousSince.After(*first.ContinuousSince) {
+		t.Errorf("expected new ContinuousSince after %v, got %v", first.ContinuousSince, again.ContinuousSince)
+	}
+	if !again.FirstSeen.Equal(first.FirstSeen) {
+		t.Errorf("FirstSeen changed: got %v, want %v", again.FirstSeen, first.FirstSeen)
+	}
+
+	// A departure flip clears it as well
+	s.MarkInactive(mac)
+	if got := get().ContinuousSince; got != nil {
+		t.Errorf("expected ContinuousSince to be cleared on departure, got %v", got)
+	}
+}
+
+func TestStore_UpsertFromRecordsObservedIP(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	mac := "aa:bb:cc:dd:ee:ff"
+	s.UpsertFrom(samplePayload(mac, "host1", "192.168.1.10"), net.ParseIP("192.168.1.10"))
+
+	records, _ := s.GetAll()
+	if records[0].ObservedIP != "192.168.1.10" || records[0].IPMismatch() {
+		t.Errorf("expected matching observed IP, got %q", records[0].ObservedIP)
+	}
+
+	s.UpsertFrom(samplePayload(mac, "host1", "192.168.1.10"), net.ParseIP("192.168.1.66"))
+	records, _ = s.GetAll()
+	if !records[0].IPMismatch() {
+		t.Errorf("expected mismatch for observed IP %q", records[0].ObservedIP)
+	}
+
+	// Plain Upsert keeps the last observed address
+	s.Upsert(samplePayload(mac, "host1", "192.168.1.10"))
+	records, _ = s.GetAll()
+	if records[0].ObservedIP != "192.168.1.66" {
+		t.Errorf("ObservedIP: got %q, want 192.168.1.66", records[0].ObservedIP)
+	}
+}
+
+func TestStore_History(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	mac := "aa:bb:cc:dd:ee:ff"
+	if _, err := s.GetHistory(mac); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for unknown host, got %v", err)
+	}
+
+	for range HistorySize + 5 {
+		s.Upsert(samplePayload(mac, "host1", "192.168.1.10"))
+	}
+
+	history, err := s.GetHistory(mac)
+	if err != nil {
+		t.Fatalf("get history failed: %v", err)
+	}
+	if len(history) != HistorySize {
+		t.Fatalf("history length: got %d, want %d", len(history), HistorySize)
+	}
+	for i := 1; i < len(history); i++ {
+		if history[i].Before(history[i-1]) {
+			t.Fatalf("history out of order at %d", i)
+		}
+	}
+
+	records, _ := s.GetAll()
+	if last := history[len(history)-1]; !last.Equal(records[0].LastSeen) {
+		t.Errorf("latest observation %v, want LastSeen %v", last, records[0].LastSeen)
+	}
+
+	s.RemoveHost(mac)
+	s.Upsert(samplePayload(mac, "host1", "192.168.1.10"))
+	if history, _ := s.GetHistory(mac); len(history) != 1 {
+		t.Errorf("expected history to restart after removal, got %d entries", len(history))
+	}
+}
+
+func TestStore_PurgeOlderThan(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	s.Upsert(samplePayload("aa:bb:cc:dd:ee:01", "old", "192.168.1.10"))
+	s.Upsert(samplePayload("aa:bb:cc:dd:ee:02", "recent", "192.168.1.11"))
+	s.Upsert(samplePayload("aa:bb:cc:dd:ee:03", "old-but-active", "192.168.1.12"))
+	s.MarkInactive("aa:bb:cc:dd:ee:01")
+	s.MarkInactive("aa:bb:cc:dd:ee:02")
+
+	// Backdate two of the hosts past the purge threshold
+	err := s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(hostsBucket)
+		for _, mac := range []string{"aa:bb:cc:dd:ee:01", "aa:bb:cc:dd:ee:03"} {
+			var record HostRecord
+			json.Unmarshal(b.Get([]byte(mac)), &record)
+			record.LastSeen = time.Now().Add(-8 * 24 * time.Hour)
+			data, _ := json.Marshal(record)
+			if err := b.Put([]byte(mac), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("backdating records: %v", err)
+	}
+
+	n, err := s.PurgeOlderThan(7 * 24 * time.Hour)
+	if err != nil {
+		t.Fatalf("purge failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("purged %d hosts, want 1", n)
+	}
+
+	records, _ := s.GetAll()
+	names := make(map[string]bool)
+	for _, r := range records {
+		names[r.Beacon.Hostname] = true
+	}
+	if names["old"] || !names["recent"] || !names["old-but-active"] {
+		t.Errorf("unexpected hosts after purge: %v", names)
+	}
+	if _, err := s.GetHistory("aa:bb:cc:dd:ee:01"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected purged host's history to be gone, got %v", err)
+	}
+}
+
+func TestStore_ConcurrentUpsertAndGetAll(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	const writers, readers, rounds = 4, 4, 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				mac := fmt.Sprintf("aa:bb:cc:dd:%02x:%02x", w, i%10)
+				if err := s.Upsert(samplePayload(mac, "host", "192.168.1.10")); err != nil {
+					t.Errorf("upsert failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				records, err := s.GetAll()
+				if err != nil {
+					t.Errorf("getall failed: %v", err)
+					return
+				}
+				for _, rec := range records {
+					if rec.PacketCount == 0 {
+						t.Errorf("record %s read with no packets", rec.Beacon.MACAddress)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("concurrent upserts and reads did not finish (deadlock?)")
+	}
+
+	records, err := s.GetAll()
+	if err != nil {
+		t.Fatalf("getall failed: %v", err)
+	}
+	if len(records) != writers*10 {
+		t.Errorf("expected %d records, got %d", writers*10, len(records))
+	}
+}
+
+// beaconFields are the HostRecord fields applyBeacon may write; every
+// other field is local state that beacons must leave alone.
+var beaconFields = map[string]bool{
+	"Beacon": true, "FirstSeen": true, "LastSeen": true, "PacketCount": true,
+	"Active": true, "ObservedIP": true, "ContinuousSince": true,
+}
+
+func TestApplyBeacon_KeepsLocalFields(t *testing.T) {
+	pushedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	local := HostRecord{
+		SSHKeyPushed:   true,
+		SSHKeyPushedAt: &pushedAt,
+		SSHKeyUser:     "alice",
+		Conflict:       true,
+		Labels:         map[string]string{"role": "web"},
+		Notes:          "flaky PSU",
+
+		PushedKeyFingerprint: "SHA256:abc",
+	}
+
+	// Every field not written by beacons must be set above, so a new
+	// local field can't be added without deciding how beacons treat it
+	v := reflect.ValueOf(local)
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if !beaconFields[name] && v.Field(i).IsZero() {
+			t.Fatalf("local field %s is not covered by this test", name)
+		}
+	}
+
+	record := local
+	for i := 0; i < 3; i++ {
+		applyBeacon(&record, samplePayload("aa:bb:cc:dd:ee:ff", "host1", "192.168.1.10"), net.ParseIP("192.168.1.10"), time.Now())
+	}
+
+	got, want := reflect.ValueOf(record), reflect.ValueOf(local)
+	for i := 0; i < got.NumField(); i++ {
+		name := got.Type().Field(i).Name
+		if !beaconFields[name] && !reflect.DeepEqual(got.Field(i).Interface(), want.Field(i).Interface()) {
+			t.Errorf("%s changed: got %v, want %v", name, got.Field(i).Interface(), want.Field(i).Interface())
+		}
+	}
+	if record.PacketCount != 3 || !record.Active || record.ObservedIP != "192.168.1.10" {
+		t.Errorf("beacon fields not applied: %+v", record)
+	}
+}
+
+func TestStore_UpsertKeepsLocalFields(t *testing.T) {
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	mac := "aa:bb:cc:dd:ee:ff"
+	if err := s.UpsertFrom(samplePayload(mac, "host1", "192.168.1.10"), net.ParseIP("10.9.9.9")); err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if err := s.MarkKeyPushed(mac, "alice", ""); err != nil {
+		t.Fatalf("mark key pushed failed: %v", err)
+	}
+	if err := s.SetLabel(mac, "role", "web"); err != nil {
+		t.Fatalf("set label failed: %v", err)
+	}
+	before, _ := s.GetAll()
+
+	for i := 0; i < 5; i++ {
+		if err := s.Upsert(samplePayload(mac, "host1-renamed", "192.168.1.10")); err != nil {
+			t.Fatalf("upsert failed: %v", err)
+		}
+	}
+
+	records, err := s.GetAll()
+	if err != nil {
+		t.Fatalf("getall failed: %v", err)
+	}
+	r := records[0]
+	if !r.SSHKeyPushed || r.SSHKeyUser != "alice" || !r.SSHKeyPushedAt.Equal(*before[0].SSHKeyPushedAt) {
+		t.Errorf("key status lost: %+v", r)
+	}
+	if r.Labels["role"] != "web" {
+		t.Errorf("labels lost: %v", r.Labels)
+	}
+	if r.ObservedIP != "10.9.9.9" {
+		t.Errorf("observed IP lost without a source: got %q", r.ObservedIP)
+	}
+	if !r.FirstSeen.Equal(before[0].FirstSeen) {
+		t.Errorf("FirstSeen changed: got %v, want %v", r.FirstSeen, before[0].FirstSeen)
+	}
+	if r.Beacon.Hostname != "host1-renamed" || r.PacketCount != 6 {
+		t.Errorf("beacon fields not refreshed: hostname %q, packets %d", r.Beacon.Hostname, r.PacketCount)
+	}
+}
@@ -0,0 +1,66 @@
+package sysinfo
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// CachedCollector collects system information like Collect and
+// CollectInterface, but reuses the slow-changing fields (hostname, OS, CPU,
+// memory and disk sizes, GPUs) for up to a TTL instead of enumerating them
+// on every beacon. Addresses, uptime, load and free disk space are always
+// collected afresh. It is safe for concurrent use.
+type CachedCollector struct {
+	ttl time.Duration
+
+	mu          sync.Mutex
+	static      *SystemInfo
+	collectedAt time.Time
+}
+
+// NewCachedCollector returns a collector that refreshes the slow-changing
+// fields once they are older than ttl.
+func NewCachedCollector(ttl time.Duration) *CachedCollector {
+	return &CachedCollector{ttl: ttl}
+}
+
+// Collect is the cached equivalent of the package-level Collect.
+func (c *CachedCollector) Collect(networkRange string, ignore []string) (*SystemInfo, error) {
+	macAddr, ipAddr, err := getNetworkInfo(networkRange, ignore)
+	if err != nil {
+		return nil, err
+	}
+	return c.collect(macAddr, ipAddr), nil
+}
+
+// CollectInterface is the cached equivalent of the package-level
+// CollectInterface.
+func (c *CachedCollector) CollectInterface(iface Interface) *SystemInfo {
+	return c.collect(iface.MACAddress, iface.IPAddress)
+}
+
+func (c *CachedCollector) collect(macAddr, ipAddr string) *SystemInfo {
+	info := c.staticInfo()
+	info.MACAddress = macAddr
+	info.IPAddress = ipAddr
+	collectVolatile(&info)
+	return &info
+}
+
+// staticInfo returns a copy of the cached slow-changing fields, collecting
+// them first if they are missing or stale.
+func (c *CachedCollector) staticInfo() SystemInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.static == nil || time.Since(c.collectedAt) >= c.ttl {
+		static := &SystemInfo{}
+		collectStatic(static)
+		c.static = static
+		c.collectedAt = time.Now()
+	}
+	info := *c.static
+	info.GPUs = slices.Clone(c.static.GPUs)
+	return info
+}
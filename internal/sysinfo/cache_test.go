@@ -0,0 +1,63 @@
+package sysinfo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCachedCollector(t *testing.T) {
+	c := NewCachedCollector(time.Hour)
+	iface := Interface{Name: "eth0", MACAddress: "aa:bb:cc:dd:ee:ff", IPAddress: "10.0.0.5"}
+
+	first := c.CollectInterface(iface)
+	if first.MACAddress != iface.MACAddress || first.IPAddress != iface.IPAddress {
+		t.Errorf("got %s %s, want the interface's addresses", first.MACAddress, first.IPAddress)
+	}
+	if first.CPUCores == 0 || first.Arch == "" {
+		t.Errorf("expected static fields to be collected: %+v", first)
+	}
+
+	// Within the TTL the static fields aren't collected again
+	cachedAt := c.collectedAt
+	iface.IPAddress = "10.0.0.6"
+	second := c.CollectInterface(iface)
+	if !c.collectedAt.Equal(cachedAt) {
+		t.Error("static fields re-collected within the TTL")
+	}
+	if second.IPAddress != "10.0.0.6" {
+		t.Errorf("IPAddress: got %s, want the new address", second.IPAddress)
+	}
+	if second.Hostname != first.Hostname || second.CPUModel != first.CPUModel {
+		t.Errorf("static fields changed: %+v vs %+v", second, first)
+	}
+
+	// Callers get their own copy
+	second.Hostname = "changed"
+	if c.CollectInterface(iface).Hostname == "changed" {
+		t.Error("modifying a result changed the cache")
+	}
+
+	// Once stale, they are
+	c.collectedAt = time.Now().Add(-2 * time.Hour)
+	c.CollectInterface(iface)
+	if !c.collectedAt.After(cachedAt) {
+		t.Error("expected stale static fields to be re-collected")
+	}
+}
+
+// Fresh collection enumerates CPU, memory, disks and GPUs every time; the
+// cached collector only reads uptime, load and free space.
+func BenchmarkCollectFresh(b *testing.B) {
+	iface := Interface{MACAddress: "aa:bb:cc:dd:ee:ff", IPAddress: "10.0.0.5"}
+	for i := 0; i < b.N; i++ {
+		CollectInterface(iface)
+	}
+}
+
+func BenchmarkCollectCached(b *testing.B) {
+	c := NewCachedCollector(time.Hour)
+	iface := Interface{MACAddress: "aa:bb:cc:dd:ee:ff", IPAddress: "10.0.0.5"}
+	for i := 0; i < b.N; i++ {
+		c.CollectInterface(iface)
+	}
+}
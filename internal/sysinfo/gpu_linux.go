@@ -0,0 +1,88 @@
+package sysinfo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pciVendors names the vendors of common display adapters; others are
+// shown by their PCI vendor ID.
+var pciVendors = map[string]string{
+	"0x10de": "NVIDIA",
+	"0x1002": "AMD",
+	"0x8086": "Intel",
+	"0x1a03": "ASPEED",
+	"0x102b": "Matrox",
+	"0x1234": "QEMU",
+	"0x1af4": "Virtio",
+	"0x15ad": "VMware",
+	"0x1414": "Microsoft",
+}
+
+// platformGPUs lists GPUs from the NVIDIA driver's /proc files, which give
+// model names, and the PCI display controllers in /sys.
+func platformGPUs() []string {
+	return readGPUs("/proc/driver/nvidia/gpus", "/sys/bus/pci/devices")
+}
+
+// readGPUs lists the NVIDIA GPUs under nvidiaDir by model name, then every
+// other PCI display controller (class 0x03) under pciDir as
+// "<vendor> [<vendor id>:<device id>]". Unreadable entries are skipped.
+func readGPUs(nvidiaDir, pciDir string) []string {
+	var gpus []string
+
+	// The NVIDIA driver names each GPU directory by its PCI address
+	named := make(map[string]bool)
+	entries, _ := os.ReadDir(nvidiaDir)
+	for _, e := range entries {
+		if model := readNvidiaModel(filepath.Join(nvidiaDir, e.Name(), "information")); model != "" {
+			gpus = append(gpus, model)
+			named[strings.ToLower(e.Name())] = true
+		}
+	}
+
+	entries, _ = os.ReadDir(pciDir)
+	for _, e := range entries {
+		if named[strings.ToLower(e.Name())] {
+			continue
+		}
+		dir := filepath.Join(pciDir, e.Name())
+		if !strings.HasPrefix(readSysfs(dir, "class"), "0x03") {
+			continue
+		}
+		vendor, device := readSysfs(dir, "vendor"), readSysfs(dir, "device")
+		name, ok := pciVendors[vendor]
+		if !ok {
+			name = "GPU"
+		}
+		gpus = append(gpus, fmt.Sprintf("%s [%s:%s]", name,
+			strings.TrimPrefix(vendor, "0x"), strings.TrimPrefix(device, "0x")))
+	}
+	return gpus
+}
+
+// readNvidiaModel returns the "Model:" line of an NVIDIA driver
+// information file.
+func readNvidiaModel(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if model, ok := strings.CutPrefix(line, "Model:"); ok {
+			return strings.TrimSpace(model)
+		}
+	}
+	return ""
+}
+
+// readSysfs returns the trimmed contents of a sysfs attribute, or "".
+func readSysfs(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,56 @@
+package sysinfo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+}
+
+func TestReadGPUs(t *testing.T) {
+	nvidia := t.TempDir()
+	writeFiles(t, nvidia, map[string]string{
+		"0000:65:00.0/information": "Model: \t\t NVIDIA A100-SXM4-40GB\nIRQ:   \t\t 140\n",
+	})
+	pci := t.TempDir()
+	writeFiles(t, pci, map[string]string{
+		// The A100 again, already named by the driver
+		"0000:65:00.0/class":  "0x030200\n",
+		"0000:65:00.0/vendor": "0x10de\n",
+		"0000:65:00.0/device": "0x20b0\n",
+		// Onboard BMC graphics
+		"0000:03:00.0/class":  "0x030000\n",
+		"0000:03:00.0/vendor": "0x1a03\n",
+		"0000:03:00.0/device": "0x2000\n",
+		// A NIC, not a display controller
+		"0000:01:00.0/class":  "0x020000\n",
+		"0000:01:00.0/vendor": "0x8086\n",
+		"0000:01:00.0/device": "0x1533\n",
+	})
+
+	got := readGPUs(nvidia, pci)
+	want := []string{"NVIDIA A100-SXM4-40GB", "ASPEED [1a03:2000]"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestReadGPUs_Missing(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	if got := readGPUs(missing, missing); got != nil {
+		t.Errorf("expected no GPUs, got %q", got)
+	}
+}
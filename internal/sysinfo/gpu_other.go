@@ -0,0 +1,8 @@
+//go:build !linux
+
+package sysinfo
+
+// platformGPUs isn't implemented outside Linux yet.
+func platformGPUs() []string {
+	return nil
+}
@@ -0,0 +1,20 @@
+package sysinfo
+
+import "strings"
+
+// darwinVirtualPrefixes name macOS pseudo-interfaces: VPN tunnels (utun,
+// ipsec), AirDrop and its low-latency link (awdl, llw), tunnelling
+// (gif, stf) and Apple internal links (anpi, ap). Some carry link-layer
+// addresses and stay up, but none is a route to the LAN.
+var darwinVirtualPrefixes = []string{"utun", "ipsec", "awdl", "llw", "gif", "stf", "anpi", "ap"}
+
+// virtualInterface reports whether name is a macOS pseudo-interface, so
+// that en0/en1 are picked instead.
+func virtualInterface(name string) bool {
+	for _, prefix := range darwinVirtualPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
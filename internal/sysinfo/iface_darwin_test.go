@@ -0,0 +1,16 @@
+package sysinfo
+
+import "testing"
+
+func TestVirtualInterface_Darwin(t *testing.T) {
+	for _, name := range []string{"utun0", "utun3", "ipsec0", "awdl0", "llw0", "anpi1", "ap1"} {
+		if !virtualInterface(name) {
+			t.Errorf("expected %s to be skipped", name)
+		}
+	}
+	for _, name := range []string{"en0", "en1", "bridge0"} {
+		if virtualInterface(name) {
+			t.Errorf("expected %s to be usable", name)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !darwin
+
+package sysinfo
+
+// virtualInterface reports whether name is a pseudo-interface to skip.
+// Only macOS needs name-based filtering; elsewhere the flags suffice.
+func virtualInterface(name string) bool {
+	return false
+}
@@ -0,0 +1,25 @@
+package sysinfo
+
+import (
+	"runtime"
+
+	"github.com/shirou/gopsutil/v3/host"
+)
+
+// getOSInfo retrieves OS name and kernel version. How they are derived
+// from host.Info differs per platform (see platformOSInfo).
+func getOSInfo() (string, string) {
+	hostInfo, err := host.Info()
+	if err != nil {
+		return runtime.GOOS, ""
+	}
+	return platformOSInfo(hostInfo)
+}
+
+// platformVersion joins a platform name and version, e.g. "darwin 14.2".
+func platformVersion(info *host.InfoStat) string {
+	if info.PlatformVersion == "" {
+		return info.Platform
+	}
+	return info.Platform + " " + info.PlatformVersion
+}
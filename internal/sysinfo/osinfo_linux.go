@@ -0,0 +1,34 @@
+package sysinfo
+
+import (
+	"os"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/host"
+)
+
+// platformOSInfo names the distribution by its os-release PRETTY_NAME
+// (e.g. "Ubuntu 22.04.4 LTS"), falling back to gopsutil's platform.
+func platformOSInfo(info *host.InfoStat) (string, string) {
+	osName := platformVersion(info)
+	if prettyName := readOSReleasePrettyName(); prettyName != "" {
+		osName = prettyName
+	}
+	return osName, info.KernelVersion
+}
+
+// readOSReleasePrettyName parses /etc/os-release for the PRETTY_NAME field.
+func readOSReleasePrettyName() string {
+	data, err := os.ReadFile("/etc/os-release")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "PRETTY_NAME=") {
+			val := strings.TrimPrefix(line, "PRETTY_NAME=")
+			val = strings.Trim(val, "\"")
+			return val
+		}
+	}
+	return ""
+}
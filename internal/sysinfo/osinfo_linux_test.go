@@ -0,0 +1,25 @@
+package sysinfo
+
+import (
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/host"
+)
+
+func TestReadOSReleasePrettyName(t *testing.T) {
+	name := readOSReleasePrettyName()
+	t.Logf("PRETTY_NAME: %q", name)
+}
+
+func TestPlatformOSInfo_Linux(t *testing.T) {
+	osName, kernel := platformOSInfo(&host.InfoStat{Platform: "ubuntu", PlatformVersion: "22.04", KernelVersion: "5.15.0"})
+	if want := readOSReleasePrettyName(); want != "" && osName != want {
+		t.Errorf("OS name: got %q, want os-release %q", osName, want)
+	}
+	if osName == "" {
+		t.Error("OS name is empty")
+	}
+	if kernel != "5.15.0" {
+		t.Errorf("kernel: got %q, want 5.15.0", kernel)
+	}
+}
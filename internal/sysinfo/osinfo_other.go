@@ -0,0 +1,13 @@
+//go:build !linux && !windows
+
+package sysinfo
+
+import (
+	"github.com/shirou/gopsutil/v3/host"
+)
+
+// platformOSInfo names the OS by gopsutil's platform and version, e.g.
+// "darwin 14.2" or "freebsd 14.0".
+func platformOSInfo(info *host.InfoStat) (string, string) {
+	return platformVersion(info), info.KernelVersion
+}
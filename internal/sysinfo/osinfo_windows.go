@@ -0,0 +1,21 @@
+package sysinfo
+
+import (
+	"github.com/shirou/gopsutil/v3/host"
+)
+
+// platformOSInfo uses the product name as the OS (e.g. "Microsoft Windows
+// 11 Pro") and the build as the kernel (e.g. "10.0.22631 Build 22631"):
+// appending the version to the name, as other platforms do, would repeat
+// it.
+func platformOSInfo(info *host.InfoStat) (string, string) {
+	osName := info.Platform
+	if osName == "" {
+		osName = "Windows"
+	}
+	kernel := info.KernelVersion
+	if kernel == "" {
+		kernel = info.PlatformVersion
+	}
+	return osName, kernel
+}
@@ -0,0 +1,25 @@
+package sysinfo
+
+import (
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/host"
+)
+
+func TestPlatformOSInfo_Windows(t *testing.T) {
+	osName, kernel := platformOSInfo(&host.InfoStat{
+		Platform:        "Microsoft Windows 11 Pro",
+		PlatformVersion: "10.0.22631 Build 22631",
+		KernelVersion:   "10.0.22631 Build 22631",
+	})
+	if osName != "Microsoft Windows 11 Pro" {
+		t.Errorf("OS name: got %q", osName)
+	}
+	if kernel != "10.0.22631 Build 22631" {
+		t.Errorf("kernel: got %q", kernel)
+	}
+
+	if osName, _ := platformOSInfo(&host.InfoStat{}); osName != "Windows" {
+		t.Errorf("empty platform: got %q, want Windows", osName)
+	}
+}
@@ -6,12 +6,14 @@ import (
 	"math"
 	"net"
 	"os"
+	"path"
 	"runtime"
 	"strings"
 
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
 	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/load"
 	"github.com/shirou/gopsutil/v3/mem"
 )
 
@@ -28,28 +30,52 @@ type SystemInfo struct {
 	CPUCores   int
 	MemoryGB   float64
 	DiskCount  int
+	Uptime     uint64 // seconds, 0 if unknown
+
+	// Size and free space of the root filesystem, 0 if unknown
+	DiskTotalGB float64
+	DiskFreeGB  float64
+	// GPUs lists display adapters, best effort: empty if none were found
+	// or they couldn't be listed
+	GPUs []string
+
+	// Load averages; all zero where the platform doesn't report them
+	Load1  float64
+	Load5  float64
+	Load15 float64
 }
 
 // Collect gathers local system information for an interface matching the provided network range.
-// If networkRange is empty, it falls back to the first non-loopback interface.
-func Collect(networkRange string) (*SystemInfo, error) {
-	macAddr, ipAddr, err := getNetworkInfo(networkRange)
+// If networkRange is empty, it uses the default-route interface (see matchInterface).
+// Interfaces whose names match an ignore pattern (see IgnoredInterface) are never used.
+func Collect(networkRange string, ignore []string) (*SystemInfo, error) {
+	macAddr, ipAddr, err := getNetworkInfo(networkRange, ignore)
 	if err != nil {
 		return nil, err
 	}
+	return collect(macAddr, ipAddr), nil
+}
 
-	hostname, _ := os.Hostname()
-	osName, kernel := getOSInfo()
+// CollectInterface gathers local system information, reporting the given
+// interface's MAC and IP address in place of the auto-detected ones.
+func CollectInterface(iface Interface) *SystemInfo {
+	return collect(iface.MACAddress, iface.IPAddress)
+}
 
-	info := &SystemInfo{
-		MACAddress: macAddr,
-		IPAddress:  ipAddr,
-		Hostname:   hostname,
-		OSName:     osName,
-		Kernel:     kernel,
-		Arch:       runtime.GOARCH,
-		CPUCores:   runtime.NumCPU(),
-	}
+func collect(macAddr, ipAddr string) *SystemInfo {
+	info := &SystemInfo{MACAddress: macAddr, IPAddress: ipAddr}
+	collectStatic(info)
+	collectVolatile(info)
+	return info
+}
+
+// collectStatic fills in the fields that rarely change while the process
+// runs: names, CPU, memory size, partitions, disk size and GPUs.
+func collectStatic(info *SystemInfo) {
+	info.Hostname, _ = os.Hostname()
+	info.OSName, info.Kernel = getOSInfo()
+	info.Arch = runtime.GOARCH
+	info.CPUCores = runtime.NumCPU()
 
 	// CPU model
 	cpuInfo, err := cpu.Info()
@@ -60,7 +86,7 @@ func Collect(networkRange string) (*SystemInfo, error) {
 	// Memory
 	memInfo, err := mem.VirtualMemory()
 	if err == nil {
-		info.MemoryGB = math.Round(float64(memInfo.Total)/(1024*1024*1024)*100) / 100
+		info.MemoryGB = toGB(memInfo.Total)
 	}
 
 	// Disk count
@@ -69,37 +95,306 @@ func Collect(networkRange string) (*SystemInfo, error) {
 		info.DiskCount = len(partitions)
 	}
 
-	return info, nil
+	// Root filesystem size
+	if usage, err := disk.Usage(systemRoot()); err == nil {
+		info.DiskTotalGB = toGB(usage.Total)
+	}
+
+	// GPUs (Linux only for now)
+	info.GPUs = platformGPUs()
+}
+
+// collectVolatile fills in the fields that change from one beacon to the
+// next: uptime, load and free disk space.
+func collectVolatile(info *SystemInfo) {
+	// Uptime
+	if uptime, err := host.Uptime(); err == nil {
+		info.Uptime = uptime
+	}
+
+	// Load average (not available on Windows)
+	if avg, err := load.Avg(); err == nil {
+		info.Load1, info.Load5, info.Load15 = avg.Load1, avg.Load5, avg.Load15
+	}
+
+	// Root filesystem free space
+	if usage, err := disk.Usage(systemRoot()); err == nil {
+		info.DiskFreeGB = toGB(usage.Free)
+	}
+}
+
+// toGB converts bytes to GiB, rounded to two decimal places.
+func toGB(bytes uint64) float64 {
+	return math.Round(float64(bytes)/(1024*1024*1024)*100) / 100
+}
+
+// systemRoot returns the filesystem whose space is reported: "/", or the
+// system drive on Windows.
+func systemRoot() string {
+	if runtime.GOOS != "windows" {
+		return "/"
+	}
+	drive := os.Getenv("SystemDrive")
+	if drive == "" {
+		drive = "C:"
+	}
+	return drive + `\`
+}
+
+// Interface describes an up, non-loopback interface with an IPv4 address.
+type Interface struct {
+	Name       string
+	MACAddress string
+	IPAddress  string
+	// Network is the interface's address and netmask: IPv4, except from
+	// InterfaceInRange with an IPv6 range.
+	Network *net.IPNet
+}
+
+// Interfaces returns the named interfaces, or every eligible interface if
+// names is empty, leaving out those matching an ignore pattern. Each
+// interface is reported with its first IPv4 address. A named interface
+// that is missing, down or has no IPv4 address is an error.
+func Interfaces(names, ignore []string) ([]Interface, error) {
+	if len(names) > 0 {
+		var result []Interface
+		for _, name := range names {
+			iface, err := net.InterfaceByName(name)
+			if err != nil {
+				return nil, fmt.Errorf("finding interface %s: %w", name, err)
+			}
+			info, ok := interfaceInfo(*iface)
+			if !ok {
+				return nil, fmt.Errorf("interface %s is down or has no IPv4 address", name)
+			}
+			result = append(result, info)
+		}
+		return result, nil
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Interface
+	for _, iface := range usableInterfaces(ifaces, ignore) {
+		if info, ok := interfaceInfo(iface); ok {
+			result = append(result, info)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no suitable network interface found")
+	}
+	return result, nil
+}
+
+func interfaceInfo(iface net.Interface) (Interface, bool) {
+	if !usableInterface(iface) {
+		return Interface{}, false
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return Interface{}, false
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.To4() == nil {
+			continue
+		}
+		return Interface{
+			Name:       iface.Name,
+			MACAddress: iface.HardwareAddr.String(),
+			IPAddress:  ipNet.IP.To4().String(),
+			Network:    &net.IPNet{IP: ipNet.IP.To4(), Mask: ipNet.Mask[len(ipNet.Mask)-net.IPv4len:]},
+		}, true
+	}
+	return Interface{}, false
+}
+
+// IgnoredInterface reports whether name matches one of the glob patterns
+// (path.Match syntax, e.g. "docker*" or "veth?"), ignoring case. Malformed
+// patterns match nothing.
+func IgnoredInterface(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(p), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// usableInterfaces returns the interfaces of ifaces that can carry beacons
+// and don't match an ignore pattern.
+func usableInterfaces(ifaces []net.Interface, ignore []string) []net.Interface {
+	var usable []net.Interface
+	for _, iface := range ifaces {
+		if usableInterface(iface) && !IgnoredInterface(iface.Name, ignore) {
+			usable = append(usable, iface)
+		}
+	}
+	return usable
+}
+
+// usableInterface reports whether iface can carry beacons: a non-loopback
+// interface with a MAC address that is up and has a link (FlagRunning).
+// An interface can be administratively up without a link, like a VPN
+// tunnel that isn't connected.
+func usableInterface(iface net.Interface) bool {
+	if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagRunning == 0 {
+		return false
+	}
+	if len(iface.HardwareAddr) == 0 {
+		return false
+	}
+	return !virtualInterface(iface.Name)
 }
 
 // getNetworkInfo returns the MAC and IPv4 address of an interface.
 // If networkRange is provided (CIDR), it finds an interface matching that range.
-// Otherwise, it returns the first non-loopback interface.
-func getNetworkInfo(networkRange string) (string, string, error) {
+// Otherwise, it prefers the interface carrying the default route.
+func getNetworkInfo(networkRange string, ignore []string) (string, string, error) {
+	iface, ip, err := matchInterface(networkRange, ignore)
+	if err != nil {
+		return "", "", err
+	}
+	return iface.HardwareAddr.String(), ip.String(), nil
+}
+
+// InterfaceName returns the name of the interface whose address falls in
+// networkRange, as used by Collect. IPv6 discovery needs it to scope
+// link-local multicast to the right link.
+func InterfaceName(networkRange string, ignore []string) (string, error) {
+	iface, _, err := matchInterface(networkRange, ignore)
+	if err != nil {
+		return "", err
+	}
+	return iface.Name, nil
+}
+
+// InterfaceInRange returns the named interface with its address in
+// networkRange, or with its first IPv4 address if networkRange is empty.
+// It pins the node to one interface when several have an address in the
+// same range. Ignore patterns don't apply to an interface named outright.
+func InterfaceInRange(name, networkRange string) (Interface, error) {
 	var targetNet *net.IPNet
 	if networkRange != "" {
 		_, tn, err := net.ParseCIDR(networkRange)
 		if err != nil {
-			return "", "", fmt.Errorf("parsing network range %s: %w", networkRange, err)
+			return Interface{}, fmt.Errorf("parsing network range %s: %w", networkRange, err)
 		}
 		targetNet = tn
 	}
 
-	ifaces, err := net.Interfaces()
+	iface, err := net.InterfaceByName(name)
 	if err != nil {
-		return "", "", err
+		return Interface{}, fmt.Errorf("finding interface %s: %w", name, err)
+	}
+	if !usableInterface(*iface) {
+		return Interface{}, fmt.Errorf("interface %s is down or can't carry beacons", name)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return Interface{}, fmt.Errorf("reading addresses of %s: %w", name, err)
 	}
 
-	for _, iface := range ifaces {
-		if iface.Flags&net.FlagLoopback != 0 {
-			continue
+	network := rangeAddr(addrs, targetNet)
+	if network == nil {
+		if networkRange != "" {
+			return Interface{}, fmt.Errorf("interface %s has no address in network range %s", name, networkRange)
 		}
-		if iface.Flags&net.FlagUp == 0 {
+		return Interface{}, fmt.Errorf("interface %s has no IPv4 address", name)
+	}
+	return Interface{
+		Name:       iface.Name,
+		MACAddress: iface.HardwareAddr.String(),
+		IPAddress:  network.IP.String(),
+		Network:    network,
+	}, nil
+}
+
+// rangeAddr returns the first address in addrs usable for targetNet (see
+// selectIP) with its netmask, or nil if there is none.
+func rangeAddr(addrs []net.Addr, targetNet *net.IPNet) *net.IPNet {
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
 			continue
 		}
-		if iface.HardwareAddr == nil || len(iface.HardwareAddr) == 0 {
+		if ip := selectIP(ipNet.IP, targetNet); ip != nil {
+			return &net.IPNet{IP: ip, Mask: ipNet.Mask[len(ipNet.Mask)-len(ip):]}
+		}
+	}
+	return nil
+}
+
+// AutoRange returns the IPv4 network, in CIDR form, of the named interface
+// or, if name is empty, of the interface Collect picks without a network
+// range: preferably the one carrying the default route. It's how a network
+// range of "auto" is resolved.
+func AutoRange(name string, ignore []string) (string, error) {
+	if name != "" {
+		iface, err := InterfaceInRange(name, "")
+		if err != nil {
+			return "", fmt.Errorf("auto-detecting network range: %w", err)
+		}
+		n := iface.Network
+		return (&net.IPNet{IP: n.IP.Mask(n.Mask), Mask: n.Mask}).String(), nil
+	}
+
+	iface, ip, err := matchInterface("", ignore)
+	if err != nil {
+		return "", fmt.Errorf("auto-detecting network range: no up interface with an IPv4 address (%w); set network_range to a CIDR", err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", fmt.Errorf("auto-detecting network range: reading addresses of %s: %w", iface.Name, err)
+	}
+	network := addrNetwork(addrs, ip)
+	if network == nil {
+		return "", fmt.Errorf("auto-detecting network range: %s has no IPv4 netmask for %s", iface.Name, ip)
+	}
+	return network.String(), nil
+}
+
+// addrNetwork returns the IPv4 network of the address in addrs equal to
+// ip, or nil if there is none.
+func addrNetwork(addrs []net.Addr, ip net.IP) *net.IPNet {
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.To4() == nil || !ipNet.IP.Equal(ip) {
 			continue
 		}
+		mask := ipNet.Mask[len(ipNet.Mask)-net.IPv4len:]
+		return &net.IPNet{IP: ipNet.IP.To4().Mask(mask), Mask: mask}
+	}
+	return nil
+}
+
+// matchInterface finds an up, non-loopback interface with an address in
+// networkRange. An IPv6 range matches IPv6 addresses only; an IPv4 or
+// empty range matches IPv4 addresses only. Without a range, the interface
+// carrying the default route is preferred, so a docker or bridge interface
+// listed first doesn't win; failing that, the first one found is used.
+func matchInterface(networkRange string, ignore []string) (net.Interface, net.IP, error) {
+	var targetNet *net.IPNet
+	if networkRange != "" {
+		_, tn, err := net.ParseCIDR(networkRange)
+		if err != nil {
+			return net.Interface{}, nil, fmt.Errorf("parsing network range %s: %w", networkRange, err)
+		}
+		targetNet = tn
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return net.Interface{}, nil, err
+	}
+
+	var candidates []candidate
+	for _, iface := range usableInterfaces(ifaces, ignore) {
 
 		addrs, err := iface.Addrs()
 		if err != nil {
@@ -111,67 +406,81 @@ func getNetworkInfo(networkRange string) (string, string, error) {
 			if !ok {
 				continue
 			}
-			ip := ipNet.IP.To4()
-			if ip == nil {
-				continue
-			}
-
-			// If target network provided, check if IP fits
-			if targetNet != nil {
-				if targetNet.Contains(ip) {
-					return iface.HardwareAddr.String(), ip.String(), nil
-				}
-				continue
+			if ip := selectIP(ipNet.IP, targetNet); ip != nil {
+				candidates = append(candidates, candidate{iface, ip})
 			}
+		}
+	}
 
-			// No target network, return first non-loopback IPv4
-			return iface.HardwareAddr.String(), ip.String(), nil
+	if len(candidates) == 0 {
+		if networkRange != "" {
+			return net.Interface{}, nil, fmt.Errorf("no interface found matching network range %s", networkRange)
 		}
+		return net.Interface{}, nil, fmt.Errorf("no suitable network interface found")
 	}
 
-	if networkRange != "" {
-		return "", "", fmt.Errorf("no interface found matching network range %s", networkRange)
+	best := candidates[0]
+	if targetNet == nil {
+		best = preferRoute(candidates, defaultRouteIP())
 	}
-	return "", "", fmt.Errorf("no suitable network interface found")
+	return best.iface, best.ip, nil
 }
 
+// candidate is an interface address matchInterface could report.
+type candidate struct {
+	iface net.Interface
+	ip    net.IP
+}
 
-// getOSInfo retrieves OS name and kernel version.
-func getOSInfo() (string, string) {
-	var osName, kernel string
-
-	hostInfo, err := host.Info()
-	if err == nil {
-		osName = hostInfo.Platform
-		if hostInfo.PlatformVersion != "" {
-			osName += " " + hostInfo.PlatformVersion
-		}
-		kernel = hostInfo.KernelVersion
-	} else {
-		osName = runtime.GOOS
-	}
-
-	if runtime.GOOS == "linux" {
-		if prettyName := readOSReleasePrettyName(); prettyName != "" {
-			osName = prettyName
+// preferRoute returns the candidate holding routeIP, or the first one if
+// none does (or routeIP is nil).
+func preferRoute(candidates []candidate, routeIP net.IP) candidate {
+	for _, c := range candidates {
+		if routeIP != nil && c.ip.Equal(routeIP) {
+			return c
 		}
 	}
-
-	return osName, kernel
+	return candidates[0]
 }
 
-// readOSReleasePrettyName parses /etc/os-release for the PRETTY_NAME field.
-func readOSReleasePrettyName() string {
-	data, err := os.ReadFile("/etc/os-release")
+// routeSentinel is the address whose route decides the default interface.
+// It's a documentation address (TEST-NET-1): connecting a UDP socket only
+// consults the routing table, nothing is sent, and any address off the
+// local networks takes the default route.
+const routeSentinel = "192.0.2.1:9"
+
+// defaultRouteIP returns the local IPv4 address the default route uses,
+// or nil if there is no default route.
+func defaultRouteIP() net.IP {
+	conn, err := net.Dial("udp4", routeSentinel)
 	if err != nil {
-		return ""
+		return nil
 	}
-	for _, line := range strings.Split(string(data), "\n") {
-		if strings.HasPrefix(line, "PRETTY_NAME=") {
-			val := strings.TrimPrefix(line, "PRETTY_NAME=")
-			val = strings.Trim(val, "\"")
-			return val
+	defer conn.Close()
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return nil
+	}
+	return addr.IP.To4()
+}
+
+// selectIP returns ip (in its 4-byte form if IPv4) if it is usable for
+// targetNet, or nil. Without a target network, the first IPv4 address wins.
+func selectIP(ip net.IP, targetNet *net.IPNet) net.IP {
+	if targetNet != nil && targetNet.IP.To4() == nil {
+		// IPv6 range
+		if ip.To4() != nil || !targetNet.Contains(ip) {
+			return nil
 		}
+		return ip
+	}
+
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return nil
+	}
+	if targetNet != nil && !targetNet.Contains(ip4) {
+		return nil
 	}
-	return ""
+	return ip4
 }
@@ -6,7 +6,7 @@ import (
 )
 
 func TestCollect(t *testing.T) {
-	info, err := Collect("")
+	info, err := Collect("", nil)
 	if err != nil {
 		t.Fatalf("Collect failed: %v", err)
 	}
@@ -25,7 +25,7 @@ func TestCollect(t *testing.T) {
 
 func TestCollect_WithNetworkRange(t *testing.T) {
 	// First get any IP to know what range to test with
-	info, err := Collect("")
+	info, err := Collect("", nil)
 	if err != nil {
 		t.Skip("skipping network range test: no interface found")
 	}
@@ -46,7 +46,7 @@ func TestCollect_WithNetworkRange(t *testing.T) {
 	}
 
 	t.Logf("Testing with CIDR: %s", cidr)
-	info2, err := Collect(cidr)
+	info2, err := Collect(cidr, nil)
 	if err != nil {
 		t.Fatalf("Collect with CIDR %s failed: %v", cidr, err)
 	}
@@ -56,8 +56,208 @@ func TestCollect_WithNetworkRange(t *testing.T) {
 	}
 }
 
-func TestReadOSReleasePrettyName(t *testing.T) {
-	name := readOSReleasePrettyName()
-	t.Logf("PRETTY_NAME: %q", name)
+func TestInterfaces(t *testing.T) {
+	ifaces, err := Interfaces(nil, nil)
+	if err != nil {
+		t.Skipf("skipping interfaces test: %v", err)
+	}
+
+	for _, iface := range ifaces {
+		ip := net.ParseIP(iface.IPAddress)
+		if ip == nil || ip.To4() == nil {
+			t.Errorf("%s: invalid IPv4 address %q", iface.Name, iface.IPAddress)
+			continue
+		}
+		if !iface.Network.Contains(ip) {
+			t.Errorf("%s: network %s does not contain %s", iface.Name, iface.Network, ip)
+		}
+	}
+
+	// Looking an interface up by name yields the same addresses
+	named, err := Interfaces([]string{ifaces[0].Name}, nil)
+	if err != nil {
+		t.Fatalf("Interfaces(%s) failed: %v", ifaces[0].Name, err)
+	}
+	if named[0].IPAddress != ifaces[0].IPAddress {
+		t.Errorf("IP mismatch: got %s, want %s", named[0].IPAddress, ifaces[0].IPAddress)
+	}
 }
 
+func TestInterfaces_Unknown(t *testing.T) {
+	if _, err := Interfaces([]string{"lanmon-does-not-exist0"}, nil); err == nil {
+		t.Error("expected error for unknown interface")
+	}
+}
+
+func TestSelectIP(t *testing.T) {
+	_, v4Net, _ := net.ParseCIDR("10.51.240.0/23")
+	_, v6Net, _ := net.ParseCIDR("fd00:51::/64")
+
+	tests := []struct {
+		name   string
+		ip     string
+		target *net.IPNet
+		want   string
+	}{
+		{"v4 in v4 range", "10.51.241.7", v4Net, "10.51.241.7"},
+		{"v4 outside v4 range", "10.0.0.1", v4Net, ""},
+		{"v6 against v4 range", "fd00:51::7", v4Net, ""},
+		{"v6 in v6 range", "fd00:51::7", v6Net, "fd00:51::7"},
+		{"v6 outside v6 range", "fd00:52::7", v6Net, ""},
+		{"v4 against v6 range", "10.51.241.7", v6Net, ""},
+		{"v4 without range", "10.0.0.1", nil, "10.0.0.1"},
+		{"v6 without range", "fd00:51::7", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := selectIP(net.ParseIP(tt.ip), tt.target)
+			if (got == nil && tt.want != "") || (got != nil && got.String() != tt.want) {
+				t.Errorf("selectIP(%s): got %v, want %q", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsableInterface(t *testing.T) {
+	mac := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	up := net.FlagUp | net.FlagRunning | net.FlagBroadcast
+
+	tests := []struct {
+		name  string
+		iface net.Interface
+		want  bool
+	}{
+		{"up with link", net.Interface{Name: "eth0", Flags: up, HardwareAddr: mac}, true},
+		{"up without link", net.Interface{Name: "eth1", Flags: net.FlagUp, HardwareAddr: mac}, false},
+		{"down", net.Interface{Name: "eth2", HardwareAddr: mac}, false},
+		{"loopback", net.Interface{Name: "lo", Flags: up | net.FlagLoopback, HardwareAddr: mac}, false},
+		{"no MAC", net.Interface{Name: "tun0", Flags: up | net.FlagPointToPoint}, false},
+	}
+	for _, tt := range tests {
+		if got := usableInterface(tt.iface); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPreferRoute(t *testing.T) {
+	docker := candidate{net.Interface{Name: "docker0"}, net.ParseIP("172.17.0.1").To4()}
+	lan := candidate{net.Interface{Name: "eth0"}, net.ParseIP("192.168.1.20").To4()}
+	candidates := []candidate{docker, lan}
+
+	if got := preferRoute(candidates, net.ParseIP("192.168.1.20")); got.iface.Name != "eth0" {
+		t.Errorf("with route: got %s, want eth0", got.iface.Name)
+	}
+	if got := preferRoute(candidates, nil); got.iface.Name != "docker0" {
+		t.Errorf("no route: got %s, want the first interface", got.iface.Name)
+	}
+	if got := preferRoute(candidates, net.ParseIP("10.8.0.2")); got.iface.Name != "docker0" {
+		t.Errorf("route via an unusable interface: got %s, want the first interface", got.iface.Name)
+	}
+}
+
+func TestUsableInterfaces_Ignore(t *testing.T) {
+	up := net.FlagUp | net.FlagRunning | net.FlagBroadcast
+	iface := func(name string) net.Interface {
+		return net.Interface{Name: name, Flags: up, HardwareAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, 1}}
+	}
+	ifaces := []net.Interface{iface("docker0"), iface("virbr0"), iface("VETH1a2b"), iface("eth0")}
+
+	got := usableInterfaces(ifaces, []string{"docker*", "virbr*", "veth*"})
+	if len(got) != 1 || got[0].Name != "eth0" {
+		t.Errorf("got %v, want only eth0", got)
+	}
+	if got := usableInterfaces(ifaces, nil); len(got) != 4 {
+		t.Errorf("no patterns: got %d interfaces, want 4", len(got))
+	}
+}
+
+func TestIgnoredInterface(t *testing.T) {
+	patterns := []string{"docker*", "br-*"}
+	for name, want := range map[string]bool{
+		"docker0":     true,
+		"Docker0":     true,
+		"br-1a2b3c4d": true,
+		"eth0":        false,
+		"enp3s0":      false,
+		"mydocker0":   false,
+	} {
+		if got := IgnoredInterface(name, patterns); got != want {
+			t.Errorf("IgnoredInterface(%q): got %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestAddrNetwork(t *testing.T) {
+	addrs := []net.Addr{
+		&net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)},
+		&net.IPNet{IP: net.ParseIP("10.51.241.7"), Mask: net.CIDRMask(23, 32)},
+		// A 16-byte mask, as some platforms report for IPv4
+		&net.IPNet{IP: net.ParseIP("192.168.7.20"), Mask: net.CIDRMask(120, 128)},
+	}
+
+	for ip, want := range map[string]string{
+		"10.51.241.7":  "10.51.240.0/23",
+		"192.168.7.20": "192.168.7.0/24",
+	} {
+		got := addrNetwork(addrs, net.ParseIP(ip).To4())
+		if got == nil || got.String() != want {
+			t.Errorf("%s: got %v, want %s", ip, got, want)
+		}
+	}
+	if got := addrNetwork(addrs, net.ParseIP("172.16.0.1")); got != nil {
+		t.Errorf("unknown address: got %v, want nil", got)
+	}
+}
+
+func TestAutoRange(t *testing.T) {
+	info, err := Collect("", nil)
+	if err != nil {
+		t.Skip("skipping: no interface found")
+	}
+
+	cidr, err := AutoRange("", nil)
+	if err != nil {
+		t.Fatalf("AutoRange: %v", err)
+	}
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatalf("AutoRange returned %q: %v", cidr, err)
+	}
+	if !network.Contains(net.ParseIP(info.IPAddress)) {
+		t.Errorf("AutoRange %s doesn't contain the collected address %s", cidr, info.IPAddress)
+	}
+}
+
+func TestRangeAddr(t *testing.T) {
+	addrs := []net.Addr{
+		&net.IPNet{IP: net.ParseIP("fd00:51::7"), Mask: net.CIDRMask(64, 128)},
+		&net.IPNet{IP: net.ParseIP("10.0.1.7"), Mask: net.CIDRMask(120, 128)},
+		&net.IPNet{IP: net.ParseIP("10.0.2.7"), Mask: net.CIDRMask(24, 32)},
+	}
+	for networkRange, want := range map[string]string{
+		"":             "10.0.1.7/24",
+		"10.0.2.0/24":  "10.0.2.7/24",
+		"fd00:51::/64": "fd00:51::7/64",
+	} {
+		var targetNet *net.IPNet
+		if networkRange != "" {
+			_, targetNet, _ = net.ParseCIDR(networkRange)
+		}
+		if got := rangeAddr(addrs, targetNet); got == nil || got.String() != want {
+			t.Errorf("range %q: got %v, want %s", networkRange, got, want)
+		}
+	}
+
+	_, other, _ := net.ParseCIDR("192.168.0.0/16")
+	if got := rangeAddr(addrs, other); got != nil {
+		t.Errorf("range outside the addresses: got %v, want nil", got)
+	}
+}
+
+func TestInterfaceInRange_Missing(t *testing.T) {
+	if _, err := InterfaceInRange("lanmon-test-nonexistent0", ""); err == nil {
+		t.Error("expected an error for a missing interface")
+	}
+}
@@ -5,6 +5,12 @@
 //	lanmon agent   — broadcast system info via UDP multicast
 //	lanmon server  — capture beacons and store host records
 //	lanmon connect — list hosts and push SSH public key
+//	lanmon list    — print discovered hosts
+//	lanmon healthcheck — exit 0 if the local node answers over RPC
+//	lanmon ping    — check beacons get to and from a node
+//	lanmon stats   — print host and beacon totals
+//	lanmon export  — write the host database as JSON
+//	lanmon import  — merge hosts from a JSON export
 package main
 
 import (
@@ -13,8 +19,13 @@ import (
 
 	"lanmon/cmd/agent"
 	"lanmon/cmd/connect"
+	"lanmon/cmd/healthcheck"
+	"lanmon/cmd/list"
 	"lanmon/cmd/node"
+	"lanmon/cmd/ping"
 	"lanmon/cmd/server"
+	"lanmon/cmd/stats"
+	"lanmon/internal/rpc"
 )
 
 const (
@@ -23,7 +34,26 @@ const (
 	version           = "1.1.0"
 )
 
+// commit and buildDate are set at build time, e.g.
+//
+//	go build -ldflags "-X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// as 'make build' does. They read "unknown" when unset.
+var commit, buildDate string
+
+// orUnknown returns s, or "unknown" if it is empty.
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
 func main() {
+	rpc.Version = version
+	rpc.Commit = orUnknown(commit)
+	rpc.BuildDate = orUnknown(buildDate)
+
 	if len(os.Args) < 2 {
 		printUsage()
 		os.Exit(1)
@@ -68,7 +98,7 @@ func main() {
 
 	switch subcommand {
 	case "node":
-		err = node.Run(configPath)
+		err = node.Run(configPath, args[1:])
 	case "agent":
 		fmt.Println("⚠ 'agent' is deprecated. Use 'lanmon node' for P2P discovery.")
 		err = agent.Run(configPath)
@@ -76,11 +106,33 @@ func main() {
 		fmt.Println("⚠ 'server' is deprecated. Use 'lanmon node' for P2P discovery.")
 		err = server.Run(configPath)
 	case "connect":
-		err = connect.Run(configPath)
+		err = connect.Run(configPath, args[1:])
+	case "list":
+		err = list.Run(configPath, args[1:])
+	case "healthcheck":
+		err = healthcheck.Run(configPath)
+	case "stats":
+		err = stats.Run(configPath)
+	case "ping":
+		err = ping.Run(configPath, args[1:])
+	case "export":
+		err = node.ExportHosts(configPath, args[1:])
+	case "import":
+		err = node.ImportHosts(configPath, args[1:])
 	case "edit":
 		err = node.EditConfig(configPath)
+	case "config":
+		switch {
+		case len(args) >= 2 && args[1] == "validate":
+			err = node.ValidateConfig(configPath)
+		case len(args) >= 2 && args[1] == "show":
+			err = node.ShowConfig(configPath)
+		default:
+			fmt.Fprintln(os.Stderr, "Usage: lanmon config validate|show")
+			os.Exit(1)
+		}
 	case "version":
-		fmt.Printf("lanmon v%s\n", version)
+		fmt.Printf("lanmon v%s\ncommit:  %s\nbuilt:   %s\n", version, rpc.Commit, rpc.BuildDate)
 		return
 	case "help", "--help", "-h":
 		printUsage()
@@ -104,9 +156,27 @@ Usage:
   lanmon <command> [--config <path>]
 
 Commands:
-  node     Start the P2P discovery node (broadcasts & listens)
-  connect  Launch the LANConnect SSH key distributor (interactive)
+  node     Start the P2P discovery node (broadcasts & listens) [--debug-packets]
+  connect  Launch the LANConnect SSH key distributor (interactive, or
+           --host <mac|ip> [--user U] [--push --password-env VAR] [--no-connect])
+           [--sudo] [--no-verify-identity]
+           [--filter-os P] [--filter-host P] [--connect]
+           [--exec CMD --all|--host H] [--put local:remote --host H]
+           [--revoke --host H [--password-env VAR]] [--no-tui]
+           [--sort hostname|ip|last-seen|os] [--group-by os]
+           [--filter-tag key[=value]] [--wide]
+           [--tag key=value | --untag key | --note text] <#|mac|ip>
+  list     Print discovered hosts [--group-by subnet|os|tag] [--prefix-len N] [--prefix-len6 N]
+           [--json] [--wide] [--match TEXT] [--offset N] [--limit N]
+  stats    Print host and beacon totals (beacon counts reset on node restart)
+  healthcheck
+           Exit 0 if the local node answers over RPC (liveness probe)
+  ping     Send a probe beacon to a node and check its answer [--timeout D] <ip|hostname>
+  export   Write the host database as JSON [file] (node must be stopped)
+  import   Merge hosts from a JSON export [file] (node must be stopped)
   edit     Edit the configuration file in your system editor
+  config   Check the configuration file ('config validate') or print it
+           with defaults applied and secrets redacted ('config show')
   version  Print version information
   help     Show this help message
 
@@ -116,8 +186,25 @@ Options:
 Examples:
   lanmon node                           # Start P2P node with default config
   lanmon edit                           # Edit configuration
+  lanmon config validate                # Check configuration before starting
+  lanmon config show                    # Settings in effect, defaults included
   lanmon connect                        # Interactive SSH key push
+  lanmon connect --host 10.0.0.5 --push --password-env LANMON_SSH_PASS --no-connect
+  lanmon connect --filter-host 'web-*' --connect   # Straight to the only match
+  lanmon connect --exec uptime --all    # Run a command on every keyed host
+  lanmon connect --host 10.0.0.5 --put ./app.conf:/etc/app/
+  lanmon connect --host 10.0.0.5 --revoke   # Remove the key again
+  lanmon connect --tag role=web 3       # Label host #3 (local only)
+  lanmon connect --filter-tag role=web  # Only hosts labeled role=web
+  lanmon connect --note "flaky PSU" 3   # Note on host #3 (--note "" clears)
+  lanmon list --group-by subnet         # Hosts per /24 (IPv6: /64) subnet
+  lanmon list --group-by tag            # Hosts per beacon tag and label
+  lanmon list --json                    # Host records for other tooling
+  lanmon list --match web --limit 50    # First 50 hosts named like "web"
+  lanmon healthcheck                    # Probe the running node
+  lanmon ping 10.0.0.5                  # Do beacons verify both ways? (secret, clocks)
+  lanmon export hosts.json              # Back up the host database
+  lanmon import hosts.json              # Merge it into another node's database
 
 `, version, defaultSystemPath)
 }
-
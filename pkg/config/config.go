@@ -2,10 +2,14 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"os"
 	"os/user"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,21 +24,271 @@ type Config struct {
 
 // NodeConfig holds settings for the P2P discovery node.
 type NodeConfig struct {
-	NetworkRange   string `toml:"network_range"`
-	Port           int    `toml:"port"`
-	Interval       string `toml:"interval"`
-	SharedSecret   string `toml:"shared_secret"`
-	DBPath         string `toml:"db_path"`
-	RPCSocket      string `toml:"rpc_socket"`
+	// NetworkRange is the CIDR to beacon on, or "auto" for the network of
+	// the interface carrying the default route.
+	NetworkRange string `toml:"network_range"`
+	// NetworkRanges lists further ranges for nodes on several networks
+	// (e.g. VLANs); the node beacons on each. Combined with NetworkRange.
+	NetworkRanges []string `toml:"network_ranges"`
+	Port          int      `toml:"port"`
+	Interval      string   `toml:"interval"`
+	// Jitter randomizes each beacon interval by up to this much either
+	// way (e.g. "5s"). Empty or zero keeps a fixed interval.
+	Jitter       string `toml:"jitter"`
+	SharedSecret string `toml:"shared_secret"`
+	// SharedSecretFile, if set, is read into SharedSecret at load time so
+	// the secret can live outside the (often world-readable) config.
+	SharedSecretFile string `toml:"shared_secret_file"`
+	// SharedSecretPrevious is the secret being replaced while SharedSecret
+	// is rolled out to every node: beacons signed with either are
+	// accepted, and this node signs with SharedSecret. Remove it once
+	// every node has the new secret.
+	SharedSecretPrevious string `toml:"shared_secret_previous"`
+
+	DBPath    string `toml:"db_path"`
+	RPCSocket string `toml:"rpc_socket"`
+	// RPCAddr, if set, also serves RPC over TCP on a "tcp://host:port"
+	// address, e.g. for a connect CLI outside the node's container. It
+	// requires RPCToken, or an APIAuth backend that uses tokens.
+	RPCAddr string `toml:"rpc_addr"`
+	// RPCToken is a read-write token TCP RPC clients may present. The
+	// tokens of APIAuth are accepted too, with their roles.
+	RPCToken       string `toml:"rpc_token"`
 	StaleThreshold string `toml:"stale_threshold"`
+	// TimestampMaxAge is how far a beacon's timestamp may be from this
+	// node's clock, either way, before the beacon is dropped as stale
+	// (default "60s"). Widen it for peers without NTP.
+	TimestampMaxAge string `toml:"timestamp_max_age"`
+	// DBFlushInterval holds repeat beacons from a host in memory for up to
+	// this long (e.g. "60s"), writing its LastSeen and packet count to the
+	// database once per interval instead of on every beacon. Must be
+	// shorter than StaleThreshold. Empty writes every beacon.
+	DBFlushInterval string `toml:"db_flush_interval"`
+	// PurgeThreshold deletes hosts that have been inactive this long
+	// (e.g. "7d" or "168h"). Empty keeps them forever.
+	PurgeThreshold string `toml:"purge_threshold"`
 	LogLevel       string `toml:"log_level"`
+	// LogFormat is "console" (the default) for human-readable logs or
+	// "json" for one JSON object per line.
+	LogFormat string `toml:"log_format"`
+	// LogOutput is "stderr" (the default) or "syslog" to send logs to the
+	// local syslog daemon (Unix only).
+	LogOutput string `toml:"log_output"`
+
+	// Interface pins the node to the named interface when several have
+	// addresses in the network ranges: beacons carry its address, and
+	// "auto" means its network. Can't be combined with Interfaces. The
+	// legacy server joins its multicast group on it.
+	Interface string `toml:"interface"`
+
+	// Interfaces enables per-interface beaconing on multi-homed hosts.
+	// Set to interface names, or ["auto"] for every up interface.
+	// When set, NetworkRange is not used.
+	Interfaces []string `toml:"interfaces"`
+
+	// ListenAllInterfaces handles beacons arriving on any interface.
+	// By default the node only handles those from its own segments: on
+	// Linux the socket is bound to the segments' interface when there is
+	// just one (SO_BINDTODEVICE), elsewhere or with several interfaces
+	// packets from outside the segments' networks are dropped. Only
+	// honored by 'lanmon node'.
+	ListenAllInterfaces bool `toml:"listen_all_interfaces"`
+
+	// IgnoreInterfaces are case-insensitive glob patterns for interfaces
+	// never to beacon from, e.g. ["docker*", "virbr*", "veth*"]. They
+	// apply to network ranges and "auto", not to interfaces named above.
+	IgnoreInterfaces []string `toml:"ignore_interfaces"`
+
+	// MulticastTTL is the TTL of multicast beacons (1-255, default 1).
+	// Above 1 they cross routers running multicast routing (e.g. PIM).
+	// Only the legacy agent sends multicast: 'lanmon node' beacons by
+	// subnet broadcast or IPv6 link-local multicast, which routers never
+	// forward.
+	MulticastTTL int `toml:"multicast_ttl"`
+
+	// VerifySourcePort drops beacons not sent from Port before verifying
+	// their HMAC. Only honored by 'lanmon node': legacy agents send from
+	// ephemeral ports.
+	VerifySourcePort bool `toml:"verify_source_port"`
+
+	// MaxPacketsPerMin limits how many packets each source IP may send per
+	// minute; the rest are dropped before any HMAC work. Negative disables
+	// the limit.
+	MaxPacketsPerMin int `toml:"max_packets_per_min"`
+	// PacketWorkers is how many goroutines handle received packets.
+	// Packets that arrive while all are busy and the queue is full are
+	// dropped rather than piling up.
+	PacketWorkers int `toml:"packet_workers"`
+
+	// Tags are free-form labels sent in this node's beacons.
+	Tags []string `toml:"tags"`
+
+	// EncryptPayload encrypts beacons with a key derived from the shared
+	// secret. Receivers decrypt automatically, so nodes can be switched
+	// over one at a time; nodes older than this option can't read them.
+	EncryptPayload bool `toml:"encrypt_payload"`
+
+	// CompressPayload gzips beacons when that makes them smaller, so
+	// larger payloads fit in a packet. Receivers inflate automatically;
+	// nodes older than this option can't read compressed beacons.
+	CompressPayload bool `toml:"compress_payload"`
+
+	// LegacySecretKey signs beacons with the old key derivation, which
+	// hex-decoded secrets that looked like hex, and accepts both kinds.
+	// Only needed while nodes from before the change remain.
+	LegacySecretKey bool `toml:"legacy_secret_key"`
+
+	// DebugPackets logs every dropped packet with its source, first bytes
+	// and the reason (HMAC, timestamp skew, size...). Noisy; for diagnosing
+	// hosts that don't show up. Only honored by 'lanmon node'.
+	DebugPackets bool `toml:"debug_packets"`
+
+	// ManageHosts keeps the hosts file in sync with discovered peers.
+	// Default true; set false to run the node without root when only
+	// discovery and SSH key pushes are wanted. Use HostsManaged to read it.
+	ManageHosts *bool `toml:"manage_hosts"`
+
+	// HostsCollision decides which entries /etc/hosts gets when several
+	// hosts report the same hostname: "skip", "suffix" or "newest".
+	HostsCollision string `toml:"hosts_collision"`
+	// HostsFile is the hosts file to manage (default /etc/hosts).
+	HostsFile string `toml:"hosts_file"`
+	// HostsDomain, if set, is appended to hostnames in the hosts file,
+	// e.g. "lan" writes "<ip> web.lan web".
+	HostsDomain string `toml:"hosts_domain"`
+	// HostsDryRun logs hosts file changes instead of making them.
+	HostsDryRun bool `toml:"hosts_dry_run"`
+
+	// HTTPAddr, if set, serves a read-mostly HTTP/JSON API on this address
+	// (e.g. "127.0.0.1:8080") next to the RPC socket.
+	HTTPAddr string `toml:"http_addr"`
+	// HTTPTLSCert and HTTPTLSKey serve the HTTP API over TLS. Required
+	// for the mtls auth backend.
+	HTTPTLSCert string `toml:"http_tls_cert"`
+	HTTPTLSKey  string `toml:"http_tls_key"`
+
+	// MetricsAddr, if set, serves Prometheus metrics at /metrics on this
+	// address. Unset means no metrics listener at all.
+	MetricsAddr string `toml:"metrics_addr"`
+
+	// APIAuth configures authentication for the remote (HTTP/TCP) APIs.
+	APIAuth APIAuthConfig `toml:"api_auth"`
+}
+
+// APIAuthConfig selects how remote API callers are authenticated.
+type APIAuthConfig struct {
+	// Backend is "token" (default), "tokens_file" or "mtls".
+	Backend string `toml:"backend"`
+	// Token is the shared read-write token for the token backend.
+	Token string `toml:"token"`
+	// TokensFile holds "<token> <role>" lines for the tokens_file backend.
+	TokensFile string `toml:"tokens_file"`
+	// ClientCA is the PEM bundle client certificates must chain to (mtls).
+	ClientCA string `toml:"client_ca"`
+	// ClientRoles maps client certificate common names to "read" or "write" (mtls).
+	ClientRoles map[string]string `toml:"client_roles"`
+}
+
+// Enabled reports whether api_auth has been configured at all. Without it
+// the remote APIs accept every caller.
+func (a APIAuthConfig) Enabled() bool {
+	return a.Backend != "" || a.Token != ""
+}
+
+// UsesTokens reports whether api_auth authenticates callers by token, as
+// TCP RPC needs: it has no TLS, so the mtls backend can't apply to it.
+func (a APIAuthConfig) UsesTokens() bool {
+	return a.Enabled() && a.Backend != "mtls"
 }
 
 // ConnectConfig holds settings for the SSH key distributor.
 type ConnectConfig struct {
-	RPCSocket    string `toml:"rpc_socket"`
+	RPCSocket string `toml:"rpc_socket"`
+	// RPCAddr, if set, is used instead of RPCSocket to reach the node,
+	// e.g. "tcp://10.0.0.2:5679". RPCToken must match the node's.
+	RPCAddr      string `toml:"rpc_addr"`
+	RPCToken     string `toml:"rpc_token"`
 	ServerPubKey string `toml:"server_pubkey"`
 	KnownHosts   string `toml:"known_hosts"`
+	// HostKeyPolicy decides what happens on connecting to a host that
+	// isn't in KnownHosts: "strict" refuses, "tofu" (the default) records
+	// its key, "insecure" skips host key checking altogether.
+	HostKeyPolicy string `toml:"host_key_policy"`
+
+	// KeyType is the algorithm used when a key pair has to be generated:
+	// "rsa" or "ed25519". Empty infers it from ServerPubKey.
+	KeyType string `toml:"key_type"`
+
+	// SSHPort is the port sshd listens on across the fleet, offered as the
+	// default at the port prompt.
+	SSHPort int `toml:"ssh_port"`
+
+	// MaxConcurrency bounds how many hosts multi-host operations
+	// (probes, batch pushes) work on at once.
+	MaxConcurrency int `toml:"max_concurrency"`
+
+	// AuthorizedKeysFile is where keys are pushed on the target hosts, for
+	// sshd configs with a non-default AuthorizedKeysFile. It takes sshd's
+	// %h and %u tokens. Empty means ~/.ssh/authorized_keys.
+	AuthorizedKeysFile string `toml:"authorized_keys_file"`
+
+	// UseSudo runs the authorized_keys edits of a key push through
+	// sudo -n on the target host. The --sudo flag turns it on too.
+	UseSudo bool `toml:"use_sudo"`
+
+	// SSHTimeout bounds each SSH connection attempt connect makes, such as
+	// "30s" for slow links. Empty keeps the built-in timeouts.
+	SSHTimeout string `toml:"ssh_timeout"`
+
+	// PushRetries is how many times a key push redials a host after a
+	// timeout or refused connection. Read it through Retries, which
+	// supplies the default.
+	PushRetries *int `toml:"push_retries"`
+}
+
+// RPCAddress returns the address connect should reach the node on:
+// RPCAddr if set, otherwise RPCSocket.
+func (c *ConnectConfig) RPCAddress() string {
+	if c.RPCAddr != "" {
+		return c.RPCAddr
+	}
+	return c.RPCSocket
+}
+
+// ParseSSHTimeout parses the SSH connection timeout. Zero means it is not
+// set and callers use their own defaults.
+func (c *ConnectConfig) ParseSSHTimeout() (time.Duration, error) {
+	if c.SSHTimeout == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(c.SSHTimeout)
+}
+
+// Retries returns PushRetries, defaulting to 3.
+func (c *ConnectConfig) Retries() int {
+	if c.PushRetries == nil {
+		return 3
+	}
+	return *c.PushRetries
+}
+
+// HostsManaged reports whether the node should maintain the hosts file:
+// ManageHosts, defaulting to true.
+func (n *NodeConfig) HostsManaged() bool {
+	return n.ManageHosts == nil || *n.ManageHosts
+}
+
+// Ranges returns NetworkRange followed by NetworkRanges, without duplicates.
+func (n *NodeConfig) Ranges() []string {
+	var ranges []string
+	seen := make(map[string]bool)
+	for _, r := range append([]string{n.NetworkRange}, n.NetworkRanges...) {
+		if r != "" && !seen[r] {
+			seen[r] = true
+			ranges = append(ranges, r)
+		}
+	}
+	return ranges
 }
 
 // ParseInterval parses the node beacon interval string to a time.Duration.
@@ -45,6 +299,14 @@ func (n *NodeConfig) ParseInterval() (time.Duration, error) {
 	return time.ParseDuration(n.Interval)
 }
 
+// ParseJitter parses the node beacon jitter. Empty means no jitter.
+func (n *NodeConfig) ParseJitter() (time.Duration, error) {
+	if n.Jitter == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(n.Jitter)
+}
+
 // ParseStaleThreshold parses the node stale threshold string to a time.Duration.
 func (n *NodeConfig) ParseStaleThreshold() (time.Duration, error) {
 	if n.StaleThreshold == "" {
@@ -53,6 +315,39 @@ func (n *NodeConfig) ParseStaleThreshold() (time.Duration, error) {
 	return time.ParseDuration(n.StaleThreshold)
 }
 
+// ParseDBFlushInterval parses the database write debounce. Empty means
+// every beacon is written.
+func (n *NodeConfig) ParseDBFlushInterval() (time.Duration, error) {
+	if n.DBFlushInterval == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(n.DBFlushInterval)
+}
+
+// ParseTimestampMaxAge parses the node's timestamp tolerance.
+func (n *NodeConfig) ParseTimestampMaxAge() (time.Duration, error) {
+	if n.TimestampMaxAge == "" {
+		return 60 * time.Second, nil
+	}
+	return time.ParseDuration(n.TimestampMaxAge)
+}
+
+// ParsePurgeThreshold parses the node purge threshold, which also accepts
+// a whole number of days such as "7d". Zero means never purge.
+func (n *NodeConfig) ParsePurgeThreshold() (time.Duration, error) {
+	if n.PurgeThreshold == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(n.PurgeThreshold, "d"); ok {
+		d, err := strconv.Atoi(days)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("invalid duration %q", n.PurgeThreshold)
+		}
+		return time.Duration(d) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(n.PurgeThreshold)
+}
+
 // Load reads and parses a TOML config file, applying defaults for unset values.
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
@@ -67,13 +362,44 @@ func Load(path string) (*Config, error) {
 
 	applyDefaults(cfg)
 	cfg.expandPaths()
+	if err := cfg.loadSecretFile(); err != nil {
+		return nil, err
+	}
 	return cfg, nil
 }
 
+// loadSecretFile populates SharedSecret from SharedSecretFile.
+func (cfg *Config) loadSecretFile() error {
+	path := cfg.Node.SharedSecretFile
+	if path == "" {
+		return nil
+	}
+	if cfg.Node.SharedSecret != "" {
+		return fmt.Errorf("shared_secret and shared_secret_file are both set; use only one")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading shared_secret_file: %w", err)
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return fmt.Errorf("shared_secret_file %s is empty", path)
+	}
+	cfg.Node.SharedSecret = secret
+	return nil
+}
+
 func (cfg *Config) expandPaths() {
 	cfg.Connect.ServerPubKey = ExpandPath(cfg.Connect.ServerPubKey)
 	cfg.Connect.KnownHosts = ExpandPath(cfg.Connect.KnownHosts)
 	cfg.Node.DBPath = ExpandPath(cfg.Node.DBPath)
+	cfg.Node.SharedSecretFile = ExpandPath(cfg.Node.SharedSecretFile)
+	cfg.Node.APIAuth.TokensFile = ExpandPath(cfg.Node.APIAuth.TokensFile)
+	cfg.Node.APIAuth.ClientCA = ExpandPath(cfg.Node.APIAuth.ClientCA)
+	cfg.Node.HostsFile = ExpandPath(cfg.Node.HostsFile)
+	cfg.Node.HTTPTLSCert = ExpandPath(cfg.Node.HTTPTLSCert)
+	cfg.Node.HTTPTLSKey = ExpandPath(cfg.Node.HTTPTLSKey)
 }
 
 // ExpandPath expands tilde (~) to the user's home directory.
@@ -112,19 +438,191 @@ func applyDefaults(cfg *Config) {
 	if cfg.Node.StaleThreshold == "" {
 		cfg.Node.StaleThreshold = "90s"
 	}
+	if cfg.Node.TimestampMaxAge == "" {
+		cfg.Node.TimestampMaxAge = "60s"
+	}
 	if cfg.Node.LogLevel == "" {
 		cfg.Node.LogLevel = "info"
 	}
+	if cfg.Node.HostsCollision == "" {
+		cfg.Node.HostsCollision = "newest"
+	}
+	if cfg.Node.MaxPacketsPerMin == 0 {
+		cfg.Node.MaxPacketsPerMin = 60
+	}
+	if cfg.Node.PacketWorkers <= 0 {
+		cfg.Node.PacketWorkers = 32
+	}
+	if cfg.Node.MulticastTTL == 0 {
+		cfg.Node.MulticastTTL = 1
+	}
 
 	// Connect defaults
 	if cfg.Connect.RPCSocket == "" {
 		cfg.Connect.RPCSocket = "/run/lanmon/server.sock"
 	}
 	if cfg.Connect.ServerPubKey == "" {
-		cfg.Connect.ServerPubKey = os.ExpandEnv("$HOME/.ssh/id_rsa.pub")
+		if cfg.Connect.KeyType == "ed25519" {
+			cfg.Connect.ServerPubKey = os.ExpandEnv("$HOME/.ssh/id_ed25519.pub")
+		} else {
+			cfg.Connect.ServerPubKey = os.ExpandEnv("$HOME/.ssh/id_rsa.pub")
+		}
 	}
 	if cfg.Connect.KnownHosts == "" {
 		cfg.Connect.KnownHosts = "/etc/lanmon/known_hosts"
 	}
+	if cfg.Connect.SSHPort == 0 {
+		cfg.Connect.SSHPort = 22
+	}
+	if cfg.Connect.MaxConcurrency <= 0 {
+		cfg.Connect.MaxConcurrency = 10
+	}
 }
 
+// Validate checks the node settings for problems that would stop a node
+// from starting. It returns every problem found, joined with errors.Join,
+// or nil if the config is usable.
+func Validate(cfg *Config) error {
+	var errs []error
+	n := &cfg.Node
+
+	if len(n.Ranges()) == 0 && len(n.Interfaces) == 0 {
+		errs = append(errs, fmt.Errorf("node.network_range: must be set (or node.network_ranges or node.interfaces)"))
+	}
+	for _, r := range n.Ranges() {
+		if r == "auto" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(r); err != nil {
+			errs = append(errs, fmt.Errorf("node.network_range: %q is not a valid CIDR", r))
+		}
+	}
+
+	if n.Interface != "" && len(n.Interfaces) > 0 {
+		errs = append(errs, fmt.Errorf("node.interface: can't be combined with node.interfaces"))
+	}
+
+	for _, p := range n.IgnoreInterfaces {
+		if _, err := path.Match(p, ""); err != nil {
+			errs = append(errs, fmt.Errorf("node.ignore_interfaces: %q: %w", p, err))
+		}
+	}
+
+	if n.Port < 1 || n.Port > 65535 {
+		errs = append(errs, fmt.Errorf("node.port: %d is out of range 1-65535", n.Port))
+	}
+
+	// Zero is left by configs that skipped applyDefaults, and means 1
+	if n.MulticastTTL < 0 || n.MulticastTTL > 255 {
+		errs = append(errs, fmt.Errorf("node.multicast_ttl: %d is out of range 1-255", n.MulticastTTL))
+	}
+
+	interval, err := n.ParseInterval()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("node.interval: %w", err))
+	}
+	if jitter, err := n.ParseJitter(); err != nil {
+		errs = append(errs, fmt.Errorf("node.jitter: %w", err))
+	} else if jitter < 0 || (interval > 0 && jitter >= interval) {
+		errs = append(errs, fmt.Errorf("node.jitter: %s must be at least 0 and shorter than interval %s", jitter, interval))
+	}
+	if _, err := n.ParseStaleThreshold(); err != nil {
+		errs = append(errs, fmt.Errorf("node.stale_threshold: %w", err))
+	}
+	if maxAge, err := n.ParseTimestampMaxAge(); err != nil {
+		errs = append(errs, fmt.Errorf("node.timestamp_max_age: %w", err))
+	} else if maxAge < time.Second {
+		errs = append(errs, fmt.Errorf("node.timestamp_max_age: %s must be at least 1s", maxAge))
+	}
+	if flush, err := n.ParseDBFlushInterval(); err != nil {
+		errs = append(errs, fmt.Errorf("node.db_flush_interval: %w", err))
+	} else if stale, err := n.ParseStaleThreshold(); err == nil && (flush < 0 || flush >= stale) {
+		errs = append(errs, fmt.Errorf("node.db_flush_interval: %s must be at least 0 and shorter than stale_threshold %s", flush, stale))
+	}
+	if purge, err := n.ParsePurgeThreshold(); err != nil {
+		errs = append(errs, fmt.Errorf("node.purge_threshold: %w", err))
+	} else if stale, err := n.ParseStaleThreshold(); err == nil && purge > 0 && purge <= stale {
+		errs = append(errs, fmt.Errorf("node.purge_threshold: %s must be longer than stale_threshold %s", purge, stale))
+	}
+
+	if n.LogFormat != "" && n.LogFormat != "console" && n.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("node.log_format: %q must be \"console\" or \"json\"", n.LogFormat))
+	}
+
+	if n.LogOutput != "" && n.LogOutput != "stderr" && n.LogOutput != "syslog" {
+		errs = append(errs, fmt.Errorf("node.log_output: %q must be \"stderr\" or \"syslog\"", n.LogOutput))
+	}
+
+	if n.SharedSecret == "" || n.SharedSecret == "CHANGE_ME" {
+		errs = append(errs, fmt.Errorf("node.shared_secret: must be set (not 'CHANGE_ME')"))
+	}
+	if n.SharedSecretPrevious != "" && n.SharedSecretPrevious == n.SharedSecret {
+		errs = append(errs, fmt.Errorf("node.shared_secret_previous: is the same as shared_secret"))
+	}
+
+	if err := checkWritableDir(filepath.Dir(n.DBPath)); err != nil {
+		errs = append(errs, fmt.Errorf("node.db_path: %w", err))
+	}
+	if n.RPCAddr != "" {
+		if !strings.HasPrefix(n.RPCAddr, "tcp://") {
+			errs = append(errs, fmt.Errorf("node.rpc_addr: %q must be a tcp://host:port address", n.RPCAddr))
+		} else if _, _, err := net.SplitHostPort(strings.TrimPrefix(n.RPCAddr, "tcp://")); err != nil {
+			errs = append(errs, fmt.Errorf("node.rpc_addr: %w", err))
+		}
+		if n.RPCToken == "" && !n.APIAuth.UsesTokens() {
+			errs = append(errs, fmt.Errorf("node.rpc_token: required when node.rpc_addr is set, unless api_auth uses tokens"))
+		}
+	}
+	if err := checkWritableDir(filepath.Dir(n.RPCSocket)); err != nil {
+		errs = append(errs, fmt.Errorf("node.rpc_socket: %w", err))
+	}
+
+	if timeout, err := cfg.Connect.ParseSSHTimeout(); err != nil {
+		errs = append(errs, fmt.Errorf("connect.ssh_timeout: %w", err))
+	} else if timeout < 0 {
+		errs = append(errs, fmt.Errorf("connect.ssh_timeout: %s must not be negative", timeout))
+	}
+	if r := cfg.Connect.Retries(); r < 0 {
+		errs = append(errs, fmt.Errorf("connect.push_retries: %d must not be negative", r))
+	}
+
+	if (n.HTTPTLSCert == "") != (n.HTTPTLSKey == "") {
+		errs = append(errs, fmt.Errorf("node.http_tls_cert: must be set together with node.http_tls_key"))
+	}
+	if n.HTTPAddr != "" && n.APIAuth.Backend == "mtls" && n.HTTPTLSCert == "" {
+		errs = append(errs, fmt.Errorf("node.http_tls_cert: required for the mtls api_auth backend"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkWritableDir reports whether files can be created in dir. A missing
+// directory is fine as long as its nearest existing ancestor is writable,
+// since the node creates it on startup.
+func checkWritableDir(dir string) error {
+	for {
+		fi, err := os.Stat(dir)
+		if err == nil {
+			if !fi.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return fmt.Errorf("no existing parent directory for %s", dir)
+		}
+		dir = parent
+	}
+
+	f, err := os.CreateTemp(dir, ".lanmon-validate-*")
+	if err != nil {
+		return fmt.Errorf("directory %s is not writable", dir)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil
+}
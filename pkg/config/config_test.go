@@ -1,9 +1,13 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad_ValidConfig(t *testing.T) {
@@ -82,6 +86,12 @@ func TestLoad_Defaults(t *testing.T) {
 	if cfg.Node.LogLevel != "info" {
 		t.Errorf("default LogLevel: got %s, want info", cfg.Node.LogLevel)
 	}
+	if cfg.Node.MulticastTTL != 1 {
+		t.Errorf("default MulticastTTL: got %d, want 1", cfg.Node.MulticastTTL)
+	}
+	if cfg.Connect.MaxConcurrency != 10 {
+		t.Errorf("default MaxConcurrency: got %d, want 10", cfg.Connect.MaxConcurrency)
+	}
 }
 
 func TestLoad_NonexistentFile(t *testing.T) {
@@ -138,3 +148,355 @@ func TestParseStaleThreshold(t *testing.T) {
 	}
 }
 
+func TestParsePurgeThreshold(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"36h", 36 * time.Hour, false},
+		{"1.5d", 0, true},
+		{"-1d", 0, true},
+	}
+	for _, tt := range tests {
+		cfg := &NodeConfig{PurgeThreshold: tt.in}
+		got, err := cfg.ParsePurgeThreshold()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParsePurgeThreshold(%q): got %v, %v; want %v (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	return &Config{Node: NodeConfig{
+		NetworkRange:   "10.51.240.0/23",
+		Port:           5678,
+		Interval:       "30s",
+		SharedSecret:   "my-secret",
+		DBPath:         filepath.Join(dir, "db", "hosts.db"),
+		RPCSocket:      filepath.Join(dir, "server.sock"),
+		StaleThreshold: "90s",
+	}}
+}
+
+func TestValidate_Valid(t *testing.T) {
+	if err := Validate(validConfig(t)); err != nil {
+		t.Errorf("expected valid config, got %v", err)
+	}
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.Node.NetworkRange = "10.51.240.0"
+	cfg.Node.Port = 70000
+	cfg.Node.Interval = "soon"
+	cfg.Node.StaleThreshold = "90"
+	cfg.Node.SharedSecret = "CHANGE_ME"
+
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) {
+		t.Fatalf("expected joined errors, got %T", err)
+	}
+	if got := len(joined.Unwrap()); got != 5 {
+		t.Errorf("expected 5 problems, got %d: %v", got, err)
+	}
+	for _, field := range []string{"network_range", "port", "interval", "stale_threshold", "shared_secret"} {
+		if !strings.Contains(err.Error(), "node."+field) {
+			t.Errorf("expected a problem for node.%s in %q", field, err)
+		}
+	}
+}
+
+func TestValidate_Jitter(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.Node.Interval = "30s"
+
+	cfg.Node.Jitter = "5s"
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected valid jitter, got %v", err)
+	}
+
+	for _, jitter := range []string{"30s", "-1s", "soon"} {
+		cfg.Node.Jitter = jitter
+		if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "node.jitter") {
+			t.Errorf("jitter %q: expected jitter problem, got %v", jitter, err)
+		}
+	}
+}
+
+func TestValidate_DBFlushInterval(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.Node.StaleThreshold = "90s"
+
+	cfg.Node.DBFlushInterval = "60s"
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected valid flush interval, got %v", err)
+	}
+
+	for _, flush := range []string{"90s", "-1s", "soon"} {
+		cfg.Node.DBFlushInterval = flush
+		if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "node.db_flush_interval") {
+			t.Errorf("flush interval %q: expected db_flush_interval problem, got %v", flush, err)
+		}
+	}
+}
+
+func TestValidate_IgnoreInterfaces(t *testing.T) {
+	cfg := validConfig(t)
+
+	cfg.Node.IgnoreInterfaces = []string{"docker*", "veth*"}
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected valid patterns, got %v", err)
+	}
+
+	cfg.Node.IgnoreInterfaces = []string{"br-["}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "node.ignore_interfaces") {
+		t.Errorf("expected ignore_interfaces problem, got %v", err)
+	}
+}
+
+func TestValidate_Interface(t *testing.T) {
+	cfg := validConfig(t)
+
+	cfg.Node.Interface = "eth1"
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected a pinned interface to be valid, got %v", err)
+	}
+
+	cfg.Node.Interfaces = []string{"eth0", "eth1"}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "node.interface") {
+		t.Errorf("expected interface/interfaces conflict, got %v", err)
+	}
+}
+
+func TestValidate_RPCAddr(t *testing.T) {
+	cfg := validConfig(t)
+
+	cfg.Node.RPCAddr = "tcp://0.0.0.0:5679"
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "node.rpc_token") {
+		t.Errorf("expected rpc_token problem, got %v", err)
+	}
+
+	// Client certificates can't authenticate TCP RPC
+	cfg.Node.APIAuth = APIAuthConfig{Backend: "mtls", ClientCA: "/etc/lanmon/ca.pem"}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "node.rpc_token") {
+		t.Errorf("expected rpc_token problem with mtls api_auth, got %v", err)
+	}
+	cfg.Node.APIAuth = APIAuthConfig{Backend: "tokens_file", TokensFile: "/etc/lanmon/api_tokens"}
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected api_auth tokens to do for TCP RPC, got %v", err)
+	}
+	cfg.Node.APIAuth = APIAuthConfig{}
+
+	cfg.Node.RPCToken = "s3cret"
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected valid TCP RPC settings, got %v", err)
+	}
+
+	cfg.Node.RPCAddr = "0.0.0.0:5679"
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "node.rpc_addr") {
+		t.Errorf("expected rpc_addr problem, got %v", err)
+	}
+}
+
+func TestValidate_MulticastTTL(t *testing.T) {
+	cfg := validConfig(t)
+
+	for _, ttl := range []int{1, 32, 255} {
+		cfg.Node.MulticastTTL = ttl
+		if err := Validate(cfg); err != nil {
+			t.Errorf("ttl %d: expected valid, got %v", ttl, err)
+		}
+	}
+	for _, ttl := range []int{-1, 256} {
+		cfg.Node.MulticastTTL = ttl
+		if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "node.multicast_ttl") {
+			t.Errorf("ttl %d: expected multicast_ttl problem, got %v", ttl, err)
+		}
+	}
+}
+
+func TestValidate_UnwritableDir(t *testing.T) {
+	cfg := validConfig(t)
+
+	// A regular file where the database directory should be
+	blocker := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	cfg.Node.DBPath = filepath.Join(blocker, "hosts.db")
+
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "node.db_path") {
+		t.Errorf("expected db_path problem, got %v", err)
+	}
+}
+
+func writeConfig(t *testing.T, dir, content string) string {
+	t.Helper()
+	cfgPath := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(cfgPath, []byte(content), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return cfgPath
+}
+
+func TestLoad_SharedSecretFile(t *testing.T) {
+	dir := t.TempDir()
+	secretPath := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secretPath, []byte("  file-secret\n"), 0600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+
+	cfg, err := Load(writeConfig(t, dir, "[node]\n  shared_secret_file = \""+secretPath+"\"\n"))
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if cfg.Node.SharedSecret != "file-secret" {
+		t.Errorf("SharedSecret: got %q, want file-secret", cfg.Node.SharedSecret)
+	}
+}
+
+func TestLoad_SharedSecretFileConflict(t *testing.T) {
+	dir := t.TempDir()
+	secretPath := filepath.Join(dir, "secret")
+	os.WriteFile(secretPath, []byte("file-secret"), 0600)
+
+	content := "[node]\n  shared_secret = \"inline\"\n  shared_secret_file = \"" + secretPath + "\"\n"
+	if _, err := Load(writeConfig(t, dir, content)); err == nil {
+		t.Error("expected error when both shared_secret and shared_secret_file are set")
+	}
+}
+
+func TestLoad_SharedSecretFileMissing(t *testing.T) {
+	dir := t.TempDir()
+	content := "[node]\n  shared_secret_file = \"" + filepath.Join(dir, "missing") + "\"\n"
+	if _, err := Load(writeConfig(t, dir, content)); err == nil {
+		t.Error("expected error for missing shared_secret_file")
+	}
+}
+
+func TestNodeConfig_Ranges(t *testing.T) {
+	n := NodeConfig{
+		NetworkRange:  "10.51.240.0/23",
+		NetworkRanges: []string{"10.51.242.0/24", "10.51.240.0/23", "192.168.7.0/24"},
+	}
+
+	want := []string{"10.51.240.0/23", "10.51.242.0/24", "192.168.7.0/24"}
+	if got := n.Ranges(); !slices.Equal(got, want) {
+		t.Errorf("Ranges: got %v, want %v", got, want)
+	}
+
+	if got := (&NodeConfig{NetworkRanges: []string{"10.0.0.0/24"}}).Ranges(); !slices.Equal(got, []string{"10.0.0.0/24"}) {
+		t.Errorf("Ranges without network_range: got %v", got)
+	}
+}
+
+func TestLoad_ManageHosts(t *testing.T) {
+	dir := t.TempDir()
+
+	cfg, err := Load(writeConfig(t, dir, "[node]\n  shared_secret = \"s\"\n"))
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if !cfg.Node.HostsManaged() {
+		t.Error("expected hosts to be managed by default")
+	}
+
+	cfg, err = Load(writeConfig(t, dir, "[node]\n  shared_secret = \"s\"\n  manage_hosts = false\n"))
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if cfg.Node.HostsManaged() {
+		t.Error("expected manage_hosts = false to turn hosts management off")
+	}
+}
+
+func TestConnectConfig_Retries(t *testing.T) {
+	cfg := validConfig(t)
+	if got := cfg.Connect.Retries(); got != 3 {
+		t.Errorf("default retries: got %d, want 3", got)
+	}
+
+	for _, n := range []int{0, 5} {
+		cfg.Connect.PushRetries = &n
+		if got := cfg.Connect.Retries(); got != n {
+			t.Errorf("retries: got %d, want %d", got, n)
+		}
+		if err := Validate(cfg); err != nil {
+			t.Errorf("push_retries %d: expected valid, got %v", n, err)
+		}
+	}
+
+	negative := -1
+	cfg.Connect.PushRetries = &negative
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "connect.push_retries") {
+		t.Errorf("expected push_retries problem, got %v", err)
+	}
+}
+
+func TestValidate_SSHTimeout(t *testing.T) {
+	cfg := validConfig(t)
+	if got, err := cfg.Connect.ParseSSHTimeout(); got != 0 || err != nil {
+		t.Errorf("unset: got %v, %v; want 0", got, err)
+	}
+
+	cfg.Connect.SSHTimeout = "45s"
+	if got, err := cfg.Connect.ParseSSHTimeout(); got != 45*time.Second || err != nil {
+		t.Errorf("45s: got %v, %v", got, err)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected valid, got %v", err)
+	}
+
+	for _, bad := range []string{"soon", "-5s"} {
+		cfg.Connect.SSHTimeout = bad
+		if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "connect.ssh_timeout") {
+			t.Errorf("%q: expected ssh_timeout problem, got %v", bad, err)
+		}
+	}
+}
+
+func TestValidate_TimestampMaxAge(t *testing.T) {
+	cfg := validConfig(t)
+	if got, err := cfg.Node.ParseTimestampMaxAge(); got != 60*time.Second || err != nil {
+		t.Errorf("default: got %v, %v; want 60s", got, err)
+	}
+
+	cfg.Node.TimestampMaxAge = "5m"
+	if got, err := cfg.Node.ParseTimestampMaxAge(); got != 5*time.Minute || err != nil {
+		t.Errorf("5m: got %v, %v", got, err)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected valid, got %v", err)
+	}
+
+	for _, bad := range []string{"a while", "0s", "-30s"} {
+		cfg.Node.TimestampMaxAge = bad
+		if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "node.timestamp_max_age") {
+			t.Errorf("%q: expected timestamp_max_age problem, got %v", bad, err)
+		}
+	}
+}
+
+func TestValidate_SharedSecretPrevious(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.Node.SharedSecretPrevious = "old-secret"
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected valid, got %v", err)
+	}
+
+	cfg.Node.SharedSecretPrevious = cfg.Node.SharedSecret
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "node.shared_secret_previous") {
+		t.Errorf("expected shared_secret_previous problem, got %v", err)
+	}
+}
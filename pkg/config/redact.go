@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// redactedValue replaces secrets in Redacted configs.
+const redactedValue = "***"
+
+// Redacted returns a copy of the config with the shared secrets and API
+// tokens replaced by "***", for display. Unset secrets stay empty, so it
+// still shows which are configured. Slices, maps and pointers are shared
+// with c.
+func (c Config) Redacted() Config {
+	c.Node = c.Node.Redacted()
+	c.Connect = c.Connect.Redacted()
+	return c
+}
+
+// Redacted returns a copy of the node settings with secrets replaced, as
+// Config.Redacted does. New secret settings must be added here.
+func (n NodeConfig) Redacted() NodeConfig {
+	redact(&n.SharedSecret)
+	redact(&n.SharedSecretPrevious)
+	redact(&n.RPCToken)
+	redact(&n.APIAuth.Token)
+	return n
+}
+
+// Redacted returns a copy of the connect settings with secrets replaced,
+// as Config.Redacted does. New secret settings must be added here.
+func (c ConnectConfig) Redacted() ConnectConfig {
+	redact(&c.RPCToken)
+	return c
+}
+
+func redact(s *string) {
+	if *s != "" {
+		*s = redactedValue
+	}
+}
+
+// String formats the redacted config, so printing a config by mistake
+// can't leak a secret. plain drops the method to keep fmt from recursing.
+func (c Config) String() string {
+	type plain Config
+	return fmt.Sprintf("%+v", plain(c.Redacted()))
+}
+
+// String formats the redacted node settings.
+func (n NodeConfig) String() string {
+	type plain NodeConfig
+	return fmt.Sprintf("%+v", plain(n.Redacted()))
+}
+
+// String formats the redacted connect settings.
+func (c ConnectConfig) String() string {
+	type plain ConnectConfig
+	return fmt.Sprintf("%+v", plain(c.Redacted()))
+}
+
+// GoString formats the redacted config for %#v.
+func (c Config) GoString() string {
+	type plain Config
+	return fmt.Sprintf("%#v", plain(c.Redacted()))
+}
+
+// GoString formats the redacted node settings for %#v.
+func (n NodeConfig) GoString() string {
+	type plain NodeConfig
+	return fmt.Sprintf("%#v", plain(n.Redacted()))
+}
+
+// GoString formats the redacted connect settings for %#v.
+func (c ConnectConfig) GoString() string {
+	type plain ConnectConfig
+	return fmt.Sprintf("%#v", plain(c.Redacted()))
+}
+
+// MarshalZerologObject logs the redacted config, one field per setting
+// under its TOML name, for zerolog's Object.
+func (c Config) MarshalZerologObject(e *zerolog.Event) {
+	e.Object("node", c.Node)
+	e.Object("connect", c.Connect)
+}
+
+// MarshalZerologObject logs the redacted node settings.
+func (n NodeConfig) MarshalZerologObject(e *zerolog.Event) {
+	logFields(e, n.Redacted())
+}
+
+// MarshalZerologObject logs the redacted connect settings.
+func (c ConnectConfig) MarshalZerologObject(e *zerolog.Event) {
+	logFields(e, c.Redacted())
+}
+
+// logFields adds each field of the struct v to e under its TOML name.
+func logFields(e *zerolog.Event, v any) {
+	rv := reflect.ValueOf(v)
+	for i := 0; i < rv.NumField(); i++ {
+		name, _, _ := strings.Cut(rv.Type().Field(i).Tag.Get("toml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		e.Interface(name, rv.Field(i).Interface())
+	}
+}
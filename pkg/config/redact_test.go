@@ -0,0 +1,81 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestRedacted(t *testing.T) {
+	cfg := Config{
+		Node: NodeConfig{
+			SharedSecret:         "new-secret",
+			SharedSecretPrevious: "old-secret",
+			RPCToken:             "node-token",
+			APIAuth:              APIAuthConfig{Token: "api-token"},
+			Port:                 5678,
+		},
+		Connect: ConnectConfig{RPCToken: "connect-token"},
+	}
+
+	r := cfg.Redacted()
+	for name, got := range map[string]string{
+		"shared_secret":          r.Node.SharedSecret,
+		"shared_secret_previous": r.Node.SharedSecretPrevious,
+		"node.rpc_token":         r.Node.RPCToken,
+		"api_auth.token":         r.Node.APIAuth.Token,
+		"connect.rpc_token":      r.Connect.RPCToken,
+	} {
+		if got != "***" {
+			t.Errorf("%s: got %q, want ***", name, got)
+		}
+	}
+	if r.Node.Port != 5678 {
+		t.Errorf("port: got %d, want it kept", r.Node.Port)
+	}
+	if cfg.Node.SharedSecret != "new-secret" {
+		t.Error("expected the original config to be left alone")
+	}
+
+	if empty := (Config{}).Redacted(); empty.Node.SharedSecret != "" || empty.Connect.RPCToken != "" {
+		t.Errorf("expected unset secrets to stay empty, got %+v", empty)
+	}
+}
+
+func TestRedacted_RenderNeverLeaks(t *testing.T) {
+	const secret = "raw-secret-value"
+	cfg := &Config{
+		Node: NodeConfig{
+			SharedSecret:         secret,
+			SharedSecretPrevious: secret + "-old",
+			RPCToken:             secret + "-rpc",
+			APIAuth:              APIAuthConfig{Token: secret + "-api"},
+			Port:                 5678,
+		},
+		Connect: ConnectConfig{RPCToken: secret + "-connect"},
+	}
+
+	var logs bytes.Buffer
+	log := zerolog.New(&logs)
+	log.Info().Object("config", cfg).Msg("")
+	log.Info().Object("node", cfg.Node).Msg("")
+	log.Info().Interface("config", cfg).Msg("")
+
+	renders := map[string]string{"zerolog": logs.String()}
+	for _, verb := range []string{"%v", "%+v", "%#v", "%s"} {
+		renders[verb+" config"] = fmt.Sprintf(verb, cfg)
+		renders[verb+" node"] = fmt.Sprintf(verb, cfg.Node)
+		renders[verb+" connect"] = fmt.Sprintf(verb, cfg.Connect)
+	}
+	for name, got := range renders {
+		if strings.Contains(got, secret) {
+			t.Errorf("%s: secret leaked:\n%s", name, got)
+		}
+	}
+	if !strings.Contains(logs.String(), `"shared_secret":"***"`) || !strings.Contains(logs.String(), `"port":5678`) {
+		t.Errorf("expected redacted settings in the log:\n%s", logs.String())
+	}
+}
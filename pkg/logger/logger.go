@@ -2,33 +2,66 @@
 package logger
 
 import (
+	"io"
 	"os"
 	"time"
 
 	"github.com/rs/zerolog"
 )
 
-// Init creates and returns a zerolog.Logger configured with the given log level.
+// Init creates and returns a zerolog.Logger with the given log level,
+// format and output.
 // Supported levels: debug, info, warn, error. Defaults to info.
-func Init(level string) zerolog.Logger {
-	var lvl zerolog.Level
+// Supported formats: console (human-readable) and json (one object per
+// line, for log aggregation). Defaults to console.
+// Supported outputs: stderr (the default) and syslog, which sends JSON
+// events to the local syslog daemon. If syslog can't be reached, Init
+// warns and falls back to stderr.
+func Init(level, format, output string) zerolog.Logger {
+	if output == "syslog" {
+		w, err := dialSyslog()
+		if err == nil {
+			return newLogger(w, level, "json")
+		}
+		log := newLogger(os.Stderr, level, format)
+		log.Warn().Err(err).Msg("Syslog unavailable, logging to stderr")
+		return log
+	}
+	return newLogger(os.Stderr, level, format)
+}
+
+// SetLevel changes the level of every logger Init has returned, for
+// applying a new log_level without a restart. Unknown levels mean info.
+func SetLevel(level string) {
+	zerolog.SetGlobalLevel(parseLevel(level))
+}
+
+func parseLevel(level string) zerolog.Level {
 	switch level {
 	case "debug":
-		lvl = zerolog.DebugLevel
+		return zerolog.DebugLevel
 	case "info":
-		lvl = zerolog.InfoLevel
+		return zerolog.InfoLevel
 	case "warn":
-		lvl = zerolog.WarnLevel
+		return zerolog.WarnLevel
 	case "error":
-		lvl = zerolog.ErrorLevel
+		return zerolog.ErrorLevel
 	default:
-		lvl = zerolog.InfoLevel
+		return zerolog.InfoLevel
 	}
+}
+
+// newLogger builds the logger. The level is zerolog's global one, so
+// SetLevel can change it later.
+func newLogger(out io.Writer, level, format string) zerolog.Logger {
+	SetLevel(level)
 
-	return zerolog.New(
-		zerolog.ConsoleWriter{
-			Out:        os.Stderr,
+	if format != "json" {
+		out = zerolog.ConsoleWriter{
+			Out:        out,
 			TimeFormat: time.RFC3339,
-		},
-	).Level(lvl).With().Timestamp().Logger()
+		}
+	}
+
+	return zerolog.New(out).With().Timestamp().Logger()
 }
@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	log := newLogger(&buf, "info", "json")
+
+	log.Info().Str("host", "web-01").Int("port", 5678).Msg("beacon sent")
+	log.Debug().Msg("filtered out")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 log line, got %d: %q", len(lines), buf.String())
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("log line is not valid JSON: %v: %s", err, lines[0])
+	}
+	for key, want := range map[string]any{
+		"level":   "info",
+		"message": "beacon sent",
+		"host":    "web-01",
+		"port":    float64(5678),
+	} {
+		if entry[key] != want {
+			t.Errorf("%s: got %v, want %v", key, entry[key], want)
+		}
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Error("expected a time field")
+	}
+}
+
+func TestNewLogger_Console(t *testing.T) {
+	var buf bytes.Buffer
+	log := newLogger(&buf, "info", "")
+	log.Info().Msg("beacon sent")
+
+	if json.Valid(buf.Bytes()) {
+		t.Errorf("expected console output, got JSON: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "beacon sent") {
+		t.Errorf("expected message in output, got %q", buf.String())
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	var buf bytes.Buffer
+	log := newLogger(&buf, "info", "json")
+	t.Cleanup(func() { SetLevel("info") })
+
+	log.Debug().Msg("hidden")
+	SetLevel("debug")
+	log.Debug().Msg("shown")
+
+	if strings.Contains(buf.String(), "hidden") {
+		t.Error("debug message logged at info level")
+	}
+	if !strings.Contains(buf.String(), "shown") {
+		t.Error("expected debug message after SetLevel(debug)")
+	}
+}
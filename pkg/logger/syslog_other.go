@@ -0,0 +1,13 @@
+//go:build !unix
+
+package logger
+
+import (
+	"errors"
+	"io"
+)
+
+// dialSyslog always fails: there is no local syslog daemon to write to.
+func dialSyslog() (io.Writer, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
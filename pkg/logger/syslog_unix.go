@@ -0,0 +1,20 @@
+//go:build unix
+
+package logger
+
+import (
+	"io"
+	"log/syslog"
+
+	"github.com/rs/zerolog"
+)
+
+// dialSyslog connects to the local syslog daemon as "lanmon" in the daemon
+// facility. Events are sent at the syslog severity matching their level.
+func dialSyslog() (io.Writer, error) {
+	w, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, "lanmon")
+	if err != nil {
+		return nil, err
+	}
+	return zerolog.SyslogLevelWriter(w), nil
+}
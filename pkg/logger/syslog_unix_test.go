@@ -0,0 +1,48 @@
+//go:build unix
+
+package logger
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// fakeSyslog records the severity of each message it is sent.
+type fakeSyslog struct {
+	severities []string
+}
+
+func (f *fakeSyslog) Write(p []byte) (int, error) { return f.record("write", p) }
+func (f *fakeSyslog) Debug(m string) error        { return f.log("debug") }
+func (f *fakeSyslog) Info(m string) error         { return f.log("info") }
+func (f *fakeSyslog) Warning(m string) error      { return f.log("warning") }
+func (f *fakeSyslog) Err(m string) error          { return f.log("err") }
+func (f *fakeSyslog) Emerg(m string) error        { return f.log("emerg") }
+func (f *fakeSyslog) Crit(m string) error         { return f.log("crit") }
+
+func (f *fakeSyslog) record(sev string, p []byte) (int, error) {
+	f.severities = append(f.severities, sev)
+	return len(p), nil
+}
+
+func (f *fakeSyslog) log(sev string) error {
+	_, err := f.record(sev, nil)
+	return err
+}
+
+func TestSyslogSeverities(t *testing.T) {
+	fake := &fakeSyslog{}
+	log := newLogger(zerolog.SyslogLevelWriter(fake), "debug", "json")
+
+	log.Debug().Msg("d")
+	log.Info().Msg("i")
+	log.Warn().Msg("w")
+	log.Error().Msg("e")
+
+	want := []string{"debug", "info", "warning", "err"}
+	if !reflect.DeepEqual(fake.severities, want) {
+		t.Errorf("severities: got %v, want %v", fake.severities, want)
+	}
+}